@@ -0,0 +1,84 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+// TestAliasesRoundTrip checks a request using aliases gets its own names
+// back from Info and Applied while the SQL uses the real columns.
+func TestAliasesRoundTrip(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		ColumnAlias:    map[string]string{"full_name": "name", "state": "status", "years": "age", "mail": "email"},
+		AllowedFilters: map[string][]string{"state": {"="}, "years": {">="}},
+		AllowedSearch:  []string{"full_name"},
+		AllowedOrderBy: []string{"years"},
+		AllowedFields:  []string{"full_name", "mail"},
+	}
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("state", "active"),
+		queryhelper.WithFilterGroups([]queryhelper.FilterGroup{{
+			Logic: queryhelper.LogicOr,
+			Filters: []queryhelper.FilterCondition{
+				{Field: "years", Operator: ">=", Value: 18},
+				{Field: "state", Operator: "=", Value: "pending"},
+			},
+		}}),
+		queryhelper.WithSearchText("ann"),
+		queryhelper.WithSearchFields([]string{"full_name"}),
+		queryhelper.WithOrderBy([]string{"-years"}),
+		queryhelper.WithFields([]string{"full_name", "mail"}),
+		queryhelper.WithFilter("status", "=", "x"),
+	)
+
+	query, err := dq.Apply(settings, queryhelpertest.DryRunDB(t, "sqlite").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+	want := `SELECT "name","email" FROM "users" WHERE "status" = 'active' AND ("age" >= 18 OR "status" = 'pending') AND "name" LIKE '%ann%' ESCAPE '\' ORDER BY "age" DESC LIMIT 10`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	info := dq.Info().Conditions
+	if len(info.Filters) != 1 || info.Filters[0].Field != "state" {
+		t.Errorf("filters %+v, want state", info.Filters)
+	}
+	if group := info.FilterGroups[0].Filters; group[0].Field != "years" || group[1].Field != "state" {
+		t.Errorf("group filters %+v, want years and state", group)
+	}
+	if !reflect.DeepEqual(info.SearchFields, []string{"full_name"}) || !reflect.DeepEqual(info.OrderBy, []string{"-years"}) || !reflect.DeepEqual(info.Fields, []string{"full_name", "mail"}) {
+		t.Errorf("search %v, order %v, fields %v, want the aliases", info.SearchFields, info.OrderBy, info.Fields)
+	}
+
+	applied := dq.Applied()
+	if len(applied.Filters) != 1 || applied.Filters[0].Field != "state" {
+		t.Errorf("applied filters %+v, want state", applied.Filters)
+	}
+	if !reflect.DeepEqual(applied.OrderBy, []queryhelper.AppliedOrder{{Field: "years", Direction: "desc"}}) {
+		t.Errorf("applied order %+v, want years desc", applied.OrderBy)
+	}
+	if !reflect.DeepEqual(applied.SearchFields, []string{"full_name"}) {
+		t.Errorf("applied search fields %v, want full_name", applied.SearchFields)
+	}
+
+	// The real column sent as a field is not allowed, and reported as sent
+	if len(applied.Dropped) != 1 || applied.Dropped[0].Field != "status" {
+		t.Errorf("dropped %+v, want status", applied.Dropped)
+	}
+
+	// Only the real columns reach the SQL
+	for _, name := range []string{`"state"`, `"years"`, `"full_name"`, `"mail"`} {
+		if strings.Contains(got, name) {
+			t.Errorf("SQL uses the alias %s:\n%s", name, got)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package queryhelper
+
+import (
+	"sort"
+)
+
+// Kinds of DroppedItem
+const (
+	DroppedSearchField = "search_field"
+	DroppedOrderBy     = "order_by"
+	DroppedField       = "field"
+	DroppedFilter      = "filter"
+	DroppedSortFactor  = "sort_factor"
+	DroppedPageSize    = "page_size"
+	DroppedInclude     = "include"
+	DroppedSummary     = "summary"
+	DroppedHistogram   = "histogram"
+	DroppedCountMode   = "count_mode"
+)
+
+// DroppedItem is part of a request that was removed or clamped during
+// validation. Field names are the ones the client sent.
+type DroppedItem struct {
+	Kind     string      `json:"kind"`
+	Code     string      `json:"code"` // stable reason code, one of the Warning constants
+	Field    string      `json:"field,omitempty"`
+	Operator string      `json:"operator,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+	Reason   string      `json:"reason"`
+}
+
+// AppliedOrder is one effective ordering column.
+type AppliedOrder struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"` // asc, desc
+}
+
+// AppliedConditions is the server's authoritative view of a request after
+// aliasing, dropping and clamping, in client-facing field names. Clients can
+// render it directly, for example as "active filter" chips.
+type AppliedConditions struct {
+	SearchText     string                       `json:"search_text,omitempty"`
+	SearchFields   []string                     `json:"search_fields"`
+	OrderBy        []AppliedOrder               `json:"order_by"`
+	Filters        []FilterCondition            `json:"filters"`
+	FilterGroups   []FilterGroup                `json:"filter_groups,omitempty"`
+	Fields         []string                     `json:"fields,omitempty"`
+	Includes       []string                     `json:"includes,omitempty"`
+	IncludeFilters map[string][]FilterCondition `json:"include_filters,omitempty"`
+	Summaries      []string                     `json:"summaries,omitempty"`
+	Histogram      *DateHistogram               `json:"histogram,omitempty"`
+	Page           int                          `json:"page"`
+	PageSize       int                          `json:"page_size"`
+	Dropped        []DroppedItem                `json:"dropped,omitempty"`
+}
+
+// Applied returns the conditions as they were applied, or nil before Apply
+// has succeeded.
+func (dq *QueryHelper) Applied() *AppliedConditions {
+
+	if dq.conditions == nil || dq.conditions.Conditions == nil {
+		return nil
+	}
+
+	ch := dq.conditions
+	conditions := ch.Conditions
+	public := ch.publicNames()
+
+	applied := &AppliedConditions{
+		SearchText:   conditions.SearchText,
+		SearchFields: publicColumns(public, conditions.SearchFields),
+		OrderBy:      make([]AppliedOrder, 0, len(conditions.OrderBy)),
+		Filters:      publicFilters(public, conditions.Filters),
+		FilterGroups: publicGroups(public, conditions.FilterGroups),
+		Fields:       publicColumns(public, conditions.Fields),
+		Page:         dq.pagination.Page(),
+		PageSize:     dq.pagination.PageSize(),
+	}
+
+	for _, entry := range conditions.OrderBy {
+		prefix, field := splitOrderBy(entry)
+
+		desc := conditions.SortFactor < 0
+		if prefix != "" {
+			desc = prefix == "-"
+		}
+
+		direction := "asc"
+		if desc {
+			direction = "desc"
+		}
+
+		applied.OrderBy = append(applied.OrderBy, AppliedOrder{
+			Field:     publicColumns(public, []string{field})[0],
+			Direction: direction,
+		})
+	}
+
+	applied.Includes = append(applied.Includes, conditions.Includes...)
+	for name, filters := range conditions.IncludeFilters {
+		if applied.IncludeFilters == nil {
+			applied.IncludeFilters = make(map[string][]FilterCondition)
+		}
+
+		// An include taken out of the settings since validation keeps its
+		// filters' names
+		var names map[string]string
+		if include, ok := ch.Settings.AllowedIncludes[name]; ok && include != nil {
+			names = publicNames(NewConditionsHandle(include.Settings).Settings.lookups().columns)
+		}
+		applied.IncludeFilters[name] = publicFilters(names, filters)
+	}
+
+	applied.Summaries = append(applied.Summaries, conditions.RequestedSummaries...)
+
+	if conditions.Histogram != nil {
+		h := *conditions.Histogram
+		h.Field = publicColumns(public, []string{h.Field})[0]
+		applied.Histogram = &h
+	}
+
+	applied.Dropped = append(applied.Dropped, ch.Dropped...)
+	applied.Dropped = append(applied.Dropped, dq.pagination.droppedItems()...)
+
+	return applied
+}
+
+// rename records the alias a request named a real column by. The first
+// alias wins when a request names one column several ways.
+func (ch *ConditionsHandle) rename(alias string, real string) {
+
+	if ch.names == nil {
+		ch.names = make(map[string]string)
+	}
+
+	if _, ok := ch.names[real]; !ok {
+		ch.names[real] = alias
+	}
+}
+
+// publicNames maps real columns to the names the request used, or for
+// columns it did not name, to their alias in the settings.
+func (ch *ConditionsHandle) publicNames() map[string]string {
+
+	public := publicNames(ch.Settings.lookups().columns)
+	for field := range ch.Settings.LocalizedColumns {
+		for _, column := range localizedVariants(ch.Settings, field) {
+			public[column] = field
+		}
+	}
+	for real, alias := range ch.names {
+		public[real] = alias
+	}
+
+	return public
+}
+
+// publicNames reverses a column alias map. When several aliases share a
+// column the alphabetically first one wins.
+func publicNames(alias map[string]string) map[string]string {
+
+	names := make([]string, 0, len(alias))
+	for name := range alias {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	public := make(map[string]string, len(alias))
+	for _, name := range names {
+		if _, ok := public[alias[name]]; !ok {
+			public[alias[name]] = name
+		}
+	}
+
+	return public
+}
+
+func publicColumns(public map[string]string, columns []string) []string {
+
+	if columns == nil {
+		return nil
+	}
+
+	return getRealColumns(public, columns)
+}
+
+func publicOrderBy(public map[string]string, entries []string) []string {
+
+	if entries == nil {
+		return nil
+	}
+
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		prefix, field := splitOrderBy(entry)
+		if name, ok := public[field]; ok {
+			field = name
+		}
+		out[i] = prefix + field
+	}
+
+	return out
+}
+
+func publicFilters(public map[string]string, filters []FilterCondition) []FilterCondition {
+
+	vals := make([]FilterCondition, len(filters))
+	for i, filter := range filters {
+		if name, ok := public[filter.Field]; ok {
+			filter.Field = name
+		}
+		vals[i] = filter
+	}
+
+	return vals
+}
+
+func publicGroups(public map[string]string, groups []FilterGroup) []FilterGroup {
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	vals := make([]FilterGroup, len(groups))
+	for i, group := range groups {
+		group.Filters = publicFilters(public, group.Filters)
+		group.Groups = publicGroups(public, group.Groups)
+		vals[i] = group
+	}
+
+	return vals
+}
+
+func (ch *ConditionsHandle) drop(item DroppedItem) {
+	ch.Dropped = append(ch.Dropped, item)
+}
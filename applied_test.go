@@ -0,0 +1,128 @@
+package queryhelper_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func appliedSettings() *queryhelper.QuerySettings {
+
+	return &queryhelper.QuerySettings{
+		AllowedSearch:  []string{"name"},
+		AllowedOrderBy: []string{"name", "company"},
+		AllowedFields:  []string{"id", "name", "company"},
+		AllowedFilters: map[string][]string{
+			"name":    {"=", "LIKE"},
+			"company": {"IN"},
+		},
+		ColumnAlias: map[string]string{"company": "company_id"},
+		AllowedIncludes: map[string]*queryhelper.IncludeSettings{
+			"orders": {
+				Relation: "Orders",
+				Settings: &queryhelper.QuerySettings{
+					AllowedFilters: map[string][]string{"total": {">="}},
+					ColumnAlias:    map[string]string{"total": "amount"},
+				},
+			},
+		},
+	}
+}
+
+// TestAppliedGolden checks the applied conditions of requests with items
+// rejected or clamped, written as JSON.
+func TestAppliedGolden(t *testing.T) {
+
+	tests := []struct {
+		name string
+		opts []queryhelper.Option
+		want string
+	}{
+		{
+			name: "nothing dropped",
+			opts: []queryhelper.Option{
+				queryhelper.WithFilter("company", "IN", []interface{}{1, 2}),
+				queryhelper.WithOrderBy([]string{"-company"}),
+			},
+			want: `{"search_fields":["name"],"order_by":[{"field":"company","direction":"desc"}],` +
+				`"filters":[{"field":"company","operator":"IN","value":[1,2]}],"page":1,"page_size":10}`,
+		},
+		{
+			name: "several dropped and clamped",
+			opts: []queryhelper.Option{
+				queryhelper.WithSearchText("ann"),
+				queryhelper.WithSearchFields([]string{"name", "password"}),
+				queryhelper.WithOrderBy([]string{"-company", "password", "name"}),
+				queryhelper.WithSortFactor(-5),
+				queryhelper.WithFilters([]queryhelper.FilterCondition{
+					{Field: "company", Operator: "IN", Value: []interface{}{1, 2}},
+					{Field: "name", Operator: ">", Value: "m"},
+					{Field: "password", Operator: "=", Value: "hunter2"},
+				}),
+				queryhelper.WithFields([]string{"name", "ssn"}),
+				queryhelper.WithIncludes([]string{"orders", "invoices"}),
+				queryhelper.WithIncludeFilters(map[string][]queryhelper.FilterCondition{
+					"orders":   {{Field: "total", Operator: ">=", Value: 10}, {Field: "note", Operator: "=", Value: "x"}},
+					"invoices": {{Field: "paid", Operator: "=", Value: true}},
+				}),
+				queryhelper.WithPage(2),
+				queryhelper.WithPageSize(500),
+			},
+			want: `{"search_text":"ann","search_fields":["name"],` +
+				`"order_by":[{"field":"company","direction":"desc"},{"field":"name","direction":"desc"}],` +
+				`"filters":[{"field":"company","operator":"IN","value":[1,2]}],"fields":["name"],"includes":["orders"],` +
+				`"include_filters":{"orders":[{"field":"total","operator":"\u003e=","value":10}]},"page":2,"page_size":100,` +
+				`"dropped":[` +
+				`{"kind":"search_field","code":"search_field_not_allowed","field":"password","reason":"field is not searchable"},` +
+				`{"kind":"order_by","code":"order_by_not_allowed","field":"password","reason":"field is not sortable"},` +
+				`{"kind":"field","code":"field_not_allowed","field":"ssn","reason":"field is not selectable"},` +
+				`{"kind":"sort_factor","code":"sort_factor_clamped","value":-5,"reason":"clamped to -1"},` +
+				`{"kind":"filter","code":"operator_not_allowed","field":"name","operator":"\u003e","value":"m","reason":"operator is not allowed"},` +
+				`{"kind":"filter","code":"filter_not_allowed","field":"password","operator":"=","value":"hunter2","reason":"field is not filterable"},` +
+				`{"kind":"include","code":"include_not_allowed","field":"invoices","reason":"relation is not includable"},` +
+				`{"kind":"filter","code":"include_not_requested","field":"invoices.paid","operator":"=","value":true,"reason":"relation is not included"},` +
+				`{"kind":"filter","code":"filter_not_allowed","field":"orders.note","operator":"=","value":"x","reason":"field is not filterable"},` +
+				`{"kind":"page_size","code":"page_size_clamped","value":500,"reason":"clamped to maximum page size"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(tt.opts...)
+			if _, err := dq.Apply(appliedSettings(), queryhelpertest.DryRunDB(t, "sqlite").Model(&testCustomer{})); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.Marshal(dq.Applied())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppliedIncludeRemovedFromSettings(t *testing.T) {
+
+	settings := appliedSettings()
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithIncludes([]string{"orders"}),
+		queryhelper.WithIncludeFilters(map[string][]queryhelper.FilterCondition{"orders": {{Field: "total", Operator: ">=", Value: 10}}}),
+	)
+	if _, err := dq.Apply(settings, queryhelpertest.DryRunDB(t, "sqlite").Model(&testCustomer{})); err != nil {
+		t.Fatal(err)
+	}
+
+	// The filters are listed by their column names
+	delete(settings.AllowedIncludes, "orders")
+	applied := dq.Applied()
+	if got := applied.IncludeFilters["orders"]; len(got) != 1 || got[0].Field != "amount" {
+		t.Errorf("include filters %+v", got)
+	}
+}
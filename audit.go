@@ -0,0 +1,222 @@
+package queryhelper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RedactedValue replaces filter values in audit entries when
+// QuerySettings.AuditRedactValues is set.
+const RedactedValue = "[redacted]"
+
+// AuditEntry records one Apply or Execute: what was queried, by which
+// conditions, and how it went. Field names are the ones clients use. The
+// search text itself is never recorded.
+type AuditEntry struct {
+	Time           time.Time                    `json:"time"`
+	Table          string                       `json:"table,omitempty"`
+	Filters        []FilterCondition            `json:"filters,omitempty"`
+	FilterGroups   []FilterGroup                `json:"filter_groups,omitempty"`
+	Includes       []string                     `json:"includes,omitempty"`
+	IncludeFilters map[string][]FilterCondition `json:"include_filters,omitempty"`
+	Search         bool                         `json:"search"`
+	SearchFields   []string                     `json:"search_fields,omitempty"`
+	OrderBy        []AppliedOrder               `json:"order_by,omitempty"`
+	Fields         []string                     `json:"fields,omitempty"`
+	Page           int                          `json:"page"`
+	PageSize       int                          `json:"page_size"`
+	Total          int64                        `json:"total"` // rows matching the conditions
+	Rows           int                          `json:"rows"`  // rows returned, none by Apply
+	Duration       time.Duration                `json:"duration"`
+	Retries        int                          `json:"retries,omitempty"` // statements retried after transient failures
+	Error          string                       `json:"error,omitempty"`
+}
+
+// AuditSink receives an entry for every Apply or Execute, successful or not.
+// Record is called synchronously, so slow sinks should buffer.
+type AuditSink interface {
+	Record(ctx context.Context, entry AuditEntry)
+}
+
+// WithAuditSink records every Apply and Execute of the helper to sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(dq *QueryHelper) {
+		dq.auditSink = sink
+	}
+}
+
+// JSONLinesAuditSink writes each entry as one line of JSON.
+type JSONLinesAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (s *JSONLinesAuditSink) Record(ctx context.Context, entry AuditEntry) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Encode terminates every value with a newline
+	if err := s.enc.Encode(entry); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns the first error writing an entry failed with.
+func (s *JSONLinesAuditSink) Err() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// audit sends the entry for an Apply or Execute that started at start.
+// dest holds the rows an Execute returned, nil for Apply.
+func (dq *QueryHelper) audit(settings *QuerySettings, query *gorm.DB, start time.Time, err error, dest interface{}) {
+
+	ctx := context.Background()
+	entry := AuditEntry{
+		Time:     start,
+		Duration: time.Since(start),
+		Retries:  dq.Retries(),
+	}
+
+	if query != nil {
+		if query.Statement.Context != nil {
+			ctx = query.Statement.Context
+		}
+
+		entry.Table = tableName(query)
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	// Conditions as applied, or as requested when validation failed
+	if applied := dq.Applied(); applied != nil && err == nil {
+		entry.Filters = applied.Filters
+		entry.FilterGroups = applied.FilterGroups
+		entry.Includes = applied.Includes
+		entry.IncludeFilters = applied.IncludeFilters
+		entry.Search = applied.SearchText != ""
+		entry.SearchFields = applied.SearchFields
+		entry.OrderBy = applied.OrderBy
+		entry.Fields = applied.Fields
+	} else {
+		requested := &dq.requested
+		entry.Filters = requested.Filters
+		entry.FilterGroups = requested.FilterGroups
+		entry.Includes = requested.Includes
+		entry.IncludeFilters = requested.IncludeFilters
+		entry.Search = requested.SearchText != ""
+		entry.SearchFields = requested.SearchFields
+		entry.Fields = requested.Fields
+	}
+
+	info := dq.pagination.CurrentInfo()
+	entry.Page = info.Page
+	entry.PageSize = info.PageSize
+	entry.Total = info.Total
+
+	if err == nil {
+		entry.Rows = rowCount(dest)
+	}
+
+	if settings != nil && settings.AuditRedactValues {
+		entry.Filters = redactFilters(entry.Filters)
+		entry.FilterGroups = redactGroups(entry.FilterGroups)
+		entry.IncludeFilters = redactIncludeFilters(entry.IncludeFilters)
+	}
+
+	dq.auditSink.Record(ctx, entry)
+}
+
+func redactFilters(filters []FilterCondition) []FilterCondition {
+
+	if filters == nil {
+		return nil
+	}
+
+	redacted := make([]FilterCondition, len(filters))
+	for i, filter := range filters {
+		// Operators without a value have nothing to hide
+		if filter.Value != nil {
+			filter.Value = RedactedValue
+		}
+		redacted[i] = filter
+	}
+
+	return redacted
+}
+
+func redactIncludeFilters(filters map[string][]FilterCondition) map[string][]FilterCondition {
+
+	if filters == nil {
+		return nil
+	}
+
+	redacted := make(map[string][]FilterCondition, len(filters))
+	for name, f := range filters {
+		redacted[name] = redactFilters(f)
+	}
+
+	return redacted
+}
+
+func redactGroups(groups []FilterGroup) []FilterGroup {
+
+	if groups == nil {
+		return nil
+	}
+
+	redacted := make([]FilterGroup, len(groups))
+	for i, group := range groups {
+		group.Filters = redactFilters(group.Filters)
+		group.Groups = redactGroups(group.Groups)
+		redacted[i] = group
+	}
+
+	return redacted
+}
+
+// rowCount counts the rows in dest: the elements of a slice, or one for a
+// single row that was found.
+func rowCount(dest interface{}) int {
+
+	if dest == nil {
+		return 0
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Slice, reflect.Array:
+		return rv.Len()
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return 0
+		}
+	default:
+		if rv.IsZero() {
+			return 0
+		}
+	}
+
+	return 1
+}
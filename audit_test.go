@@ -0,0 +1,188 @@
+package queryhelper_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+type auditRecorder struct {
+	entries []queryhelper.AuditEntry
+}
+
+func (r *auditRecorder) Record(ctx context.Context, entry queryhelper.AuditEntry) {
+	r.entries = append(r.entries, entry)
+}
+
+func auditSettings(redact bool) *queryhelper.QuerySettings {
+
+	return &queryhelper.QuerySettings{
+		AllowedSearch:  []string{"name"},
+		AllowedOrderBy: []string{"name"},
+		AllowedFilters: map[string][]string{"company_id": {"IN", "IS NOT NULL"}},
+		AllowedIncludes: map[string]*queryhelper.IncludeSettings{
+			"orders": {
+				Relation: "Orders",
+				Settings: &queryhelper.QuerySettings{AllowedFilters: map[string][]string{"amount": {">="}}},
+			},
+		},
+		AuditRedactValues: redact,
+	}
+}
+
+func auditOptions(sink queryhelper.AuditSink) []queryhelper.Option {
+
+	return []queryhelper.Option{
+		queryhelper.WithAuditSink(sink),
+		queryhelper.WithSearchText("customer"),
+		queryhelper.WithFilters([]queryhelper.FilterCondition{
+			{Field: "company_id", Operator: "IN", Value: []interface{}{1, 2}},
+			{Field: "company_id", Operator: "IS NOT NULL"},
+		}),
+		queryhelper.WithIncludes([]string{"orders"}),
+		queryhelper.WithIncludeFilters(map[string][]queryhelper.FilterCondition{"orders": {{Field: "amount", Operator: ">=", Value: 50}}}),
+		queryhelper.WithOrderBy([]string{"name"}),
+		queryhelper.WithPageSize(5),
+	}
+}
+
+func TestAuditExecute(t *testing.T) {
+
+	db := customersDB(t)
+	sink := &auditRecorder{}
+
+	dq := queryhelper.NewQueryHelper(auditOptions(sink)...)
+	var customers []testCustomer
+	if err := dq.Execute(auditSettings(false), db.Model(&testCustomer{}), &customers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(sink.entries))
+	}
+	entry := sink.entries[0]
+
+	if entry.Table != "customers" || entry.Error != "" {
+		t.Errorf("table %q, error %q", entry.Table, entry.Error)
+	}
+	// Customers of the first two companies, one page of them returned
+	if entry.Total != 8 || entry.Rows != 5 || entry.Page != 1 || entry.PageSize != 5 {
+		t.Errorf("total %d, rows %d, page %d of %d", entry.Total, entry.Rows, entry.Page, entry.PageSize)
+	}
+	if !entry.Search || len(entry.Filters) != 2 || len(entry.OrderBy) != 1 {
+		t.Errorf("search %v, filters %+v, order %+v", entry.Search, entry.Filters, entry.OrderBy)
+	}
+	if got := entry.IncludeFilters["orders"]; len(got) != 1 || got[0].Field != "amount" || got[0].Value != 50 {
+		t.Errorf("include filters %+v", entry.IncludeFilters)
+	}
+}
+
+func TestAuditFailures(t *testing.T) {
+
+	db := customersDB(t)
+
+	rejecting := auditSettings(false)
+	rejecting.ValueValidators = map[string]map[string]func(v interface{}) error{
+		"company_id": {"IN": func(v interface{}) error { return errors.New("not yours") }},
+	}
+
+	tests := []struct {
+		name     string
+		settings *queryhelper.QuerySettings
+		err      error
+	}{
+		{
+			name:     "rejected conditions",
+			settings: rejecting,
+			err:      queryhelper.ErrInvalidConditions,
+		},
+		{
+			name:     "database error",
+			settings: auditSettings(false),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			sink := &auditRecorder{}
+			dq := queryhelper.NewQueryHelper(auditOptions(sink)...)
+
+			// The table is missing, so the query itself fails
+			query := db.Table("missing")
+			if tt.err != nil {
+				query = db.Model(&testCustomer{})
+			}
+
+			var rows []map[string]interface{}
+			err := dq.Execute(tt.settings, query, &rows)
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+
+			if len(sink.entries) != 1 {
+				t.Fatalf("%d entries, want 1", len(sink.entries))
+			}
+			entry := sink.entries[0]
+			if entry.Error != err.Error() || entry.Rows != 0 {
+				t.Errorf("error %q, rows %d", entry.Error, entry.Rows)
+			}
+			// Conditions are recorded even when the query failed
+			if len(entry.Filters) != 2 || !entry.Search {
+				t.Errorf("filters %+v, search %v", entry.Filters, entry.Search)
+			}
+		})
+	}
+}
+
+func TestAuditRedaction(t *testing.T) {
+
+	db := customersDB(t)
+
+	var buf bytes.Buffer
+	sink := queryhelper.NewJSONLinesAuditSink(&buf)
+
+	for _, redact := range []bool{true, false} {
+		dq := queryhelper.NewQueryHelper(auditOptions(sink)...)
+		if _, err := dq.Apply(auditSettings(redact), db.Model(&testCustomer{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2:\n%s", len(lines), buf.String())
+	}
+
+	for i, line := range lines {
+		var entry queryhelper.AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+
+		// The search text is never written
+		if strings.Contains(line, `"customer"`) {
+			t.Errorf("line %d holds the search text: %s", i, line)
+		}
+
+		redacted := i == 0
+		values := []interface{}{entry.Filters[0].Value, entry.IncludeFilters["orders"][0].Value}
+		for _, v := range values {
+			if (v == queryhelper.RedactedValue) != redacted {
+				t.Errorf("line %d records %v, redacted %v", i, v, redacted)
+			}
+		}
+		// Operators without a value record none either way
+		if entry.Filters[1].Value != nil {
+			t.Errorf("line %d records %v for IS NOT NULL", i, entry.Filters[1].Value)
+		}
+	}
+}
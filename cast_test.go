@@ -0,0 +1,141 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testLedger struct {
+	ID     uint
+	Amount string
+}
+
+func (testLedger) TableName() string {
+	return "ledgers"
+}
+
+func castSettings(typ string) *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"amount": {">", "BETWEEN", "IN", "LIKE", "IS NULL"}},
+		AllowedOrderBy: []string{"amount"},
+		FieldTypes:     map[string]string{"amount": "float"},
+		ColumnCasts:    map[string]string{"amount": typ},
+	}
+}
+
+func TestColumnCasts(t *testing.T) {
+
+	queryhelper.RegisterDialect("pgmoney", &queryhelper.Dialect{
+		CastTypes: map[string]string{queryhelper.CastDecimal: "NUMERIC(12,2)"},
+	})
+
+	tests := []struct {
+		name    string
+		dialect string
+		typ     string
+		opts    []queryhelper.Option
+		want    string
+	}{
+		{
+			name:    "postgres comparison",
+			dialect: "postgres",
+			typ:     queryhelper.CastDecimal,
+			opts:    []queryhelper.Option{queryhelper.WithFilter("amount", ">", "9")},
+			want:    `SELECT * FROM "ledgers" WHERE CAST("amount" AS NUMERIC) > 9 ORDER BY CAST("amount" AS NUMERIC) LIMIT 10`,
+		},
+		{
+			name:    "mysql between",
+			dialect: "mysql",
+			typ:     queryhelper.CastDecimal,
+			opts:    []queryhelper.Option{queryhelper.WithFilter("amount", "BETWEEN", []interface{}{"10", "20"})},
+			want:    "SELECT * FROM `ledgers` WHERE CAST(`amount` AS DECIMAL(65,30)) BETWEEN 10 AND 20 ORDER BY CAST(`amount` AS DECIMAL(65,30)) LIMIT 10",
+		},
+		{
+			name:    "mysql integer in, descending",
+			dialect: "mysql",
+			typ:     queryhelper.CastInteger,
+			opts:    []queryhelper.Option{queryhelper.WithFilter("amount", "IN", []interface{}{1, 2}), queryhelper.WithOrderBy([]string{"-amount"})},
+			want:    "SELECT * FROM `ledgers` WHERE CAST(`amount` AS SIGNED) IN (1,2) ORDER BY CAST(`amount` AS SIGNED) DESC LIMIT 10",
+		},
+		{
+			name:    "uncast operators",
+			dialect: "postgres",
+			typ:     queryhelper.CastFloat,
+			opts:    []queryhelper.Option{queryhelper.WithFilter("amount", "LIKE", "1%"), queryhelper.WithFilter("amount", "IS NULL", nil)},
+			want:    `SELECT * FROM "ledgers" WHERE "amount" LIKE '1%' AND "amount" IS NULL ORDER BY CAST("amount" AS DOUBLE PRECISION) LIMIT 10`,
+		},
+		{
+			name:    "verbatim type",
+			dialect: "postgres",
+			typ:     "MONEY",
+			opts:    []queryhelper.Option{queryhelper.WithFilter("amount", ">", "9")},
+			want:    `SELECT * FROM "ledgers" WHERE CAST("amount" AS MONEY) > 9 ORDER BY CAST("amount" AS MONEY) LIMIT 10`,
+		},
+		{
+			name:    "dialect spelling",
+			dialect: "pgmoney",
+			typ:     queryhelper.CastDecimal,
+			opts:    []queryhelper.Option{queryhelper.WithFilter("amount", ">", "9")},
+			want:    `SELECT * FROM "ledgers" WHERE CAST("amount" AS NUMERIC(12,2)) > 9 ORDER BY CAST("amount" AS NUMERIC(12,2)) LIMIT 10`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			query, err := queryhelper.NewQueryHelper(tt.opts...).Apply(castSettings(tt.typ), queryhelpertest.DryRunDB(t, tt.dialect).Model(&testLedger{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := findSQL(t, query); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnCastsRows(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testLedger{})
+	queryhelpertest.Seed(t, db, &[]testLedger{{ID: 1, Amount: "80"}, {ID: 2, Amount: "9"}, {ID: 3, Amount: "100"}, {ID: 4, Amount: "12"}})
+
+	tests := []struct {
+		name string
+		typ  string
+		want []string
+	}{
+		// As text no amount sorts after '9'
+		{name: "as text"},
+		// As text '100' would also sort before '12'
+		{name: "as integers", typ: queryhelper.CastInteger, want: []string{"12", "80", "100"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := castSettings(tt.typ)
+			if tt.typ == "" {
+				settings.ColumnCasts = nil
+				settings.FieldTypes = nil
+			}
+
+			var ledgers []testLedger
+			err := queryhelper.NewQueryHelper(queryhelper.WithFilter("amount", ">", "9")).Execute(settings, db.Model(&testLedger{}), &ledgers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, l := range ledgers {
+				got = append(got, l.Amount)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
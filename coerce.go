@@ -0,0 +1,215 @@
+package queryhelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	FieldTypeString = "string"
+	FieldTypeInt    = "int"
+	FieldTypeFloat  = "float"
+	FieldTypeBool   = "bool"
+	FieldTypeTime   = "time"
+	FieldTypeUUID   = "uuid"
+)
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// coerceFilterValue converts a filter value to the declared field type.
+// List operators get every element converted and always yield []interface{}.
+func coerceFilterValue(fieldType string, operator string, value interface{}) (interface{}, error) {
+
+	if fieldType == "" || value == nil {
+		return value, nil
+	}
+
+	switch operator {
+	case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+		// These operators take no value
+		return nil, nil
+	case "LIKE":
+		// Patterns are always matched as text
+		return value, nil
+	case "BETWEEN", "IN", "NOT IN", "REL_ANY", "REL_ALL":
+		items, ok := toInterfaceSlice(value)
+		if !ok {
+			return nil, fmt.Errorf("operator %s requires a list value", operator)
+		}
+
+		vals := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := coerceScalar(fieldType, item)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+
+		return vals, nil
+	}
+
+	return coerceScalar(fieldType, value)
+}
+
+func toInterfaceSlice(value interface{}) ([]interface{}, bool) {
+
+	if vals, ok := value.([]interface{}); ok {
+		return vals, true
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	// []byte is a scalar value, not a list
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	vals := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		vals[i] = rv.Index(i).Interface()
+	}
+
+	return vals, true
+}
+
+func coerceScalar(fieldType string, value interface{}) (interface{}, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case FieldTypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case fmt.Stringer:
+			return v.String(), nil
+		}
+
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return fmt.Sprint(value), nil
+		}
+	case FieldTypeInt:
+		return toInt64(value)
+	case FieldTypeFloat:
+		return toFloat64(value)
+	case FieldTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err == nil {
+				return b, nil
+			}
+		}
+	case FieldTypeTime:
+		return toTime(value)
+	case FieldTypeUUID:
+		if s, ok := value.(string); ok {
+			if id, ok := parseUUID(s); ok {
+				return id, nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown field type %q", fieldType)
+	}
+
+	return nil, fmt.Errorf("cannot convert %v to %s", value, fieldType)
+}
+
+func toInt64(value interface{}) (int64, error) {
+
+	switch v := value.(type) {
+	case json.Number:
+		return v.Int64()
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, nil
+		}
+		return 0, fmt.Errorf("cannot convert %q to int", v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			break
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		// JSON numbers decode as float64
+		f := rv.Float()
+		if f == math.Trunc(f) && f >= math.MinInt64 && f <= math.MaxInt64 {
+			return int64(f), nil
+		}
+	}
+
+	return 0, fmt.Errorf("cannot convert %v to int", value)
+}
+
+func toFloat64(value interface{}) (float64, error) {
+
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+		return 0, fmt.Errorf("cannot convert %q to float", v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+
+	return 0, fmt.Errorf("cannot convert %v to float", value)
+}
+
+func toTime(value interface{}) (time.Time, error) {
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot convert %v to time", value)
+}
@@ -3,6 +3,8 @@ package queryhelper
 import (
 	"errors"
 	"strings"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -10,29 +12,111 @@ import (
 
 type FilterCondition struct {
 	Field    string      `json:"field"`
-	Operator string      `json:"operator"` // =, !=, >, <, >=, <=, BETWEEN, IN, NOT IN, LIKE
+	Operator string      `json:"operator"` // =, !=, >, <, >=, <=, BETWEEN, IN, NOT IN, LIKE, IS NULL, IS NOT NULL, EMPTY, NOT EMPTY, NULL_SAFE_EQ
 	Value    interface{} `json:"value"`
 }
 
+const (
+	LogicAnd = "AND"
+	LogicOr  = "OR"
+)
+
+// Search modes, how the search text matches a field
+const (
+	SearchModeContains = "contains" // LIKE '%text%'
+	SearchModePrefix   = "prefix"   // LIKE 'text%'
+	SearchModeExact    = "exact"    // = 'text'
+)
+
+// FilterGroup combines filters and nested groups with AND or OR.
+type FilterGroup struct {
+	Logic   string            `json:"logic"` // AND, OR
+	Not     bool              `json:"not,omitempty"`
+	Filters []FilterCondition `json:"filters,omitempty"`
+	Groups  []FilterGroup     `json:"groups,omitempty"`
+}
+
 type QueryConditions struct {
-	SearchText   string            `json:"search_text"`
-	SearchFields []string          `json:"search_fields"`
-	OrderBy      []string          `json:"order_by"`
-	SortFactor   int               `json:"sort_factor"` // 1, -1
-	Filters      []FilterCondition `json:"filters"`
+	SearchText         string                       `json:"search_text"`
+	SearchFields       []string                     `json:"search_fields"`
+	OrderBy            []string                     `json:"order_by"`
+	SortFactor         int                          `json:"sort_factor"` // 1, -1; overridden per column by a "-" or "+" prefix in OrderBy
+	Filters            []FilterCondition            `json:"filters"`
+	FilterGroups       []FilterGroup                `json:"filter_groups,omitempty"`   // ANDed with Filters
+	Fields             []string                     `json:"fields,omitempty"`          // sparse select, all columns when empty
+	Includes           []string                     `json:"includes,omitempty"`        // relations to preload
+	IncludeFilters     map[string][]FilterCondition `json:"include_filters,omitempty"` // include -> filters on its rows
+	RequestedSummaries []string                     `json:"summaries,omitempty"`       // names of QuerySettings.Summaries Execute computes over all matching rows
+	Histogram          *DateHistogram               `json:"histogram,omitempty"`       // rows per interval Execute counts over all matching rows
+	Locale             string                       `json:"locale,omitempty"`          // picks the columns of QuerySettings.LocalizedColumns fields
 }
 
 type ConditionsHandle struct {
 	Settings   *QuerySettings   `json:"settings"`
 	Conditions *QueryConditions `json:"conditions"`
+	Dropped    []DroppedItem    `json:"dropped"` // what UpdateConditions removed or adjusted
+
+	// real column -> the alias the request named it by
+	names map[string]string
+
+	// locale LocalizedColumns fields resolve for
+	locale string
+
+	// qualifies the table's own columns in a query with joins, see ownColumn
+	table string
 }
 
 type QuerySettings struct {
-	ColumnAlias       map[string]string            `json:"column_alias"`
-	AllowedOrderBy    []string                     `json:"allowed_order_by"`
-	AllowedSearch     []string                     `json:"allowed_search"`
-	AllowedFilters    map[string][]string          `json:"allowed_filters"` // field -> allowed operators
-	DefaultSortFactor int                          `json:"default_sort_factor"`
+	ColumnAlias             map[string]string                               `json:"column_alias"`
+	AllowedOrderBy          []string                                        `json:"allowed_order_by"`
+	AllowedSearch           []string                                        `json:"allowed_search"`
+	AllowedFilters          map[string][]string                             `json:"allowed_filters"` // field -> allowed operators
+	DefaultSortFactor       int                                             `json:"default_sort_factor"`
+	FieldTypes              map[string]string                               `json:"field_types"`    // field -> string, int, float, bool, time, uuid
+	ValueValidators         map[string]map[string]func(v interface{}) error `json:"-"`              // field -> operator ("*" for any) -> validator
+	AllowedFields           []string                                        `json:"allowed_fields"` // columns a request may select
+	CaseInsensitiveFields   []string                                        `json:"case_insensitive_fields"`
+	CitextFields            []string                                        `json:"citext_fields"`              // case-insensitive fields stored as citext on Postgres
+	MaxQueryCost            int                                             `json:"max_query_cost"`             // 0 disables the cost check
+	CostWeights             *CostWeights                                    `json:"cost_weights"`               // nil uses DefaultCostWeights
+	IndexedFields           []string                                        `json:"indexed_fields"`             // fields a selective filter can use an index on
+	PlanCache               *PlanCache                                      `json:"-"`                          // optional cache of normalized conditions
+	IndexHint               *IndexHint                                      `json:"index_hint"`                 // static optimizer hint for the main table
+	IndexHintFunc           func(c *QueryConditions) *IndexHint             `json:"-"`                          // picks a hint from the normalized conditions, overrides IndexHint
+	PaginationStrategy      string                                          `json:"pagination_strategy"`        // offset (default) or two_phase
+	PrimaryKey              string                                          `json:"primary_key"`                // primary key column, defaults to the gorm schema's
+	DeduplicateOnPrimaryKey bool                                            `json:"deduplicate_on_primary_key"` // return each parent row once when joins multiply rows
+	CountTable              string                                          `json:"count_table"`                // table to count on instead of the query's, e.g. a distributed table
+	SQLiteTimeFormat        string                                          `json:"sqlite_time_format"`         // rfc3339 or unix, how SQLite time columns are stored; empty binds time.Time as is
+	AllowedIncludes         map[string]*IncludeSettings                     `json:"allowed_includes"`           // include name -> relation and constraints
+	QueryTimeout            time.Duration                                   `json:"query_timeout"`              // limit for each statement Apply and Execute run, 0 for none
+	DefaultSearchFields     []string                                        `json:"default_search_fields"`      // searched when a request names no fields, defaults to AllowedSearch
+	ColumnCasts             map[string]string                               `json:"column_casts"`               // field -> CastInteger, CastDecimal, CastFloat, CastText or an SQL type to compare and sort as
+	SearchMode              string                                          `json:"search_mode"`                // contains (default), prefix or exact
+	SearchFieldModes        map[string]string                               `json:"search_field_modes"`         // field -> search mode, overrides SearchMode
+	SearchFieldLogic        string                                          `json:"search_field_logic"`         // OR (default): the search text matches any field, AND: every field
+	RetryPolicy             *RetryPolicy                                    `json:"-"`                          // retries transient failures of the statements Apply and Execute run
+	AuditRedactValues       bool                                            `json:"audit_redact_values"`        // replace filter values in audit entries
+	ResultCache             ResultCache                                     `json:"-"`                          // optional cache of the pages Execute finds
+	ResultCacheTTL          time.Duration                                   `json:"result_cache_ttl"`           // how long cached pages are served, 0 until evicted
+	HistogramFields         map[string][]string                             `json:"histogram_fields"`           // time field -> intervals a DateHistogram may use
+	Summaries               map[string]string                               `json:"summaries"`                  // summary name -> aggregate SQL, e.g. SUM(amount)
+	Relations               map[string]*Relation                            `json:"relations"`                  // relation path -> join, for "relation.column" fields
+	LocalizedColumns        map[string]map[string]string                    `json:"localized_columns"`          // field -> locale -> column, e.g. name -> de -> name_de
+	LocaleFallbacks         []string                                        `json:"locale_fallbacks"`           // locales tried in order when a localized field has no column for the request's
+	SubqueryColumns         []string                                        `json:"subquery_columns"`           // output columns of a query wrapped by WithSubqueryWrapping, checked when set
+	SearchIDFields          []string                                        `json:"search_id_fields"`           // identifier fields a search text shaped like an integer or UUID also matches exactly
+	EmbeddedPrefixes        map[string]string                               `json:"embedded_prefixes"`          // embedded struct path -> column prefix, for "path.column" fields
+	RequireSearchableFields bool                                            `json:"require_searchable_fields"`  // fail instead of warning when a search or ordering has no allowed field left
+	AllowedCountModes       []string                                        `json:"allowed_count_modes"`        // count modes requests may ask for, exact only when empty
+	DefaultCountMode        string                                          `json:"default_count_mode"`         // count mode of requests asking for none or a disallowed one, exact when empty
+	ConflictPolicy          string                                          `json:"conflict_policy"`            // error, first_wins or last_wins for = filters on one field with different values
+	RelationFilters         map[string]*RelationFilter                      `json:"relation_filters"`           // virtual field -> many-to-many relation REL_ANY and REL_ALL filter on, e.g. tags
+	FieldWrappers           map[string]string                               `json:"field_wrappers"`             // field -> SQL its column is wrapped in for filters, search and order, %s marking the column, e.g. DATE_TRUNC('day', %s)
+	FieldWrapperDialects    map[string]map[string]string                    `json:"field_wrapper_dialects"`     // field -> dialect name -> wrapper, overriding FieldWrappers
+
+	lookupOnce sync.Once
+	lookup     *settingsLookup
 }
 
 var DefaultQuerySettings = &QuerySettings{
@@ -58,6 +142,33 @@ func getRealColumns(alias map[string]string, columns []string) []string {
 	return fields
 }
 
+// splitOrderBy separates an optional direction prefix from an order by entry.
+// "-" sorts descending and "+" ascending regardless of SortFactor.
+func splitOrderBy(entry string) (string, string) {
+
+	if strings.HasPrefix(entry, "-") || strings.HasPrefix(entry, "+") {
+		return entry[:1], entry[1:]
+	}
+
+	return "", entry
+}
+
+func getRealOrderBy(alias map[string]string, entries []string) []string {
+
+	fields := make([]string, len(entries))
+
+	for i, entry := range entries {
+		prefix, field := splitOrderBy(entry)
+		if v, ok := alias[field]; ok {
+			field = v
+		}
+
+		fields[i] = prefix + field
+	}
+
+	return fields
+}
+
 func NewConditionsHandle(settings *QuerySettings) *ConditionsHandle {
 
 	if settings == nil {
@@ -70,103 +181,333 @@ func NewConditionsHandle(settings *QuerySettings) *ConditionsHandle {
 	}
 }
 
+// NewConditionsHandleFromProvider snapshots the provider's current settings,
+// which the handle then uses for its whole lifetime.
+func NewConditionsHandleFromProvider(provider SettingsProvider) *ConditionsHandle {
+
+	if provider == nil {
+		return NewConditionsHandle(nil)
+	}
+
+	return NewConditionsHandle(provider.Current())
+}
+
 func (ch *ConditionsHandle) UpdateConditions(conditions *QueryConditions) error {
 
+	cache := ch.Settings.PlanCache
+	if cache == nil {
+		return ch.updateConditions(conditions)
+	}
+
+	key, ok := newPlanKey(ch.Settings, conditions)
+	if !ok {
+		return ch.updateConditions(conditions)
+	}
+
+	if p, ok := cache.get(key); ok {
+		return ch.usePlan(p, conditions)
+	}
+
+	err := ch.updateConditions(conditions)
+	cache.add(key, newPlan(conditions, ch.Dropped, ch.names, ch.locale, err))
+
+	return err
+}
+
+func (ch *ConditionsHandle) updateConditions(conditions *QueryConditions) error {
+
 	settings := ch.Settings
+	lookup := settings.lookups()
+	ch.Dropped = nil
+	ch.names = nil
+	ch.locale = conditions.Locale
 
-	// check search fields
-	var allowedSearch []string
+	// check and map search fields
 	// If no search fields provided, SearchFields = [""]
 	if len(conditions.SearchFields) == 0 || (len(conditions.SearchFields) == 1 && conditions.SearchFields[0] == "") {
-		allowedSearch = settings.AllowedSearch
+		conditions.SearchFields = ch.localizeColumns(lookup.defaultSearch, false)
 	} else {
-
-		// filter search fields
-		allowedSearch = make([]string, 0)
-		for _, field := range conditions.SearchFields {
-			for _, allowed := range settings.AllowedSearch {
-				if field == allowed {
-					allowedSearch = append(allowedSearch, field)
-					break
-				}
-			}
-		}
+		conditions.SearchFields = ch.allowedColumns(conditions.SearchFields, lookup.search, false, DroppedSearchField, WarningSearchFieldNotAllowed, "field is not searchable")
 	}
 
-	// map search fields
-	conditions.SearchFields = getRealColumns(settings.ColumnAlias, allowedSearch)
-
-	// check order by
-	var orderBy []string
-	if conditions.OrderBy == nil || len(conditions.OrderBy) == 0 {
-		orderBy = settings.AllowedOrderBy
+	// check and map order by, entries may carry a direction prefix ("-price")
+	orderRequested := len(conditions.OrderBy) > 0
+	if !orderRequested {
+		conditions.OrderBy = ch.localizeColumns(lookup.defaultOrderBy, true)
 	} else {
+		conditions.OrderBy = ch.allowedColumns(conditions.OrderBy, lookup.orderBy, true, DroppedOrderBy, WarningOrderByNotAllowed, "field is not sortable")
+	}
 
-		// filter order by fields
-		orderBy = make([]string, 0)
-		for _, ob := range conditions.OrderBy {
-			for _, allowed := range settings.AllowedOrderBy {
-				if ob == allowed {
-					orderBy = append(orderBy, ob)
-					break
-				}
-			}
-		}
+	// A search or ordering left without columns would quietly not happen
+	if err := ch.checkAvailable(conditions, orderRequested); err != nil {
+		return err
 	}
 
-	// map order by fields
-	conditions.OrderBy = getRealColumns(settings.ColumnAlias, orderBy)
+	// check and map selected fields
+	if len(conditions.Fields) > 0 {
+		conditions.Fields = ch.allowedColumns(conditions.Fields, lookup.fields, false, DroppedField, WarningFieldNotAllowed, "field is not selectable")
+	}
 
 	// check sort factor
 	if conditions.SortFactor == 0 {
 		conditions.SortFactor = settings.DefaultSortFactor
 	} else if conditions.SortFactor > 1 {
+		ch.drop(DroppedItem{Kind: DroppedSortFactor, Code: WarningSortFactorClamped, Value: conditions.SortFactor, Reason: "clamped to 1"})
 		conditions.SortFactor = 1
 	} else if conditions.SortFactor < -1 {
+		ch.drop(DroppedItem{Kind: DroppedSortFactor, Code: WarningSortFactorClamped, Value: conditions.SortFactor, Reason: "clamped to -1"})
 		conditions.SortFactor = -1
 	}
 
 	// check and filter allowed filters
+	var filterErrors []*FilterError
 	if len(conditions.Filters) > 0 {
-		validFilters := make([]FilterCondition, 0)
+		validFilters := make([]FilterCondition, 0, len(conditions.Filters))
 		for _, filter := range conditions.Filters {
-			// Check if field is allowed
-			allowedOps, fieldAllowed := settings.AllowedFilters[filter.Field]
-			if !fieldAllowed {
+			f, ok, ferr := ch.normalizeFilter(filter)
+			if ferr != nil {
+				filterErrors = append(filterErrors, ferr)
+			}
+			if !ok {
 				continue
 			}
 
-			// Check if operator is allowed for this field
-			operatorAllowed := false
-			for _, op := range allowedOps {
-				if filter.Operator == op {
-					operatorAllowed = true
-					break
-				}
-			}
-			if !operatorAllowed {
-				continue
+			validFilters = append(validFilters, f)
+		}
+		conditions.Filters = ch.resolveConflicts(validFilters, &filterErrors)
+	}
+
+	// check filter groups
+	if len(conditions.FilterGroups) > 0 {
+		conditions.FilterGroups = ch.normalizeGroups(conditions.FilterGroups, &filterErrors)
+	}
+
+	// check includes and their filters
+	if len(conditions.Includes) > 0 || len(conditions.IncludeFilters) > 0 {
+		ch.normalizeIncludes(conditions, &filterErrors)
+	}
+
+	// check summaries
+	if len(conditions.RequestedSummaries) > 0 {
+		conditions.RequestedSummaries = ch.normalizeSummaries(conditions.RequestedSummaries)
+	}
+
+	// check histogram
+	if conditions.Histogram != nil {
+		conditions.Histogram = ch.normalizeHistogram(conditions.Histogram)
+	}
+
+	if len(filterErrors) > 0 {
+		return &ValidationError{Errors: filterErrors}
+	}
+
+	// reject requests the database cannot serve cheaply
+	if settings.MaxQueryCost > 0 {
+		cost, breakdown := ch.estimateCost(conditions)
+		if cost > settings.MaxQueryCost {
+			return &QueryCostError{Cost: cost, MaxCost: settings.MaxQueryCost, Breakdown: breakdown}
+		}
+	}
+
+	ch.Conditions = conditions
+
+	return nil
+}
+
+// checkAvailable reports a search text no field is left to search and an
+// ordering whose every column was rejected, failing under
+// RequireSearchableFields and warning otherwise. The ordering then falls back
+// to the default one.
+func (ch *ConditionsHandle) checkAvailable(conditions *QueryConditions, orderRequested bool) error {
+
+	text := strings.TrimSpace(conditions.SearchText)
+	if text != "" && len(conditions.SearchFields) == 0 {
+		if ids, _ := ch.searchIDMatches(text); len(ids) == 0 {
+			if ch.Settings.RequireSearchableFields {
+				return ErrSearchNotAvailable
 			}
+			ch.drop(DroppedItem{Kind: DroppedSearchField, Code: WarningSearchNotAvailable, Value: conditions.SearchText, Reason: "no field is searchable, search text ignored"})
+		}
+	}
 
-			// Map field alias to real column name
-			realField := filter.Field
-			if alias, ok := settings.ColumnAlias[filter.Field]; ok {
-				realField = alias
+	if orderRequested && len(conditions.OrderBy) == 0 {
+		if ch.Settings.RequireSearchableFields {
+			return ErrOrderByNotAvailable
+		}
+		ch.drop(DroppedItem{Kind: DroppedOrderBy, Code: WarningOrderByNotAvailable, Reason: "no requested field is sortable, default order used"})
+		conditions.OrderBy = ch.localizeColumns(ch.Settings.lookups().defaultOrderBy, true)
+	}
+
+	return nil
+}
+
+// normalizeFilter checks a filter against the allow-lists and prepares its
+// value. Filters that are not allowed are dropped without an error but
+// recorded in Dropped.
+func (ch *ConditionsHandle) normalizeFilter(filter FilterCondition) (FilterCondition, bool, *FilterError) {
+
+	settings := ch.Settings
+	lookup := settings.lookups()
+
+	// Settings name operators canonically, clients may use aliases
+	contains := isContainsOperator(filter.Operator)
+	if op, ok := CanonicalOperator(filter.Operator); ok {
+		filter.Operator = op
+	}
+
+	// Check if field is allowed
+	allowedOps, fieldAllowed := lookup.filters[filter.Field]
+	if !fieldAllowed {
+		ch.drop(DroppedItem{Kind: DroppedFilter, Code: WarningFilterNotAllowed, Field: filter.Field, Operator: filter.Operator, Value: filter.Value, Reason: "field is not filterable"})
+		return filter, false, nil
+	}
+
+	// Check if operator is allowed for this field
+	if _, operatorAllowed := allowedOps[filter.Operator]; !operatorAllowed {
+		ch.drop(DroppedItem{Kind: DroppedFilter, Code: WarningOperatorNotAllowed, Field: filter.Field, Operator: filter.Operator, Value: filter.Value, Reason: "operator is not allowed"})
+		return filter, false, nil
+	}
+
+	if err := checkRelationFilter(settings, filter); err != nil {
+		return filter, false, &FilterError{Field: filter.Field, Operator: filter.Operator, Err: err}
+	}
+
+	// Convert value to the declared field type
+	value, err := coerceFilterValue(settings.FieldTypes[filter.Field], filter.Operator, filter.Value)
+	if err != nil {
+		return filter, false, &FilterError{Field: filter.Field, Operator: filter.Operator, Err: err}
+	}
+	filter.Value = value
+
+	if isRelationOperator(filter.Operator) {
+		var valueType string
+		if rf := settings.RelationFilters[filter.Field]; rf != nil {
+			valueType = rf.ValueType
+		}
+		if filter.Value, err = distinctValues(filter.Value, valueType); err != nil {
+			return filter, false, &FilterError{Field: filter.Field, Operator: filter.Operator, Err: err}
+		}
+	}
+
+	if s, ok := filter.Value.(string); ok && contains {
+		filter.Value = "%" + escapeLike(s) + "%"
+	}
+
+	// Compare case-insensitive fields on lower-cased values
+	if _, ok := lookup.caseInsensitive[filter.Field]; ok {
+		filter.Value = lowerFilterValue(filter.Value)
+	}
+
+	// Run custom validators on the converted value
+	if err := validateFilterValue(settings.ValueValidators[filter.Field], filter); err != nil {
+		return filter, false, &FilterError{Field: filter.Field, Operator: filter.Operator, Err: err}
+	}
+
+	// Map field alias to real column name
+	if alias, ok := ch.realColumn(filter.Field); ok {
+		ch.rename(filter.Field, alias)
+		filter.Field = alias
+	}
+
+	return filter, true, nil
+}
+
+// normalizeGroups validates nested filter groups, dropping filters that are
+// not allowed and groups left empty.
+func (ch *ConditionsHandle) normalizeGroups(groups []FilterGroup, filterErrors *[]*FilterError) []FilterGroup {
+
+	validGroups := make([]FilterGroup, 0, len(groups))
+	for _, group := range groups {
+
+		logic := strings.ToUpper(strings.TrimSpace(group.Logic))
+		switch logic {
+		case "":
+			logic = LogicAnd
+		case LogicAnd, LogicOr:
+		default:
+			*filterErrors = append(*filterErrors, &FilterError{Operator: group.Logic, Err: errors.New("unknown group logic")})
+			continue
+		}
+
+		filters := make([]FilterCondition, 0, len(group.Filters))
+		for _, filter := range group.Filters {
+			f, ok, ferr := ch.normalizeFilter(filter)
+			if ferr != nil {
+				*filterErrors = append(*filterErrors, ferr)
+			}
+			if ok {
+				filters = append(filters, f)
 			}
-			filter.Field = realField
+		}
+
+		var subGroups []FilterGroup
+		if len(group.Groups) > 0 {
+			subGroups = ch.normalizeGroups(group.Groups, filterErrors)
+		}
+
+		if len(filters) == 0 && len(subGroups) == 0 {
+			continue
+		}
+
+		validGroups = append(validGroups, FilterGroup{
+			Logic:   logic,
+			Not:     group.Not,
+			Filters: filters,
+			Groups:  subGroups,
+		})
+	}
+
+	return validGroups
+}
+
+func validateFilterValue(validators map[string]func(v interface{}) error, filter FilterCondition) error {
 
-			validFilters = append(validFilters, filter)
+	if len(validators) == 0 {
+		return nil
+	}
+
+	if validate, ok := validators[filter.Operator]; ok {
+		if err := validate(filter.Value); err != nil {
+			return err
 		}
-		conditions.Filters = validFilters
 	}
 
-	ch.Conditions = conditions
+	if validate, ok := validators["*"]; ok {
+		if err := validate(filter.Value); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// CurrentInfo returns the normalized conditions with fields named as the
+// request named them. Apply uses the real columns. A nil handle, before any
+// Apply, has none.
 func (ch *ConditionsHandle) CurrentInfo() *QueryConditions {
-	return ch.Conditions
+
+	if ch == nil || ch.Conditions == nil {
+		return nil
+	}
+
+	public := ch.publicNames()
+	info := cloneConditions(*ch.Conditions)
+
+	info.SearchFields = publicColumns(public, info.SearchFields)
+	info.OrderBy = publicOrderBy(public, info.OrderBy)
+	info.Fields = publicColumns(public, info.Fields)
+	if info.Filters != nil {
+		info.Filters = publicFilters(public, info.Filters)
+	}
+	info.FilterGroups = publicGroups(public, info.FilterGroups)
+
+	if info.Histogram != nil {
+		h := *info.Histogram
+		h.Field = publicColumns(public, []string{h.Field})[0]
+		info.Histogram = &h
+	}
+
+	return &info
 }
 
 func (ch *ConditionsHandle) Apply(db *gorm.DB) (*gorm.DB, error) {
@@ -179,63 +520,59 @@ func (ch *ConditionsHandle) Apply(db *gorm.DB) (*gorm.DB, error) {
 		return db, errors.New("conditions not set")
 	}
 
-	query := db
-
-	// Apply filters
-	for _, filter := range ch.Conditions.Filters {
-		switch filter.Operator {
-		case "=":
-			query = query.Where(filter.Field+" = ?", filter.Value)
-		case "!=":
-			query = query.Where(filter.Field+" != ?", filter.Value)
-		case ">":
-			query = query.Where(filter.Field+" > ?", filter.Value)
-		case "<":
-			query = query.Where(filter.Field+" < ?", filter.Value)
-		case ">=":
-			query = query.Where(filter.Field+" >= ?", filter.Value)
-		case "<=":
-			query = query.Where(filter.Field+" <= ?", filter.Value)
-		case "BETWEEN":
-			// Value should be an array with 2 elements
-			if vals, ok := filter.Value.([]interface{}); ok && len(vals) == 2 {
-				query = query.Where(filter.Field+" BETWEEN ? AND ?", vals[0], vals[1])
-			}
-		case "IN":
-			query = query.Where(filter.Field+" IN ?", filter.Value)
-		case "NOT IN":
-			query = query.Where(filter.Field+" NOT IN ?", filter.Value)
-		case "LIKE":
-			query = query.Where(filter.Field+" LIKE ?", filter.Value)
-		}
-	}
-
-	// Apply search conditions
-	keywords := strings.TrimSpace(ch.Conditions.SearchText)
-	if keywords != "" && len(ch.Conditions.SearchFields) > 0 {
-		like := "%" + keywords + "%"
-
-		// Build the OR conditions
-		var orQuery string
-		orArgs := []interface{}{}
-		for i, field := range ch.Conditions.SearchFields {
-			if i > 0 {
-				orQuery += " OR "
-			}
-			orQuery += field + " LIKE ?"
-			orArgs = append(orArgs, like)
-		}
+	if err := ch.checkEmbedded(db); err != nil {
+		return db, err
+	}
 
-		// Apply to the main query with AND
-		query = query.Where(orQuery, orArgs...)
+	// Join each referenced relation once and use its alias in every clause
+	resolved, query := ch.resolveJoins(db)
+
+	// Apply sparse select
+	if len(resolved.Conditions.Fields) > 0 {
+		query = resolved.selectFields(query)
+	}
+
+	dialect := dialectName(query)
+
+	// Apply index hint
+	if hint := ch.indexHint(); hint != nil {
+		query = query.Clauses(indexHintClause{hint: hint, dialect: dialect})
+	}
+
+	// Apply filters, filter groups and search conditions
+	for _, expr := range resolved.whereExprs(dialect) {
+		query = query.Where(expr)
 	}
 
 	// Apply order by
+	casts := resolved.castColumns(dialect, lookupDialect(dialect))
+	wrappers := resolved.wrapperColumns(dialect)
 	orderCols := make([]clause.OrderByColumn, 0)
-	for _, v := range ch.Conditions.OrderBy {
+	for _, v := range resolved.Conditions.OrderBy {
+		prefix, field := splitOrderBy(v)
+		desc := ch.Conditions.SortFactor < 0
+		if prefix != "" {
+			desc = prefix == "-"
+		}
+
 		o := clause.OrderByColumn{
-			Column: clause.Column{Name: v},
-			Desc:   ch.Conditions.SortFactor < 0,
+			Column: ownColumn(resolved.table, field),
+			Desc:   desc,
+		}
+
+		// Wrapped columns sort by the wrapper's result, cast columns by the
+		// converted value
+		wrapper, wrapped := wrappers[field]
+		cast, isCast := casts[field]
+		if wrapped || isCast {
+			sql := query.Statement.Quote(o.Column)
+			if wrapped {
+				sql = wrapQuoted(wrapper, sql)
+			}
+			if isCast {
+				sql = "CAST(" + sql + " AS " + cast + ")"
+			}
+			o.Column = clause.Column{Name: sql, Raw: true}
 		}
 		orderCols = append(orderCols, o)
 	}
@@ -249,5 +586,10 @@ func (ch *ConditionsHandle) Apply(db *gorm.DB) (*gorm.DB, error) {
 		query = query.Order(orderClause)
 	}
 
+	// Preload included relations
+	if len(ch.Conditions.Includes) > 0 {
+		return ch.applyIncludes(query)
+	}
+
 	return query, nil
 }
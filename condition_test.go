@@ -0,0 +1,142 @@
+package queryhelper
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// benchmarkSettings resembles the settings of a typical listing endpoint,
+// with n extra filterable and sortable columns.
+func benchmarkSettings(n int, aliases bool) *QuerySettings {
+
+	settings := &QuerySettings{
+		AllowedSearch:         []string{"name", "email", "company"},
+		AllowedOrderBy:        []string{"name", "created_at", "age"},
+		AllowedFields:         []string{"id", "name", "email", "status", "age", "created_at"},
+		CaseInsensitiveFields: []string{"name", "email"},
+		IndexedFields:         []string{"status", "created_at"},
+		FieldTypes:            map[string]string{"age": "int", "created_at": "time"},
+		AllowedFilters: map[string][]string{
+			"status":     {"=", "!=", "IN"},
+			"age":        {"=", ">", ">=", "<", "<=", "BETWEEN"},
+			"name":       {"=", "LIKE"},
+			"created_at": {">=", "<"},
+		},
+		DefaultSortFactor: 1,
+	}
+
+	for i := 0; i < n; i++ {
+		field := fmt.Sprintf("attr_%d", i)
+		settings.AllowedOrderBy = append(settings.AllowedOrderBy, field)
+		settings.AllowedFields = append(settings.AllowedFields, field)
+		settings.AllowedFilters[field] = []string{"=", "!=", "IN", "<", ">"}
+	}
+
+	if aliases {
+		settings.ColumnAlias = map[string]string{
+			"company":    "companies.name",
+			"created_at": "users.created_at",
+		}
+	}
+
+	return settings
+}
+
+func benchmarkConditions() QueryConditions {
+	return QueryConditions{
+		SearchText: "ann",
+		Fields:     []string{"id", "name", "email", "created_at", "attr_3"},
+		OrderBy:    []string{"-created_at", "name", "attr_7"},
+		Filters: []FilterCondition{
+			{Field: "status", Operator: "IN", Value: []interface{}{"active", "invited"}},
+			{Field: "age", Operator: ">=", Value: 18},
+			{Field: "created_at", Operator: ">=", Value: "2024-01-01T00:00:00Z"},
+			{Field: "attr_12", Operator: "=", Value: "x"},
+		},
+		FilterGroups: []FilterGroup{{
+			Logic: LogicOr,
+			Filters: []FilterCondition{
+				{Field: "name", Operator: "LIKE", Value: "an%"},
+				{Field: "attr_5", Operator: "!=", Value: 0},
+			},
+		}},
+	}
+}
+
+func BenchmarkUpdateConditions(b *testing.B) {
+
+	for _, bench := range []struct {
+		name    string
+		n       int
+		aliases bool
+	}{
+		{name: "fields=20", n: 20},
+		{name: "fields=20/aliases", n: 20, aliases: true},
+		{name: "fields=200", n: 200},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+
+			settings := benchmarkSettings(bench.n, bench.aliases)
+			settings.lookups()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				conditions := benchmarkConditions()
+				if err := NewConditionsHandle(settings).UpdateConditions(&conditions); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedColumns(t *testing.T) {
+
+	settings := &QuerySettings{
+		AllowedOrderBy: []string{"name", "age", "company"},
+		ColumnAlias:    map[string]string{"company": "companies.name"},
+	}
+	allowed := settings.lookups().orderBy
+
+	// Nothing dropped or renamed returns the input itself
+	ch := NewConditionsHandle(settings)
+	columns := []string{"-name", "age"}
+	out := ch.allowedColumns(columns, allowed, true, DroppedOrderBy, WarningOrderByNotAllowed, "field is not sortable")
+	if &out[0] != &columns[0] || len(out) != len(columns) {
+		t.Errorf("got a copy %v of the input", out)
+	}
+	if len(ch.Dropped) != 0 {
+		t.Errorf("dropped %+v", ch.Dropped)
+	}
+
+	ch = NewConditionsHandle(settings)
+	columns = []string{"-name", "password", "+company", "age"}
+	out = ch.allowedColumns(columns, allowed, true, DroppedOrderBy, WarningOrderByNotAllowed, "field is not sortable")
+	if want := []string{"-name", "+companies.name", "age"}; !reflect.DeepEqual(out, want) {
+		t.Errorf("got %v, want %v", out, want)
+	}
+	if want := []string{"-name", "password", "+company", "age"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("input modified to %v", columns)
+	}
+	if len(ch.Dropped) != 1 || ch.Dropped[0].Field != "password" {
+		t.Errorf("dropped %+v, want password", ch.Dropped)
+	}
+}
+
+// TestUpdateConditionsBenchmarkInput keeps the benchmark's request valid,
+// so it measures validation rather than an early error.
+func TestUpdateConditionsBenchmarkInput(t *testing.T) {
+
+	for _, aliases := range []bool{false, true} {
+		ch := NewConditionsHandle(benchmarkSettings(20, aliases))
+		conditions := benchmarkConditions()
+		if err := ch.UpdateConditions(&conditions); err != nil {
+			t.Fatal(err)
+		}
+		if len(ch.Dropped) != 0 {
+			t.Errorf("aliases=%v: dropped %+v", aliases, ch.Dropped)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package queryhelper
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Conflict policies, how requests with several = filters on one field are
+// resolved. Such filters AND to an empty result, which is rarely meant.
+const (
+	ConflictPolicyError     = "error"      // reject the request
+	ConflictPolicyFirstWins = "first_wins" // keep the first filter
+	ConflictPolicyLastWins  = "last_wins"  // keep the last filter
+)
+
+// resolveConflicts drops exact duplicates among the normalized top-level
+// filters and resolves = filters on one field with different values per
+// ConflictPolicy. Without a policy they are kept as requested. Filters in
+// groups are left alone, as OR groups name a field several times on purpose.
+func (ch *ConditionsHandle) resolveConflicts(filters []FilterCondition, filterErrors *[]*FilterError) []FilterCondition {
+
+	public := ch.publicNames()
+	field := func(f FilterCondition) string {
+		return publicColumns(public, []string{f.Field})[0]
+	}
+
+	unique := make([]FilterCondition, 0, len(filters))
+	for _, f := range filters {
+		if containsFilter(unique, f) {
+			ch.drop(DroppedItem{Kind: DroppedFilter, Code: WarningDuplicateFilter, Field: field(f), Operator: f.Operator, Value: f.Value, Reason: "duplicate filter"})
+			continue
+		}
+
+		unique = append(unique, f)
+	}
+
+	policy := ch.Settings.ConflictPolicy
+	switch policy {
+	case ConflictPolicyError, ConflictPolicyFirstWins, ConflictPolicyLastWins:
+	default:
+		return unique
+	}
+
+	// Index of the = filter kept for each field
+	kept := make(map[string]int)
+	for i, f := range unique {
+		if f.Operator != "=" {
+			continue
+		}
+
+		k, ok := kept[f.Field]
+		if !ok {
+			kept[f.Field] = i
+			continue
+		}
+
+		switch policy {
+		case ConflictPolicyError:
+			*filterErrors = append(*filterErrors, &FilterError{
+				Field:    field(f),
+				Operator: f.Operator,
+				Err:      fmt.Errorf("%w: %v and %v", ErrConflictingFilters, unique[k].Value, f.Value),
+			})
+		case ConflictPolicyLastWins:
+			kept[f.Field] = i
+		}
+	}
+
+	reason := "conflicts with an earlier filter"
+	if policy == ConflictPolicyLastWins {
+		reason = "conflicts with a later filter"
+	}
+
+	resolved := make([]FilterCondition, 0, len(unique))
+	for i, f := range unique {
+		if k, ok := kept[f.Field]; ok && f.Operator == "=" && k != i {
+			if policy != ConflictPolicyError {
+				ch.drop(DroppedItem{Kind: DroppedFilter, Code: WarningConflictingFilter, Field: field(f), Operator: f.Operator, Value: f.Value, Reason: reason})
+			}
+			continue
+		}
+
+		resolved = append(resolved, f)
+	}
+
+	return resolved
+}
+
+func containsFilter(filters []FilterCondition, f FilterCondition) bool {
+
+	for _, other := range filters {
+		if other.Field == f.Field && other.Operator == f.Operator && reflect.DeepEqual(other.Value, f.Value) {
+			return true
+		}
+	}
+
+	return false
+}
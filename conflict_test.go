@@ -0,0 +1,164 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+func TestConflictPolicy(t *testing.T) {
+
+	db := customersDB(t)
+
+	eq := func(field string, value interface{}) queryhelper.FilterCondition {
+		return queryhelper.FilterCondition{Field: field, Operator: "=", Value: value}
+	}
+
+	// Customers of company 1 are 3, 6, 9 and 12, of company 2 1, 4, 7 and 10
+	tests := []struct {
+		name     string
+		policy   string
+		filters  []queryhelper.FilterCondition
+		groups   []queryhelper.FilterGroup
+		ids      []uint
+		warnings []string // code and value of each warning
+		err      bool
+	}{
+		{
+			name:     "exact duplicate without a policy",
+			filters:  []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 1)},
+			ids:      []uint{3, 6, 9, 12},
+			warnings: []string{"duplicate_filter 1"},
+		},
+		{
+			name:     "exact duplicate with error",
+			policy:   queryhelper.ConflictPolicyError,
+			filters:  []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 1)},
+			ids:      []uint{3, 6, 9, 12},
+			warnings: []string{"duplicate_filter 1"},
+		},
+		{
+			name:    "conflict without a policy is kept",
+			filters: []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 2)},
+		},
+		{
+			name:    "conflict with error",
+			policy:  queryhelper.ConflictPolicyError,
+			filters: []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 2)},
+			err:     true,
+		},
+		{
+			name:     "conflict with first_wins",
+			policy:   queryhelper.ConflictPolicyFirstWins,
+			filters:  []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 2)},
+			ids:      []uint{3, 6, 9, 12},
+			warnings: []string{"conflicting_filter 2"},
+		},
+		{
+			name:     "conflict with last_wins",
+			policy:   queryhelper.ConflictPolicyLastWins,
+			filters:  []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 2)},
+			ids:      []uint{1, 4, 7, 10},
+			warnings: []string{"conflicting_filter 1"},
+		},
+		{
+			name:     "duplicate and conflicts with last_wins",
+			policy:   queryhelper.ConflictPolicyLastWins,
+			filters:  []queryhelper.FilterCondition{eq("company_id", 3), eq("company_id", 1), eq("company_id", 3), eq("company_id", 2)},
+			ids:      []uint{1, 4, 7, 10},
+			warnings: []string{"duplicate_filter 3", "conflicting_filter 3", "conflicting_filter 1"},
+		},
+		{
+			name:   "range on one field",
+			policy: queryhelper.ConflictPolicyError,
+			filters: []queryhelper.FilterCondition{
+				{Field: "id", Operator: ">=", Value: 3},
+				{Field: "id", Operator: "<=", Value: 8},
+				{Field: "id", Operator: "!=", Value: 5},
+				{Field: "id", Operator: "!=", Value: 6},
+			},
+			ids: []uint{3, 4, 7, 8},
+		},
+		{
+			name:    "equal filters on different fields",
+			policy:  queryhelper.ConflictPolicyError,
+			filters: []queryhelper.FilterCondition{eq("company_id", 2), eq("id", 4)},
+			ids:     []uint{4},
+		},
+		{
+			name:   "either value in a group",
+			policy: queryhelper.ConflictPolicyError,
+			groups: []queryhelper.FilterGroup{{Logic: queryhelper.LogicOr, Filters: []queryhelper.FilterCondition{eq("company_id", 1), eq("company_id", 2), eq("company_id", 2)}}},
+			ids:    []uint{1, 3, 4, 6, 7, 9, 10, 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := &queryhelper.QuerySettings{
+				AllowedFilters: map[string][]string{"company_id": {"="}, "id": {"=", ">=", "<=", "!="}},
+				AllowedOrderBy: []string{"id"},
+				ConflictPolicy: tt.policy,
+			}
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithFilters(tt.filters), queryhelper.WithFilterGroups(tt.groups), queryhelper.WithPageSize(20))
+			var customers []testCustomer
+			err := dq.Execute(settings, db.Model(&testCustomer{}), &customers)
+
+			if tt.err {
+				var verr *queryhelper.ValidationError
+				if !errors.Is(err, queryhelper.ErrConflictingFilters) || !errors.As(err, &verr) || verr.Errors[0].Field != "company_id" {
+					t.Fatalf("err = %v, want conflicting company_id filters", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []uint
+			for _, c := range customers {
+				ids = append(ids, c.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("found %v, want %v", ids, tt.ids)
+			}
+
+			var warnings []string
+			for _, w := range dq.Info().Warnings {
+				warnings = append(warnings, fmt.Sprint(w.Code, " ", w.Params["value"]))
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestConflictPolicyAlias(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		ColumnAlias:    map[string]string{"org": "company_id"},
+		AllowedFilters: map[string][]string{"org": {"="}},
+		ConflictPolicy: queryhelper.ConflictPolicyFirstWins,
+	}
+
+	ch := queryhelper.NewConditionsHandle(settings)
+	err := ch.UpdateConditions(&queryhelper.QueryConditions{Filters: []queryhelper.FilterCondition{
+		{Field: "org", Operator: "=", Value: 1},
+		{Field: "org", Operator: "=", Value: 2},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The decision names the field as the client did
+	want := []queryhelper.DroppedItem{{Kind: queryhelper.DroppedFilter, Code: queryhelper.WarningConflictingFilter, Field: "org", Operator: "=", Value: 2, Reason: "conflicts with an earlier filter"}}
+	if !reflect.DeepEqual(ch.Dropped, want) {
+		t.Errorf("dropped %+v, want %+v", ch.Dropped, want)
+	}
+}
@@ -0,0 +1,155 @@
+package queryhelper
+
+import (
+	"strings"
+)
+
+// Constructs reported in a cost breakdown
+const (
+	CostUnanchoredLike       = "unanchored_like"
+	CostOrBranch             = "or_branch"
+	CostInItem               = "in_item"
+	CostMissingIndexedFilter = "missing_indexed_filter"
+)
+
+// CostWeights prices the constructs that make a query expensive. A zero
+// weight makes the construct free.
+type CostWeights struct {
+	UnanchoredLike       int `json:"unanchored_like"`        // per LIKE pattern or search field starting with a wildcard
+	OrBranch             int `json:"or_branch"`              // per OR branch beyond the first
+	InItem               int `json:"in_item"`                // per IN or NOT IN value
+	MissingIndexedFilter int `json:"missing_indexed_filter"` // once when no filter uses an IndexedFields field
+}
+
+var DefaultCostWeights = &CostWeights{
+	UnanchoredLike:       10,
+	OrBranch:             2,
+	InItem:               1,
+	MissingIndexedFilter: 25,
+}
+
+// CostItem is one entry of a cost breakdown. Field is the client-facing name.
+type CostItem struct {
+	Construct string `json:"construct"`
+	Field     string `json:"field,omitempty"`
+	Count     int    `json:"count"`
+	Cost      int    `json:"cost"`
+}
+
+// estimateCost prices normalized conditions with the configured weights.
+func (ch *ConditionsHandle) estimateCost(conditions *QueryConditions) (int, []CostItem) {
+
+	settings := ch.Settings
+	weights := settings.CostWeights
+	if weights == nil {
+		weights = DefaultCostWeights
+	}
+
+	public := ch.publicNames()
+	breakdown := make([]CostItem, 0)
+
+	add := func(construct string, column string, count int, weight int) {
+		if count == 0 || weight == 0 {
+			return
+		}
+
+		field := column
+		if name, ok := public[column]; ok {
+			field = name
+		}
+
+		breakdown = append(breakdown, CostItem{Construct: construct, Field: field, Count: count, Cost: count * weight})
+	}
+
+	var addFilter func(filter FilterCondition)
+	addFilter = func(filter FilterCondition) {
+		switch filter.Operator {
+		case "LIKE":
+			if s, ok := filter.Value.(string); ok && (strings.HasPrefix(s, "%") || strings.HasPrefix(s, "_")) {
+				add(CostUnanchoredLike, filter.Field, 1, weights.UnanchoredLike)
+			}
+		case "IN", "NOT IN", "REL_ANY", "REL_ALL":
+			if vals, ok := toInterfaceSlice(filter.Value); ok {
+				add(CostInItem, filter.Field, len(vals), weights.InItem)
+			}
+		}
+	}
+
+	var addGroup func(group FilterGroup)
+	addGroup = func(group FilterGroup) {
+		if group.Logic == LogicOr {
+			add(CostOrBranch, "", len(group.Filters)+len(group.Groups)-1, weights.OrBranch)
+		}
+
+		for _, filter := range group.Filters {
+			addFilter(filter)
+		}
+
+		for _, sub := range group.Groups {
+			addGroup(sub)
+		}
+	}
+
+	for _, filter := range conditions.Filters {
+		addFilter(filter)
+	}
+
+	for _, group := range conditions.FilterGroups {
+		addGroup(group)
+	}
+
+	// Search fields in contains mode match with %text%, and are ORed
+	// together unless SearchFieldLogic is AND
+	if strings.TrimSpace(conditions.SearchText) != "" && len(conditions.SearchFields) > 0 {
+		searchModes := settings.lookups().searchModes
+		for _, field := range conditions.SearchFields {
+			mode, ok := searchModes[field]
+			if !ok {
+				mode = settings.SearchMode
+			}
+
+			if mode != SearchModeExact && mode != SearchModePrefix {
+				add(CostUnanchoredLike, field, 1, weights.UnanchoredLike)
+			}
+		}
+
+		if !strings.EqualFold(settings.SearchFieldLogic, LogicAnd) {
+			add(CostOrBranch, "", len(conditions.SearchFields)-1, weights.OrBranch)
+		}
+	}
+
+	// Exact ID matches are ORed with the search fields
+	if ids, _ := ch.searchIDMatches(strings.TrimSpace(conditions.SearchText)); len(ids) > 0 {
+		branches := len(ids)
+		if len(conditions.SearchFields) == 0 {
+			branches--
+		}
+		add(CostOrBranch, "", branches, weights.OrBranch)
+	}
+
+	// Only top-level filters are guaranteed to narrow the scan
+	if len(settings.IndexedFields) > 0 {
+		lookup := settings.lookups()
+
+		indexed := false
+		for _, filter := range conditions.Filters {
+			_, real := lookup.indexed[filter.Field]
+			_, aliased := lookup.indexed[public[filter.Field]]
+			if real || aliased {
+				indexed = true
+				break
+			}
+		}
+
+		if !indexed {
+			add(CostMissingIndexedFilter, "", 1, weights.MissingIndexedFilter)
+		}
+	}
+
+	total := 0
+	for _, item := range breakdown {
+		total += item.Cost
+	}
+
+	return total, breakdown
+}
@@ -0,0 +1,153 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+// TestQueryCostThreshold validates each request against a budget of exactly
+// its cost, which passes, and one less, which fails with the breakdown.
+func TestQueryCostThreshold(t *testing.T) {
+
+	textFields := []string{"name", "email", "title", "company", "city", "notes"}
+
+	tests := []struct {
+		name       string
+		settings   func() *queryhelper.QuerySettings
+		conditions queryhelper.QueryConditions
+		cost       int
+		breakdown  []queryhelper.CostItem
+	}{
+		{
+			name: "wildcard search over six columns",
+			settings: func() *queryhelper.QuerySettings {
+				return &queryhelper.QuerySettings{
+					AllowedSearch: textFields,
+					IndexedFields: []string{"status"},
+				}
+			},
+			conditions: queryhelper.QueryConditions{SearchText: "ann"},
+			cost:       95,
+			breakdown: []queryhelper.CostItem{
+				{Construct: queryhelper.CostUnanchoredLike, Field: "name", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostUnanchoredLike, Field: "email", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostUnanchoredLike, Field: "title", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostUnanchoredLike, Field: "company", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostUnanchoredLike, Field: "city", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostUnanchoredLike, Field: "notes", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostOrBranch, Count: 5, Cost: 10},
+				{Construct: queryhelper.CostMissingIndexedFilter, Count: 1, Cost: 25},
+			},
+		},
+		{
+			name: "prefix search on an indexed filter",
+			settings: func() *queryhelper.QuerySettings {
+				return &queryhelper.QuerySettings{
+					AllowedSearch:  []string{"name", "email"},
+					AllowedFilters: map[string][]string{"state": {"="}},
+					ColumnAlias:    map[string]string{"state": "status"},
+					IndexedFields:  []string{"status"},
+					SearchMode:     queryhelper.SearchModePrefix,
+				}
+			},
+			conditions: queryhelper.QueryConditions{
+				SearchText: "ann",
+				Filters:    []queryhelper.FilterCondition{{Field: "state", Operator: "=", Value: "open"}},
+			},
+			cost:      2,
+			breakdown: []queryhelper.CostItem{{Construct: queryhelper.CostOrBranch, Count: 1, Cost: 2}},
+		},
+		{
+			name: "long IN list",
+			settings: func() *queryhelper.QuerySettings {
+				return &queryhelper.QuerySettings{
+					AllowedFilters: map[string][]string{"id": {"IN"}},
+					IndexedFields:  []string{"id"},
+				}
+			},
+			conditions: queryhelper.QueryConditions{
+				Filters: []queryhelper.FilterCondition{{Field: "id", Operator: "IN", Value: []interface{}{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}}},
+			},
+			cost:      20,
+			breakdown: []queryhelper.CostItem{{Construct: queryhelper.CostInItem, Field: "id", Count: 20, Cost: 20}},
+		},
+		{
+			name: "wide OR group",
+			settings: func() *queryhelper.QuerySettings {
+				return &queryhelper.QuerySettings{
+					AllowedFilters: map[string][]string{"name": {"LIKE"}, "status": {"="}},
+					IndexedFields:  []string{"status"},
+				}
+			},
+			conditions: queryhelper.QueryConditions{
+				FilterGroups: []queryhelper.FilterGroup{{
+					Logic: queryhelper.LogicOr,
+					Filters: []queryhelper.FilterCondition{
+						{Field: "name", Operator: "LIKE", Value: "%ann"},
+						{Field: "name", Operator: "LIKE", Value: "bob%"},
+						{Field: "status", Operator: "=", Value: "a"},
+						{Field: "status", Operator: "=", Value: "b"},
+					},
+				}},
+			},
+			cost: 41,
+			breakdown: []queryhelper.CostItem{
+				{Construct: queryhelper.CostOrBranch, Count: 3, Cost: 6},
+				{Construct: queryhelper.CostUnanchoredLike, Field: "name", Count: 1, Cost: 10},
+				{Construct: queryhelper.CostMissingIndexedFilter, Count: 1, Cost: 25},
+			},
+		},
+		{
+			name: "custom weights",
+			settings: func() *queryhelper.QuerySettings {
+				return &queryhelper.QuerySettings{
+					AllowedSearch:  []string{"name"},
+					AllowedFilters: map[string][]string{"id": {"NOT IN"}},
+					IndexedFields:  []string{"status"},
+					CostWeights:    &queryhelper.CostWeights{InItem: 5},
+				}
+			},
+			conditions: queryhelper.QueryConditions{
+				SearchText: "ann",
+				Filters:    []queryhelper.FilterCondition{{Field: "id", Operator: "NOT IN", Value: []interface{}{1, 2, 3}}},
+			},
+			cost:      15,
+			breakdown: []queryhelper.CostItem{{Construct: queryhelper.CostInItem, Field: "id", Count: 3, Cost: 15}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			update := func(maxCost int) error {
+				settings := tt.settings()
+				settings.MaxQueryCost = maxCost
+				conditions := tt.conditions
+				return queryhelper.NewConditionsHandle(settings).UpdateConditions(&conditions)
+			}
+
+			if err := update(tt.cost); err != nil {
+				t.Errorf("at the budget: %v", err)
+			}
+
+			err := update(tt.cost - 1)
+			if !errors.Is(err, queryhelper.ErrQueryTooExpensive) {
+				t.Fatalf("over the budget: err = %v, want ErrQueryTooExpensive", err)
+			}
+
+			var cerr *queryhelper.QueryCostError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("err = %v, want a QueryCostError", err)
+			}
+			if cerr.Cost != tt.cost || cerr.MaxCost != tt.cost-1 {
+				t.Errorf("cost %d of %d, want %d of %d", cerr.Cost, cerr.MaxCost, tt.cost, tt.cost-1)
+			}
+			if !reflect.DeepEqual(cerr.Breakdown, tt.breakdown) {
+				t.Errorf("breakdown\n%+v\nwant\n%+v", cerr.Breakdown, tt.breakdown)
+			}
+		})
+	}
+}
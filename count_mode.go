@@ -0,0 +1,217 @@
+package queryhelper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Count modes, how the total of a paginated request is found
+const (
+	CountModeExact     = "exact"     // COUNT(*), the default
+	CountModeEstimated = "estimated" // the query planner's row estimate, on PostgreSQL and MySQL
+	CountModeNone      = "none"      // no count, Total and TotalPages are left 0
+)
+
+var errNoCountEstimate = errors.New("the database gives no row estimates")
+
+// WithCountMode asks for a count mode, which QuerySettings.AllowedCountModes
+// must allow.
+func WithCountMode(mode string) Option {
+	return func(dq *QueryHelper) {
+		dq.paginationRequest.CountMode = mode
+	}
+}
+
+// resolveCountMode picks the count mode the request asked for when the
+// settings allow it, and otherwise DefaultCountMode with a warning. Exact
+// counts are allowed when AllowedCountModes is empty.
+func (p *PaginationHandle) resolveCountMode(settings *QuerySettings) string {
+
+	p.modeDropped = nil
+
+	fallback := CountModeExact
+	allowed := []string{CountModeExact}
+	if settings != nil {
+		if m := strings.ToLower(settings.DefaultCountMode); isCountMode(m) {
+			fallback = m
+		}
+		if len(settings.AllowedCountModes) > 0 {
+			allowed = settings.AllowedCountModes
+		}
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(p.countMode))
+	if mode == "" {
+		return fallback
+	}
+
+	for _, m := range allowed {
+		if strings.EqualFold(m, mode) && isCountMode(mode) {
+			return mode
+		}
+	}
+
+	p.modeDropped = append(p.modeDropped, DroppedItem{Kind: DroppedCountMode, Code: WarningCountModeNotAllowed, Value: p.countMode, Reason: "count mode is not allowed, using " + fallback})
+
+	return fallback
+}
+
+func isCountMode(mode string) bool {
+	return mode == CountModeExact || mode == CountModeEstimated || mode == CountModeNone
+}
+
+// count finds the query's total in the resolved count mode, counting
+// exactly when the database cannot estimate.
+func (p *PaginationHandle) count(query *gorm.DB, mode string) error {
+
+	p.Info.CountMode = mode
+	p.Info.Total = 0
+	p.Info.TotalPages = 0
+
+	if mode == CountModeNone {
+		return nil
+	}
+
+	// A sample is counted on itself, not on CountTable
+	countQuery := withoutPreloads(query)
+	if p.countTable != "" && p.sampled == 0 {
+		countQuery = countQuery.Session(&gorm.Session{}).Table(p.countTable)
+	}
+
+	var total int64
+	err := withTimeout(countQuery, p.timeout, func(q *gorm.DB) error {
+		return p.retry.run(q, &p.retries, func(q *gorm.DB) error {
+
+			if mode == CountModeEstimated {
+				n, err := estimateCount(q)
+				if err == nil {
+					total = n
+					return nil
+				}
+
+				p.modeDropped = append(p.modeDropped, DroppedItem{Kind: DroppedCountMode, Code: WarningCountModeUnavailable, Value: mode, Reason: fmt.Sprintf("counted exactly: %v", err)})
+				mode = CountModeExact
+				p.Info.CountMode = mode
+			}
+
+			return q.Count(&total).Error
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// The sample's count is scaled to the whole table, an estimate
+	if p.sampled > 0 {
+		total = int64(math.Round(float64(total) * 100 / p.sampled))
+		p.Info.CountMode = CountModeEstimated
+	}
+
+	p.Info.Total = total
+	if total == 0 {
+		p.Info.TotalPages = 1
+	} else {
+		p.Info.TotalPages = int((total + int64(p.Info.Limit) - 1) / int64(p.Info.Limit))
+	}
+
+	return nil
+}
+
+// estimateCount returns the planner's estimate of the rows query matches.
+func estimateCount(query *gorm.DB) (int64, error) {
+
+	var explain string
+	switch dialectName(query) {
+	case "postgres":
+		explain = "EXPLAIN (FORMAT JSON) "
+	case "mysql":
+		explain = "EXPLAIN "
+	default:
+		return 0, errNoCountEstimate
+	}
+
+	var rows []map[string]interface{}
+	stmt := query.Session(&gorm.Session{DryRun: true}).Find(&rows).Statement
+	if stmt.Error != nil {
+		return 0, stmt.Error
+	}
+
+	var explained []map[string]interface{}
+	err := query.Session(&gorm.Session{NewDB: true}).Raw(explain+stmt.SQL.String(), stmt.Vars...).Find(&explained).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if dialectName(query) == "postgres" {
+		if len(explained) == 0 {
+			return 0, errors.New("the plan is empty")
+		}
+
+		// Drivers return the JSON column as text or decoded
+		var plan []byte
+		switch v := explained[0]["QUERY PLAN"].(type) {
+		case []byte:
+			plan = v
+		case string:
+			plan = []byte(v)
+		default:
+			plan, _ = json.Marshal(v)
+		}
+
+		var plans []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal(plan, &plans); err != nil || len(plans) == 0 {
+			return 0, fmt.Errorf("unexpected plan: %s", plan)
+		}
+
+		return int64(plans[0].Plan.Rows), nil
+	}
+
+	// MySQL estimates each table of the join; their product is the result's
+	estimate, found := 1.0, false
+	for _, row := range explained {
+		n, ok := explainNumber(row["rows"])
+		if !ok {
+			continue
+		}
+
+		if filtered, ok := explainNumber(row["filtered"]); ok {
+			n = n * filtered / 100
+		}
+
+		estimate *= n
+		found = true
+	}
+
+	if !found {
+		return 0, errors.New("the plan has no row estimates")
+	}
+
+	return int64(math.Round(estimate)), nil
+}
+
+func explainNumber(v interface{}) (float64, bool) {
+
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return 0, false
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+
+	return f, err == nil
+}
@@ -0,0 +1,250 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// namedDialector runs on SQLite under another dialect's name.
+type namedDialector struct {
+	gorm.Dialector
+	name string
+}
+
+func (d namedDialector) Name() string {
+	return d.name
+}
+
+// explainAs makes db pass for dialect and answer every EXPLAIN with plan,
+// returning the EXPLAIN statements run.
+func explainAs(t *testing.T, db *gorm.DB, dialect string, plan []map[string]interface{}) *[]string {
+
+	t.Helper()
+
+	db.Config.Dialector = namedDialector{Dialector: db.Dialector, name: dialect}
+
+	var explained []string
+	err := db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		if sql := tx.Statement.SQL.String(); strings.HasPrefix(sql, "EXPLAIN") {
+			explained = append(explained, sql)
+			*tx.Statement.Dest.(*[]map[string]interface{}) = plan
+			return
+		}
+		callbacks.Query(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &explained
+}
+
+func TestCountModes(t *testing.T) {
+
+	db := customersDB(t)
+	statements := executedQueries(t, db)
+
+	allowAll := []string{queryhelper.CountModeExact, queryhelper.CountModeEstimated, queryhelper.CountModeNone}
+
+	tests := []struct {
+		name       string
+		mode       string
+		allowed    []string
+		fallback   string
+		want       string // count mode reported
+		total      int64
+		totalPages int
+		statements int
+		warnings   []string
+	}{
+		{name: "exact by default", allowed: allowAll, want: "exact", total: 12, totalPages: 3, statements: 2},
+		{name: "exact", mode: "exact", allowed: allowAll, want: "exact", total: 12, totalPages: 3, statements: 2},
+		{name: "none", mode: "none", allowed: allowAll, want: "none", statements: 1},
+		{name: "case and spaces", mode: " NONE ", allowed: allowAll, want: "none", statements: 1},
+		{
+			name:       "estimated without estimates",
+			mode:       "estimated",
+			allowed:    allowAll,
+			want:       "exact",
+			total:      12,
+			totalPages: 3,
+			statements: 2,
+			warnings:   []string{queryhelper.WarningCountModeUnavailable},
+		},
+		{name: "default mode", allowed: allowAll, fallback: "none", want: "none", statements: 1},
+		{
+			name:       "not allowed",
+			mode:       "estimated",
+			allowed:    []string{"exact", "none"},
+			fallback:   "none",
+			want:       "none",
+			statements: 1,
+			warnings:   []string{queryhelper.WarningCountModeNotAllowed},
+		},
+		{
+			name:       "unknown mode",
+			mode:       "approximate",
+			allowed:    allowAll,
+			want:       "exact",
+			total:      12,
+			totalPages: 3,
+			statements: 2,
+			warnings:   []string{queryhelper.WarningCountModeNotAllowed},
+		},
+		{
+			name:       "only exact when nothing is allowed",
+			mode:       "none",
+			want:       "exact",
+			total:      12,
+			totalPages: 3,
+			statements: 2,
+			warnings:   []string{queryhelper.WarningCountModeNotAllowed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := &queryhelper.QuerySettings{AllowedCountModes: tt.allowed, DefaultCountMode: tt.fallback}
+
+			before := len(*statements)
+			dq := queryhelper.NewQueryHelper(queryhelper.WithCountMode(tt.mode), queryhelper.WithPageSize(5))
+			var customers []testCustomer
+			if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+				t.Fatal(err)
+			}
+
+			// The page is found in every mode
+			if len(customers) != 5 {
+				t.Errorf("found %d customers, want 5", len(customers))
+			}
+
+			p := dq.Info().Pagination
+			if p.CountMode != tt.want || p.Total != tt.total || p.TotalPages != tt.totalPages {
+				t.Errorf("mode %q, total %d in %d pages, want %q, %d in %d", p.CountMode, p.Total, p.TotalPages, tt.want, tt.total, tt.totalPages)
+			}
+			if n := len(*statements) - before; n != tt.statements {
+				t.Errorf("%d statements, want %d", n, tt.statements)
+			}
+
+			var codes []string
+			for _, w := range dq.Info().Warnings {
+				codes = append(codes, w.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.warnings) {
+				t.Errorf("warnings %v, want %v", codes, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestCountModeEstimated(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		dialect    string
+		plan       []map[string]interface{}
+		explain    string
+		want       string
+		total      int64
+		totalPages int
+		warnings   []string
+	}{
+		{
+			name:       "postgres plan as text",
+			dialect:    "postgres",
+			plan:       []map[string]interface{}{{"QUERY PLAN": `[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 1234}}]`}},
+			explain:    "EXPLAIN (FORMAT JSON) SELECT",
+			want:       "estimated",
+			total:      1234,
+			totalPages: 247,
+		},
+		{
+			name:       "postgres plan decoded",
+			dialect:    "postgres",
+			plan:       []map[string]interface{}{{"QUERY PLAN": []interface{}{map[string]interface{}{"Plan": map[string]interface{}{"Plan Rows": 40.0}}}}},
+			explain:    "EXPLAIN (FORMAT JSON) SELECT",
+			want:       "estimated",
+			total:      40,
+			totalPages: 8,
+		},
+		{
+			name:    "mysql join",
+			dialect: "mysql",
+			plan: []map[string]interface{}{
+				{"table": "customers", "rows": []byte("40"), "filtered": "50.00"},
+				{"table": "orders", "rows": int64(3), "filtered": nil},
+			},
+			explain:    "EXPLAIN SELECT",
+			want:       "estimated",
+			total:      60,
+			totalPages: 12,
+		},
+		{
+			name:       "unreadable plan",
+			dialect:    "postgres",
+			plan:       []map[string]interface{}{{"QUERY PLAN": "not json"}},
+			explain:    "EXPLAIN (FORMAT JSON) SELECT",
+			want:       "exact",
+			total:      4,
+			totalPages: 1,
+			warnings:   []string{queryhelper.WarningCountModeUnavailable},
+		},
+		{
+			name:       "no row estimates",
+			dialect:    "mysql",
+			plan:       []map[string]interface{}{{"table": "customers", "rows": nil}},
+			explain:    "EXPLAIN SELECT",
+			want:       "exact",
+			total:      4,
+			totalPages: 1,
+			warnings:   []string{queryhelper.WarningCountModeUnavailable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := customersDB(t)
+			explained := explainAs(t, db, tt.dialect, tt.plan)
+
+			settings := &queryhelper.QuerySettings{
+				AllowedFilters:    map[string][]string{"company_id": {"="}},
+				AllowedCountModes: []string{queryhelper.CountModeExact, queryhelper.CountModeEstimated},
+			}
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithCountMode("estimated"), queryhelper.WithEqual("company_id", 1), queryhelper.WithPageSize(5))
+			var customers []testCustomer
+			if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(customers) != 4 {
+				t.Errorf("found %d customers, want the 4 of company 1", len(customers))
+			}
+
+			// The planner is asked about the filtered query
+			if len(*explained) != 1 || !strings.HasPrefix((*explained)[0], tt.explain) || !strings.Contains((*explained)[0], "company_id") {
+				t.Errorf("explained %q, want one %q of the filtered query", *explained, tt.explain)
+			}
+
+			p := dq.Info().Pagination
+			if p.CountMode != tt.want || p.Total != tt.total || p.TotalPages != tt.totalPages {
+				t.Errorf("mode %q, total %d in %d pages, want %q, %d in %d", p.CountMode, p.Total, p.TotalPages, tt.want, tt.total, tt.totalPages)
+			}
+
+			var codes []string
+			for _, w := range dq.Info().Warnings {
+				codes = append(codes, w.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.warnings) {
+				t.Errorf("warnings %v, want %v", codes, tt.warnings)
+			}
+		})
+	}
+}
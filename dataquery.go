@@ -1,12 +1,19 @@
 package queryhelper
 
 import (
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type QueryHelperInfo struct {
 	Pagination *PaginationInfo
 	Conditions *QueryConditions
+	Applied    *AppliedConditions
+	Summaries  map[string]interface{} // computed by Execute
+	Histogram  []HistogramBucket      // computed by Execute
+	Warnings   []Warning              // adjustments made to the request
 }
 
 type QueryHelper struct {
@@ -14,6 +21,20 @@ type QueryHelper struct {
 	paginationRequest *PaginationRequest
 	pagination        *PaginationHandle
 	conditions        *ConditionsHandle
+	settingsProvider  SettingsProvider
+	auditSink         AuditSink
+	locking           *Locking
+	retries           int // retries of the last Apply or Execute, besides the count's
+	partialShards     bool
+	shardErrors       []*ShardError
+	wrapSubquery      bool
+	aggregateQuery    *gorm.DB // the conditioned query before pagination, for summaries and histograms
+	summaries         map[string]interface{}
+	histogram         []HistogramBucket
+	err               error           // first mistake an option reported, see WithFilter
+	sampling          *Sampling       // see WithSampling
+	twoPhase          *twoPhaseKeys   // key order of the last two-phase page, see SortPage
+	requested         QueryConditions // the conditions before validation, audited when rejected
 }
 
 type Option func(*QueryHelper)
@@ -30,6 +51,22 @@ func WithPageSize(pageSize int) Option {
 	}
 }
 
+// WithOffset skips a raw number of records instead of using page arithmetic.
+// It cannot be combined with WithPage.
+func WithOffset(offset int) Option {
+	return func(dq *QueryHelper) {
+		dq.paginationRequest.Offset = &offset
+	}
+}
+
+// WithLimit returns at most limit records, still capped by
+// DefaultMaxPageSize. It cannot be combined with WithPage.
+func WithLimit(limit int) Option {
+	return func(dq *QueryHelper) {
+		dq.paginationRequest.Limit = &limit
+	}
+}
+
 func WithSearchText(text string) Option {
 	return func(dq *QueryHelper) {
 		dq.queryConditions.SearchText = text
@@ -54,9 +91,31 @@ func WithSortFactor(factor int) Option {
 	}
 }
 
+// WithFilters appends filters to the request, so it composes with WithFilter
+// and its shorthands in any order.
 func WithFilters(filters []FilterCondition) Option {
 	return func(dq *QueryHelper) {
-		dq.queryConditions.Filters = filters
+		dq.queryConditions.Filters = append(dq.queryConditions.Filters, filters...)
+	}
+}
+
+func WithFilterGroups(groups []FilterGroup) Option {
+	return func(dq *QueryHelper) {
+		dq.queryConditions.FilterGroups = groups
+	}
+}
+
+func WithFields(fields []string) Option {
+	return func(dq *QueryHelper) {
+		dq.queryConditions.Fields = fields
+	}
+}
+
+// WithSettingsProvider makes Apply fall back to the provider's current
+// settings when it is called with nil settings.
+func WithSettingsProvider(provider SettingsProvider) Option {
+	return func(dq *QueryHelper) {
+		dq.settingsProvider = provider
 	}
 }
 
@@ -77,6 +136,30 @@ func NewQueryHelper(opts ...Option) *QueryHelper {
 	return dq
 }
 
+// Validate checks the conditions against the settings without applying them,
+// leaving the helper's conditions untouched.
+func (dq *QueryHelper) Validate(settings *QuerySettings) error {
+
+	if dq.err != nil {
+		return dq.err
+	}
+
+	if settings == nil && dq.settingsProvider != nil {
+		settings = dq.settingsProvider.Current()
+	}
+
+	// UpdateConditions only reassigns fields, so a shallow copy is enough
+	conditions := *dq.queryConditions
+
+	return NewConditionsHandle(settings).UpdateConditions(&conditions)
+}
+
+// Err returns the first mistake an option such as WithBetween reported, which
+// Apply and Execute also return.
+func (dq *QueryHelper) Err() error {
+	return dq.err
+}
+
 func (dq *QueryHelper) GetPaginationRequest() *PaginationRequest {
 	return dq.paginationRequest
 }
@@ -85,18 +168,92 @@ func (dq *QueryHelper) GetQueryConditions() *QueryConditions {
 	return dq.queryConditions
 }
 
+// Retries returns how many statements of the last Apply or Execute were
+// retried under the settings' RetryPolicy.
+func (dq *QueryHelper) Retries() int {
+	return dq.retries + dq.pagination.retries
+}
+
 func (dq *QueryHelper) Info() *QueryHelperInfo {
 	return &QueryHelperInfo{
 		Pagination: dq.pagination.CurrentInfo(),
 		Conditions: dq.conditions.CurrentInfo(),
+		Applied:    dq.Applied(),
+		Summaries:  dq.summaries,
+		Histogram:  dq.histogram,
+		Warnings:   dq.Warnings(),
+	}
+}
+
+// ApplyWithProvider applies the query using a single snapshot of the
+// provider's settings.
+func (dq *QueryHelper) ApplyWithProvider(provider SettingsProvider, query *gorm.DB) (*gorm.DB, error) {
+
+	var settings *QuerySettings
+	if provider != nil {
+		settings = provider.Current()
 	}
+
+	return dq.Apply(settings, query)
 }
 
 func (dq *QueryHelper) Apply(settings *QuerySettings, query *gorm.DB) (*gorm.DB, error) {
 
-	// Prepare dataquery handle
+	// Capture settings once so the whole query sees a consistent snapshot
+	if settings == nil && dq.settingsProvider != nil {
+		settings = dq.settingsProvider.Current()
+	}
+
+	if dq.auditSink == nil {
+		return dq.apply(settings, query)
+	}
+
+	start := time.Now()
+	q, err := dq.apply(settings, query)
+	dq.audit(settings, query, start, err, nil)
+
+	return q, err
+}
+
+func (dq *QueryHelper) apply(settings *QuerySettings, query *gorm.DB) (*gorm.DB, error) {
+
+	dq.retries = 0
+	dq.pagination.retries = 0
+	dq.aggregateQuery = nil
+	dq.summaries = nil
+	dq.histogram = nil
+	dq.twoPhase = nil
+
+	// UpdateConditions only reassigns fields, so a shallow copy is enough
+	dq.requested = *dq.queryConditions
+
+	if dq.err != nil {
+		return nil, dq.err
+	}
+
+	// A sample of rows cannot be locked consistently
+	if dq.sampling != nil && dq.locking != nil {
+		return nil, ErrSamplingWithLocking
+	}
+
+	// Prepare dataquery handle, kept even when the conditions are rejected
+	// so Info and Warnings describe this attempt
 	dqh := NewConditionsHandle(settings)
-	dqh.UpdateConditions(dq.queryConditions)
+	dq.conditions = dqh
+	if err := dqh.UpdateConditions(dq.queryConditions); err != nil {
+		return nil, err
+	}
+
+	// Select from the query as a derived table
+	if dq.wrapSubquery {
+		if err := dqh.checkSubqueryColumns(); err != nil {
+			return nil, err
+		}
+
+		if query != nil {
+			query = wrapSubquery(query)
+		}
+	}
 
 	// Apply conditions to query
 	if query != nil {
@@ -109,10 +266,64 @@ func (dq *QueryHelper) Apply(settings *QuerySettings, query *gorm.DB) (*gorm.DB,
 		query = q
 	}
 
-	dq.conditions = dqh
+	// Check locking before any statement runs
+	var lock *clause.Locking
+	if query != nil && dq.locking != nil {
+		l, err := lockingClause(query, dq.locking)
+		if err != nil {
+			return nil, err
+		}
+
+		lock = &l
+	}
+
+	// Preview a sample of the table's rows
+	if query != nil && dq.sampling != nil {
+		q, err := applySampling(query, dq.sampling, dqh.Settings.PrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+
+		query = q
+	}
+
+	// Collapse rows multiplied by joins before counting and paging
+	if query != nil && dqh.Settings.DeduplicateOnPrimaryKey {
+		q, err := deduplicate(query, dqh.Settings, dqh.Conditions.Fields)
+		if err != nil {
+			return nil, err
+		}
+
+		query = q
+	}
+
+	// Let the database enforce the timeout as well
+	if query != nil && dqh.Settings.QueryTimeout > 0 {
+		q, err := applyStatementTimeout(query, dqh.Settings.QueryTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		query = q
+	}
+
+	// Summaries and histograms aggregate every matching row, not the page.
+	// Scopes forces a copy of the statement pagination would modify
+	if query != nil && (len(dqh.Conditions.RequestedSummaries) > 0 || dqh.Conditions.Histogram != nil) {
+		dq.aggregateQuery = query.Session(&gorm.Session{}).Scopes()
+	}
 
 	// Apply pagination to query
 	if query != nil {
+		dq.pagination.countTable = dqh.Settings.CountTable
+		dq.pagination.timeout = dqh.Settings.QueryTimeout
+		dq.pagination.retry = dqh.Settings.RetryPolicy
+		dq.pagination.sampled = 0
+		if dq.sampling != nil {
+			dq.pagination.sampled = dq.sampling.Percent
+		}
+		dq.pagination.Info.CountMode = dq.pagination.resolveCountMode(dqh.Settings)
+
 		q, err := dq.pagination.Apply(query)
 		if err != nil {
 			return nil, err
@@ -121,5 +332,21 @@ func (dq *QueryHelper) Apply(settings *QuerySettings, query *gorm.DB) (*gorm.DB,
 		query = q
 	}
 
+	// Fetch the page's keys first and return a query for just those rows
+	if query != nil && dqh.Settings.PaginationStrategy == PaginationTwoPhase {
+		q, keys, err := applyTwoPhase(query, dqh.Settings, dqh.Conditions.Fields, &dq.retries)
+		if err != nil {
+			return nil, err
+		}
+
+		query = q
+		dq.twoPhase = keys
+	}
+
+	// Lock the rows of the page only, after counting
+	if lock != nil {
+		query = query.Clauses(*lock)
+	}
+
 	return query, nil
 }
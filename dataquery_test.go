@@ -0,0 +1,89 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func TestInfoAfterFailedApply(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy:          []string{"name"},
+		RequireSearchableFields: true,
+	}
+
+	tests := []struct {
+		name string
+		opts []queryhelper.Option
+	}{
+		{
+			name: "before apply",
+		},
+		{
+			name: "rejected conditions",
+			opts: []queryhelper.Option{queryhelper.WithOrderBy([]string{"password"})},
+		},
+		{
+			name: "rejected options",
+			opts: []queryhelper.Option{queryhelper.WithSampling(10, queryhelper.SamplingSystem), queryhelper.WithLocking(queryhelper.Locking{})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(tt.opts...)
+			if tt.opts != nil {
+				if _, err := dq.Apply(settings, queryhelpertest.DryRunDB(t, "sqlite").Model(&testUser{})); err == nil {
+					t.Fatal("Apply succeeded")
+				}
+			}
+
+			info := dq.Info()
+			if info.Conditions != nil {
+				t.Errorf("Conditions = %+v, want nil", info.Conditions)
+			}
+		})
+	}
+}
+
+func TestInfoReplacesEarlierApply(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy:          []string{"name"},
+		RequireSearchableFields: true,
+	}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithOrderBy([]string{"name"}))
+	if _, err := dq.Apply(settings, queryhelpertest.DryRunDB(t, "sqlite").Model(&testUser{})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings no longer allowing the ordering reject it, and Info stops
+	// describing the earlier query
+	_, err := dq.Apply(&queryhelper.QuerySettings{AllowedOrderBy: []string{"age"}, RequireSearchableFields: true}, queryhelpertest.DryRunDB(t, "sqlite").Model(&testUser{}))
+	if !errors.Is(err, queryhelper.ErrOrderByNotAvailable) {
+		t.Fatalf("err = %v, want ErrOrderByNotAvailable", err)
+	}
+
+	info := dq.Info()
+	if info.Conditions != nil {
+		t.Errorf("Conditions = %+v, want nil", info.Conditions)
+	}
+	if len(info.Warnings) == 0 {
+		t.Error("no warning for the dropped ordering")
+	}
+}
+
+func TestApplySamplingWithLocking(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithSampling(10, queryhelper.SamplingSystem), queryhelper.WithLocking(queryhelper.Locking{}))
+
+	_, err := dq.Apply(&queryhelper.QuerySettings{}, queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if !errors.Is(err, queryhelper.ErrSamplingWithLocking) {
+		t.Errorf("err = %v, want ErrSamplingWithLocking", err)
+	}
+}
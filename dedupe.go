@@ -0,0 +1,128 @@
+package queryhelper
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Alias of the key subquery an ordered de-duplication joins
+const dedupeKeys = "qh_keys"
+
+// deduplicate rewrites the conditioned query as
+//
+//	SELECT ... FROM t WHERE t.pk IN (SELECT DISTINCT t.pk FROM <query>)
+//
+// so a parent matched through several joined children is returned and
+// counted once. The sparse select and preloads move to the outer query.
+//
+// Ordering may reference joined columns the outer query does not have, so
+// an ordered query takes its sort keys from the key subquery instead, the
+// lowest value per parent ascending and the highest descending:
+//
+//	SELECT ... FROM t JOIN (SELECT t.pk AS qh_key, MIN(c) AS qh_order_0
+//	  FROM <query> GROUP BY t.pk) qh_keys ON qh_keys.qh_key = t.pk
+//	ORDER BY qh_keys.qh_order_0
+func deduplicate(query *gorm.DB, settings *QuerySettings, fields []string) (*gorm.DB, error) {
+
+	pk, err := primaryKeyColumn(query, settings.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: pk}
+
+	keys := query.Session(&gorm.Session{}).Clauses(clause.Select{Distinct: true, Columns: []clause.Column{column}})
+	orderBy, ordered := keys.Statement.Clauses["ORDER BY"]
+
+	// Some databases reject ORDER BY in a subquery
+	delete(keys.Statement.Clauses, "ORDER BY")
+
+	rows := query.Session(&gorm.Session{NewDB: true})
+	if query.Statement.Model != nil {
+		rows = rows.Model(query.Statement.Model)
+	}
+	if query.Statement.Table != "" {
+		rows = rows.Table(query.Statement.Table)
+	}
+	rows = copyPreloads(rows, query)
+
+	order, _ := orderBy.Expression.(clause.OrderBy)
+	if !ordered || len(order.Columns) == 0 {
+		if len(fields) > 0 {
+			rows = rows.Select(fields)
+		}
+
+		rows = rows.Where(clause.Expr{SQL: "? IN (?)", Vars: []interface{}{column, keys}})
+
+		if ordered {
+			rows = rows.Clauses(orderBy.Expression)
+		}
+
+		return rows, nil
+	}
+
+	// Aggregate each ordering column per parent in the key subquery
+	sql := "? AS qh_key"
+	vars := []interface{}{column}
+	outer := make([]clause.OrderByColumn, len(order.Columns))
+	for i, o := range order.Columns {
+		o = splitOrderDirection(o)
+
+		// The key subquery has the joins, so the table's own columns are
+		// qualified
+		if !o.Column.Raw && o.Column.Table == "" && !strings.Contains(o.Column.Name, ".") {
+			o.Column.Table = clause.CurrentTable
+		}
+
+		name := "qh_order_" + strconv.Itoa(i)
+		aggregate := "MIN"
+		if o.Desc {
+			aggregate = "MAX"
+		}
+
+		sql += ", " + aggregate + "(?) AS " + name
+		vars = append(vars, o.Column)
+		outer[i] = clause.OrderByColumn{Column: clause.Column{Table: dedupeKeys, Name: name}, Desc: o.Desc}
+	}
+
+	keys = keys.Clauses(
+		clause.Select{Expression: clause.Expr{SQL: sql, Vars: vars}},
+		clause.GroupBy{Columns: []clause.Column{column}},
+	)
+
+	// Only the table's own columns are returned, not the sort keys
+	if len(fields) > 0 {
+		rows = rows.Select(fields)
+	} else {
+		rows = rows.Clauses(clause.Select{Expression: clause.Expr{SQL: "?.*", Vars: []interface{}{clause.Table{Name: clause.CurrentTable}}}})
+	}
+
+	rows = rows.Joins("JOIN (?) "+dedupeKeys+" ON ? = ?", keys, clause.Column{Table: dedupeKeys, Name: "qh_key"}, column)
+	rows = rows.Order(clause.OrderBy{Columns: outer})
+
+	return rows, nil
+}
+
+// splitOrderDirection moves a direction written into a raw column, as in
+// Order("name DESC"), to the column's Desc.
+func splitOrderDirection(o clause.OrderByColumn) clause.OrderByColumn {
+
+	if !o.Column.Raw {
+		return o
+	}
+
+	name := strings.TrimSpace(o.Column.Name)
+	upper := strings.ToUpper(name)
+	switch {
+	case strings.HasSuffix(upper, " DESC"):
+		o.Column.Name = strings.TrimSpace(name[:len(name)-len(" DESC")])
+		o.Desc = true
+	case strings.HasSuffix(upper, " ASC"):
+		o.Column.Name = strings.TrimSpace(name[:len(name)-len(" ASC")])
+	}
+
+	return o
+}
@@ -0,0 +1,67 @@
+package queryhelper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func TestDeduplicateRelationOrder(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters:          map[string][]string{"tags.name": {"="}},
+		AllowedOrderBy:          []string{"company.name", "name"},
+		Relations:               map[string]*queryhelper.Relation{"company": {Table: "companies", ForeignKey: "company_id"}},
+		DeduplicateOnPrimaryKey: true,
+	}
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithFilter("tags.name", "=", "go"),
+		queryhelper.WithOrderBy([]string{"-company.name", "name"}),
+	)
+
+	db := queryhelpertest.DryRunDB(t, "sqlite")
+	query, err := dq.Apply(settings, db.Model(&testUser{}).Joins("JOIN tags ON tags.user_id = users.id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+
+	// The relation is joined where the ordering is computed, and the outer
+	// query sorts by the keys the subquery returns
+	want := `SELECT "users".* FROM "users" JOIN (SELECT "users"."id" AS qh_key, MAX("company"."name") AS qh_order_0, MIN("users"."name") AS qh_order_1 ` +
+		`FROM "users" JOIN tags ON tags.user_id = users.id LEFT JOIN "companies" "company" ON "company"."id" = "users"."company_id" ` +
+		`WHERE "tags"."name" = 'go' GROUP BY "users"."id") qh_keys ON "qh_keys"."qh_key" = "users"."id" ` +
+		`ORDER BY "qh_keys"."qh_order_0" DESC,"qh_keys"."qh_order_1" LIMIT 10`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDeduplicateUnordered(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters:          map[string][]string{"tags.name": {"="}},
+		DeduplicateOnPrimaryKey: true,
+	}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithFilter("tags.name", "=", "go"))
+
+	db := queryhelpertest.DryRunDB(t, "sqlite")
+	query, err := dq.Apply(settings, db.Model(&testUser{}).Joins("JOIN tags ON tags.user_id = users.id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+	want := `WHERE "users"."id" IN (SELECT DISTINCT "users"."id" FROM "users" JOIN tags ON tags.user_id = users.id WHERE "tags"."name" = 'go')`
+	if !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", got, want)
+	}
+	if strings.Contains(got, "qh_keys") {
+		t.Errorf("unordered query joins the keys:\n%s", got)
+	}
+}
@@ -0,0 +1,251 @@
+package queryhelper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DescribeOptions controls the wording of QueryHelperInfo.Describe.
+type DescribeOptions struct {
+	FieldNames map[string]string // field -> display name, the field itself when missing
+	Operators  map[string]string // operator -> phrase, overriding the defaults, e.g. "IN" -> "is any of"
+	OrderNames map[string]string // order entry -> phrase, e.g. "-created_at" -> "newest first"
+	TimeLayout string            // layout of time values, "2006-01-02" when empty
+
+	// Formatters phrase a field's filters themselves, e.g. a created_at >=
+	// filter as "created in the last 7 days"
+	Formatters map[string]func(filter FilterCondition) string
+}
+
+var describeOperators = map[string]string{
+	"=":            "is",
+	"!=":           "is not",
+	">":            "is greater than",
+	"<":            "is less than",
+	">=":           "is at least",
+	"<=":           "is at most",
+	"BETWEEN":      "is between",
+	"IN":           "is one of",
+	"NOT IN":       "is not one of",
+	"LIKE":         "matches",
+	"IS NULL":      "is not set",
+	"IS NOT NULL":  "is set",
+	"EMPTY":        "is empty",
+	"NOT EMPTY":    "is not empty",
+	"NULL_SAFE_EQ": "is",
+	"REL_ANY":      "has any of",
+	"REL_ALL":      "has all of",
+}
+
+// Describe phrases the applied conditions and pagination as a sentence for
+// people, such as
+//
+//	Status is one of [open, pending], created in the last 7 days, sorted by newest first, page 2 of 14
+//
+// Fields are named as the client named them. Describe needs Info taken
+// after Apply; before it, only the pagination is described.
+func (info *QueryHelperInfo) Describe(opts DescribeOptions) string {
+
+	var parts []string
+
+	if applied := info.Applied; applied != nil {
+		if applied.SearchText != "" {
+			part := fmt.Sprintf("matching %q", applied.SearchText)
+			if len(applied.SearchFields) > 0 {
+				part += " in " + strings.Join(opts.fieldNames(applied.SearchFields), ", ")
+			}
+			parts = append(parts, part)
+		}
+
+		for _, filter := range applied.Filters {
+			parts = append(parts, opts.filter(filter))
+		}
+
+		for _, group := range applied.FilterGroups {
+			parts = append(parts, opts.group(group))
+		}
+
+		includes := make([]string, 0, len(applied.IncludeFilters))
+		for name := range applied.IncludeFilters {
+			includes = append(includes, name)
+		}
+		sort.Strings(includes)
+
+		for _, name := range includes {
+			filters := make([]string, len(applied.IncludeFilters[name]))
+			for i, filter := range applied.IncludeFilters[name] {
+				filters[i] = opts.filter(filter)
+			}
+			parts = append(parts, "with "+opts.fieldName(name)+" where "+strings.Join(filters, " and "))
+		}
+
+		if len(applied.OrderBy) > 0 {
+			orders := make([]string, len(applied.OrderBy))
+			for i, order := range applied.OrderBy {
+				orders[i] = opts.order(order)
+			}
+			parts = append(parts, "sorted by "+strings.Join(orders, ", then "))
+		}
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, "all rows")
+	}
+
+	// TotalPages is 0 until the rows are counted
+	if p := info.Pagination; p != nil && p.Page > 0 {
+		if p.TotalPages > 0 {
+			parts = append(parts, fmt.Sprintf("page %d of %d", p.Page, p.TotalPages))
+		} else {
+			parts = append(parts, fmt.Sprintf("page %d", p.Page))
+		}
+	}
+
+	sentence := strings.Join(parts, ", ")
+	r, size := utf8.DecodeRuneInString(sentence)
+
+	return string(unicode.ToUpper(r)) + sentence[size:]
+}
+
+func (opts *DescribeOptions) fieldName(field string) string {
+
+	if name, ok := opts.FieldNames[field]; ok {
+		return name
+	}
+
+	return field
+}
+
+func (opts *DescribeOptions) fieldNames(fields []string) []string {
+
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = opts.fieldName(field)
+	}
+
+	return names
+}
+
+func (opts *DescribeOptions) filter(filter FilterCondition) string {
+
+	if format, ok := opts.Formatters[filter.Field]; ok {
+		return format(filter)
+	}
+
+	op := strings.ToUpper(filter.Operator)
+
+	phrase, ok := opts.Operators[op]
+	if !ok {
+		if phrase, ok = describeOperators[op]; !ok {
+			phrase = strings.ToLower(filter.Operator)
+		}
+	}
+
+	switch op {
+	case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+		return opts.fieldName(filter.Field) + " " + phrase
+	case "BETWEEN":
+		if bounds := listValues(filter.Value); len(bounds) == 2 {
+			return fmt.Sprintf("%s %s %s and %s", opts.fieldName(filter.Field), phrase, opts.value(bounds[0]), opts.value(bounds[1]))
+		}
+	}
+
+	return opts.fieldName(filter.Field) + " " + phrase + " " + opts.value(filter.Value)
+}
+
+func (opts *DescribeOptions) group(group FilterGroup) string {
+
+	parts := make([]string, 0, len(group.Filters)+len(group.Groups))
+	for _, filter := range group.Filters {
+		parts = append(parts, opts.filter(filter))
+	}
+	for _, g := range group.Groups {
+		parts = append(parts, opts.group(g))
+	}
+
+	logic := " and "
+	if strings.EqualFold(group.Logic, LogicOr) {
+		logic = " or "
+	}
+
+	described := "(" + strings.Join(parts, logic) + ")"
+	if group.Not {
+		described = "not " + described
+	}
+
+	return described
+}
+
+func (opts *DescribeOptions) order(order AppliedOrder) string {
+
+	entry := order.Field
+	if order.Direction == "desc" {
+		entry = "-" + entry
+	}
+
+	if name, ok := opts.OrderNames[entry]; ok {
+		return name
+	}
+
+	if order.Direction == "desc" {
+		return opts.fieldName(order.Field) + " descending"
+	}
+
+	return opts.fieldName(order.Field) + " ascending"
+}
+
+// value formats a filter value, lists as [a, b] and times per TimeLayout.
+func (opts *DescribeOptions) value(v interface{}) string {
+
+	if values := listValues(v); values != nil {
+		formatted := make([]string, len(values))
+		for i, value := range values {
+			formatted[i] = opts.value(value)
+		}
+		return "[" + strings.Join(formatted, ", ") + "]"
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "nothing"
+	case time.Time:
+		layout := opts.TimeLayout
+		if layout == "" {
+			layout = "2006-01-02"
+		}
+		return v.Format(layout)
+	case *time.Time:
+		if v == nil {
+			return "nothing"
+		}
+		return opts.value(*v)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// listValues returns the elements of a slice value, or nil for any other.
+func listValues(v interface{}) []interface{} {
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+
+	// Bytes are a value, not a list
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil
+	}
+
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+
+	return values
+}
@@ -0,0 +1,147 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testTask struct {
+	ID        uint
+	Title     string
+	Status    string
+	Priority  int
+	CreatedAt time.Time
+}
+
+func (testTask) TableName() string {
+	return "tasks"
+}
+
+func TestDescribe(t *testing.T) {
+
+	// Forty tasks created a day apart from March 2, 2024
+	db := queryhelpertest.NewTestDB(t, &testTask{})
+	var tasks []testTask
+	for i := 1; i <= 40; i++ {
+		tasks = append(tasks, testTask{
+			Title:     fmt.Sprintf("task %02d", i),
+			Status:    []string{"open", "pending", "closed"}[i%3],
+			Priority:  i % 5,
+			CreatedAt: time.Date(2024, 3, 1+i, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	queryhelpertest.Seed(t, db, &tasks)
+
+	settings := &queryhelper.QuerySettings{
+		ColumnAlias:    map[string]string{"state": "status"},
+		AllowedFilters: map[string][]string{"state": {"IN", "!=", "="}, "priority": {"BETWEEN", "="}, "created_at": {">=", "<"}},
+		AllowedSearch:  []string{"title"},
+		AllowedOrderBy: []string{"created_at", "title"},
+	}
+
+	since := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts []queryhelper.Option
+		desc queryhelper.DescribeOptions
+		want string
+	}{
+		{
+			name: "display names, phrasings and a formatter",
+			opts: []queryhelper.Option{
+				queryhelper.WithFilter("state", "in", []string{"open", "pending"}),
+				queryhelper.WithFilter("created_at", ">=", since),
+				queryhelper.WithOrderBy([]string{"-created_at"}),
+				queryhelper.WithPage(2),
+				queryhelper.WithPageSize(2),
+			},
+			desc: queryhelper.DescribeOptions{
+				FieldNames: map[string]string{"state": "Status"},
+				OrderNames: map[string]string{"-created_at": "newest first"},
+				Formatters: map[string]func(queryhelper.FilterCondition) string{
+					"created_at": func(queryhelper.FilterCondition) string { return "created in the last 7 days" },
+				},
+			},
+			want: "Status is one of [open, pending], created in the last 7 days, sorted by newest first, page 2 of 9",
+		},
+		{
+			name: "search, between and an alias",
+			opts: []queryhelper.Option{
+				queryhelper.WithSearchText("task 1"),
+				queryhelper.WithFilter("state", "!=", "closed"),
+				queryhelper.WithFilter("priority", "between", []int{1, 3}),
+				queryhelper.WithOrderBy([]string{"title"}),
+			},
+			desc: queryhelper.DescribeOptions{FieldNames: map[string]string{"title": "Title"}},
+			want: `Matching "task 1" in Title, state is not closed, priority is between 1 and 3, sorted by Title ascending, page 1 of 1`,
+		},
+		{
+			name: "groups, time layout and an operator override",
+			opts: []queryhelper.Option{
+				queryhelper.WithFilter("created_at", "<", since),
+				queryhelper.WithFilterGroups([]queryhelper.FilterGroup{{
+					Logic:   queryhelper.LogicOr,
+					Filters: []queryhelper.FilterCondition{{Field: "priority", Operator: "=", Value: 0}},
+					Groups:  []queryhelper.FilterGroup{{Logic: queryhelper.LogicAnd, Not: true, Filters: []queryhelper.FilterCondition{{Field: "state", Operator: "=", Value: "closed"}}}},
+				}}),
+				queryhelper.WithOrderBy([]string{"-title", "created_at"}),
+			},
+			desc: queryhelper.DescribeOptions{
+				Operators:  map[string]string{"<": "is before"},
+				TimeLayout: "Jan 2, 2006",
+			},
+			want: "Created_at is before Mar 15, 2024, (priority is 0 or not (state is closed)), sorted by title descending, then created_at ascending, page 1 of 1",
+		},
+		{
+			name: "dropped conditions are not described, the default order is",
+			opts: []queryhelper.Option{
+				queryhelper.WithFilter("status", "=", "open"),
+				queryhelper.WithOrderBy([]string{"priority"}),
+				queryhelper.WithPageSize(15),
+			},
+			want: "Sorted by created_at ascending, then title ascending, page 1 of 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(tt.opts...)
+			var rows []testTask
+			if err := dq.Execute(settings, db.Model(&testTask{}), &rows); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := dq.Info().Describe(tt.desc); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeEmpty(t *testing.T) {
+
+	tests := []struct {
+		name string
+		info queryhelper.QueryHelperInfo
+		want string
+	}{
+		{name: "nothing", want: "All rows"},
+		{name: "before Apply", info: *queryhelper.NewQueryHelper(queryhelper.WithPage(3)).Info(), want: "All rows, page 3"},
+		{name: "no conditions", info: queryhelper.QueryHelperInfo{Applied: &queryhelper.AppliedConditions{}, Pagination: &queryhelper.PaginationInfo{Page: 1, TotalPages: 1}}, want: "All rows, page 1 of 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if got := tt.info.Describe(queryhelper.DescribeOptions{}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
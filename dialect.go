@@ -0,0 +1,262 @@
+package queryhelper
+
+import (
+	"sync"
+	"time"
+)
+
+// Time formats for databases that store times as text or numbers
+const (
+	TimeFormatRFC3339 = "rfc3339" // UTC, second precision: 2006-01-02T15:04:05Z
+	TimeFormatUnix    = "unix"    // seconds since the epoch
+)
+
+// Dialect describes how generated clauses differ between databases.
+// Identifier quoting is left to the gorm dialector.
+type Dialect struct {
+	// EmptyStringIsNull is set when the database stores '' as NULL, as
+	// Oracle does.
+	EmptyStringIsNull bool
+
+	// LikeEscape is appended to LIKE comparisons so a backslash escapes
+	// wildcards, for databases without a default escape character.
+	LikeEscape string
+
+	// TrueValue and FalseValue replace boolean filter values for databases
+	// without a boolean type. Nil keeps the Go bool.
+	TrueValue  interface{}
+	FalseValue interface{}
+
+	// OrderedPagination is set when OFFSET/FETCH requires an ORDER BY.
+	OrderedPagination bool
+
+	// TimeFormat renders time values of comparisons in one canonical form,
+	// for databases that compare stored times as text. Empty binds
+	// time.Time as is.
+	TimeFormat string
+
+	// NoWindowFunctions is set for databases without ROW_NUMBER(), such as
+	// MySQL before 8.0, so per-parent include limits use a correlated
+	// subquery instead.
+	NoWindowFunctions bool
+
+	// NoRowLocking, NoShareLock and NoSkipLocked are set for databases
+	// without SELECT ... FOR UPDATE, FOR SHARE, or SKIP LOCKED and NOWAIT,
+	// so WithLocking fails with a LockingError instead.
+	NoRowLocking bool
+	NoShareLock  bool
+	NoSkipLocked bool
+
+	// NullSafeEqual is the operator NULL_SAFE_EQ filters compare with, one
+	// under which NULL equals NULL. Empty emulates it with = and IS NULL.
+	NullSafeEqual string
+
+	// TableSample is set for databases sampling tables with TABLESAMPLE
+	// SYSTEM and BERNOULLI, for WithSampling.
+	TableSample bool
+
+	// Random is an expression for a random number from 0 up to 1, drawn per
+	// row, which WithSampling keeps rows by where TableSample is unset. Empty
+	// samples by primary key modulo instead.
+	Random string
+
+	// CastTypes spells the Cast* types for ColumnCasts, overriding the
+	// built-in spellings for the dialect's name.
+	CastTypes map[string]string
+}
+
+// Portable types for QuerySettings.ColumnCasts. Other values are used as
+// SQL types verbatim.
+const (
+	CastInteger = "integer"
+	CastDecimal = "decimal"
+	CastFloat   = "float"
+	CastText    = "text"
+)
+
+var (
+	defaultCastTypes = map[string]string{
+		CastInteger: "BIGINT",
+		CastDecimal: "NUMERIC",
+		CastFloat:   "DOUBLE PRECISION",
+		CastText:    "VARCHAR",
+	}
+	builtinCastTypes = map[string]map[string]string{
+		// MySQL casts to a small set of types, DECIMAL defaults to no scale
+		"mysql": {
+			CastInteger: "SIGNED",
+			CastDecimal: "DECIMAL(65,30)",
+			CastFloat:   "DOUBLE",
+			CastText:    "CHAR",
+		},
+		"sqlserver": {
+			CastDecimal: "DECIMAL(38,10)",
+			CastFloat:   "FLOAT",
+			CastText:    "NVARCHAR(MAX)",
+		},
+		"sqlite": {
+			CastInteger: "INTEGER",
+			CastFloat:   "REAL",
+			CastText:    "TEXT",
+		},
+		"oracle": {
+			CastInteger: "NUMBER(19)",
+			CastDecimal: "NUMBER",
+			CastFloat:   "BINARY_DOUBLE",
+			CastText:    "VARCHAR2(4000)",
+		},
+		"clickhouse": {
+			CastInteger: "Int64",
+			CastDecimal: "Decimal(38,10)",
+			CastFloat:   "Float64",
+			CastText:    "String",
+		},
+	}
+)
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]*Dialect{
+		"postgres": {
+			NullSafeEqual: "IS NOT DISTINCT FROM",
+			TableSample:   true,
+			Random:        "random()",
+		},
+		"mysql": {
+			NullSafeEqual: "<=>",
+			Random:        "RAND()",
+		},
+		// SQL Server locks with table hints, not FOR UPDATE
+		"sqlserver": {
+			LikeEscape:        ` ESCAPE '\'`,
+			OrderedPagination: true,
+			NoRowLocking:      true,
+			Random:            "((CHECKSUM(NEWID()) % 1000000 + 1000000) % 1000000) / 1000000.0",
+		},
+		"sqlite": {
+			LikeEscape:   ` ESCAPE '\'`,
+			NoRowLocking: true,
+			Random:       "((RANDOM() % 1000000 + 1000000) % 1000000) / 1000000.0",
+		},
+		// ClickHouse escapes LIKE wildcards with a backslash by default
+		"clickhouse": {
+			NoRowLocking: true,
+			Random:       "rand() / 4294967296",
+		},
+		"oracle": {
+			EmptyStringIsNull: true,
+			LikeEscape:        ` ESCAPE '\'`,
+			TrueValue:         1,
+			FalseValue:        0,
+			OrderedPagination: true,
+			NoShareLock:       true,
+			Random:            "DBMS_RANDOM.VALUE",
+		},
+	}
+	defaultDialect = &Dialect{}
+)
+
+// RegisterDialect sets the dialect used for a gorm dialector name, so custom
+// drivers can be supported or built-in dialects overridden.
+func RegisterDialect(name string, dialect *Dialect) {
+
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	dialects[name] = dialect
+}
+
+func lookupDialect(name string) *Dialect {
+
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+
+	if d, ok := dialects[name]; ok && d != nil {
+		return d
+	}
+
+	return defaultDialect
+}
+
+// boolValue maps boolean values, including inside lists, to the dialect's
+// literals.
+func (d *Dialect) boolValue(value interface{}) interface{} {
+
+	if d.TrueValue == nil && d.FalseValue == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return d.TrueValue
+		}
+		return d.FalseValue
+	case []interface{}:
+		vals := make([]interface{}, len(v))
+		for i, item := range v {
+			vals[i] = d.boolValue(item)
+		}
+		return vals
+	}
+
+	return value
+}
+
+// timeValue renders time values, including inside lists, in the dialect's
+// canonical format.
+func (d *Dialect) timeValue(value interface{}) interface{} {
+
+	if d.TimeFormat == "" {
+		return value
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		if d.TimeFormat == TimeFormatUnix {
+			return v.Unix()
+		}
+		return v.UTC().Format("2006-01-02T15:04:05Z")
+	case []interface{}:
+		vals := make([]interface{}, len(v))
+		for i, item := range v {
+			vals[i] = d.timeValue(item)
+		}
+		return vals
+	}
+
+	return value
+}
+
+// escapeLike makes LIKE wildcards in s match literally.
+func escapeLike(s string) string {
+
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\', '%', '_':
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+
+	return string(b)
+}
+
+// castType spells a ColumnCasts type for the dialect named name.
+func (d *Dialect) castType(name string, typ string) string {
+
+	if t, ok := d.CastTypes[typ]; ok {
+		return t
+	}
+
+	if t, ok := builtinCastTypes[name][typ]; ok {
+		return t
+	}
+
+	if t, ok := defaultCastTypes[typ]; ok {
+		return t
+	}
+
+	return typ
+}
@@ -0,0 +1,276 @@
+package queryhelper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+type testDoc struct {
+	ID        uint
+	Title     string
+	Published bool
+}
+
+func (testDoc) TableName() string {
+	return "docs"
+}
+
+// stubDialector renders like a database driver without one: it quotes with
+// Open and Close, and with FetchNext paginates with OFFSET ... FETCH NEXT
+// as SQL Server and Oracle do. Like those, it orders nothing itself.
+type stubDialector struct {
+	queryhelpertest.Dialector
+	Open, Close byte
+	FetchNext   bool
+}
+
+func (d stubDialector) Initialize(db *gorm.DB) error {
+
+	if err := d.Dialector.Initialize(db); err != nil {
+		return err
+	}
+
+	if d.FetchNext {
+		db.ClauseBuilders["LIMIT"] = func(c clause.Clause, builder clause.Builder) {
+			limit, ok := c.Expression.(clause.Limit)
+			if !ok {
+				return
+			}
+			builder.WriteString("OFFSET ")
+			builder.AddVar(builder, limit.Offset)
+			builder.WriteString(" ROWS")
+			if limit.Limit != nil {
+				builder.WriteString(" FETCH NEXT ")
+				builder.AddVar(builder, *limit.Limit)
+				builder.WriteString(" ROWS ONLY")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d stubDialector) QuoteTo(writer clause.Writer, str string) {
+
+	for i, part := range strings.Split(str, ".") {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		writer.WriteByte(d.Open)
+		writer.WriteString(part)
+		writer.WriteByte(d.Close)
+	}
+}
+
+var (
+	sqlServerDialector  = stubDialector{Dialector: queryhelpertest.Dialector{Dialect: "sqlserver"}, Open: '[', Close: ']', FetchNext: true}
+	oracleDialector     = stubDialector{Dialector: queryhelpertest.Dialector{Dialect: "oracle"}, Open: '"', Close: '"', FetchNext: true}
+	clickHouseDialector = stubDialector{Dialector: queryhelpertest.Dialector{Dialect: "clickhouse"}, Open: '`', Close: '`'}
+)
+
+func stubDB(t *testing.T, dialector gorm.Dialector) *gorm.DB {
+
+	t.Helper()
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func dialectSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedSearch: []string{"title"},
+		AllowedFilters: map[string][]string{
+			"title":     {"=", "LIKE", "EMPTY", "NOT EMPTY"},
+			"published": {"="},
+		},
+		CaseInsensitiveFields: []string{"title"},
+		AllowedCountModes:     []string{queryhelper.CountModeExact, queryhelper.CountModeNone},
+	}
+}
+
+// renderDialect applies opts to docs on dialector and returns the count and
+// page queries.
+func renderDialect(t *testing.T, dialector gorm.Dialector, settings *queryhelper.QuerySettings, opts ...queryhelper.Option) (string, string) {
+
+	t.Helper()
+
+	db := stubDB(t, dialector)
+	statements := recordQueries(t, db)
+
+	query, err := queryhelper.NewQueryHelper(opts...).Apply(settings, db.Model(&testDoc{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := ""
+	if len(*statements) > 0 {
+		count = (*statements)[0]
+	}
+
+	return count, findSQL(t, query)
+}
+
+func TestSQLServerClauses(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		sortable bool
+		opts     []queryhelper.Option
+		want     string
+	}{
+		{
+			name: "filters",
+			opts: []queryhelper.Option{
+				queryhelper.WithEqual("title", "Guide"),
+				queryhelper.WithFilter("title", "LIKE", "intro%"),
+			},
+			want: `SELECT * FROM [docs] WHERE LOWER([title]) = LOWER('guide') AND LOWER([title]) LIKE LOWER('intro%') ESCAPE '\' ORDER BY [docs].[id] OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+		{
+			name: "search",
+			opts: []queryhelper.Option{queryhelper.WithSearchText("50%")},
+			want: `SELECT * FROM [docs] WHERE LOWER([title]) LIKE LOWER('%50\%%') ESCAPE '\' ORDER BY [docs].[id] OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+		{
+			name:     "ordered",
+			sortable: true,
+			opts:     []queryhelper.Option{queryhelper.WithOrderBy([]string{"-title"}), queryhelper.WithPage(3)},
+			want:     `SELECT * FROM [docs] ORDER BY [title] DESC OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := dialectSettings()
+			if tt.sortable {
+				settings.AllowedOrderBy = []string{"title"}
+			}
+			_, got := renderDialect(t, sqlServerDialector, settings, tt.opts...)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			if strings.Contains(got, "ILIKE") || strings.Contains(got, "LIMIT") {
+				t.Errorf("not T-SQL:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestOracleClauses(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		sortable bool
+		opts     []queryhelper.Option
+		want     string
+	}{
+		{
+			name: "empty is null",
+			opts: []queryhelper.Option{queryhelper.WithFilter("title", "EMPTY", nil)},
+			want: `SELECT * FROM "docs" WHERE "title" IS NULL ORDER BY "docs"."id" OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+		{
+			name: "not empty",
+			opts: []queryhelper.Option{queryhelper.WithFilter("title", "NOT EMPTY", nil)},
+			want: `SELECT * FROM "docs" WHERE "title" IS NOT NULL ORDER BY "docs"."id" OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+		{
+			name: "boolean literals",
+			opts: []queryhelper.Option{queryhelper.WithEqual("published", true)},
+			want: `SELECT * FROM "docs" WHERE "published" = 1 ORDER BY "docs"."id" OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+		{
+			name: "like escape",
+			opts: []queryhelper.Option{queryhelper.WithSearchText("a_b")},
+			want: `SELECT * FROM "docs" WHERE LOWER("title") LIKE LOWER('%a\_b%') ESCAPE '\' ORDER BY "docs"."id" OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+		{
+			name:     "ordered",
+			sortable: true,
+			opts:     []queryhelper.Option{queryhelper.WithOrderBy([]string{"title"}), queryhelper.WithPage(2)},
+			want:     `SELECT * FROM "docs" ORDER BY "title" OFFSET 10 ROWS FETCH NEXT 10 ROWS ONLY`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := dialectSettings()
+			if tt.sortable {
+				settings.AllowedOrderBy = []string{"title"}
+			}
+			_, got := renderDialect(t, oracleDialector, settings, tt.opts...)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptyOperatorsElsewhere(t *testing.T) {
+
+	settings := dialectSettings()
+	_, got := renderDialect(t, clickHouseDialector, settings, queryhelper.WithFilter("title", "EMPTY", nil), queryhelper.WithFilter("title", "NOT EMPTY", nil))
+
+	want := "SELECT * FROM `docs` WHERE (`title` IS NULL OR `title` = '') AND (`title` IS NOT NULL AND `title` <> '') LIMIT 10"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegisterDialect(t *testing.T) {
+
+	queryhelper.RegisterDialect("godror", &queryhelper.Dialect{
+		EmptyStringIsNull: true,
+		TrueValue:         "Y",
+		FalseValue:        "N",
+		OrderedPagination: true,
+	})
+
+	dialector := oracleDialector
+	dialector.Dialect = "godror"
+
+	settings := dialectSettings()
+	_, got := renderDialect(t, dialector, settings, queryhelper.WithFilter("title", "EMPTY", nil), queryhelper.WithEqual("published", false))
+
+	want := `SELECT * FROM "docs" WHERE "title" IS NULL AND "published" = 'N' ORDER BY "docs"."id" OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestClickHouseClauses(t *testing.T) {
+
+	settings := dialectSettings()
+	settings.CountTable = "docs_distributed"
+
+	count, got := renderDialect(t, clickHouseDialector, settings, queryhelper.WithFilter("title", "LIKE", "a%"), queryhelper.WithPage(2))
+
+	wantCount := "SELECT count(*) FROM `docs_distributed` WHERE LOWER(`title`) LIKE LOWER('a%')"
+	if count != wantCount {
+		t.Errorf("count\n%s\nwant\n%s", count, wantCount)
+	}
+
+	want := "SELECT * FROM `docs` WHERE LOWER(`title`) LIKE LOWER('a%') LIMIT 10 OFFSET 10"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(got, "ILIKE") || strings.Contains(got, "ESCAPE") {
+		t.Errorf("ClickHouse needs neither ILIKE nor ESCAPE:\n%s", got)
+	}
+}
@@ -0,0 +1,425 @@
+package queryhelper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DSLMaxLength = 4096
+	DSLMaxDepth  = 16
+)
+
+// DSLError reports a query DSL problem at a byte offset of the input.
+type DSLError struct {
+	Pos     int
+	Message string
+}
+
+func (e *DSLError) Error() string {
+	return fmt.Sprintf("query dsl: %s at position %d", e.Message, e.Pos)
+}
+
+type dslTokenKind int
+
+const (
+	dslEOF dslTokenKind = iota
+	dslIdent
+	dslString
+	dslNumber
+	dslOperator
+	dslLParen
+	dslRParen
+	dslComma
+)
+
+type dslToken struct {
+	kind dslTokenKind
+	text string
+	pos  int
+}
+
+func (t dslToken) describe() string {
+	switch t.kind {
+	case dslEOF:
+		return "end of input"
+	case dslString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func lexDSL(s string) ([]dslToken, error) {
+
+	tokens := make([]dslToken, 0)
+
+	i := 0
+	for i < len(s) {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, dslToken{kind: dslLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, dslToken{kind: dslRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, dslToken{kind: dslComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			i++
+
+			var sb strings.Builder
+			closed := false
+			for i < len(s) {
+				if s[i] == '\\' && i+1 < len(s) {
+					sb.WriteByte(s[i+1])
+					i += 2
+					continue
+				}
+				if s[i] == c {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+
+			if !closed {
+				return nil, &DSLError{Pos: start, Message: "unterminated string"}
+			}
+
+			tokens = append(tokens, dslToken{kind: dslString, text: sb.String(), pos: start})
+		case c == '=' || c == '~':
+			tokens = append(tokens, dslToken{kind: dslOperator, text: string(c), pos: i})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			start := i
+			i++
+			if i < len(s) && s[i] == '=' {
+				i++
+			}
+
+			op := s[start:i]
+			if op == "!" {
+				return nil, &DSLError{Pos: start, Message: "unexpected \"!\", expected \"!=\""}
+			}
+
+			tokens = append(tokens, dslToken{kind: dslOperator, text: op, pos: start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(s) && (s[i] == '.' || (s[i] >= '0' && s[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, dslToken{kind: dslNumber, text: s[start:i], pos: start})
+		case isDSLIdentChar(c):
+			start := i
+			for i < len(s) && isDSLIdentChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, dslToken{kind: dslIdent, text: s[start:i], pos: start})
+		default:
+			return nil, &DSLError{Pos: i, Message: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+
+	tokens = append(tokens, dslToken{kind: dslEOF, pos: len(s)})
+
+	return tokens, nil
+}
+
+func isDSLIdentChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+type dslParser struct {
+	tokens   []dslToken
+	pos      int
+	depth    int
+	settings *QuerySettings
+}
+
+// ParseQueryDSL parses a readable filter expression such as
+// `status = "active" AND (price < 100 OR stock = 0) AND name ~ "chair"`.
+// AND binds tighter than OR, ~ matches values containing the string, and
+// `= null` / `!= null` test for NULL. Fields and operators are checked
+// against the settings while parsing.
+func ParseQueryDSL(s string, settings *QuerySettings) (*QueryConditions, error) {
+
+	if settings == nil {
+		settings = DefaultQuerySettings
+	}
+
+	if len(s) > DSLMaxLength {
+		return nil, &DSLError{Pos: DSLMaxLength, Message: fmt.Sprintf("expression longer than %d bytes", DSLMaxLength)}
+	}
+
+	conditions := &QueryConditions{}
+
+	tokens, err := lexDSL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokens[0].kind == dslEOF {
+		return conditions, nil
+	}
+
+	p := &dslParser{
+		tokens:   tokens,
+		settings: settings,
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != dslEOF {
+		return nil, p.unexpected(tok, "AND", "OR")
+	}
+
+	root.apply(conditions)
+
+	return conditions, nil
+}
+
+func (p *dslParser) peek() dslToken {
+	return p.tokens[p.pos]
+}
+
+func (p *dslParser) next() dslToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != dslEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *dslParser) isKeyword(tok dslToken, keyword string) bool {
+	return tok.kind == dslIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *dslParser) unexpected(tok dslToken, expected ...string) error {
+	return &DSLError{
+		Pos:     tok.pos,
+		Message: fmt.Sprintf("unexpected %s, expected %s", tok.describe(), strings.Join(expected, " or ")),
+	}
+}
+
+func (p *dslParser) parseOr() (*filterNode, error) {
+	return p.parseList(LogicOr, p.parseAnd)
+}
+
+func (p *dslParser) parseAnd() (*filterNode, error) {
+	return p.parseList(LogicAnd, p.parsePrimary)
+}
+
+func (p *dslParser) parseList(logic string, next func() (*filterNode, error)) (*filterNode, error) {
+
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &filterNode{logic: logic}
+	n.add(first)
+
+	for p.isKeyword(p.peek(), logic) {
+		p.next()
+
+		child, err := next()
+		if err != nil {
+			return nil, err
+		}
+		n.add(child)
+	}
+
+	if len(n.children) == 1 {
+		return n.children[0], nil
+	}
+
+	return n, nil
+}
+
+func (p *dslParser) parsePrimary() (*filterNode, error) {
+
+	tok := p.peek()
+
+	if tok.kind == dslLParen {
+		p.next()
+
+		p.depth++
+		if p.depth > DSLMaxDepth {
+			return nil, &DSLError{Pos: tok.pos, Message: fmt.Sprintf("nesting deeper than %d levels", DSLMaxDepth)}
+		}
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != dslRParen {
+			return nil, p.unexpected(closing, "\")\"", "AND", "OR")
+		}
+		p.depth--
+
+		return n, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *dslParser) parseComparison() (*filterNode, error) {
+
+	fieldTok := p.next()
+	if fieldTok.kind != dslIdent {
+		return nil, p.unexpected(fieldTok, "field name", "\"(\"")
+	}
+
+	// Operator
+	opTok := p.next()
+	var operator string
+	switch {
+	case opTok.kind == dslOperator:
+		operator = opTok.text
+	case p.isKeyword(opTok, "IN"):
+		operator = "IN"
+	case p.isKeyword(opTok, "NOT") && p.isKeyword(p.peek(), "IN"):
+		p.next()
+		operator = "NOT IN"
+	default:
+		return nil, p.unexpected(opTok, "=", "!=", "<", "<=", ">", ">=", "~", "IN", "NOT IN")
+	}
+
+	// Value
+	var value interface{}
+	if operator == "IN" || operator == "NOT IN" {
+		vals, err := p.parseValueList()
+		if err != nil {
+			return nil, err
+		}
+		value = vals
+	} else {
+		valTok := p.peek()
+		v, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case v == nil && operator == "=":
+			operator = "IS NULL"
+		case v == nil && operator == "!=":
+			operator = "IS NOT NULL"
+		case v == nil:
+			return nil, &DSLError{Pos: valTok.pos, Message: fmt.Sprintf("null cannot be used with %s", operator)}
+		case operator == "~":
+			s, ok := v.(string)
+			if !ok {
+				return nil, &DSLError{Pos: valTok.pos, Message: "~ requires a string value"}
+			}
+			// Wildcards in the string match literally
+			operator = "LIKE"
+			v = "%" + escapeLike(s) + "%"
+		}
+
+		value = v
+	}
+
+	field := fieldTok.text
+	if err := p.checkAllowed(field, operator, fieldTok.pos, opTok.pos); err != nil {
+		return nil, err
+	}
+
+	return &filterNode{
+		filter: &FilterCondition{
+			Field:    field,
+			Operator: operator,
+			Value:    value,
+		},
+	}, nil
+}
+
+func (p *dslParser) checkAllowed(field string, operator string, fieldPos int, opPos int) error {
+
+	allowedOps, ok := p.settings.AllowedFilters[field]
+	if !ok {
+		return &DSLError{Pos: fieldPos, Message: fmt.Sprintf("field %q is not filterable", field)}
+	}
+
+	for _, op := range allowedOps {
+		if op == operator {
+			return nil
+		}
+	}
+
+	return &DSLError{Pos: opPos, Message: fmt.Sprintf("operator %s is not allowed on field %q", operator, field)}
+}
+
+// parseValueList parses a parenthesized, comma separated list of literals.
+func (p *dslParser) parseValueList() ([]interface{}, error) {
+
+	if tok := p.next(); tok.kind != dslLParen {
+		return nil, p.unexpected(tok, "\"(\"")
+	}
+
+	vals := make([]interface{}, 0)
+	for {
+		v, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+
+		tok := p.next()
+		if tok.kind == dslRParen {
+			return vals, nil
+		}
+		if tok.kind != dslComma {
+			return nil, p.unexpected(tok, "\",\"", "\")\"")
+		}
+	}
+}
+
+func (p *dslParser) parseLiteral() (interface{}, error) {
+
+	tok := p.next()
+
+	switch tok.kind {
+	case dslString:
+		return tok.text, nil
+	case dslNumber:
+		if strings.Contains(tok.text, ".") {
+			f, err := strconv.ParseFloat(tok.text, 64)
+			if err != nil {
+				return nil, &DSLError{Pos: tok.pos, Message: fmt.Sprintf("invalid number %q", tok.text)}
+			}
+			return f, nil
+		}
+
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, &DSLError{Pos: tok.pos, Message: fmt.Sprintf("invalid number %q", tok.text)}
+		}
+		return n, nil
+	case dslIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+	}
+
+	return nil, p.unexpected(tok, "string", "number", "true", "false", "null")
+}
@@ -0,0 +1,211 @@
+package queryhelper_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func TestDSLContainsMatchesWildcardsLiterally(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"name": {"LIKE"}},
+	}
+
+	conditions, err := queryhelper.ParseQueryDSL(`name ~ "50%"`, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := queryhelper.NewConditionsHandle(settings)
+	if err := ch.UpdateConditions(conditions); err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := ch.Apply(queryhelpertest.DryRunDB(t, "sqlite").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+	want := `WHERE "name" LIKE '%50\%%' ESCAPE '\'`
+	if !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", got, want)
+	}
+}
+
+var dslSettings = &queryhelper.QuerySettings{
+	AllowedFilters: map[string][]string{
+		"status":       {"=", "!=", "IN", "NOT IN", "IS NULL", "IS NOT NULL"},
+		"price":        {"<", "<=", ">", ">="},
+		"stock":        {"="},
+		"name":         {"LIKE"},
+		"active":       {"="},
+		"company.name": {"="},
+	},
+}
+
+func TestParseQueryDSLGolden(t *testing.T) {
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: ``, want: `{}`},
+		{expr: `status = "active"`, want: `{"filters":[{"field":"status","operator":"=","value":"active"}]}`},
+		{expr: `status = 'active'`, want: `{"filters":[{"field":"status","operator":"=","value":"active"}]}`},
+		{expr: `status = "say \"hi\""`, want: `{"filters":[{"field":"status","operator":"=","value":"say \"hi\""}]}`},
+		{expr: `price < 100 AND price >= 9.5`, want: `{"filters":[{"field":"price","operator":"<","value":100},{"field":"price","operator":">=","value":9.5}]}`},
+		{expr: `price > -3`, want: `{"filters":[{"field":"price","operator":">","value":-3}]}`},
+		{expr: `active = true`, want: `{"filters":[{"field":"active","operator":"=","value":true}]}`},
+		{expr: `active = FALSE`, want: `{"filters":[{"field":"active","operator":"=","value":false}]}`},
+		{expr: `status = null`, want: `{"filters":[{"field":"status","operator":"IS NULL"}]}`},
+		{expr: `status != NULL`, want: `{"filters":[{"field":"status","operator":"IS NOT NULL"}]}`},
+		{expr: `status IN ("a", "b")`, want: `{"filters":[{"field":"status","operator":"IN","value":["a","b"]}]}`},
+		{expr: `status not in ('a')`, want: `{"filters":[{"field":"status","operator":"NOT IN","value":["a"]}]}`},
+		{expr: `company.name = "acme"`, want: `{"filters":[{"field":"company.name","operator":"=","value":"acme"}]}`},
+		{expr: `name ~ "chair"`, want: `{"filters":[{"field":"name","operator":"LIKE","value":"%chair%"}]}`},
+		{expr: `name ~ "100%"`, want: `{"filters":[{"field":"name","operator":"LIKE","value":"%100\\%%"}]}`},
+		{expr: `name ~ "a_b"`, want: `{"filters":[{"field":"name","operator":"LIKE","value":"%a\\_b%"}]}`},
+		{expr: `name ~ "back\\slash"`, want: `{"filters":[{"field":"name","operator":"LIKE","value":"%back\\\\slash%"}]}`},
+		{expr: `status = "a" OR status = "b"`, want: `{"filter_groups":[{"logic":"OR","filters":[{"field":"status","operator":"=","value":"a"},{"field":"status","operator":"=","value":"b"}]}]}`},
+		{expr: `status = "a" OR stock = 0 AND price < 1`, want: `{"filter_groups":[{"logic":"OR","filters":[{"field":"status","operator":"=","value":"a"}],` +
+			`"groups":[{"logic":"AND","filters":[{"field":"stock","operator":"=","value":0},{"field":"price","operator":"<","value":1}]}]}]}`},
+		{expr: `status = "active" AND (price < 100 OR stock = 0) AND name ~ "chair"`, want: `{"filters":[{"field":"status","operator":"=","value":"active"},{"field":"name","operator":"LIKE","value":"%chair%"}],` +
+			`"filter_groups":[{"logic":"OR","filters":[{"field":"price","operator":"<","value":100},{"field":"stock","operator":"=","value":0}]}]}`},
+		{expr: `((status = "a"))`, want: `{"filters":[{"field":"status","operator":"=","value":"a"}]}`},
+		{expr: `status = "a" and (price < 1 or (stock = 0 and active = true))`, want: `{"filters":[{"field":"status","operator":"=","value":"a"}],` +
+			`"filter_groups":[{"logic":"OR","filters":[{"field":"price","operator":"<","value":1}],` +
+			`"groups":[{"logic":"AND","filters":[{"field":"stock","operator":"=","value":0},{"field":"active","operator":"=","value":true}]}]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+
+			got, err := queryhelper.ParseQueryDSL(tt.expr, dslSettings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Compared as JSON, where the parsed int64 and float64 look alike
+			var want queryhelper.QueryConditions
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("got  %s\nwant %s", gotJSON, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryDSLErrors(t *testing.T) {
+
+	tests := []struct {
+		expr string
+		pos  int
+		want string
+	}{
+		{expr: `status = "active`, pos: 9, want: "unterminated string"},
+		{expr: `status ! "a"`, pos: 7, want: `unexpected "!", expected "!="`},
+		{expr: `status = "a" & stock = 0`, pos: 13, want: `unexpected character '&'`},
+		{expr: `status = "a" stock = 0`, pos: 13, want: `unexpected "stock", expected AND or OR`},
+		{expr: `status "a"`, pos: 7, want: `unexpected string "a", expected =`},
+		{expr: `= "a"`, pos: 0, want: `unexpected "=", expected field name or "("`},
+		{expr: `status =`, pos: 8, want: `unexpected end of input, expected string or number or true or false or null`},
+		{expr: `(status = "a"`, pos: 13, want: `unexpected end of input, expected ")" or AND or OR`},
+		{expr: `status = "a")`, pos: 12, want: `unexpected ")", expected AND or OR`},
+		{expr: `status IN "a"`, pos: 10, want: `unexpected string "a", expected "("`},
+		{expr: `status IN ("a" "b")`, pos: 15, want: `unexpected string "b", expected "," or ")"`},
+		{expr: `price < null`, pos: 8, want: "null cannot be used with <"},
+		{expr: `name ~ 3`, pos: 7, want: "~ requires a string value"},
+		{expr: `price < 1.2.3`, pos: 8, want: `invalid number "1.2.3"`},
+		{expr: `password = "x"`, pos: 0, want: `field "password" is not filterable`},
+		{expr: `stock > 3`, pos: 6, want: `operator > is not allowed on field "stock"`},
+		{expr: `name = "x"`, pos: 5, want: `operator = is not allowed on field "name"`},
+		{expr: `status = "a" AND`, pos: 16, want: `unexpected end of input, expected field name or "("`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+
+			_, err := queryhelper.ParseQueryDSL(tt.expr, dslSettings)
+
+			var derr *queryhelper.DSLError
+			if !errors.As(err, &derr) {
+				t.Fatalf("got %v, want a DSLError", err)
+			}
+			if derr.Pos != tt.pos || !strings.HasPrefix(derr.Message, tt.want) {
+				t.Errorf("got %q at %d, want %q at %d", derr.Message, derr.Pos, tt.want, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseQueryDSLLimits(t *testing.T) {
+
+	// Nested up to the limit parses, one level more does not
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + `stock = 0` + strings.Repeat(")", depth)
+	}
+	if _, err := queryhelper.ParseQueryDSL(nested(queryhelper.DSLMaxDepth), dslSettings); err != nil {
+		t.Errorf("depth %d: %v", queryhelper.DSLMaxDepth, err)
+	}
+
+	var derr *queryhelper.DSLError
+	_, err := queryhelper.ParseQueryDSL(nested(queryhelper.DSLMaxDepth+1), dslSettings)
+	if !errors.As(err, &derr) || derr.Pos != queryhelper.DSLMaxDepth || !strings.Contains(derr.Message, "nesting deeper") {
+		t.Errorf("depth %d: %v", queryhelper.DSLMaxDepth+1, err)
+	}
+
+	// Sibling groups do not add up to the depth
+	siblings := strings.TrimSuffix(strings.Repeat(`(stock = 0) AND `, queryhelper.DSLMaxDepth+1), " AND ")
+	if _, err := queryhelper.ParseQueryDSL(siblings, dslSettings); err != nil {
+		t.Errorf("siblings: %v", err)
+	}
+
+	long := `status = "` + strings.Repeat("a", queryhelper.DSLMaxLength) + `"`
+	_, err = queryhelper.ParseQueryDSL(long, dslSettings)
+	if !errors.As(err, &derr) || !strings.Contains(derr.Message, "longer than") {
+		t.Errorf("long expression: %v", err)
+	}
+}
+
+func TestParseQueryDSLSQL(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{
+			"status": {"="},
+			"price":  {"<"},
+			"stock":  {"="},
+			"name":   {"LIKE"},
+		},
+		FieldTypes: map[string]string{"price": queryhelper.FieldTypeFloat, "stock": queryhelper.FieldTypeInt},
+	}
+
+	conditions, err := queryhelper.ParseQueryDSL(`status = "active" AND (price < 100 OR stock = 0) AND name ~ "chair"`, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := queryhelper.NewConditionsHandle(settings)
+	if err := ch.UpdateConditions(conditions); err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := ch.Apply(queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `SELECT * FROM "users" WHERE "status" = 'active' AND "name" LIKE '%chair%' AND ("price" < 100 OR "stock" = 0)`
+	if got := findSQL(t, query); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
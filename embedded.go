@@ -0,0 +1,119 @@
+package queryhelper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// embeddedColumn maps a path to a field of an embedded struct, e.g.
+// "billing_address.city", to its prefixed column, e.g. billing_city, when
+// the struct is registered in EmbeddedPrefixes.
+func (s *QuerySettings) embeddedColumn(path string) (string, bool) {
+
+	i := strings.LastIndexByte(path, '.')
+	if i < 0 {
+		return path, false
+	}
+
+	prefix, ok := s.EmbeddedPrefixes[path[:i]]
+	if !ok {
+		return path, false
+	}
+
+	return prefix + path[i+1:], true
+}
+
+// columnNames maps every field the settings name to its real column: aliases
+// to their columns and embedded struct paths to their prefixed columns. An
+// alias may name an embedded path. It also returns the embedded paths keyed
+// by column.
+func columnNames(s *QuerySettings) (map[string]string, map[string]string) {
+
+	if len(s.EmbeddedPrefixes) == 0 {
+		return s.ColumnAlias, nil
+	}
+
+	columns := make(map[string]string, len(s.ColumnAlias))
+	embedded := make(map[string]string)
+
+	add := func(field string) {
+		if _, ok := columns[field]; ok {
+			return
+		}
+
+		target := field
+		if real, ok := s.ColumnAlias[field]; ok {
+			target = real
+		}
+
+		if column, ok := s.embeddedColumn(target); ok {
+			columns[field] = column
+			embedded[column] = target
+		} else if target != field {
+			columns[field] = target
+		}
+	}
+
+	for field := range s.ColumnAlias {
+		add(field)
+	}
+
+	for _, list := range [][]string{s.AllowedSearch, s.AllowedFields, s.DefaultSearchFields, s.CaseInsensitiveFields, s.SearchIDFields} {
+		for _, field := range list {
+			add(field)
+		}
+	}
+
+	for _, entry := range s.AllowedOrderBy {
+		_, field := splitOrderBy(entry)
+		add(field)
+	}
+
+	for _, fields := range []map[string][]string{s.AllowedFilters, s.HistogramFields} {
+		for field := range fields {
+			add(field)
+		}
+	}
+
+	for _, fields := range []map[string]string{s.SearchFieldModes, s.ColumnCasts} {
+		for field := range fields {
+			add(field)
+		}
+	}
+
+	return columns, embedded
+}
+
+// checkEmbedded rejects embedded struct paths the query's model has no
+// column for, e.g. because the prefix or the field name is misspelled.
+// Queries without a model are not checked.
+func (ch *ConditionsHandle) checkEmbedded(query *gorm.DB) error {
+
+	embedded := ch.Settings.lookups().embedded
+	if len(embedded) == 0 || query.Statement.Model == nil {
+		return nil
+	}
+
+	stmt := query.Session(&gorm.Session{NewDB: true}).Statement
+	if err := stmt.Parse(query.Statement.Model); err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(embedded))
+	for column := range embedded {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		if _, ok := stmt.Schema.FieldsByDBName[column]; !ok {
+			path := embedded[column]
+			return fmt.Errorf("unknown field %s: %s has no column %s", path, stmt.Schema.Name, column)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testAddress struct {
+	City    string
+	Country string
+}
+
+type testShipment struct {
+	ID       uint
+	Billing  testAddress `gorm:"embedded;embeddedPrefix:billing_"`
+	Shipping testAddress `gorm:"embedded;embeddedPrefix:shipping_"`
+}
+
+func (testShipment) TableName() string {
+	return "shipments"
+}
+
+func embeddedSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		EmbeddedPrefixes: map[string]string{
+			"billing_address":  "billing_",
+			"shipping_address": "shipping_",
+		},
+		AllowedFilters: map[string][]string{"billing_address.city": {"="}, "ship_to": {"!="}},
+		AllowedSearch:  []string{"shipping_address.city"},
+		AllowedOrderBy: []string{"shipping_address.country", "id"},
+		ColumnAlias:    map[string]string{"ship_to": "shipping_address.country"},
+	}
+}
+
+func TestEmbeddedPrefixes(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testShipment{})
+	queryhelpertest.Seed(t, db, &[]testShipment{
+		{ID: 1, Billing: testAddress{City: "Paris", Country: "FR"}, Shipping: testAddress{City: "Berlin", Country: "DE"}},
+		{ID: 2, Billing: testAddress{City: "Paris", Country: "FR"}, Shipping: testAddress{City: "Paris", Country: "FR"}},
+		{ID: 3, Billing: testAddress{City: "Lyon", Country: "FR"}, Shipping: testAddress{City: "Paris", Country: "FR"}},
+		{ID: 4, Billing: testAddress{City: "Paris", Country: "FR"}, Shipping: testAddress{City: "Austin", Country: "US"}},
+		{ID: 5, Billing: testAddress{City: "Paris", Country: "FR"}, Shipping: testAddress{City: "Vienna", Country: "AT"}},
+	})
+
+	tests := []struct {
+		name string
+		opts []queryhelper.Option
+		want []uint
+	}{
+		{
+			name: "filter the billing city, order by the shipping country",
+			opts: []queryhelper.Option{queryhelper.WithEqual("billing_address.city", "Paris"), queryhelper.WithOrderBy([]string{"-shipping_address.country"})},
+			want: []uint{4, 2, 1, 5},
+		},
+		{
+			name: "alias of a path",
+			opts: []queryhelper.Option{queryhelper.WithFilter("ship_to", "!=", "FR"), queryhelper.WithOrderBy([]string{"shipping_address.country"})},
+			want: []uint{5, 1, 4},
+		},
+		{
+			name: "search the shipping city",
+			opts: []queryhelper.Option{queryhelper.WithSearchText("pari"), queryhelper.WithOrderBy([]string{"id"})},
+			want: []uint{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var shipments []testShipment
+			if err := queryhelper.NewQueryHelper(tt.opts...).Execute(embeddedSettings(), db.Model(&testShipment{}), &shipments); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []uint
+			for _, s := range shipments {
+				got = append(got, s.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedPrefixesSQL(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("billing_address.city", "Paris"),
+		queryhelper.WithOrderBy([]string{"-shipping_address.country"}),
+		queryhelper.WithFilter("billing_address.zip", "=", "75001"),
+	)
+
+	queryhelpertest.AssertSQL(t, dq, embeddedSettings(), &testShipment{},
+		`WHERE "billing_city" = 'Paris' ORDER BY "shipping_country" DESC`)
+
+	info := dq.Info()
+	if info.Conditions.Filters[0].Field != "billing_address.city" || info.Conditions.OrderBy[0] != "-shipping_address.country" {
+		t.Errorf("info %+v, want the paths", info.Conditions)
+	}
+
+	// A path the settings do not allow is dropped
+	if len(info.Warnings) != 1 || info.Warnings[0].Field != "billing_address.zip" || info.Warnings[0].Code != queryhelper.WarningFilterNotAllowed {
+		t.Errorf("warnings %+v, want billing_address.zip not allowed", info.Warnings)
+	}
+}
+
+func TestEmbeddedPrefixesUnknownPath(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		prefixes map[string]string
+		filter   string
+		want     string
+	}{
+		{
+			name:     "misspelled field",
+			prefixes: map[string]string{"billing_address": "billing_"},
+			filter:   "billing_address.town",
+			want:     "unknown field billing_address.town",
+		},
+		{
+			name:     "misspelled prefix",
+			prefixes: map[string]string{"billing_address": "bill_"},
+			filter:   "billing_address.city",
+			want:     "unknown field billing_address.city",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := &queryhelper.QuerySettings{
+				EmbeddedPrefixes: tt.prefixes,
+				AllowedFilters:   map[string][]string{tt.filter: {"="}},
+			}
+
+			_, _, err := queryhelpertest.RenderSQL(t, "sqlite", queryhelper.NewQueryHelper(), settings, &testShipment{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package queryhelper
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encode writes the conditions as query parameters in the bracketed
+// convention read by ParseValues, for saved searches and shareable URLs.
+// Commas and backslashes in list items are escaped with a backslash. Values
+// that would not survive decoding, such as empty list items, are rejected.
+func (c *QueryConditions) Encode() (url.Values, error) {
+
+	values := url.Values{}
+
+	if c.SearchText != "" {
+		values.Set("search", c.SearchText)
+	}
+
+	for key, list := range map[string][]string{
+		"search_fields": c.SearchFields,
+		"order_by":      c.OrderBy,
+		"fields":        c.Fields,
+		"include":       c.Includes,
+		"summaries":     c.RequestedSummaries,
+	} {
+		joined, err := joinList(key, list)
+		if err != nil {
+			return nil, err
+		}
+
+		if joined != "" {
+			values.Set(key, joined)
+		}
+	}
+
+	if c.Locale != "" {
+		values.Set("locale", c.Locale)
+	}
+
+	if c.SortFactor != 0 {
+		values.Set("sort_factor", strconv.Itoa(c.SortFactor))
+	}
+
+	if err := encodeFilters(values, "", c.Filters); err != nil {
+		return nil, err
+	}
+
+	for i, group := range c.FilterGroups {
+		if err := encodeGroup(values, "group["+strconv.Itoa(i)+"]", group); err != nil {
+			return nil, err
+		}
+	}
+
+	if h := c.Histogram; h != nil {
+		values.Set("histogram[field]", h.Field)
+		values.Set("histogram[interval]", h.Interval)
+		if h.TimeZone != "" {
+			values.Set("histogram[time_zone]", h.TimeZone)
+		}
+		if h.FillEmpty {
+			values.Set("histogram[fill_empty]", "true")
+		}
+	}
+
+	// Filters on included relations are prefixed with the include name
+	for include, filters := range c.IncludeFilters {
+		if err := encodeFilters(values, include+".", filters); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// Decode replaces the conditions with those read from query parameters.
+func (c *QueryConditions) Decode(values url.Values) error {
+
+	conditions, _, err := ParseValues(values)
+	if err != nil {
+		return err
+	}
+
+	*c = *conditions
+
+	return nil
+}
+
+// Encode writes the pagination request as query parameters read by
+// ParseValues.
+func (p *PaginationRequest) Encode() (url.Values, error) {
+
+	values := url.Values{}
+
+	if p.Page != 0 {
+		values.Set("page", strconv.Itoa(p.Page))
+	}
+
+	if p.PageSize != 0 {
+		values.Set("page_size", strconv.Itoa(p.PageSize))
+	}
+
+	if p.Offset != nil {
+		values.Set("offset", strconv.Itoa(*p.Offset))
+	}
+
+	if p.Limit != nil {
+		values.Set("limit", strconv.Itoa(*p.Limit))
+	}
+
+	if p.CountMode != "" {
+		values.Set("count_mode", p.CountMode)
+	}
+
+	return values, nil
+}
+
+// Decode replaces the pagination request with one read from query
+// parameters.
+func (p *PaginationRequest) Decode(values url.Values) error {
+
+	_, pagination, err := ParseValues(values)
+	if err != nil {
+		return err
+	}
+
+	*p = *pagination
+
+	return nil
+}
+
+func encodeFilters(values url.Values, prefix string, filters []FilterCondition) error {
+
+	for _, filter := range filters {
+		key, err := filterKey(filter)
+		if err != nil {
+			return err
+		}
+		key = prefix + key
+
+		// Repeated keys would be merged or rejected by ParseValues
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("%w: duplicate filter %s", ErrInvalidConditions, key)
+		}
+
+		encoded, err := encodeFilterValue(filter)
+		if err != nil {
+			return err
+		}
+
+		values[key] = encoded
+	}
+
+	return nil
+}
+
+// encodeGroup writes a filter group under prefix, e.g. group[0]:
+//
+//	group[0][logic]=or&group[0][not]=true
+//	&group[0][filter][0][status]=active&group[0][filter][1][price][gte]=100
+//	&group[0][group][0][logic]=and&...
+//
+// Filters are numbered, so a group may hold the same field and operator
+// twice. The logic is always written, so an empty group is kept.
+func encodeGroup(values url.Values, prefix string, group FilterGroup) error {
+
+	values.Set(prefix+"[logic]", group.Logic)
+	if group.Not {
+		values.Set(prefix+"[not]", "true")
+	}
+
+	for i, filter := range group.Filters {
+		key, err := filterKey(filter)
+		if err != nil {
+			return err
+		}
+
+		encoded, err := encodeFilterValue(filter)
+		if err != nil {
+			return err
+		}
+
+		values[prefix+"[filter]["+strconv.Itoa(i)+"]"+strings.TrimPrefix(key, "filter")] = encoded
+	}
+
+	for i, sub := range group.Groups {
+		if err := encodeGroup(values, prefix+"[group]["+strconv.Itoa(i)+"]", sub); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func joinList(key string, list []string) (string, error) {
+
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, escapeListItem(item))
+		}
+	}
+
+	return strings.Join(items, ","), nil
+}
+
+// escapeListItem escapes the backslashes and commas of a list item, which
+// splitList reads back.
+func escapeListItem(item string) string {
+
+	item = strings.ReplaceAll(item, `\`, `\\`)
+
+	return strings.ReplaceAll(item, ",", `\,`)
+}
+
+func filterKey(filter FilterCondition) (string, error) {
+
+	if filter.Field == "" || strings.ContainsAny(filter.Field, "[]") {
+		return "", fmt.Errorf("%w: filter field %q cannot be encoded", ErrInvalidConditions, filter.Field)
+	}
+
+	if filter.Operator == "=" {
+		return "filter[" + filter.Field + "]", nil
+	}
+
+	if isContainsOperator(filter.Operator) {
+		return "filter[" + filter.Field + "][contains]", nil
+	}
+
+	token, ok := operatorToken(filter.Operator)
+	if !ok {
+		return "", fmt.Errorf("%w: filter %s: unknown operator %q", ErrInvalidConditions, filter.Field, filter.Operator)
+	}
+
+	return "filter[" + filter.Field + "][" + token + "]", nil
+}
+
+func encodeFilterValue(filter FilterCondition) ([]string, error) {
+
+	switch filter.Operator {
+	case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+		return []string{""}, nil
+	case "IN", "NOT IN", "BETWEEN", "REL_ANY", "REL_ALL":
+		items, ok := toInterfaceSlice(filter.Value)
+		if !ok {
+			return nil, fmt.Errorf("%w: filter %s %s requires a list value", ErrInvalidConditions, filter.Field, filter.Operator)
+		}
+
+		vals := make([]string, len(items))
+		for i, item := range items {
+			v, err := encodeScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("%w: filter %s: %v", ErrInvalidConditions, filter.Field, err)
+			}
+
+			if strings.TrimSpace(v) != v || v == "" {
+				return nil, fmt.Errorf("%w: filter %s: list item %q cannot be encoded", ErrInvalidConditions, filter.Field, v)
+			}
+			vals[i] = escapeListItem(v)
+		}
+
+		return []string{strings.Join(vals, ",")}, nil
+	}
+
+	v, err := encodeScalar(filter.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: filter %s: %v", ErrInvalidConditions, filter.Field, err)
+	}
+
+	return []string{v}, nil
+}
+
+func encodeScalar(value interface{}) (string, error) {
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("cannot encode value of type %T", value)
+}
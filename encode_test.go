@@ -0,0 +1,203 @@
+package queryhelper
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEncodeCommasAndGroups(t *testing.T) {
+
+	c := QueryConditions{
+		SearchFields: []string{"name", `odd,field`},
+		Filters: []FilterCondition{
+			{Field: "label", Operator: "IN", Value: []interface{}{"50,5%", `back\slash`, `a\,b`}},
+		},
+		FilterGroups: []FilterGroup{
+			{
+				Logic: "OR",
+				Filters: []FilterCondition{
+					{Field: "status", Operator: "=", Value: "new"},
+					{Field: "status", Operator: "=", Value: "open"},
+				},
+				Groups: []FilterGroup{
+					{Logic: "AND", Not: true, Filters: []FilterCondition{{Field: "price", Operator: "BETWEEN", Value: []interface{}{"1,5", "10"}}}},
+				},
+			},
+		},
+	}
+
+	values, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := values.Get("filter[label][in]"), `50\,5%,back\\slash,a\\\,b`; got != want {
+		t.Errorf("filter[label][in] = %s, want %s", got, want)
+	}
+	if got := values.Get("group[0][group][0][filter][0][price][between]"); got != `1\,5,10` {
+		t.Errorf("nested group filter = %s", got)
+	}
+
+	var decoded QueryConditions
+	if err := decoded.Decode(values); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.SearchFields, c.SearchFields) {
+		t.Errorf("SearchFields = %q, want %q", decoded.SearchFields, c.SearchFields)
+	}
+	if !reflect.DeepEqual(decoded.Filters, c.Filters) {
+		t.Errorf("Filters = %v, want %v", decoded.Filters, c.Filters)
+	}
+	if !reflect.DeepEqual(decoded.FilterGroups, c.FilterGroups) {
+		t.Errorf("FilterGroups = %+v, want %+v", decoded.FilterGroups, c.FilterGroups)
+	}
+}
+
+// TestEncodeRoundTrip decodes random conditions written by Encode back to
+// the same conditions.
+func TestEncodeRoundTrip(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 500; n++ {
+		c := QueryConditions{
+			SearchFields: randomList(r),
+			OrderBy:      randomList(r),
+			Filters:      randomFilters(r, true),
+			FilterGroups: randomGroups(r, 2),
+		}
+
+		values, err := c.Encode()
+		if err != nil {
+			t.Fatalf("encode %+v: %v", c, err)
+		}
+
+		var decoded QueryConditions
+		if err := decoded.Decode(values); err != nil {
+			t.Fatalf("decode %s: %v", values.Encode(), err)
+		}
+
+		// Filters are read in parameter order
+		sortFilters(c.Filters)
+		sortFilters(decoded.Filters)
+
+		if !reflect.DeepEqual(decoded.SearchFields, c.SearchFields) ||
+			!reflect.DeepEqual(decoded.OrderBy, c.OrderBy) ||
+			!reflect.DeepEqual(decoded.Filters, c.Filters) ||
+			!reflect.DeepEqual(decoded.FilterGroups, c.FilterGroups) {
+			t.Fatalf("round trip of %s\ngot  %+v\nwant %+v", values.Encode(), decoded, c)
+		}
+	}
+}
+
+// Characters the encoding has to survive
+const randomAlphabet = `ab, \[]%=&`
+
+func randomString(r *rand.Rand) string {
+
+	var b strings.Builder
+	for i := r.Intn(6); i >= 0; i-- {
+		b.WriteByte(randomAlphabet[r.Intn(len(randomAlphabet))])
+	}
+
+	return b.String()
+}
+
+// randomItem returns a list item, which is never empty nor padded.
+func randomItem(r *rand.Rand) string {
+
+	item := strings.TrimSpace(randomString(r))
+	if item == "" {
+		return "x"
+	}
+
+	return item
+}
+
+func randomList(r *rand.Rand) []string {
+
+	n := r.Intn(4)
+	if n == 0 {
+		return nil
+	}
+
+	list := make([]string, n)
+	for i := range list {
+		list[i] = randomItem(r)
+	}
+
+	return list
+}
+
+func randomFilters(r *rand.Rand, uniqueFields bool) []FilterCondition {
+
+	n := r.Intn(4)
+	if n == 0 {
+		return nil
+	}
+
+	operators := []string{"=", "!=", ">=", "<", "IN", "NOT IN", "BETWEEN", "IS NULL"}
+
+	filters := make([]FilterCondition, n)
+	for i := range filters {
+		field := string(rune('a' + r.Intn(3)))
+		if uniqueFields {
+			field = string(rune('a' + i))
+		}
+
+		filter := FilterCondition{Field: field, Operator: operators[r.Intn(len(operators))]}
+		switch filter.Operator {
+		case "IN", "NOT IN":
+			items := make([]interface{}, 1+r.Intn(3))
+			for j := range items {
+				items[j] = randomItem(r)
+			}
+			filter.Value = items
+		case "BETWEEN":
+			filter.Value = []interface{}{randomItem(r), randomItem(r)}
+		case "IS NULL":
+		default:
+			filter.Value = randomString(r)
+		}
+
+		filters[i] = filter
+	}
+
+	return filters
+}
+
+func randomGroups(r *rand.Rand, depth int) []FilterGroup {
+
+	if depth == 0 {
+		return nil
+	}
+
+	n := r.Intn(3)
+	if n == 0 {
+		return nil
+	}
+
+	logics := []string{"", "AND", "OR"}
+
+	groups := make([]FilterGroup, n)
+	for i := range groups {
+		groups[i] = FilterGroup{
+			Logic:   logics[r.Intn(len(logics))],
+			Not:     r.Intn(2) == 0,
+			Filters: randomFilters(r, false),
+			Groups:  randomGroups(r, depth-1),
+		}
+	}
+
+	return groups
+}
+
+func sortFilters(filters []FilterCondition) {
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].Field < filters[j].Field
+	})
+}
@@ -0,0 +1,142 @@
+package queryhelper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidConditions   = errors.New("invalid query conditions")
+	ErrPageWithOffset      = errors.New("page cannot be combined with offset or limit")
+	ErrQueryTooExpensive   = errors.New("query is too expensive")
+	ErrLockingNotSupported = errors.New("locking is not supported by the database")
+	ErrQueryTimeout        = errors.New("query timed out")
+	ErrShardFailed         = errors.New("shard query failed")
+	ErrMixedRequestShape   = errors.New("request mixes legacy and current keys")
+	ErrConflictingFilters  = errors.New("conflicting filters")
+	ErrInvalidFilterOption = errors.New("invalid filter option")
+	ErrSearchNotAvailable  = errors.New("search is not available: no field is searchable")
+	ErrOrderByNotAvailable = errors.New("order is not available: no requested field is sortable")
+	ErrInvalidSampling     = errors.New("invalid sampling")
+	ErrSamplingWithLocking = errors.New("sampling cannot be combined with locking")
+)
+
+// FilterError describes why a single filter was rejected.
+type FilterError struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Err      error  `json:"-"`
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter %s %s: %v", e.Field, e.Operator, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError collects the per-filter failures of one UpdateConditions
+// call. It matches ErrInvalidConditions with errors.Is.
+type ValidationError struct {
+	Errors []*FilterError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+
+	return ErrInvalidConditions.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidConditions
+}
+
+// Unwrap returns the failures, so errors.Is also matches their causes, such
+// as ErrConflictingFilters.
+func (e *ValidationError) Unwrap() []error {
+
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+
+	return errs
+}
+
+// QueryCostError reports a request whose estimated cost exceeds
+// QuerySettings.MaxQueryCost. It matches ErrQueryTooExpensive with errors.Is.
+type QueryCostError struct {
+	Cost      int        `json:"cost"`
+	MaxCost   int        `json:"max_cost"`
+	Breakdown []CostItem `json:"breakdown"`
+}
+
+func (e *QueryCostError) Error() string {
+	return fmt.Sprintf("%v: cost %d exceeds %d", ErrQueryTooExpensive, e.Cost, e.MaxCost)
+}
+
+func (e *QueryCostError) Is(target error) bool {
+	return target == ErrQueryTooExpensive
+}
+
+// LockingError reports a WithLocking option the query's database cannot
+// express. It matches ErrLockingNotSupported with errors.Is.
+type LockingError struct {
+	Dialect string
+	Feature string
+}
+
+func (e *LockingError) Error() string {
+	return fmt.Sprintf("%v: %s on %s", ErrLockingNotSupported, e.Feature, e.Dialect)
+}
+
+func (e *LockingError) Is(target error) bool {
+	return target == ErrLockingNotSupported
+}
+
+// QueryTimeoutError reports a statement cut short by
+// QuerySettings.QueryTimeout. It matches ErrQueryTimeout with errors.Is and
+// unwraps to the driver's error.
+type QueryTimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("%v after %s: %v", ErrQueryTimeout, e.Timeout, e.Err)
+}
+
+func (e *QueryTimeoutError) Is(target error) bool {
+	return target == ErrQueryTimeout
+}
+
+func (e *QueryTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// ShardError reports the failure of one shard of ExecuteSharded, by its
+// index in the shards passed. It matches ErrShardFailed with errors.Is and
+// unwraps to the shard's error.
+type ShardError struct {
+	Shard int
+	Err   error
+}
+
+func (e *ShardError) Error() string {
+	return fmt.Sprintf("%v: shard %d: %v", ErrShardFailed, e.Shard, e.Err)
+}
+
+func (e *ShardError) Is(target error) bool {
+	return target == ErrShardFailed
+}
+
+func (e *ShardError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,166 @@
+package queryhelper
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Expression returns the filters, filter groups and search of the validated
+// conditions as one expression, for embedding in a hand-built query such as
+// a UNION branch or a subquery. Ordering, the sparse select and pagination
+// are left out. The SQL is rendered for the dialect of the statement the
+// expression is built into. Relation columns are referenced by the aliases
+// Apply joins them as; join them with Joins.
+func (ch *ConditionsHandle) Expression() (clause.Expression, error) {
+
+	if ch.Conditions == nil {
+		return nil, errors.New("conditions not set")
+	}
+
+	return conditionsExpr{ch: ch}, nil
+}
+
+type conditionsExpr struct {
+	ch *ConditionsHandle
+}
+
+func (e conditionsExpr) Build(builder clause.Builder) {
+
+	// Relation columns take their aliases, and with other tables joined the
+	// table's own columns are qualified, as Apply does
+	ch, j := e.ch.resolveWhere()
+
+	dialect := ""
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		dialect = dialectName(stmt.DB)
+		ch = ch.qualified(stmt, len(j.paths) > 0)
+	}
+
+	exprs := ch.whereExprs(dialect)
+	if len(exprs) == 0 {
+		// Matches everything, like a query without conditions
+		builder.WriteString("1 = 1")
+		return
+	}
+
+	// The output is always parenthesized, so a search ORing several fields
+	// stays apart from the caller's own conditions. clause.And leaves a
+	// single condition bare and parenthesizes several itself
+	if len(exprs) == 1 {
+		builder.WriteByte('(')
+		exprs[0].Build(builder)
+		builder.WriteByte(')')
+		return
+	}
+
+	clause.And(exprs...).Build(builder)
+}
+
+// whereExprs renders the filters, filter groups and search block for a
+// dialect, one expression per WHERE condition.
+func (ch *ConditionsHandle) whereExprs(dialect string) []clause.Expression {
+
+	d := lookupDialect(dialect)
+	if dialect == "sqlite" && ch.Settings.SQLiteTimeFormat != "" && ch.Settings.SQLiteTimeFormat != d.TimeFormat {
+		sqlite := *d
+		sqlite.TimeFormat = ch.Settings.SQLiteTimeFormat
+		d = &sqlite
+	}
+	caseInsensitive := ch.caseInsensitiveColumns(dialect)
+	casts := ch.castColumns(dialect, d)
+	wrappers := ch.wrapperColumns(dialect)
+
+	exprs := make([]clause.Expression, 0, len(ch.Conditions.Filters)+len(ch.Conditions.FilterGroups)+1)
+
+	relations := ch.Settings.RelationFilters
+
+	for _, filter := range ch.Conditions.Filters {
+		sql, args, ok := buildFilter(filter, ch.table, caseInsensitive[filter.Field], casts[filter.Field], wrappers[filter.Field], d)
+		if rf, isRelation := relations[filter.Field]; isRelation {
+			sql, args, ok = buildRelationFilter(filter, rf)
+		}
+
+		if ok {
+			exprs = append(exprs, clause.Expr{SQL: sql, Vars: args})
+		}
+	}
+
+	for _, group := range ch.Conditions.FilterGroups {
+		if sql, args, ok := buildGroup(group, ch.table, caseInsensitive, casts, wrappers, relations, d); ok {
+			exprs = append(exprs, clause.Expr{SQL: sql, Vars: args})
+		}
+	}
+
+	keywords := strings.TrimSpace(ch.Conditions.SearchText)
+	idColumns, idValues := ch.searchIDMatches(keywords)
+	if keywords != "" && len(ch.Conditions.SearchFields)+len(idColumns) > 0 {
+		// Wildcards typed by the user match literally
+		escaped := escapeLike(keywords)
+		searchModes := ch.Settings.lookups().searchModes
+
+		// The keyword must match any field, or every field with LogicAnd
+		logic := " OR "
+		if strings.EqualFold(ch.Settings.SearchFieldLogic, LogicAnd) {
+			logic = " AND "
+		}
+
+		var searchQuery string
+		searchArgs := []interface{}{}
+		for i, field := range ch.Conditions.SearchFields {
+			if i > 0 {
+				searchQuery += logic
+			}
+
+			mode, ok := searchModes[field]
+			if !ok {
+				mode = ch.Settings.SearchMode
+			}
+
+			op, pattern := "LIKE", "%"+escaped+"%"
+			switch mode {
+			case SearchModeExact:
+				op, pattern = "=", keywords
+			case SearchModePrefix:
+				pattern = escaped + "%"
+			}
+
+			if caseInsensitive[field] {
+				searchQuery += "LOWER(?) " + op + " LOWER(?)"
+			} else {
+				searchQuery += "? " + op + " ?"
+			}
+			if op == "LIKE" {
+				searchQuery += d.LikeEscape
+			}
+			var column interface{} = ownColumn(ch.table, field)
+			if wrapper, ok := wrappers[field]; ok {
+				column = wrappedColumn{template: wrapper, column: ownColumn(ch.table, field)}
+			}
+			searchArgs = append(searchArgs, column, pattern)
+		}
+
+		// IDs pasted into the search also match identifier columns exactly
+		if len(idColumns) > 0 && logic == " AND " && len(ch.Conditions.SearchFields) > 1 {
+			searchQuery = "(" + searchQuery + ")"
+		}
+		for i, column := range idColumns {
+			if searchQuery != "" {
+				searchQuery += " OR "
+			}
+
+			if caseInsensitive[column] {
+				searchQuery += "LOWER(?) = LOWER(?)"
+			} else {
+				searchQuery += "? = ?"
+			}
+			searchArgs = append(searchArgs, ownColumn(ch.table, column), idValues[i])
+		}
+
+		exprs = append(exprs, clause.Expr{SQL: searchQuery, Vars: searchArgs})
+	}
+
+	return exprs
+}
@@ -0,0 +1,132 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func TestExpressionComposesLikeApply(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedSearch:  []string{"name", "email"},
+		AllowedFilters: map[string][]string{"age": {">="}},
+	}
+
+	tests := []struct {
+		name       string
+		conditions queryhelper.QueryConditions
+		want       string
+	}{
+		{
+			name:       "search only",
+			conditions: queryhelper.QueryConditions{SearchText: "foo"},
+			want:       `WHERE status = 'active' AND ("name" LIKE '%foo%' ESCAPE '\' OR "email" LIKE '%foo%' ESCAPE '\')`,
+		},
+		{
+			name: "filter and search",
+			conditions: queryhelper.QueryConditions{
+				SearchText: "foo",
+				Filters:    []queryhelper.FilterCondition{{Field: "age", Operator: ">=", Value: 18}},
+			},
+			want: `WHERE status = 'active' AND ("age" >= 18 AND ("name" LIKE '%foo%' ESCAPE '\' OR "email" LIKE '%foo%' ESCAPE '\'))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ch := queryhelper.NewConditionsHandle(settings)
+			if err := ch.UpdateConditions(&tt.conditions); err != nil {
+				t.Fatal(err)
+			}
+
+			expr, err := ch.Expression()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db := queryhelpertest.DryRunDB(t, "sqlite")
+			embedded := findSQL(t, db.Model(&testUser{}).Where("status = ?", "active").Where(expr))
+			if !strings.Contains(embedded, tt.want) {
+				t.Errorf("Expression renders\n%s\nwant it to contain\n%s", embedded, tt.want)
+			}
+
+			applied, err := ch.Apply(db.Model(&testUser{}).Where("status = ?", "active"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Apply adds each condition on its own; both keep the search together
+			appliedSQL := findSQL(t, applied)
+			if !strings.Contains(appliedSQL, `AND ("name" LIKE '%foo%' ESCAPE '\' OR "email" LIKE '%foo%' ESCAPE '\')`) {
+				t.Errorf("Apply renders\n%s", appliedSQL)
+			}
+		})
+	}
+}
+
+func TestExpressionMatchesApplyWithRelations(t *testing.T) {
+
+	db := customersDB(t)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedSearch:  []string{"name"},
+		AllowedFilters: map[string][]string{"company.name": {"!="}, "name": {"!="}},
+		AllowedOrderBy: []string{"id"},
+		Relations:      map[string]*queryhelper.Relation{"company": {Table: "companies", ForeignKey: "company_id"}},
+	}
+
+	conditions := &queryhelper.QueryConditions{
+		SearchText: "customer 0",
+		Filters: []queryhelper.FilterCondition{
+			{Field: "company.name", Operator: "!=", Value: "initech"},
+			{Field: "name", Operator: "!=", Value: "customer 05"},
+		},
+		OrderBy: []string{"id"},
+	}
+
+	ch := queryhelper.NewConditionsHandle(settings)
+	if err := ch.UpdateConditions(conditions); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := ch.Apply(db.Model(&testCustomer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []testCustomer
+	if err := applied.Find(&want).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(want) == 0 {
+		t.Fatal("Apply found no customers")
+	}
+
+	expr, err := ch.Expression()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Embedded in a query of its own, with the relations joined
+	var direct []testCustomer
+	if err := ch.Joins(db.Model(&testCustomer{})).Where(expr).Order("customers.id").Find(&direct).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(direct, want) {
+		t.Errorf("embedded expression found\n%+v\nApply found\n%+v", direct, want)
+	}
+
+	// And in a subquery of ids
+	ids := ch.Joins(db.Model(&testCustomer{})).Select("customers.id").Where(expr)
+	var nested []testCustomer
+	if err := db.Model(&testCustomer{}).Where("id IN (?)", ids).Order("id").Find(&nested).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nested, want) {
+		t.Errorf("expression in a subquery found\n%+v\nApply found\n%+v", nested, want)
+	}
+}
@@ -0,0 +1,57 @@
+package queryhelper
+
+import (
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// wrapperPlaceholder marks where a FieldWrappers template takes the column.
+const wrapperPlaceholder = "%s"
+
+// wrapperColumns resolves FieldWrappers and FieldWrapperDialects to real
+// column names and the dialect's template.
+func (ch *ConditionsHandle) wrapperColumns(dialect string) map[string]string {
+
+	columns := ch.Settings.lookups().wrappers
+	if len(columns) == 0 {
+		return nil
+	}
+
+	wrappers := make(map[string]string, len(columns))
+	for column, templates := range columns {
+		template, ok := templates[dialect]
+		if !ok {
+			template = templates[""]
+		}
+
+		if template != "" {
+			wrappers[column] = template
+		}
+	}
+
+	return wrappers
+}
+
+// wrappedColumn renders a FieldWrappers template around a column, quoted by
+// the dialector. Only the placeholders are replaced, so the template is
+// written as configured.
+type wrappedColumn struct {
+	template string
+	column   clause.Column
+}
+
+func (w wrappedColumn) Build(builder clause.Builder) {
+
+	for i, part := range strings.Split(w.template, wrapperPlaceholder) {
+		if i > 0 {
+			builder.WriteQuoted(w.column)
+		}
+		builder.WriteString(part)
+	}
+}
+
+// wrapQuoted fills a template with an already quoted column.
+func wrapQuoted(template string, quoted string) string {
+	return strings.ReplaceAll(template, wrapperPlaceholder, quoted)
+}
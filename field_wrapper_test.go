@@ -0,0 +1,126 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func wrapperSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"email": {"="}, "status": {"="}, "score": {">="}},
+		AllowedSearch:  []string{"email", "name"},
+		AllowedOrderBy: []string{"score", "id"},
+		ColumnAlias:    map[string]string{"score": "age"},
+		FieldWrappers: map[string]string{
+			"email":  "LOWER(%s)",
+			"score":  "ROUND(%s, 1)",
+			"status": "no column",
+		},
+		FieldWrapperDialects: map[string]map[string]string{
+			"score": {"mysql": "TRUNCATE(%s, 1)"},
+		},
+	}
+}
+
+func TestFieldWrappersSQL(t *testing.T) {
+
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{
+			dialect: "postgres",
+			want:    `SELECT * FROM "users" WHERE LOWER("email") = ? AND "status" = ? AND ROUND("age", 1) >= ? AND (LOWER("email") LIKE ? OR "name" LIKE ?) ORDER BY ROUND("age", 1) DESC,"id" LIMIT ?`,
+		},
+		{
+			dialect: "mysql",
+			want:    "SELECT * FROM `users` WHERE LOWER(`email`) = ? AND `status` = ? AND TRUNCATE(`age`, 1) >= ? AND (LOWER(`email`) LIKE ? OR `name` LIKE ?) ORDER BY TRUNCATE(`age`, 1) DESC,`id` LIMIT ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(
+				queryhelper.WithEqual("email", "ann@example.com"),
+				queryhelper.WithEqual("status", "open"),
+				queryhelper.WithFilter("score", ">=", 4.5),
+				queryhelper.WithSearchText("ann"),
+				queryhelper.WithOrderBy([]string{"-score", "id"}),
+			)
+
+			sql, vars, err := queryhelpertest.RenderSQL(t, tt.dialect, dq, wrapperSettings(), &testUser{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", sql, tt.want)
+			}
+
+			want := []interface{}{"ann@example.com", "open", 4.5, "%ann%", "%ann%", 10}
+			if !reflect.DeepEqual(vars, want) {
+				t.Errorf("vars %v, want %v", vars, want)
+			}
+		})
+	}
+}
+
+// TestFieldWrappersFromRequest checks a request cannot name a wrapped column
+// or bring its own function.
+func TestFieldWrappersFromRequest(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("LOWER(email)", "x"),
+		queryhelper.WithEqual("email) OR (1", "1"),
+		queryhelper.WithOrderBy([]string{"ROUND(age, 1)", "id"}),
+	)
+
+	sql, _, err := queryhelpertest.RenderSQL(t, "postgres", dq, wrapperSettings(), &testUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT * FROM "users" ORDER BY "id" LIMIT ?`; sql != want {
+		t.Errorf("got\n%s\nwant\n%s", sql, want)
+	}
+
+	var codes []string
+	for _, w := range dq.Info().Warnings {
+		codes = append(codes, w.Code)
+	}
+	want := []string{queryhelper.WarningOrderByNotAllowed, queryhelper.WarningFilterNotAllowed, queryhelper.WarningFilterNotAllowed}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("warnings %v, want %v", codes, want)
+	}
+}
+
+func TestFieldWrappersRows(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testUser{})
+	queryhelpertest.Seed(t, db, &[]testUser{
+		{ID: 1, Email: "Ann@Example.com", Age: 41},
+		{ID: 2, Email: "ann@example.com", Age: 38},
+		{ID: 3, Email: "bob@example.com", Age: 45},
+		{ID: 4, Email: "ANN@EXAMPLE.COM", Age: 47},
+	})
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("email", "ann@example.com"),
+		queryhelper.WithOrderBy([]string{"-score"}),
+	)
+
+	var users []testUser
+	if err := dq.Execute(wrapperSettings(), db.Model(&testUser{}), &users); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []uint
+	for _, u := range users {
+		got = append(got, u.ID)
+	}
+	if want := []uint{4, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("found %v, want %v", got, want)
+	}
+}
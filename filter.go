@@ -0,0 +1,354 @@
+package queryhelper
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OperatorTokens maps operator names used in URL query parameters, such as
+// filter[age][gte]=18, to filter operators.
+var OperatorTokens = map[string]string{
+	"eq":       "=",
+	"ne":       "!=",
+	"gt":       ">",
+	"gte":      ">=",
+	"lt":       "<",
+	"lte":      "<=",
+	"between":  "BETWEEN",
+	"in":       "IN",
+	"nin":      "NOT IN",
+	"like":     "LIKE",
+	"null":     "IS NULL",
+	"notnull":  "IS NOT NULL",
+	"empty":    "EMPTY",
+	"notempty": "NOT EMPTY",
+	"nseq":     "NULL_SAFE_EQ",
+	"relany":   "REL_ANY",
+	"relall":   "REL_ALL",
+}
+
+// OperatorAliases maps further operator names clients send to filter
+// operators. Names are matched case-insensitively, and "contains" also wraps
+// the value in % wildcards, matching it literally.
+var OperatorAliases = map[string]string{
+	"==":           "=",
+	"neq":          "!=",
+	"<>":           "!=",
+	"ge":           ">=",
+	"le":           "<=",
+	"not_in":       "NOT IN",
+	"notin":        "NOT IN",
+	"is_null":      "IS NULL",
+	"is_not_null":  "IS NOT NULL",
+	"not_null":     "IS NOT NULL",
+	"not_empty":    "NOT EMPTY",
+	"contains":     "LIKE",
+	"<=>":          "NULL_SAFE_EQ",
+	"contains_any": "REL_ANY",
+	"contains_all": "REL_ALL",
+}
+
+var operators = map[string]struct{}{
+	"=": {}, "!=": {}, ">": {}, "<": {}, ">=": {}, "<=": {},
+	"BETWEEN": {}, "IN": {}, "NOT IN": {}, "LIKE": {},
+	"IS NULL": {}, "IS NOT NULL": {}, "EMPTY": {}, "NOT EMPTY": {},
+	"NULL_SAFE_EQ": {}, "REL_ANY": {}, "REL_ALL": {},
+}
+
+// CanonicalOperator returns the filter operator an operator name stands for,
+// accepting any letter case and spacing, the names of OperatorTokens and
+// OperatorAliases.
+func CanonicalOperator(name string) (string, bool) {
+
+	name = strings.TrimSpace(name)
+
+	lower := strings.ToLower(name)
+	if op, ok := OperatorTokens[lower]; ok {
+		return op, true
+	}
+	if op, ok := OperatorAliases[lower]; ok {
+		return op, true
+	}
+
+	// "not  in" and "Is Null" are spelled differently, not differently named
+	op := strings.ToUpper(strings.Join(strings.Fields(name), " "))
+	if _, ok := operators[op]; ok {
+		return op, true
+	}
+
+	return name, false
+}
+
+func isContainsOperator(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), "contains")
+}
+
+func dialectName(db *gorm.DB) string {
+
+	if db == nil || db.Dialector == nil {
+		return ""
+	}
+
+	return db.Dialector.Name()
+}
+
+func containsString(list []string, s string) bool {
+
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lowerFilterValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case string:
+		return strings.ToLower(v)
+	case []string:
+		vals := make([]string, len(v))
+		for i, s := range v {
+			vals[i] = strings.ToLower(s)
+		}
+		return vals
+	case []interface{}:
+		vals := make([]interface{}, len(v))
+		for i, item := range v {
+			vals[i] = lowerFilterValue(item)
+		}
+		return vals
+	}
+
+	return value
+}
+
+// caseInsensitiveColumns resolves the case-insensitive fields to real column
+// names. Citext columns already compare case-insensitively on Postgres, so
+// they are left out there.
+func (ch *ConditionsHandle) caseInsensitiveColumns(dialect string) map[string]bool {
+
+	lookup := ch.Settings.lookups()
+	if dialect == "postgres" {
+		return lookup.ciColumnsPostgres
+	}
+
+	return lookup.ciColumns
+}
+
+// castColumns resolves ColumnCasts to real column names and the dialect's
+// spelling of each type.
+func (ch *ConditionsHandle) castColumns(name string, dialect *Dialect) map[string]string {
+
+	columns := ch.Settings.lookups().casts
+	if len(columns) == 0 {
+		return nil
+	}
+
+	casts := make(map[string]string, len(columns))
+	for column, typ := range columns {
+		casts[column] = dialect.castType(name, typ)
+	}
+
+	return casts
+}
+
+func castColumn(column interface{}, typ string) clause.Expr {
+	return clause.Expr{SQL: "CAST(? AS " + typ + ")", Vars: []interface{}{column}}
+}
+
+// buildFilter renders a single filter as a WHERE fragment with its arguments.
+// The column is passed as the first argument so gorm quotes it for the
+// dialect.
+func buildFilter(filter FilterCondition, table string, caseInsensitive bool, cast string, wrapper string, dialect *Dialect) (string, []interface{}, bool) {
+
+	var column interface{} = ownColumn(table, filter.Field)
+	if wrapper != "" {
+		column = wrappedColumn{template: wrapper, column: ownColumn(table, filter.Field)}
+	}
+
+	// Comparisons of cast columns compare the converted value
+	if cast != "" {
+		switch filter.Operator {
+		case "=", "!=", ">", "<", ">=", "<=", "BETWEEN", "IN", "NOT IN", "NULL_SAFE_EQ":
+			column = castColumn(column, cast)
+		}
+	}
+
+	filter.Value = dialect.boolValue(filter.Value)
+	if filter.Operator != "LIKE" {
+		filter.Value = dialect.timeValue(filter.Value)
+	}
+
+	field := "?"
+	placeholder := "?"
+	if caseInsensitive {
+		field = "LOWER(?)"
+		placeholder = "LOWER(?)"
+	}
+
+	switch filter.Operator {
+	case "=":
+		return field + " = " + placeholder, []interface{}{column, filter.Value}, true
+	case "!=":
+		return field + " != " + placeholder, []interface{}{column, filter.Value}, true
+	case ">":
+		return "? > ?", []interface{}{column, filter.Value}, true
+	case "<":
+		return "? < ?", []interface{}{column, filter.Value}, true
+	case ">=":
+		return "? >= ?", []interface{}{column, filter.Value}, true
+	case "<=":
+		return "? <= ?", []interface{}{column, filter.Value}, true
+	case "BETWEEN":
+		// Value should be an array with 2 elements
+		if vals, ok := filter.Value.([]interface{}); ok && len(vals) == 2 {
+			return "? BETWEEN ? AND ?", []interface{}{column, vals[0], vals[1]}, true
+		}
+	case "IN":
+		// List values were lower-cased during normalization
+		return field + " IN ?", []interface{}{column, filter.Value}, true
+	case "NOT IN":
+		return field + " NOT IN ?", []interface{}{column, filter.Value}, true
+	case "LIKE":
+		return field + " LIKE " + placeholder + dialect.LikeEscape, []interface{}{column, filter.Value}, true
+	case "NULL_SAFE_EQ":
+		// NULL matches NULL, binding the value twice where it is emulated
+		if dialect.NullSafeEqual != "" {
+			return field + " " + dialect.NullSafeEqual + " " + placeholder, []interface{}{column, filter.Value}, true
+		}
+		return field + " = " + placeholder + " OR (? IS NULL AND ? IS NULL)", []interface{}{column, filter.Value, column, filter.Value}, true
+	case "IS NULL":
+		return "? IS NULL", []interface{}{column}, true
+	case "IS NOT NULL":
+		return "? IS NOT NULL", []interface{}{column}, true
+	case "EMPTY":
+		// '' is NULL on some databases
+		if dialect.EmptyStringIsNull {
+			return "? IS NULL", []interface{}{column}, true
+		}
+		return "? IS NULL OR ? = ''", []interface{}{column, column}, true
+	case "NOT EMPTY":
+		if dialect.EmptyStringIsNull {
+			return "? IS NOT NULL", []interface{}{column}, true
+		}
+		return "? IS NOT NULL AND ? <> ''", []interface{}{column, column}, true
+	}
+
+	return "", nil, false
+}
+
+// buildGroup renders a filter group and its nested groups as a single WHERE
+// fragment. Nested groups are parenthesized; gorm wraps the outermost one.
+func buildGroup(group FilterGroup, table string, caseInsensitive map[string]bool, casts map[string]string, wrappers map[string]string, relations map[string]*RelationFilter, dialect *Dialect) (string, []interface{}, bool) {
+
+	parts := make([]string, 0, len(group.Filters)+len(group.Groups))
+	args := make([]interface{}, 0)
+
+	for _, filter := range group.Filters {
+		sql, fargs, ok := buildFilter(filter, table, caseInsensitive[filter.Field], casts[filter.Field], wrappers[filter.Field], dialect)
+		if rf, isRelation := relations[filter.Field]; isRelation {
+			sql, fargs, ok = buildRelationFilter(filter, rf)
+		}
+
+		if ok {
+			// Filters rendered as two comparisons need their own parentheses
+			if (filter.Operator == "EMPTY" || filter.Operator == "NOT EMPTY") && !dialect.EmptyStringIsNull {
+				sql = "(" + sql + ")"
+			}
+			if filter.Operator == "NULL_SAFE_EQ" && dialect.NullSafeEqual == "" {
+				sql = "(" + sql + ")"
+			}
+			parts = append(parts, sql)
+			args = append(args, fargs...)
+		}
+	}
+
+	for _, sub := range group.Groups {
+		if sql, gargs, ok := buildGroup(sub, table, caseInsensitive, casts, wrappers, relations, dialect); ok {
+			if !sub.Not {
+				sql = "(" + sql + ")"
+			}
+			parts = append(parts, sql)
+			args = append(args, gargs...)
+		}
+	}
+
+	if len(parts) == 0 {
+		return "", nil, false
+	}
+
+	logic := LogicAnd
+	if group.Logic == LogicOr {
+		logic = LogicOr
+	}
+
+	sql := strings.Join(parts, " "+logic+" ")
+	if group.Not {
+		sql = "NOT (" + sql + ")"
+	}
+
+	return sql, args, true
+}
+
+// filterNode is the expression tree built by the filter parsers before it is
+// mapped onto Filters and FilterGroups.
+type filterNode struct {
+	logic    string
+	not      bool
+	children []*filterNode
+	filter   *FilterCondition
+}
+
+func (n *filterNode) add(child *filterNode) {
+
+	// Flatten nested lists with the same logic
+	if child.filter == nil && !child.not && child.logic == n.logic {
+		n.children = append(n.children, child.children...)
+		return
+	}
+
+	n.children = append(n.children, child)
+}
+
+func (n *filterNode) group() FilterGroup {
+
+	group := FilterGroup{
+		Logic: n.logic,
+		Not:   n.not,
+	}
+
+	for _, child := range n.children {
+		if child.filter != nil {
+			group.Filters = append(group.Filters, *child.filter)
+			continue
+		}
+		group.Groups = append(group.Groups, child.group())
+	}
+
+	return group
+}
+
+// apply maps the tree onto conditions. Top-level AND comparisons become
+// Filters and everything else becomes filter groups.
+func (n *filterNode) apply(conditions *QueryConditions) {
+
+	switch {
+	case n.filter != nil:
+		conditions.Filters = append(conditions.Filters, *n.filter)
+	case n.logic == LogicAnd && !n.not:
+		for _, child := range n.children {
+			if child.filter != nil {
+				conditions.Filters = append(conditions.Filters, *child.filter)
+				continue
+			}
+			conditions.FilterGroups = append(conditions.FilterGroups, child.group())
+		}
+	default:
+		conditions.FilterGroups = append(conditions.FilterGroups, n.group())
+	}
+}
@@ -0,0 +1,93 @@
+package queryhelper
+
+import (
+	"fmt"
+)
+
+// WithFilter appends a filter to the request, after those of earlier
+// options. A filter whose operator is unknown or whose value does not fit it,
+// such as BETWEEN without two values, is not added; Err, Apply and Execute
+// return the mistake instead.
+func WithFilter(field string, operator string, value interface{}) Option {
+	return func(dq *QueryHelper) {
+
+		filter, err := newFilter(field, operator, value)
+		if err != nil {
+			if dq.err == nil {
+				dq.err = err
+			}
+			return
+		}
+
+		dq.queryConditions.Filters = append(dq.queryConditions.Filters, filter)
+	}
+}
+
+// WithEqual appends a field = value filter.
+func WithEqual(field string, value interface{}) Option {
+	return WithFilter(field, "=", value)
+}
+
+// WithIn appends a filter matching any of values.
+func WithIn(field string, values ...interface{}) Option {
+	return WithFilter(field, "IN", values)
+}
+
+// WithBetween appends a filter matching from to to, both included.
+func WithBetween(field string, from interface{}, to interface{}) Option {
+	return WithFilter(field, "BETWEEN", []interface{}{from, to})
+}
+
+// WithNull appends an IS NULL filter, or IS NOT NULL when isNull is false.
+func WithNull(field string, isNull bool) Option {
+
+	if isNull {
+		return WithFilter(field, "IS NULL", nil)
+	}
+
+	return WithFilter(field, "IS NOT NULL", nil)
+}
+
+// newFilter builds a filter after checking its value fits the operator.
+func newFilter(field string, operator string, value interface{}) (FilterCondition, error) {
+
+	if field == "" {
+		return FilterCondition{}, fmt.Errorf("filter %s: %w: no field", operator, ErrInvalidFilterOption)
+	}
+
+	op, ok := CanonicalOperator(operator)
+	if !ok {
+		return FilterCondition{}, fmt.Errorf("filter %s %s: %w: unknown operator", field, operator, ErrInvalidFilterOption)
+	}
+
+	// "contains" is resolved with the settings, like any request's
+	filter := FilterCondition{Field: field, Operator: operator, Value: value}
+
+	switch op {
+	case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+		if value != nil {
+			return filter, fmt.Errorf("filter %s %s: %w: takes no value", field, op, ErrInvalidFilterOption)
+		}
+	case "BETWEEN":
+		if values, ok := toInterfaceSlice(value); !ok || len(values) != 2 {
+			return filter, fmt.Errorf("filter %s %s: %w: needs two values", field, op, ErrInvalidFilterOption)
+		}
+	case "IN", "NOT IN", "REL_ANY", "REL_ALL":
+		if values, ok := toInterfaceSlice(value); !ok || len(values) == 0 {
+			return filter, fmt.Errorf("filter %s %s: %w: needs a list of values", field, op, ErrInvalidFilterOption)
+		}
+	case "NULL_SAFE_EQ":
+		if _, ok := toInterfaceSlice(value); ok {
+			return filter, fmt.Errorf("filter %s %s: %w: takes one value, not a list", field, op, ErrInvalidFilterOption)
+		}
+	default:
+		if value == nil {
+			return filter, fmt.Errorf("filter %s %s: %w: needs a value", field, op, ErrInvalidFilterOption)
+		}
+		if _, ok := toInterfaceSlice(value); ok {
+			return filter, fmt.Errorf("filter %s %s: %w: takes one value, not a list", field, op, ErrInvalidFilterOption)
+		}
+	}
+
+	return filter, nil
+}
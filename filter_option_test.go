@@ -0,0 +1,123 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func TestFilterOptionsSQL(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{
+			"status":     {"="},
+			"company_id": {"IN"},
+			"age":        {"BETWEEN"},
+			"email":      {"IS NOT NULL"},
+			"name":       {"LIKE", "IS NULL"},
+		},
+	}
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("status", "active"),
+		queryhelper.WithIn("company_id", 1, 2),
+		queryhelper.WithBetween("age", 18, 65),
+		queryhelper.WithNull("email", false),
+		queryhelper.WithFilter("name", "like", "ann%"),
+	)
+
+	queryhelpertest.AssertSQL(t, dq, settings, &testUser{},
+		`WHERE "status" = 'active' AND "company_id" IN (1,2) AND ("age" BETWEEN 18 AND 65) AND "email" IS NOT NULL AND "name" LIKE 'ann%' ESCAPE '\'`)
+
+	dq = queryhelper.NewQueryHelper(queryhelper.WithNull("name", true))
+	queryhelpertest.AssertSQL(t, dq, settings, &testUser{}, `WHERE "name" IS NULL`)
+}
+
+func TestFilterOptionsCompose(t *testing.T) {
+
+	a := queryhelper.FilterCondition{Field: "a", Operator: "=", Value: 1}
+	b := queryhelper.FilterCondition{Field: "b", Operator: "=", Value: 2}
+
+	tests := []struct {
+		name string
+		opts []queryhelper.Option
+		want string
+	}{
+		{
+			name: "options then a list",
+			opts: []queryhelper.Option{queryhelper.WithEqual("x", 1), queryhelper.WithIn("y", 2, 3), queryhelper.WithFilters([]queryhelper.FilterCondition{a, b})},
+			want: "x = 1; y IN [2 3]; a = 1; b = 2",
+		},
+		{
+			name: "a list between options",
+			opts: []queryhelper.Option{queryhelper.WithNull("x", true), queryhelper.WithFilters([]queryhelper.FilterCondition{a}), queryhelper.WithBetween("y", 1, 9)},
+			want: "x IS NULL <nil>; a = 1; y BETWEEN [1 9]",
+		},
+		{
+			name: "two lists",
+			opts: []queryhelper.Option{queryhelper.WithFilters([]queryhelper.FilterCondition{a}), queryhelper.WithFilters([]queryhelper.FilterCondition{b}), queryhelper.WithFilter("z", ">", 0)},
+			want: "a = 1; b = 2; z > 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(tt.opts...)
+			if err := dq.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			for i, f := range dq.GetQueryConditions().Filters {
+				if i > 0 {
+					got += "; "
+				}
+				got += fmt.Sprint(f.Field, " ", f.Operator, " ", f.Value)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterOptionsRejected(t *testing.T) {
+
+	tests := []struct {
+		name string
+		opt  queryhelper.Option
+	}{
+		{name: "between one value", opt: queryhelper.WithFilter("age", "BETWEEN", []int{18})},
+		{name: "between no list", opt: queryhelper.WithFilter("age", "between", 18)},
+		{name: "in no values", opt: queryhelper.WithIn("age")},
+		{name: "in one value", opt: queryhelper.WithFilter("age", "IN", 18)},
+		{name: "unknown operator", opt: queryhelper.WithFilter("age", "=>", 18)},
+		{name: "no field", opt: queryhelper.WithEqual("", 18)},
+		{name: "equal nothing", opt: queryhelper.WithEqual("age", nil)},
+		{name: "equal a list", opt: queryhelper.WithEqual("age", []int{1, 2})},
+		{name: "null with a value", opt: queryhelper.WithFilter("age", "IS NULL", 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// The mistake is kept, the filters around it are not affected
+			dq := queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "active"), tt.opt, queryhelper.WithEqual("name", "ann"))
+			if !errors.Is(dq.Err(), queryhelper.ErrInvalidFilterOption) {
+				t.Fatalf("Err() = %v, want ErrInvalidFilterOption", dq.Err())
+			}
+			if n := len(dq.GetQueryConditions().Filters); n != 2 {
+				t.Errorf("%d filters, want the 2 valid ones", n)
+			}
+
+			_, err := dq.Apply(&queryhelper.QuerySettings{}, queryhelpertest.DryRunDB(t, "sqlite").Model(&testUser{}))
+			if err != dq.Err() {
+				t.Errorf("Apply: err = %v, want %v", err, dq.Err())
+			}
+		})
+	}
+}
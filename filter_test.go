@@ -0,0 +1,191 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testAccount struct {
+	ID      uint
+	Country string
+	Token   string
+}
+
+func (testAccount) TableName() string {
+	return "accounts"
+}
+
+// TestCaseInsensitiveFields contrasts a listed field with an unlisted one in
+// the same request.
+func TestCaseInsensitiveFields(t *testing.T) {
+
+	settings := func(citext bool) *queryhelper.QuerySettings {
+
+		s := &queryhelper.QuerySettings{
+			AllowedSearch: []string{"country", "token"},
+			AllowedFilters: map[string][]string{
+				"country": {"=", "!=", "IN", "NOT IN", "LIKE"},
+				"token":   {"=", "!=", "IN", "NOT IN", "LIKE"},
+			},
+			CaseInsensitiveFields: []string{"country"},
+		}
+		if citext {
+			s.CitextFields = []string{"country"}
+		}
+
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		dialect  string
+		citext   bool
+		operator string
+		country  interface{}
+		token    interface{}
+		want     string
+	}{
+		{name: "equal", dialect: "sqlite", operator: "=", country: "SE", token: "AbC", want: `SELECT * FROM "accounts" WHERE LOWER("country") = LOWER('se') AND "token" = 'AbC' LIMIT 10`},
+		{name: "not equal", dialect: "sqlite", operator: "!=", country: "SE", token: "AbC", want: `SELECT * FROM "accounts" WHERE LOWER("country") != LOWER('se') AND "token" != 'AbC' LIMIT 10`},
+		{name: "in", dialect: "sqlite", operator: "IN", country: []interface{}{"SE", "No"}, token: []interface{}{"AbC", "dEf"}, want: `SELECT * FROM "accounts" WHERE LOWER("country") IN ('se','no') AND "token" IN ('AbC','dEf') LIMIT 10`},
+		{name: "not in", dialect: "sqlite", operator: "NOT IN", country: []interface{}{"SE", "No"}, token: []interface{}{"AbC", "dEf"}, want: `SELECT * FROM "accounts" WHERE LOWER("country") NOT IN ('se','no') AND "token" NOT IN ('AbC','dEf') LIMIT 10`},
+		{name: "like", dialect: "sqlite", operator: "LIKE", country: "S%", token: "Ab%", want: `SELECT * FROM "accounts" WHERE LOWER("country") LIKE LOWER('s%') ESCAPE '\' AND "token" LIKE 'Ab%' ESCAPE '\' LIMIT 10`},
+		{name: "postgres", dialect: "postgres", operator: "IN", country: []interface{}{"SE", "No"}, token: []interface{}{"AbC", "dEf"}, want: `SELECT * FROM "accounts" WHERE LOWER("country") IN ('se','no') AND "token" IN ('AbC','dEf') LIMIT 10`},
+		{name: "postgres citext", dialect: "postgres", citext: true, operator: "IN", country: []interface{}{"SE", "No"}, token: []interface{}{"AbC", "dEf"}, want: `SELECT * FROM "accounts" WHERE "country" IN ('se','no') AND "token" IN ('AbC','dEf') LIMIT 10`},
+		{name: "sqlite ignores citext", dialect: "sqlite", citext: true, operator: "=", country: "SE", token: "AbC", want: `SELECT * FROM "accounts" WHERE LOWER("country") = LOWER('se') AND "token" = 'AbC' LIMIT 10`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(
+				queryhelper.WithFilter("country", tt.operator, tt.country),
+				queryhelper.WithFilter("token", tt.operator, tt.token),
+			)
+
+			query, err := dq.Apply(settings(tt.citext), queryhelpertest.DryRunDB(t, tt.dialect).Model(&testAccount{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := findSQL(t, query); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCaseInsensitiveSearch searches a listed and an unlisted field.
+func TestCaseInsensitiveSearch(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedSearch:         []string{"country", "token"},
+		CaseInsensitiveFields: []string{"country"},
+	}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithSearchText("Se"))
+	query, err := dq.Apply(settings, queryhelpertest.DryRunDB(t, "sqlite").Model(&testAccount{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `SELECT * FROM "accounts" WHERE LOWER("country") LIKE LOWER('%Se%') ESCAPE '\' OR "token" LIKE '%Se%' ESCAPE '\' LIMIT 10`
+	if got := findSQL(t, query); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestCanonicalOperator(t *testing.T) {
+
+	type test struct {
+		name   string
+		want   string
+		wantOK bool
+	}
+
+	var tests []test
+	for _, names := range []map[string]string{queryhelper.OperatorTokens, queryhelper.OperatorAliases} {
+		for name, op := range names {
+			tests = append(tests,
+				test{name: name, want: op, wantOK: true},
+				test{name: strings.ToUpper(name), want: op, wantOK: true},
+				test{name: " " + name + " ", want: op, wantOK: true},
+			)
+		}
+	}
+
+	tests = append(tests,
+		test{name: "In", want: "IN", wantOK: true},
+		test{name: "not  in", want: "NOT IN", wantOK: true},
+		test{name: "Is Null", want: "IS NULL", wantOK: true},
+		test{name: "Like", want: "LIKE", wantOK: true},
+		test{name: "nOt_In", want: "NOT IN", wantOK: true},
+		test{name: "!=", want: "!=", wantOK: true},
+		test{name: "", want: ""},
+		test{name: "eqq", want: "eqq"},
+		test{name: "=>", want: "=>"},
+		test{name: "in in", want: "in in"},
+		test{name: " like% ", want: "like%"},
+		test{name: "not", want: "not"},
+		test{name: "1=1; DROP TABLE users", want: "1=1; DROP TABLE users"},
+	)
+
+	for _, tt := range tests {
+		got, ok := queryhelper.CanonicalOperator(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("CanonicalOperator(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestOperatorAliasesAllowed checks aliases pass settings naming operators
+// canonically and are reported canonically.
+func TestOperatorAliasesAllowed(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{
+			"age":  {">=", "<=", "!=", "NOT IN", "BETWEEN"},
+			"name": {"LIKE"},
+		},
+	}
+
+	conditions := &queryhelper.QueryConditions{
+		Filters: []queryhelper.FilterCondition{
+			{Field: "age", Operator: "GTE", Value: 18},
+			{Field: "age", Operator: "le", Value: 65},
+			{Field: "age", Operator: "Neq", Value: 30},
+			{Field: "age", Operator: "not_in", Value: []interface{}{40, 41}},
+			{Field: "age", Operator: "between", Value: []interface{}{20, 60}},
+			{Field: "name", Operator: "Contains", Value: "50%"},
+			{Field: "age", Operator: "eqq", Value: 1},
+		},
+	}
+
+	ch := queryhelper.NewConditionsHandle(settings)
+	if err := ch.UpdateConditions(conditions); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []queryhelper.FilterCondition{
+		{Field: "age", Operator: ">=", Value: 18},
+		{Field: "age", Operator: "<=", Value: 65},
+		{Field: "age", Operator: "!=", Value: 30},
+		{Field: "age", Operator: "NOT IN", Value: []interface{}{40, 41}},
+		{Field: "age", Operator: "BETWEEN", Value: []interface{}{20, 60}},
+		{Field: "name", Operator: "LIKE", Value: `%50\%%`},
+	}
+
+	got := ch.CurrentInfo().Filters
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+
+	dropped := ch.Dropped
+	if len(dropped) != 1 || dropped[0].Operator != "eqq" || dropped[0].Code != queryhelper.WarningOperatorNotAllowed {
+		t.Errorf("dropped %+v, want the unknown operator", dropped)
+	}
+}
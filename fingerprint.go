@@ -0,0 +1,212 @@
+package queryhelper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fingerprint identifies the normalized conditions: a SHA-256 of their
+// canonical form, in hex. Requests differing only in the order of their
+// filters, search fields or list values share a fingerprint; the ordering is
+// kept in sequence. Values are tagged with their kind, so 1 and "1" differ.
+// Call it after UpdateConditions.
+func (ch *ConditionsHandle) Fingerprint() string {
+
+	var c QueryConditions
+	if ch.Conditions != nil {
+		c = *ch.Conditions
+	}
+
+	return fingerprint(canonicalConditions(&c))
+}
+
+// Fingerprint identifies the request: the fingerprint of its conditions
+// normalized with settings, the page, the count mode and the sampling. A nil
+// settings uses the helper's settings provider.
+func (dq *QueryHelper) Fingerprint(settings *QuerySettings) (string, error) {
+
+	if dq.err != nil {
+		return "", dq.err
+	}
+
+	if dq.pagination.err != nil {
+		return "", dq.pagination.err
+	}
+
+	if settings == nil && dq.settingsProvider != nil {
+		settings = dq.settingsProvider.Current()
+	}
+
+	dqh := NewConditionsHandle(settings)
+	conditions := *dq.queryConditions
+	if err := dqh.UpdateConditions(&conditions); err != nil {
+		return "", err
+	}
+
+	info := dq.pagination.Info
+
+	sampling := "none"
+	if s := dq.sampling; s != nil {
+		sampling = s.Method + " " + strconv.FormatFloat(s.Percent, 'g', -1, 64)
+	}
+
+	return fingerprint(
+		dqh.Fingerprint(),
+		"offset "+strconv.Itoa(info.Offset),
+		"limit "+strconv.Itoa(info.Limit),
+		"count "+dq.pagination.resolveCountMode(settings),
+		"sampling "+sampling,
+	), nil
+}
+
+func fingerprint(parts ...string) string {
+
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalConditions writes the conditions one line per part, each set
+// sorted.
+func canonicalConditions(c *QueryConditions) string {
+
+	lines := []string{
+		"search " + strconv.Quote(c.SearchText),
+		"search_fields " + sortedList(c.SearchFields),
+		"order_by " + quotedList(c.OrderBy),
+		"sort_factor " + strconv.Itoa(c.SortFactor),
+		"filters " + canonicalFilters(c.Filters),
+		"groups " + canonicalGroups(c.FilterGroups),
+		"fields " + sortedList(c.Fields),
+		"includes " + sortedList(c.Includes),
+		"summaries " + sortedList(c.RequestedSummaries),
+		"locale " + strconv.Quote(c.Locale),
+	}
+
+	includes := make([]string, 0, len(c.IncludeFilters))
+	for name, filters := range c.IncludeFilters {
+		includes = append(includes, strconv.Quote(name)+" "+canonicalFilters(filters))
+	}
+	sort.Strings(includes)
+	lines = append(lines, "include_filters ["+strings.Join(includes, ",")+"]")
+
+	if h := c.Histogram; h != nil {
+		lines = append(lines, "histogram "+quotedList([]string{h.Field, h.Interval, h.TimeZone})+" "+strconv.FormatBool(h.FillEmpty))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func canonicalFilters(filters []FilterCondition) string {
+
+	encoded := make([]string, len(filters))
+	for i, filter := range filters {
+		// Set operators match whatever the order of their values
+		unordered := filter.Operator == "IN" || filter.Operator == "NOT IN" || isRelationOperator(filter.Operator)
+
+		encoded[i] = strconv.Quote(filter.Field) + " " + strconv.Quote(filter.Operator) + " " + canonicalValue(filter.Value, unordered)
+	}
+	sort.Strings(encoded)
+
+	return "[" + strings.Join(encoded, ",") + "]"
+}
+
+func canonicalGroups(groups []FilterGroup) string {
+
+	encoded := make([]string, len(groups))
+	for i, group := range groups {
+		encoded[i] = "(" + strconv.Quote(strings.ToUpper(group.Logic)) + " " + strconv.FormatBool(group.Not) + " " + canonicalFilters(group.Filters) + " " + canonicalGroups(group.Groups) + ")"
+	}
+	sort.Strings(encoded)
+
+	return "[" + strings.Join(encoded, ",") + "]"
+}
+
+// canonicalValue encodes a value tagged with its kind: s:"1" for the string
+// and i:1 for the integer. Lists are sorted when unordered, maps always.
+func canonicalValue(v interface{}, unordered bool) string {
+
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "s:" + strconv.Quote(v)
+	case []byte:
+		return "x:" + hex.EncodeToString(v)
+	case bool:
+		return "b:" + strconv.FormatBool(v)
+	case time.Time:
+		return "t:" + v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return "null"
+		}
+		return canonicalValue(*v, false)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "i:" + strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "i:" + strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return "f:" + strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.String:
+		return "s:" + strconv.Quote(rv.String())
+	case reflect.Bool:
+		return "b:" + strconv.FormatBool(rv.Bool())
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = canonicalValue(rv.Index(i).Interface(), false)
+		}
+		if unordered {
+			sort.Strings(items)
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case reflect.Map:
+		entries := make([]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			entries = append(entries, canonicalValue(iter.Key().Interface(), false)+":"+canonicalValue(iter.Value().Interface(), false))
+		}
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ",") + "}"
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "null"
+		}
+		return canonicalValue(rv.Elem().Interface(), unordered)
+	}
+
+	return fmt.Sprintf("%T:%v", v, v)
+}
+
+func sortedList(list []string) string {
+
+	sorted := append([]string(nil), list...)
+	sort.Strings(sorted)
+
+	return quotedList(sorted)
+}
+
+func quotedList(list []string) string {
+
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = strconv.Quote(s)
+	}
+
+	return "[" + strings.Join(quoted, ",") + "]"
+}
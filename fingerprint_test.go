@@ -0,0 +1,284 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+func fingerprintSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{
+			"status":     {"=", "!="},
+			"age":        {">=", "<", "BETWEEN"},
+			"company_id": {"IN", "NOT IN"},
+			"name":       {"LIKE"},
+			"email":      {"IS NULL"},
+		},
+		AllowedSearch:     []string{"name", "email", "status"},
+		AllowedOrderBy:    []string{"name", "age", "id"},
+		AllowedCountModes: []string{queryhelper.CountModeExact, queryhelper.CountModeNone},
+	}
+}
+
+// conditionsFingerprint normalizes c with the fingerprint settings and
+// returns the fingerprint of what is left.
+func conditionsFingerprint(t *testing.T, c *queryhelper.QueryConditions) string {
+
+	t.Helper()
+
+	ch := queryhelper.NewConditionsHandle(fingerprintSettings())
+	if err := ch.UpdateConditions(c); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.Dropped) != 0 {
+		t.Fatalf("dropped %+v", ch.Dropped)
+	}
+
+	return ch.Fingerprint()
+}
+
+// TestFingerprintReordered builds random requests and checks each shares
+// its fingerprint with every shuffle of it, and not with a changed value.
+func TestFingerprintReordered(t *testing.T) {
+
+	pool := []queryhelper.FilterCondition{
+		{Field: "status", Operator: "=", Value: "open"},
+		{Field: "status", Operator: "!=", Value: "closed"},
+		{Field: "age", Operator: ">=", Value: 18},
+		{Field: "age", Operator: "<", Value: 65.5},
+		{Field: "age", Operator: "BETWEEN", Value: []int{20, 30}},
+		{Field: "company_id", Operator: "IN", Value: []interface{}{1, 2, 3, 4}},
+		{Field: "company_id", Operator: "NOT IN", Value: []interface{}{"7", "8"}},
+		{Field: "name", Operator: "LIKE", Value: "ann%"},
+		{Field: "email", Operator: "IS NULL"},
+	}
+	searchFields := []string{"name", "email", "status"}
+
+	rnd := rand.New(rand.NewSource(1))
+
+	// A copy of request with every set shuffled
+	shuffled := func(c *queryhelper.QueryConditions) *queryhelper.QueryConditions {
+
+		s := *c
+		s.Filters = shuffleFilters(rnd, c.Filters)
+		s.SearchFields = append([]string(nil), c.SearchFields...)
+		rnd.Shuffle(len(s.SearchFields), func(i, j int) { s.SearchFields[i], s.SearchFields[j] = s.SearchFields[j], s.SearchFields[i] })
+
+		s.FilterGroups = nil
+		for _, g := range c.FilterGroups {
+			g.Filters = shuffleFilters(rnd, g.Filters)
+			s.FilterGroups = append([]queryhelper.FilterGroup{g}, s.FilterGroups...)
+		}
+
+		return &s
+	}
+
+	for i := 0; i < 200; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+
+			// The same random picks build the request each time it is needed
+			seed := rnd.Int63()
+			request := func() *queryhelper.QueryConditions {
+
+				r := rand.New(rand.NewSource(seed))
+				picked := r.Perm(len(pool))
+
+				c := &queryhelper.QueryConditions{
+					SearchText:   "ann",
+					SearchFields: searchFields[:1+r.Intn(len(searchFields))],
+					OrderBy:      []string{"-age", "name"},
+				}
+				for _, p := range picked[:1+r.Intn(len(pool)-1)] {
+					c.Filters = append(c.Filters, pool[p])
+				}
+				for g := 0; g < r.Intn(3); g++ {
+					c.FilterGroups = append(c.FilterGroups, queryhelper.FilterGroup{
+						Logic:   []string{queryhelper.LogicAnd, queryhelper.LogicOr}[g%2],
+						Not:     g == 1,
+						Filters: []queryhelper.FilterCondition{pool[picked[len(pool)-1]], {Field: "status", Operator: "=", Value: fmt.Sprint("group ", g)}},
+					})
+				}
+
+				return c
+			}
+
+			want := conditionsFingerprint(t, request())
+			for j := 0; j < 5; j++ {
+				if got := conditionsFingerprint(t, shuffled(request())); got != want {
+					t.Fatalf("a shuffled request has fingerprint %s, want %s", got, want)
+				}
+			}
+
+			// Any one value changed gives another fingerprint
+			changed := request()
+			k := rnd.Intn(len(changed.Filters))
+			if changed.Filters[k].Value == nil {
+				return
+			}
+			changed.Filters[k].Value = changedValue(changed.Filters[k].Value)
+			if got := conditionsFingerprint(t, shuffled(changed)); got == want {
+				t.Errorf("changing filter %+v kept fingerprint %s", changed.Filters[k], got)
+			}
+		})
+	}
+}
+
+func shuffleFilters(rnd *rand.Rand, filters []queryhelper.FilterCondition) []queryhelper.FilterCondition {
+
+	shuffled := make([]queryhelper.FilterCondition, len(filters))
+	for i, f := range filters {
+		// The values of set operators are shuffled too
+		if list, ok := f.Value.([]interface{}); ok && (f.Operator == "IN" || f.Operator == "NOT IN") {
+			values := append([]interface{}(nil), list...)
+			rnd.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+			f.Value = values
+		}
+		shuffled[i] = f
+	}
+	rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	return shuffled
+}
+
+// changedValue returns another value of the same kind.
+func changedValue(v interface{}) interface{} {
+
+	switch v := v.(type) {
+	case int:
+		return v + 1
+	case float64:
+		return v + 1
+	case string:
+		return v + "!"
+	case []int:
+		return []int{v[0] - 1, v[1]}
+	case []interface{}:
+		return append([]interface{}{9}, v[1:]...)
+	}
+
+	panic(fmt.Sprintf("no other value of %T", v))
+}
+
+func TestFingerprintDiffers(t *testing.T) {
+
+	filter := func(field, operator string, value interface{}) []queryhelper.FilterCondition {
+		return []queryhelper.FilterCondition{{Field: field, Operator: operator, Value: value}}
+	}
+
+	tests := []struct {
+		name string
+		a, b queryhelper.QueryConditions
+	}{
+		{name: "integer and string", a: queryhelper.QueryConditions{Filters: filter("status", "=", 1)}, b: queryhelper.QueryConditions{Filters: filter("status", "=", "1")}},
+		{name: "integer and float", a: queryhelper.QueryConditions{Filters: filter("age", ">=", 18)}, b: queryhelper.QueryConditions{Filters: filter("age", ">=", 18.5)}},
+		{name: "operator", a: queryhelper.QueryConditions{Filters: filter("company_id", "IN", []int{1, 2})}, b: queryhelper.QueryConditions{Filters: filter("company_id", "NOT IN", []int{1, 2})}},
+		{name: "between bounds in order", a: queryhelper.QueryConditions{Filters: filter("age", "BETWEEN", []int{20, 30})}, b: queryhelper.QueryConditions{Filters: filter("age", "BETWEEN", []int{30, 20})}},
+		{name: "ordering in sequence", a: queryhelper.QueryConditions{OrderBy: []string{"name", "age"}}, b: queryhelper.QueryConditions{OrderBy: []string{"age", "name"}}},
+		{name: "ordering direction", a: queryhelper.QueryConditions{OrderBy: []string{"name"}}, b: queryhelper.QueryConditions{OrderBy: []string{"-name"}}},
+		{name: "search text", a: queryhelper.QueryConditions{SearchText: "ann"}, b: queryhelper.QueryConditions{SearchText: "anna"}},
+		{name: "search fields", a: queryhelper.QueryConditions{SearchText: "ann", SearchFields: []string{"name"}}, b: queryhelper.QueryConditions{SearchText: "ann", SearchFields: []string{"email"}}},
+		{
+			name: "negated group",
+			a:    queryhelper.QueryConditions{FilterGroups: []queryhelper.FilterGroup{{Logic: queryhelper.LogicOr, Filters: filter("status", "=", "open")}}},
+			b:    queryhelper.QueryConditions{FilterGroups: []queryhelper.FilterGroup{{Logic: queryhelper.LogicOr, Not: true, Filters: filter("status", "=", "open")}}},
+		},
+		{
+			name: "filter in a group",
+			a:    queryhelper.QueryConditions{Filters: filter("status", "=", "open")},
+			b:    queryhelper.QueryConditions{FilterGroups: []queryhelper.FilterGroup{{Logic: queryhelper.LogicAnd, Filters: filter("status", "=", "open")}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if conditionsFingerprint(t, &tt.a) == conditionsFingerprint(t, &tt.b) {
+				t.Errorf("%+v and %+v share a fingerprint", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestFingerprintStable(t *testing.T) {
+
+	// Map values and settings maps are iterated in a new order each time
+	want := conditionsFingerprint(t, &queryhelper.QueryConditions{
+		Filters: []queryhelper.FilterCondition{{Field: "status", Operator: "=", Value: map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}}},
+	})
+	for i := 0; i < 50; i++ {
+		got := conditionsFingerprint(t, &queryhelper.QueryConditions{
+			Filters: []queryhelper.FilterCondition{{Field: "status", Operator: "=", Value: map[string]int{"e": 5, "d": 4, "c": 3, "b": 2, "a": 1}}},
+		})
+		if got != want {
+			t.Fatalf("fingerprint %s, want %s", got, want)
+		}
+	}
+
+	// The fingerprint is a hex SHA-256
+	if len(want) != 64 {
+		t.Errorf("fingerprint %q is not a hex SHA-256", want)
+	}
+}
+
+func TestQueryHelperFingerprint(t *testing.T) {
+
+	base := []queryhelper.Option{
+		queryhelper.WithFilter("status", "=", "open"),
+		queryhelper.WithIn("company_id", 1, 2),
+		queryhelper.WithOrderBy([]string{"name"}),
+	}
+	fingerprint := func(opts ...queryhelper.Option) string {
+
+		t.Helper()
+
+		f, err := queryhelper.NewQueryHelper(opts...).Fingerprint(fingerprintSettings())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	want := fingerprint(base...)
+
+	// The same request with its filters the other way around
+	same := fingerprint(queryhelper.WithIn("company_id", 2, 1), queryhelper.WithFilter("status", "=", "open"), queryhelper.WithOrderBy([]string{"name"}))
+	if same != want {
+		t.Errorf("reordered filters: fingerprint %s, want %s", same, want)
+	}
+
+	// A disallowed count mode falls back to the exact count
+	if got := fingerprint(append(base, queryhelper.WithCountMode("estimated"))...); got != want {
+		t.Errorf("fallback count mode: fingerprint %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name string
+		opt  queryhelper.Option
+	}{
+		{name: "page", opt: queryhelper.WithPage(2)},
+		{name: "page size", opt: queryhelper.WithPageSize(50)},
+		{name: "count mode", opt: queryhelper.WithCountMode(queryhelper.CountModeNone)},
+		{name: "sampling", opt: queryhelper.WithSampling(10, queryhelper.SamplingSystem)},
+		{name: "sampling method", opt: queryhelper.WithSampling(100, queryhelper.SamplingBernoulli)},
+		{name: "another filter", opt: queryhelper.WithFilter("age", ">=", 18)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if got := fingerprint(append(base, tt.opt)...); got == want {
+				t.Errorf("fingerprint %s is unchanged", got)
+			}
+		})
+	}
+
+	// A mistake in the request is returned instead
+	_, err := queryhelper.NewQueryHelper(queryhelper.WithIn("company_id")).Fingerprint(fingerprintSettings())
+	if err == nil {
+		t.Error("want the invalid filter's error")
+	}
+}
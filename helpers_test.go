@@ -0,0 +1,93 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+type testUser struct {
+	ID        uint
+	Name      string
+	Email     string
+	Status    string
+	Age       int
+	CompanyID uint
+}
+
+func (testUser) TableName() string {
+	return "users"
+}
+
+type testCompany struct {
+	ID   uint
+	Name string
+}
+
+func (testCompany) TableName() string {
+	return "companies"
+}
+
+type testCustomer struct {
+	ID        uint
+	Name      string
+	CompanyID uint
+	Company   testCompany
+	Orders    []testOrder `gorm:"foreignKey:CustomerID"`
+}
+
+func (testCustomer) TableName() string {
+	return "customers"
+}
+
+type testOrder struct {
+	ID         uint
+	CustomerID uint
+	Amount     int
+}
+
+func (testOrder) TableName() string {
+	return "orders"
+}
+
+// customersDB holds twelve customers of three companies, with one or two
+// orders each.
+func customersDB(t *testing.T) *gorm.DB {
+
+	t.Helper()
+
+	db := queryhelpertest.NewTestDB(t, &testCompany{}, &testCustomer{}, &testOrder{})
+	queryhelpertest.Seed(t, db, &[]testCompany{{ID: 1, Name: "acme"}, {ID: 2, Name: "globex"}, {ID: 3, Name: "initech"}})
+
+	var customers []testCustomer
+	var orders []testOrder
+	for i := 1; i <= 12; i++ {
+		customers = append(customers, testCustomer{ID: uint(i), Name: fmt.Sprintf("customer %02d", 13-i), CompanyID: uint(i%3 + 1)})
+		for j := 0; j <= i%2; j++ {
+			orders = append(orders, testOrder{CustomerID: uint(i), Amount: i*10 + j})
+		}
+	}
+	queryhelpertest.Seed(t, db, &customers, &orders)
+
+	return db
+}
+
+// findSQL renders the query's find with the values written in. Statements
+// a dry run count left behind are discarded first.
+func findSQL(t testing.TB, query *gorm.DB) string {
+
+	t.Helper()
+
+	query.Statement.SQL.Reset()
+	query.Statement.Vars = nil
+
+	var rows []map[string]interface{}
+	stmt := query.Find(&rows).Statement
+	if stmt.Error != nil {
+		t.Fatal(stmt.Error)
+	}
+
+	return query.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+}
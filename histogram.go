@@ -0,0 +1,313 @@
+package queryhelper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Date histogram intervals
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week" // starting on Monday
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// intervalQuarterHour groups UTC times finely enough to re-bucket them in
+// zones with a fractional hour offset.
+const intervalQuarterHour = "quarter_hour"
+
+// DateHistogram requests the number of matching rows per interval of a time
+// column, computed by Execute over every matching row.
+type DateHistogram struct {
+	Field     string `json:"field"`
+	Interval  string `json:"interval"`             // hour, day, week, month or year
+	TimeZone  string `json:"time_zone,omitempty"`  // IANA name buckets start in, defaults to UTC
+	FillEmpty bool   `json:"fill_empty,omitempty"` // add empty buckets between the first and the last
+}
+
+// HistogramBucket is the number of rows from Start up to the next bucket.
+type HistogramBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// WithHistogram requests a date histogram of the matching rows.
+func WithHistogram(histogram *DateHistogram) Option {
+	return func(dq *QueryHelper) {
+		dq.queryConditions.Histogram = histogram
+	}
+}
+
+// normalizeHistogram checks the histogram against HistogramFields and maps
+// its field to the real column. Histograms that are not allowed are dropped.
+func (ch *ConditionsHandle) normalizeHistogram(histogram *DateHistogram) *DateHistogram {
+
+	h := *histogram
+	h.Interval = strings.ToLower(h.Interval)
+
+	intervals, ok := ch.Settings.HistogramFields[h.Field]
+	if !ok {
+		ch.drop(DroppedItem{Kind: DroppedHistogram, Code: WarningHistogramNotAllowed, Field: h.Field, Value: h.Interval, Reason: "field has no histogram"})
+		return nil
+	}
+
+	if !containsString(intervals, h.Interval) {
+		ch.drop(DroppedItem{Kind: DroppedHistogram, Code: WarningIntervalNotAllowed, Field: h.Field, Value: h.Interval, Reason: "interval is not allowed"})
+		return nil
+	}
+
+	if _, err := time.LoadLocation(h.TimeZone); err != nil {
+		ch.drop(DroppedItem{Kind: DroppedHistogram, Code: WarningUnknownTimeZone, Field: h.Field, Value: h.TimeZone, Reason: "unknown time zone"})
+		return nil
+	}
+
+	if real, ok := ch.realColumn(h.Field); ok {
+		ch.rename(h.Field, real)
+		h.Field = real
+	}
+
+	return &h
+}
+
+// histogram counts the rows of the conditioned query per bucket. Postgres
+// truncates times in the requested zone itself. MySQL and SQLite truncate in
+// UTC, the zone times are stored in, and for other zones group by UTC hour
+// or quarter hour, which Go re-buckets in the zone so months and DST
+// changes fall where they should.
+func histogram(query *gorm.DB, h *DateHistogram, sqliteTimeFormat string) ([]HistogramBucket, error) {
+
+	loc, err := time.LoadLocation(h.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	dialect := dialectName(query)
+
+	interval := h.Interval
+	native := dialect == "postgres" || loc == time.UTC
+	if !native {
+		interval = IntervalHour
+		if fractionalOffset(loc) {
+			interval = intervalQuarterHour
+		}
+	}
+
+	var column interface{} = clause.Column{Name: h.Field}
+	if dialect == "sqlite" && sqliteTimeFormat == TimeFormatUnix {
+		column = clause.Expr{SQL: "datetime(?, 'unixepoch')", Vars: []interface{}{column}}
+	}
+
+	bucket, err := truncateExpr(dialect, column, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := aggregateBase(query).
+		Clauses(clause.Select{Expression: clause.Expr{SQL: "? AS qh_bucket, COUNT(*) AS qh_count", Vars: []interface{}{bucket}}}).
+		Group("qh_bucket").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Buckets keyed by start, several SQL buckets may share one
+	counts := make(map[int64]*HistogramBucket)
+	for rows.Next() {
+		var (
+			value interface{}
+			count int64
+		)
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, err
+		}
+
+		// Rows with a NULL time fall in no bucket
+		if value == nil {
+			continue
+		}
+
+		wall, err := bucketTime(value)
+		if err != nil {
+			return nil, err
+		}
+
+		var start time.Time
+		if native {
+			start = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+		} else {
+			utc := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC)
+			start = truncateTime(utc.In(loc), h.Interval)
+		}
+
+		if b, ok := counts[start.Unix()]; ok {
+			b.Count += count
+			continue
+		}
+		counts[start.Unix()] = &HistogramBucket{Start: start, Count: count}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]HistogramBucket, 0, len(counts))
+	for _, b := range counts {
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+
+	if h.FillEmpty && len(buckets) > 1 {
+		buckets = fillBuckets(buckets, h.Interval)
+	}
+
+	return buckets, nil
+}
+
+// truncateExpr renders column truncated to the start of its interval, as a
+// time in loc on Postgres and as UTC text elsewhere.
+func truncateExpr(dialect string, column interface{}, interval string, loc *time.Location) (clause.Expr, error) {
+
+	switch dialect {
+	case "postgres":
+		// AT TIME ZONE turns a timestamptz into the zone's wall time
+		return clause.Expr{SQL: "date_trunc(?, ? AT TIME ZONE ?)", Vars: []interface{}{interval, column, loc.String()}}, nil
+	case "mysql":
+		switch interval {
+		case IntervalHour:
+			return clause.Expr{SQL: "DATE_FORMAT(?, '%Y-%m-%d %H:00:00')", Vars: []interface{}{column}}, nil
+		case intervalQuarterHour:
+			return clause.Expr{SQL: "CONCAT(DATE_FORMAT(?, '%Y-%m-%d %H:'), LPAD(FLOOR(MINUTE(?) / 15) * 15, 2, '0'), ':00')", Vars: []interface{}{column, column}}, nil
+		case IntervalDay:
+			return clause.Expr{SQL: "DATE_FORMAT(?, '%Y-%m-%d 00:00:00')", Vars: []interface{}{column}}, nil
+		case IntervalWeek:
+			return clause.Expr{SQL: "DATE_FORMAT(DATE_SUB(?, INTERVAL WEEKDAY(?) DAY), '%Y-%m-%d 00:00:00')", Vars: []interface{}{column, column}}, nil
+		case IntervalMonth:
+			return clause.Expr{SQL: "DATE_FORMAT(?, '%Y-%m-01 00:00:00')", Vars: []interface{}{column}}, nil
+		case IntervalYear:
+			return clause.Expr{SQL: "DATE_FORMAT(?, '%Y-01-01 00:00:00')", Vars: []interface{}{column}}, nil
+		}
+	case "sqlite":
+		switch interval {
+		case IntervalHour:
+			return clause.Expr{SQL: "strftime('%Y-%m-%d %H:00:00', ?)", Vars: []interface{}{column}}, nil
+		case intervalQuarterHour:
+			return clause.Expr{SQL: "strftime('%Y-%m-%d %H:', ?) || printf('%02d:00', CAST(strftime('%M', ?) AS INTEGER) / 15 * 15)", Vars: []interface{}{column, column}}, nil
+		case IntervalDay:
+			return clause.Expr{SQL: "strftime('%Y-%m-%d 00:00:00', ?)", Vars: []interface{}{column}}, nil
+		case IntervalWeek:
+			// The next Sunday, or the same day, less six days is Monday
+			return clause.Expr{SQL: "strftime('%Y-%m-%d 00:00:00', ?, 'weekday 0', '-6 days')", Vars: []interface{}{column}}, nil
+		case IntervalMonth:
+			return clause.Expr{SQL: "strftime('%Y-%m-01 00:00:00', ?)", Vars: []interface{}{column}}, nil
+		case IntervalYear:
+			return clause.Expr{SQL: "strftime('%Y-01-01 00:00:00', ?)", Vars: []interface{}{column}}, nil
+		}
+	default:
+		return clause.Expr{}, fmt.Errorf("date histograms are not supported on %q", dialect)
+	}
+
+	return clause.Expr{}, fmt.Errorf("unknown histogram interval %q", interval)
+}
+
+// bucketTime reads a bucket start as scanned, ignoring any zone it carries.
+func bucketTime(value interface{}) (time.Time, error) {
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		value = string(v)
+	}
+
+	if s, ok := value.(string); ok {
+		if t, err := toTime(s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot read histogram bucket %v", value)
+}
+
+// fractionalOffset reports whether loc is ever offset from UTC by other
+// than whole hours, judged by this year's winter and summer.
+func fractionalOffset(loc *time.Location) bool {
+
+	year := time.Now().Year()
+	for _, month := range []time.Month{time.January, time.July} {
+		_, offset := time.Date(year, month, 1, 0, 0, 0, 0, loc).Zone()
+		if offset%3600 != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// truncateTime returns the start of t's interval in t's location.
+func truncateTime(t time.Time, interval string) time.Time {
+
+	y, m, d := t.Date()
+	loc := t.Location()
+
+	switch interval {
+	case IntervalHour:
+		// From the instant, so an hour repeated when clocks go back is two
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case IntervalWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case IntervalYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	}
+
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// nextBucket returns the start of the bucket after start.
+func nextBucket(start time.Time, interval string) time.Time {
+
+	var next time.Time
+	switch interval {
+	case IntervalHour:
+		return start.Add(time.Hour)
+	case IntervalWeek:
+		next = start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		next = start.AddDate(0, 1, 0)
+	case IntervalYear:
+		next = start.AddDate(1, 0, 0)
+	default:
+		next = start.AddDate(0, 0, 1)
+	}
+
+	// Midnight may not exist on the day clocks go forward
+	return truncateTime(next, interval)
+}
+
+// fillBuckets adds empty buckets between the first and the last.
+func fillBuckets(buckets []HistogramBucket, interval string) []HistogramBucket {
+
+	filled := make([]HistogramBucket, 0, len(buckets))
+
+	for i, b := range buckets {
+		if i > 0 {
+			for start := nextBucket(buckets[i-1].Start, interval); start.Before(b.Start); start = nextBucket(start, interval) {
+				filled = append(filled, HistogramBucket{Start: start})
+			}
+		}
+
+		filled = append(filled, b)
+	}
+
+	return filled
+}
@@ -0,0 +1,177 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testVisit struct {
+	ID   uint
+	Kind string
+	At   time.Time
+}
+
+func (testVisit) TableName() string {
+	return "visits"
+}
+
+// Berlin is UTC+1, and UTC+2 from 2024-03-31 01:00 UTC to 2024-10-27 01:00
+// UTC.
+var visits = []testVisit{
+	{Kind: "month", At: time.Date(2024, 1, 31, 22, 30, 0, 0, time.UTC)}, // Jan 31 in Berlin
+	{Kind: "month", At: time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC)}, // Feb 1 in Berlin
+	{Kind: "month", At: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)},
+	{Kind: "dst", At: time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC)},     // 01:30 CET
+	{Kind: "dst", At: time.Date(2024, 3, 31, 22, 30, 0, 0, time.UTC)},    // Apr 1 00:30 CEST
+	{Kind: "dst", At: time.Date(2024, 10, 26, 22, 30, 0, 0, time.UTC)},   // Oct 27 00:30 CEST
+	{Kind: "dst", At: time.Date(2024, 10, 27, 22, 30, 0, 0, time.UTC)},   // Oct 27 23:30 CET
+	{Kind: "repeat", At: time.Date(2024, 10, 27, 0, 15, 0, 0, time.UTC)}, // 02:15 CEST
+	{Kind: "repeat", At: time.Date(2024, 10, 27, 1, 15, 0, 0, time.UTC)}, // 02:15 CET
+}
+
+func visitsHistogram(t *testing.T, kind string, h *queryhelper.DateHistogram) ([]string, []queryhelper.Warning) {
+
+	t.Helper()
+
+	db := queryhelpertest.NewTestDB(t, &testVisit{})
+	queryhelpertest.Seed(t, db, &visits)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters:  map[string][]string{"kind": {"="}},
+		AllowedOrderBy:  []string{"id"},
+		ColumnAlias:     map[string]string{"visited_at": "at"},
+		HistogramFields: map[string][]string{"visited_at": {queryhelper.IntervalHour, queryhelper.IntervalDay, queryhelper.IntervalWeek, queryhelper.IntervalMonth}},
+	}
+
+	opts := []queryhelper.Option{queryhelper.WithHistogram(h), queryhelper.WithPageSize(1)}
+	if kind != "" {
+		opts = append(opts, queryhelper.WithEqual("kind", kind))
+	}
+
+	dq := queryhelper.NewQueryHelper(opts...)
+	var rows []testVisit
+	if err := dq.Execute(settings, db.Model(&testVisit{}), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var buckets []string
+	for _, b := range dq.Info().Histogram {
+		buckets = append(buckets, fmt.Sprintf("%s %d", b.Start.Format(time.RFC3339), b.Count))
+	}
+
+	return buckets, dq.Info().Warnings
+}
+
+func TestDateHistogram(t *testing.T) {
+
+	tests := []struct {
+		name string
+		kind string
+		h    queryhelper.DateHistogram
+		want []string
+	}{
+		{
+			name: "months in UTC",
+			kind: "month",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "month"},
+			want: []string{"2024-01-01T00:00:00Z 2", "2024-02-01T00:00:00Z 1"},
+		},
+		{
+			name: "months in Berlin",
+			kind: "month",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "Month", TimeZone: "Europe/Berlin"},
+			want: []string{"2024-01-01T00:00:00+01:00 1", "2024-02-01T00:00:00+01:00 2"},
+		},
+		{
+			name: "months in Kolkata",
+			kind: "month",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "month", TimeZone: "Asia/Kolkata"},
+			want: []string{"2024-02-01T00:00:00+05:30 3"},
+		},
+		{
+			name: "days across DST in Berlin",
+			kind: "dst",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "day", TimeZone: "Europe/Berlin"},
+			want: []string{"2024-03-31T00:00:00+01:00 1", "2024-04-01T00:00:00+02:00 1", "2024-10-27T00:00:00+02:00 2"},
+		},
+		{
+			name: "the repeated hour is two buckets",
+			kind: "repeat",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "hour", TimeZone: "Europe/Berlin"},
+			want: []string{"2024-10-27T02:00:00+02:00 1", "2024-10-27T02:00:00+01:00 1"},
+		},
+		{
+			name: "weeks start on Monday",
+			kind: "month",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "week"},
+			want: []string{"2024-01-29T00:00:00Z 2", "2024-02-26T00:00:00Z 1"},
+		},
+		{
+			name: "empty months filled",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "month", TimeZone: "Europe/Berlin", FillEmpty: true},
+			want: []string{
+				"2024-01-01T00:00:00+01:00 1",
+				"2024-02-01T00:00:00+01:00 2",
+				"2024-03-01T00:00:00+01:00 1",
+				"2024-04-01T00:00:00+02:00 1",
+				"2024-05-01T00:00:00+02:00 0",
+				"2024-06-01T00:00:00+02:00 0",
+				"2024-07-01T00:00:00+02:00 0",
+				"2024-08-01T00:00:00+02:00 0",
+				"2024-09-01T00:00:00+02:00 0",
+				"2024-10-01T00:00:00+02:00 4",
+			},
+		},
+		{
+			name: "empty hours filled across DST",
+			kind: "repeat",
+			h:    queryhelper.DateHistogram{Field: "visited_at", Interval: "hour", TimeZone: "Europe/Berlin", FillEmpty: true},
+			want: []string{"2024-10-27T02:00:00+02:00 1", "2024-10-27T02:00:00+01:00 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, warnings := visitsHistogram(t, tt.kind, &tt.h)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+			if len(warnings) != 0 {
+				t.Errorf("warnings %+v", warnings)
+			}
+		})
+	}
+}
+
+func TestDateHistogramNotAllowed(t *testing.T) {
+
+	tests := []struct {
+		name string
+		h    queryhelper.DateHistogram
+		code string
+	}{
+		{name: "field", h: queryhelper.DateHistogram{Field: "id", Interval: "day"}, code: queryhelper.WarningHistogramNotAllowed},
+		{name: "real column", h: queryhelper.DateHistogram{Field: "at", Interval: "day"}, code: queryhelper.WarningHistogramNotAllowed},
+		{name: "interval", h: queryhelper.DateHistogram{Field: "visited_at", Interval: "year"}, code: queryhelper.WarningIntervalNotAllowed},
+		{name: "time zone", h: queryhelper.DateHistogram{Field: "visited_at", Interval: "day", TimeZone: "Mars/Olympus"}, code: queryhelper.WarningUnknownTimeZone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, warnings := visitsHistogram(t, "", &tt.h)
+			if got != nil {
+				t.Errorf("got buckets %q", got)
+			}
+			if len(warnings) != 1 || warnings[0].Code != tt.code {
+				t.Errorf("warnings %+v, want %s", warnings, tt.code)
+			}
+		})
+	}
+}
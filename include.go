@@ -0,0 +1,375 @@
+package queryhelper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IncludeSettings describes a relation a request may include, and the
+// constraints applied to the preloaded rows.
+type IncludeSettings struct {
+	Relation string         `json:"relation"` // gorm relation name, defaults to the include name
+	Limit    int            `json:"limit"`    // related rows per parent, 0 for all
+	OrderBy  []string       `json:"order_by"` // columns ordering the related rows, "-" prefix for descending
+	Settings *QuerySettings `json:"settings"` // filters allowed on the relation, nil allows none
+}
+
+// WithIncludes preloads the named AllowedIncludes with the rows.
+func WithIncludes(names []string) Option {
+	return func(dq *QueryHelper) {
+		dq.queryConditions.Includes = names
+	}
+}
+
+// WithIncludeFilters filters the preloaded rows of each include, keyed by
+// include name. Filters are appended to those already given.
+func WithIncludeFilters(filters map[string][]FilterCondition) Option {
+	return func(dq *QueryHelper) {
+
+		if len(filters) == 0 {
+			return
+		}
+
+		if dq.queryConditions.IncludeFilters == nil {
+			dq.queryConditions.IncludeFilters = make(map[string][]FilterCondition, len(filters))
+		}
+
+		for name, f := range filters {
+			dq.queryConditions.IncludeFilters[name] = append(dq.queryConditions.IncludeFilters[name], f...)
+		}
+	}
+}
+
+// normalizeIncludes drops includes that are not allowed and validates the
+// filters of the remaining ones against their include settings.
+func (ch *ConditionsHandle) normalizeIncludes(conditions *QueryConditions, filterErrors *[]*FilterError) {
+
+	allowed := ch.Settings.AllowedIncludes
+
+	includes := make([]string, 0, len(conditions.Includes))
+	for _, name := range conditions.Includes {
+		if include, ok := allowed[name]; !ok || include == nil || containsString(includes, name) {
+			if !ok || include == nil {
+				ch.drop(DroppedItem{Kind: DroppedInclude, Code: WarningIncludeNotAllowed, Field: name, Reason: "relation is not includable"})
+			}
+			continue
+		}
+
+		includes = append(includes, name)
+	}
+
+	// Map iteration order would make drops and errors unstable
+	names := make([]string, 0, len(conditions.IncludeFilters))
+	for name := range conditions.IncludeFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var includeFilters map[string][]FilterCondition
+	for _, name := range names {
+		filters := conditions.IncludeFilters[name]
+
+		if !containsString(includes, name) {
+			for _, filter := range filters {
+				ch.drop(DroppedItem{Kind: DroppedFilter, Code: WarningIncludeNotRequested, Field: name + "." + filter.Field, Operator: filter.Operator, Value: filter.Value, Reason: "relation is not included"})
+			}
+			continue
+		}
+
+		sub := NewConditionsHandle(allowed[name].Settings)
+
+		valid := make([]FilterCondition, 0, len(filters))
+		for _, filter := range filters {
+			f, ok, ferr := sub.normalizeFilter(filter)
+			if ferr != nil {
+				ferr.Field = name + "." + ferr.Field
+				*filterErrors = append(*filterErrors, ferr)
+			}
+			if ok {
+				valid = append(valid, f)
+			}
+		}
+
+		for _, item := range sub.Dropped {
+			item.Field = name + "." + item.Field
+			ch.drop(item)
+		}
+
+		if len(valid) > 0 {
+			if includeFilters == nil {
+				includeFilters = make(map[string][]FilterCondition)
+			}
+			includeFilters[name] = valid
+		}
+	}
+
+	conditions.Includes = includes
+	conditions.IncludeFilters = includeFilters
+}
+
+// applyIncludes preloads the included relations with their filters, order
+// and per-parent limit.
+func (ch *ConditionsHandle) applyIncludes(query *gorm.DB) (*gorm.DB, error) {
+
+	for _, name := range ch.Conditions.Includes {
+		include := ch.Settings.AllowedIncludes[name]
+
+		relation := include.Relation
+		if relation == "" {
+			relation = name
+		}
+
+		// The parent side of the relation is only needed to rank rows per parent
+		var partition []string
+		if include.Limit > 0 {
+			p, err := partitionColumns(query, relation)
+			if err != nil {
+				return nil, fmt.Errorf("include %s: %w", name, err)
+			}
+			partition = p
+		}
+
+		sub := NewConditionsHandle(include.Settings)
+		sub.Conditions = &QueryConditions{Filters: ch.Conditions.IncludeFilters[name]}
+
+		query = query.Preload(relation, func(tx *gorm.DB) *gorm.DB {
+			return preloadScope(tx, sub, include, partition)
+		})
+	}
+
+	return query, nil
+}
+
+// partitionColumns returns the related table's columns pointing at the
+// parent, or nil when the relation has none (many to many and belongs to).
+func partitionColumns(query *gorm.DB, relation string) ([]string, error) {
+
+	stmt := query.Statement
+	if stmt.Model == nil {
+		return nil, fmt.Errorf("a model is required to limit related rows")
+	}
+
+	if err := stmt.Parse(stmt.Model); err != nil {
+		return nil, err
+	}
+
+	rel, ok := stmt.Schema.Relationships.Relations[relation]
+	if !ok {
+		return nil, fmt.Errorf("unknown relation %s", relation)
+	}
+
+	if rel.JoinTable != nil {
+		return nil, nil
+	}
+
+	columns := make([]string, 0, len(rel.References))
+	for _, ref := range rel.References {
+		if ref.OwnPrimaryKey && ref.PrimaryValue == "" {
+			columns = append(columns, ref.ForeignKey.DBName)
+		}
+	}
+
+	return columns, nil
+}
+
+func preloadScope(tx *gorm.DB, sub *ConditionsHandle, include *IncludeSettings, partition []string) *gorm.DB {
+
+	dialect := dialectName(tx)
+	filters := sub.whereExprs(dialect)
+
+	for _, expr := range filters {
+		tx = tx.Where(expr)
+	}
+
+	orderCols := make([]clause.OrderByColumn, 0, len(include.OrderBy))
+	for _, entry := range include.OrderBy {
+		prefix, field := splitOrderBy(entry)
+		orderCols = append(orderCols, clause.OrderByColumn{Column: clause.Column{Name: field}, Desc: prefix == "-"})
+	}
+
+	if include.Limit > 0 && len(partition) > 0 {
+		if lookupDialect(dialect).NoWindowFunctions {
+			tx = limitPerParentCorrelated(tx, include.Limit, partition, orderCols, filters)
+		} else {
+			tx = limitPerParent(tx, include.Limit, partition, orderCols, filters)
+		}
+	}
+
+	if len(orderCols) > 0 {
+		tx = tx.Order(clause.OrderBy{Columns: orderCols})
+	}
+
+	return tx
+}
+
+// limitPerParent keeps the first limit related rows of each parent with
+//
+//	pk IN (SELECT pk FROM (SELECT pk, ROW_NUMBER() OVER (PARTITION BY fk
+//	ORDER BY ...) AS qh_rank FROM related WHERE fk IN (parents) AND filters)
+//	qh_ranked WHERE qh_rank <= limit)
+//
+// Only the children of the loaded parents matching the include filters are
+// ranked, not the whole related table.
+func limitPerParent(tx *gorm.DB, limit int, partition []string, orderCols []clause.OrderByColumn, filters []clause.Expression) *gorm.DB {
+
+	pk, ok := relatedPrimaryKey(tx)
+	if !ok {
+		tx.AddError(fmt.Errorf("related model has no single primary key"))
+		return tx
+	}
+
+	vars := []interface{}{clause.Column{Name: pk}}
+
+	var over strings.Builder
+	over.WriteString("?, ROW_NUMBER() OVER (PARTITION BY ")
+	for i, column := range partition {
+		if i > 0 {
+			over.WriteString(", ")
+		}
+		over.WriteString("?")
+		vars = append(vars, clause.Column{Name: column})
+	}
+
+	// Rank by primary key when no order is configured so ranks are stable
+	if len(orderCols) == 0 {
+		orderCols = []clause.OrderByColumn{{Column: clause.Column{Name: pk}}}
+	}
+	// clause.OrderBy renders its own ORDER BY keyword
+	over.WriteString(" ?) AS qh_rank")
+	vars = append(vars, clause.OrderBy{Columns: orderCols})
+
+	// The ranked rows are the filtered children of the loaded parents
+	ranked := tx.Session(&gorm.Session{NewDB: true}).Model(tx.Statement.Model).Select(over.String(), vars...)
+	for _, expr := range parentKeys(tx, partition) {
+		ranked = ranked.Where(expr)
+	}
+	for _, expr := range filters {
+		ranked = ranked.Where(expr)
+	}
+	keys := tx.Session(&gorm.Session{NewDB: true}).
+		Table("(?) qh_ranked", ranked).
+		Select("?", clause.Column{Name: pk}).
+		Where("qh_rank <= ?", limit)
+
+	return tx.Where("? IN (?)", clause.Column{Table: clause.CurrentTable, Name: pk}, keys)
+}
+
+// parentKeys returns the IN on the partition columns gorm adds to a preload
+// before its scope runs, restricting the related rows to the loaded parents.
+func parentKeys(tx *gorm.DB, partition []string) []clause.Expression {
+
+	c, ok := tx.Statement.Clauses["WHERE"]
+	if !ok {
+		return nil
+	}
+
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return nil
+	}
+
+	var keys []clause.Expression
+	for _, expr := range where.Exprs {
+		in, ok := expr.(clause.IN)
+		if !ok {
+			continue
+		}
+
+		switch column := in.Column.(type) {
+		case clause.Column:
+			if containsString(partition, column.Name) {
+				keys = append(keys, in)
+			}
+		case []clause.Column:
+			if len(column) > 0 && containsString(partition, column[0].Name) {
+				keys = append(keys, in)
+			}
+		}
+	}
+
+	return keys
+}
+
+// limitPerParentCorrelated is the fallback for databases without window
+// functions, such as MySQL before 8.0. A row is kept when fewer than limit
+// rows of the same parent sort before it on the first order column, so ties
+// on that column may return more than limit rows.
+func limitPerParentCorrelated(tx *gorm.DB, limit int, partition []string, orderCols []clause.OrderByColumn, filters []clause.Expression) *gorm.DB {
+
+	pk, ok := relatedPrimaryKey(tx)
+	if !ok {
+		tx.AddError(fmt.Errorf("related model has no single primary key"))
+		return tx
+	}
+
+	table := tx.Statement.Table
+
+	order := clause.OrderByColumn{Column: clause.Column{Name: pk}}
+	if len(orderCols) > 0 {
+		order = orderCols[0]
+	}
+
+	peers := tx.Session(&gorm.Session{NewDB: true}).Table("? qh_peer", clause.Table{Name: table}).Select("COUNT(*)")
+
+	for _, column := range partition {
+		peers = peers.Where("? = ?", clause.Column{Table: "qh_peer", Name: column}, clause.Column{Table: table, Name: column})
+	}
+
+	// Peers sorting before the row
+	op := "<"
+	if order.Desc {
+		op = ">"
+	}
+	peers = peers.Where("? "+op+" ?", clause.Column{Table: "qh_peer", Name: order.Column.Name}, clause.Column{Table: table, Name: order.Column.Name})
+
+	// Only peers matching the include filters count towards the limit;
+	// unqualified columns resolve to qh_peer
+	for _, expr := range filters {
+		peers = peers.Where(expr)
+	}
+
+	return tx.Where("(?) < ?", peers, limit)
+}
+
+// withoutPreloads returns the query without its preloads, for counting and
+// plucking keys where gorm cannot load associations.
+func withoutPreloads(query *gorm.DB) *gorm.DB {
+
+	if len(query.Statement.Preloads) == 0 {
+		return query
+	}
+
+	// Scopes forces a copy of the statement before it is modified
+	tx := query.Session(&gorm.Session{}).Scopes()
+	tx.Statement.Preloads = map[string][]interface{}{}
+
+	return tx
+}
+
+// copyPreloads adds the preloads of from to a query built from scratch.
+func copyPreloads(query *gorm.DB, from *gorm.DB) *gorm.DB {
+
+	for name, args := range from.Statement.Preloads {
+		query = query.Preload(name, args...)
+	}
+
+	return query
+}
+
+func relatedPrimaryKey(tx *gorm.DB) (string, bool) {
+
+	if err := tx.Statement.Parse(tx.Statement.Model); err != nil {
+		return "", false
+	}
+
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return "", false
+	}
+
+	return field.DBName, true
+}
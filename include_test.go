@@ -0,0 +1,98 @@
+package queryhelper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/logger"
+)
+
+type testPost struct {
+	ID       uint
+	Comments []testComment `gorm:"foreignKey:PostID"`
+}
+
+type testComment struct {
+	ID     uint
+	PostID uint
+	Status string
+}
+
+// postsDB answers queries for posts with two posts, so their comments are
+// preloaded, and records every statement.
+func postsDB(t *testing.T, dialect string) (*gorm.DB, *[]string) {
+
+	t.Helper()
+
+	db, err := gorm.Open(queryhelpertest.Dialector{Dialect: dialect}, &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var statements []string
+	err = db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+
+		callbacks.BuildQuerySQL(tx)
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+
+		switch dest := tx.Statement.Dest.(type) {
+		case *int64:
+			*dest = 2
+		case *[]testPost:
+			*dest = []testPost{{ID: 1}, {ID: 2}}
+			tx.RowsAffected = 2
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, &statements
+}
+
+func TestIncludeLimitRanksLoadedParentsOnly(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedIncludes: map[string]*queryhelper.IncludeSettings{
+			"comments": {
+				Relation: "Comments",
+				Limit:    3,
+				OrderBy:  []string{"-id"},
+				Settings: &queryhelper.QuerySettings{AllowedFilters: map[string][]string{"status": {"="}}},
+			},
+		},
+	}
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithIncludes([]string{"comments"}),
+		queryhelper.WithIncludeFilters(map[string][]queryhelper.FilterCondition{"comments": {{Field: "status", Operator: "=", Value: "visible"}}}),
+	)
+
+	db, statements := postsDB(t, "sqlite")
+	query, err := dq.Apply(settings, db.Model(&testPost{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var posts []testPost
+	if err := query.Find(&posts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var preload string
+	for _, s := range *statements {
+		if strings.Contains(s, "qh_rank") {
+			preload = s
+		}
+	}
+
+	want := `SELECT "id", ROW_NUMBER() OVER (PARTITION BY "post_id" ORDER BY "id" DESC) AS qh_rank FROM "test_comments" ` +
+		`WHERE "test_comments"."post_id" IN (1,2) AND "status" = 'visible') qh_ranked WHERE qh_rank <= 3`
+	if !strings.Contains(preload, want) {
+		t.Errorf("preload\n%s\nwant it to contain\n%s", preload, want)
+	}
+}
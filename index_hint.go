@@ -0,0 +1,148 @@
+package queryhelper
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	IndexHintUse    = "USE"
+	IndexHintForce  = "FORCE"
+	IndexHintIgnore = "IGNORE"
+)
+
+// IndexHint tells the optimizer which indexes to use on the main table. It is
+// rendered as USE/FORCE/IGNORE INDEX on MySQL and as a WITH (INDEX(...)) table
+// hint on SQL Server for USE and FORCE, right after the main table and before
+// any join. Other dialects ignore it.
+type IndexHint struct {
+	Type    string   `json:"type"` // USE (default), FORCE, IGNORE
+	Indexes []string `json:"indexes"`
+	Count   bool     `json:"count"` // hint the count query as well
+}
+
+func (ch *ConditionsHandle) indexHint() *IndexHint {
+
+	settings := ch.Settings
+
+	hint := settings.IndexHint
+	if settings.IndexHintFunc != nil {
+		hint = settings.IndexHintFunc(ch.Conditions)
+	}
+
+	if hint == nil || len(hint.Indexes) == 0 {
+		return nil
+	}
+
+	return hint
+}
+
+// indexHintClause writes the hint after the main table of the FROM clause.
+type indexHintClause struct {
+	hint    *IndexHint
+	dialect string
+}
+
+func (c indexHintClause) hintType() string {
+
+	hintType := strings.ToUpper(c.hint.Type)
+	if hintType == "" {
+		hintType = IndexHintUse
+	}
+
+	return hintType
+}
+
+func (c indexHintClause) ModifyStatement(stmt *gorm.Statement) {
+
+	switch c.dialect {
+	case "mysql":
+	case "sqlserver":
+		// SQL Server has no way to merely suggest or exclude an index
+		if c.hintType() == IndexHintIgnore {
+			return
+		}
+	default:
+		return
+	}
+
+	from := stmt.Clauses["FROM"]
+	from.Builder = c.buildFrom
+	stmt.Clauses["FROM"] = from
+}
+
+// buildFrom writes the FROM clause like gorm does, with the hint between the
+// main table and the joins. Counts are left without it unless Count is set.
+func (c indexHintClause) buildFrom(from clause.Clause, builder clause.Builder) {
+
+	expr, ok := from.Expression.(clause.From)
+	if !ok {
+		from.Builder = nil
+		from.Build(builder)
+		return
+	}
+
+	if from.BeforeExpression != nil {
+		from.BeforeExpression.Build(builder)
+		builder.WriteByte(' ')
+	}
+
+	builder.WriteString("FROM ")
+	if len(expr.Tables) == 0 {
+		builder.WriteQuoted(clause.Table{Name: clause.CurrentTable})
+	}
+	for i, table := range expr.Tables {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteQuoted(table)
+	}
+
+	if stmt, isStmt := builder.(*gorm.Statement); !isStmt || !isCount(stmt) || c.hint.Count {
+		builder.WriteByte(' ')
+		c.Build(builder)
+	}
+
+	for _, join := range expr.Joins {
+		builder.WriteByte(' ')
+		join.Build(builder)
+	}
+
+	if from.AfterExpression != nil {
+		builder.WriteByte(' ')
+		from.AfterExpression.Build(builder)
+	}
+}
+
+func (c indexHintClause) Build(builder clause.Builder) {
+
+	switch c.dialect {
+	case "mysql":
+		builder.WriteString(c.hintType() + " INDEX (")
+	case "sqlserver":
+		builder.WriteString("WITH (INDEX(")
+	}
+
+	for i, index := range c.hint.Indexes {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteQuoted(index)
+	}
+
+	builder.WriteByte(')')
+	if c.dialect == "sqlserver" {
+		builder.WriteByte(')')
+	}
+}
+
+// isCount reports whether the statement is a Count, which finds into an
+// int64.
+func isCount(stmt *gorm.Statement) bool {
+
+	_, ok := stmt.Dest.(*int64)
+
+	return ok
+}
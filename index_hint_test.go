@@ -0,0 +1,148 @@
+package queryhelper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+// recordQueries records the SQL of every query db runs.
+func recordQueries(t *testing.T, db *gorm.DB) *[]string {
+
+	t.Helper()
+
+	var statements []string
+	err := db.Callback().Query().After("gorm:query").Register("test:record", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &statements
+}
+
+func TestIndexHint(t *testing.T) {
+
+	hintSettings := func(hint *queryhelper.IndexHint) *queryhelper.QuerySettings {
+		return &queryhelper.QuerySettings{
+			AllowedFilters: map[string][]string{"status": {"="}, "company.name": {"="}},
+			Relations:      map[string]*queryhelper.Relation{"company": {Table: "companies", ForeignKey: "company_id"}},
+			IndexHint:      hint,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		dialect   string
+		hint      *queryhelper.IndexHint
+		wantFind  string
+		wantCount string
+	}{
+		{
+			name:      "mysql",
+			dialect:   "mysql",
+			hint:      &queryhelper.IndexHint{Indexes: []string{"idx_status_created"}},
+			wantFind:  "FROM `users` USE INDEX (`idx_status_created`) LEFT JOIN `companies` `company` ON ",
+			wantCount: "SELECT count(*) FROM `users` LEFT JOIN ",
+		},
+		{
+			name:      "mysql force on count",
+			dialect:   "mysql",
+			hint:      &queryhelper.IndexHint{Type: "force", Indexes: []string{"a", "b"}, Count: true},
+			wantFind:  "FROM `users` FORCE INDEX (`a`,`b`) LEFT JOIN ",
+			wantCount: "SELECT count(*) FROM `users` FORCE INDEX (`a`,`b`) LEFT JOIN ",
+		},
+		{
+			name:      "sqlserver",
+			dialect:   "sqlserver",
+			hint:      &queryhelper.IndexHint{Indexes: []string{"idx_status_created"}},
+			wantFind:  `FROM "users" WITH (INDEX("idx_status_created")) LEFT JOIN `,
+			wantCount: `SELECT count(*) FROM "users" LEFT JOIN `,
+		},
+		{
+			name:      "sqlserver ignore",
+			dialect:   "sqlserver",
+			hint:      &queryhelper.IndexHint{Type: queryhelper.IndexHintIgnore, Indexes: []string{"idx_status_created"}},
+			wantFind:  `FROM "users" LEFT JOIN `,
+			wantCount: `SELECT count(*) FROM "users" LEFT JOIN `,
+		},
+		{
+			name:      "postgres",
+			dialect:   "postgres",
+			hint:      &queryhelper.IndexHint{Indexes: []string{"idx_status_created"}},
+			wantFind:  `FROM "users" LEFT JOIN `,
+			wantCount: `SELECT count(*) FROM "users" LEFT JOIN `,
+		},
+		{
+			name:      "sqlite",
+			dialect:   "sqlite",
+			hint:      &queryhelper.IndexHint{Indexes: []string{"idx_status_created"}},
+			wantFind:  `FROM "users" LEFT JOIN `,
+			wantCount: `SELECT count(*) FROM "users" LEFT JOIN `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := queryhelpertest.DryRunDB(t, tt.dialect)
+			statements := recordQueries(t, db)
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "active"), queryhelper.WithEqual("company.name", "acme"))
+			query, err := dq.Apply(hintSettings(tt.hint), db.Model(&testUser{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(*statements) != 1 || !strings.HasPrefix((*statements)[0], tt.wantCount) {
+				t.Errorf("count %q, want it to start with\n%s", *statements, tt.wantCount)
+			}
+
+			got := findSQL(t, query)
+			if !strings.Contains(got, tt.wantFind) {
+				t.Errorf("got\n%s\nwant it to contain\n%s", got, tt.wantFind)
+			}
+			if tt.dialect != "mysql" && strings.Contains(got, "INDEX (") {
+				t.Errorf("%s renders a MySQL hint:\n%s", tt.dialect, got)
+			}
+		})
+	}
+}
+
+func TestIndexHintFunc(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"status": {"="}, "age": {">="}},
+		IndexHintFunc: func(c *queryhelper.QueryConditions) *queryhelper.IndexHint {
+			for _, f := range c.Filters {
+				if f.Field == "status" {
+					return &queryhelper.IndexHint{Indexes: []string{"idx_status"}}
+				}
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		filter queryhelper.Option
+		want   string
+	}{
+		{filter: queryhelper.WithEqual("status", "active"), want: "FROM `users` USE INDEX (`idx_status`) WHERE"},
+		{filter: queryhelper.WithFilter("age", ">=", 18), want: "FROM `users` WHERE"},
+	}
+
+	for _, tt := range tests {
+		query, err := queryhelper.NewQueryHelper(tt.filter).Apply(settings, queryhelpertest.DryRunDB(t, "mysql").Model(&testUser{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := findSQL(t, query); !strings.Contains(got, tt.want) {
+			t.Errorf("got\n%s\nwant it to contain\n%s", got, tt.want)
+		}
+	}
+}
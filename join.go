@@ -0,0 +1,301 @@
+package queryhelper
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Relation is a table joined to the query so requests can reference its
+// columns as "relation.column", e.g. "company.name". Nested relations are
+// registered under their full path, e.g. "company.parent", next to their
+// parents.
+type Relation struct {
+	Table      string `json:"table"`       // joined table
+	ForeignKey string `json:"foreign_key"` // column of the parent table, e.g. company_id
+	References string `json:"references"`  // column of Table, defaults to id
+	Inner      bool   `json:"inner"`       // INNER JOIN instead of LEFT JOIN
+}
+
+// relationAlias is the stable alias a relation path is joined as, e.g.
+// "company.parent" is joined as company__parent.
+func relationAlias(path string) string {
+	return strings.ReplaceAll(path, ".", "__")
+}
+
+// relationColumn maps "company.parent.name" to "company__parent.name" when
+// company.parent is a registered relation, and returns the relation path.
+func relationColumn(relations map[string]*Relation, column string) (string, string) {
+
+	i := strings.LastIndexByte(column, '.')
+	if i < 0 {
+		return column, ""
+	}
+
+	path := column[:i]
+	if _, ok := relations[path]; !ok {
+		return column, ""
+	}
+
+	return relationAlias(path) + column[i:], path
+}
+
+// joinSet collects the relations one Apply needs, each joined once.
+type joinSet struct {
+	relations map[string]*Relation
+	paths     map[string]struct{}
+}
+
+// column maps a column to its relation alias and records the relation and
+// its parents. Other columns are returned unchanged.
+func (j *joinSet) column(column string) string {
+
+	aliased, path := relationColumn(j.relations, column)
+	if path == "" {
+		return column
+	}
+
+	// Parents are joined before the relations hanging off them
+	for p := path; ; {
+		if _, ok := j.relations[p]; ok {
+			j.paths[p] = struct{}{}
+		}
+
+		k := strings.LastIndexByte(p, '.')
+		if k < 0 {
+			break
+		}
+		p = p[:k]
+	}
+
+	return aliased
+}
+
+func (j *joinSet) columns(columns []string) []string {
+
+	if columns == nil {
+		return nil
+	}
+
+	mapped := make([]string, len(columns))
+	for i, column := range columns {
+		mapped[i] = j.column(column)
+	}
+
+	return mapped
+}
+
+func (j *joinSet) filters(filters []FilterCondition) []FilterCondition {
+
+	if filters == nil {
+		return nil
+	}
+
+	mapped := make([]FilterCondition, len(filters))
+	for i, filter := range filters {
+		filter.Field = j.column(filter.Field)
+		mapped[i] = filter
+	}
+
+	return mapped
+}
+
+func (j *joinSet) groups(groups []FilterGroup) []FilterGroup {
+
+	if groups == nil {
+		return nil
+	}
+
+	mapped := make([]FilterGroup, len(groups))
+	for i, group := range groups {
+		group.Filters = j.filters(group.Filters)
+		group.Groups = j.groups(group.Groups)
+		mapped[i] = group
+	}
+
+	return mapped
+}
+
+// resolveJoins returns a handle whose conditions reference joined relations
+// by alias, and the query with every relation they need joined once.
+func (ch *ConditionsHandle) resolveJoins(query *gorm.DB) (*ConditionsHandle, *gorm.DB) {
+
+	if len(ch.Settings.Relations) == 0 {
+		return ch.qualified(query.Statement, false), query
+	}
+
+	j := ch.joinSet()
+
+	conditions := ch.whereConditions(j)
+	conditions.Fields = j.columns(conditions.Fields)
+
+	conditions.OrderBy = make([]string, len(ch.Conditions.OrderBy))
+	for i, entry := range ch.Conditions.OrderBy {
+		prefix, field := splitOrderBy(entry)
+		conditions.OrderBy[i] = prefix + j.column(field)
+	}
+
+	if len(j.paths) == 0 {
+		return ch.qualified(query.Statement, false), query
+	}
+
+	for _, path := range j.sortedPaths() {
+		query = joinRelation(query, path, j.relations)
+	}
+
+	resolved := *ch.qualified(query.Statement, false)
+	resolved.Conditions = conditions
+
+	return &resolved, query
+}
+
+// resolveWhere returns a handle whose filters and search reference relations
+// by alias, as Apply resolves them, and the relations they need joined.
+func (ch *ConditionsHandle) resolveWhere() (*ConditionsHandle, *joinSet) {
+
+	j := ch.joinSet()
+	if len(j.relations) == 0 {
+		return ch, j
+	}
+
+	resolved := *ch
+	resolved.Conditions = ch.whereConditions(j)
+
+	return &resolved, j
+}
+
+func (ch *ConditionsHandle) joinSet() *joinSet {
+
+	return &joinSet{
+		relations: ch.Settings.Relations,
+		paths:     make(map[string]struct{}),
+	}
+}
+
+// whereConditions maps the filters, filter groups and search fields to
+// relation aliases, recording the relations in j.
+func (ch *ConditionsHandle) whereConditions(j *joinSet) *QueryConditions {
+
+	conditions := *ch.Conditions
+	// Search fields are not searched, nor joined, without a search text
+	if strings.TrimSpace(conditions.SearchText) != "" {
+		conditions.SearchFields = j.columns(conditions.SearchFields)
+	}
+	conditions.Filters = j.filters(conditions.Filters)
+	conditions.FilterGroups = j.groups(conditions.FilterGroups)
+
+	return &conditions
+}
+
+// sortedPaths returns the recorded relations, shorter paths first so a
+// relation's parent is always joined before it.
+func (j *joinSet) sortedPaths() []string {
+
+	paths := make([]string, 0, len(j.paths))
+	for path := range j.paths {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(a, b int) bool {
+		da, db := strings.Count(paths[a], "."), strings.Count(paths[b], ".")
+		if da != db {
+			return da < db
+		}
+		return paths[a] < paths[b]
+	})
+
+	return paths
+}
+
+// Joins joins the relations the filters and search of the validated
+// conditions reference, under the aliases Expression uses for them. Call it
+// on the query an Expression is embedded in.
+func (ch *ConditionsHandle) Joins(db *gorm.DB) *gorm.DB {
+
+	if db == nil || ch.Conditions == nil {
+		return db
+	}
+
+	_, j := ch.resolveWhere()
+	for _, path := range j.sortedPaths() {
+		db = joinRelation(db, path, j.relations)
+	}
+
+	return db
+}
+
+// qualified returns a handle qualifying the table's own columns when the
+// statement joins other tables, which may have columns of the same names,
+// or joined is set.
+func (ch *ConditionsHandle) qualified(stmt *gorm.Statement, joined bool) *ConditionsHandle {
+
+	if len(stmt.Joins) == 0 && !joined {
+		return ch
+	}
+
+	// A derived table is written whole where its name would go
+	if stmt.TableExpr != nil {
+		return ch
+	}
+
+	qualified := *ch
+	qualified.table = clause.CurrentTable
+
+	return &qualified
+}
+
+// ownColumn returns a column of the query's table, qualified with table
+// when that is set. Relation columns, already "alias.column", are left as
+// they are.
+func ownColumn(table string, name string) clause.Column {
+
+	if table == "" || strings.Contains(name, ".") {
+		return clause.Column{Name: name}
+	}
+
+	return clause.Column{Table: table, Name: name}
+}
+
+// selectFields selects the sparse fields, qualified in a query with joins.
+func (ch *ConditionsHandle) selectFields(query *gorm.DB) *gorm.DB {
+
+	if ch.table == "" {
+		return query.Select(ch.Conditions.Fields)
+	}
+
+	columns := make([]clause.Column, len(ch.Conditions.Fields))
+	for i, field := range ch.Conditions.Fields {
+		columns[i] = ownColumn(ch.table, field)
+	}
+
+	return query.Clauses(clause.Select{Columns: columns})
+}
+
+func joinRelation(query *gorm.DB, path string, relations map[string]*Relation) *gorm.DB {
+
+	rel := relations[path]
+
+	references := rel.References
+	if references == "" {
+		references = "id"
+	}
+
+	parent := clause.CurrentTable
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		parent = relationAlias(path[:i])
+	}
+
+	join := "LEFT JOIN"
+	if rel.Inner {
+		join = "INNER JOIN"
+	}
+
+	alias := relationAlias(path)
+
+	return query.Joins(join+" ? ON ? = ?",
+		clause.Table{Name: rel.Table, Alias: alias},
+		clause.Column{Table: alias, Name: references},
+		clause.Column{Table: parent, Name: rel.ForeignKey},
+	)
+}
@@ -0,0 +1,149 @@
+package queryhelper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+var joinSettings = &queryhelper.QuerySettings{
+	AllowedFilters: map[string][]string{"company.name": {"="}, "company.parent.name": {"="}, "status": {"="}},
+	AllowedOrderBy: []string{"company.created_at", "name"},
+	AllowedSearch:  []string{"company.description", "name"},
+	AllowedFields:  []string{"id", "name", "company.name"},
+	Relations: map[string]*queryhelper.Relation{
+		"company":        {Table: "companies", ForeignKey: "company_id"},
+		"company.parent": {Table: "companies", ForeignKey: "parent_id"},
+	},
+}
+
+func TestRelationJoinedOnce(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("company.name", "acme"),
+		queryhelper.WithOrderBy([]string{"-company.created_at"}),
+		queryhelper.WithSearchText("x"),
+		queryhelper.WithSearchFields([]string{"company.description"}),
+	)
+
+	query, err := dq.Apply(joinSettings, queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+	if n := strings.Count(got, "JOIN"); n != 1 {
+		t.Errorf("%d joins in\n%s", n, got)
+	}
+
+	for _, want := range []string{
+		`LEFT JOIN "companies" "company" ON "company"."id" = "users"."company_id"`,
+		`"company"."name" = 'acme'`,
+		`"company"."description" LIKE '%x%'`,
+		`ORDER BY "company"."created_at" DESC`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to contain\n%s", got, want)
+		}
+	}
+}
+
+func TestNestedRelationJoinedAfterParent(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("company.parent.name", "holding"),
+		queryhelper.WithEqual("company.name", "acme"),
+	)
+
+	query, err := dq.Apply(joinSettings, queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+	want := `FROM "users" LEFT JOIN "companies" "company" ON "company"."id" = "users"."company_id" ` +
+		`LEFT JOIN "companies" "company__parent" ON "company__parent"."id" = "company"."parent_id" ` +
+		`WHERE "company__parent"."name" = 'holding' AND "company"."name" = 'acme'`
+	if !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", got, want)
+	}
+}
+
+func TestJoinedQueryQualifiesOwnColumns(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("company.name", "acme"),
+		queryhelper.WithEqual("status", "active"),
+		queryhelper.WithSearchText("x"),
+		queryhelper.WithSearchFields([]string{"name"}),
+		queryhelper.WithOrderBy([]string{"name"}),
+		queryhelper.WithFields([]string{"id", "name", "company.name"}),
+	)
+
+	query, err := dq.Apply(joinSettings, queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := findSQL(t, query)
+	want := `SELECT "users"."id","users"."name","company"."name" FROM "users" ` +
+		`LEFT JOIN "companies" "company" ON "company"."id" = "users"."company_id" ` +
+		`WHERE "company"."name" = 'acme' AND "users"."status" = 'active' AND "users"."name" LIKE '%x%' ` +
+		`ORDER BY "users"."name" LIMIT 10`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Without joins nothing is qualified. The search fields, defaulting to
+	// company.description, join nothing without a search text
+	dq = queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "active"), queryhelper.WithOrderBy([]string{"name"}))
+	query, err = dq.Apply(joinSettings, queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got = findSQL(t, query)
+	if want := `WHERE "status" = 'active' ORDER BY "name"`; !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", got, want)
+	}
+}
+
+func TestJoinedRowsOnSQLite(t *testing.T) {
+
+	db := customersDB(t)
+
+	// Both tables have id and name
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithEqual("company.name", "globex"),
+		queryhelper.WithOrderBy([]string{"-name"}),
+		queryhelper.WithFields([]string{"id", "name"}),
+	)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"company.name": {"="}},
+		AllowedOrderBy: []string{"name"},
+		AllowedFields:  []string{"id", "name"},
+		Relations:      map[string]*queryhelper.Relation{"company": {Table: "companies", ForeignKey: "company_id"}},
+	}
+
+	var customers []testCustomer
+	if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range customers {
+		got = append(got, c.Name)
+	}
+	if want := "customer 12,customer 09,customer 06,customer 03"; strings.Join(got, ",") != want {
+		t.Errorf("found %v, want %s", got, want)
+	}
+	if customers[0].ID != 1 {
+		t.Errorf("customer 12 has id %d, want 1", customers[0].ID)
+	}
+	if total := dq.Info().Pagination.Total; total != 4 {
+		t.Errorf("total %d, want 4", total)
+	}
+}
@@ -0,0 +1,238 @@
+// Package jsonapi parses JSON:API style query parameters such as
+// filter[status]=active&filter[age][gte]=18&sort=-created_at,name&page[number]=2
+// into query conditions and pagination.
+package jsonapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weedbox/queryhelper"
+)
+
+// Error reports an invalid query parameter. It matches
+// queryhelper.ErrInvalidConditions with errors.Is.
+type Error struct {
+	Parameter string
+	Message   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonapi: %s: %s", e.Parameter, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == queryhelper.ErrInvalidConditions
+}
+
+// Parse maps JSON:API query parameters onto query conditions and a
+// pagination request. A filter without an operator uses "=", or "IN" when
+// its value is a comma separated list. Sort fields with a leading minus sort
+// descending. Filter fields and operators are checked against the settings.
+func Parse(values url.Values, settings *queryhelper.QuerySettings) (*queryhelper.QueryConditions, *queryhelper.PaginationRequest, error) {
+
+	if settings == nil {
+		settings = queryhelper.DefaultQuerySettings
+	}
+
+	conditions := &queryhelper.QueryConditions{}
+	pagination := &queryhelper.PaginationRequest{}
+
+	// Iterate in a stable order so errors and filters are deterministic
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filterErrors []*queryhelper.FilterError
+	fieldsets := 0
+
+	for _, key := range keys {
+		value := values.Get(key)
+
+		name, path, err := splitKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch name {
+		case "filter":
+			filter, ferr := parseFilter(path, value, settings)
+			if ferr != nil {
+				filterErrors = append(filterErrors, ferr)
+				continue
+			}
+			conditions.Filters = append(conditions.Filters, filter)
+		case "sort":
+			if len(path) > 0 {
+				return nil, nil, &Error{Parameter: key, Message: "unexpected brackets"}
+			}
+			conditions.OrderBy = parseSort(value)
+		case "page":
+			if len(path) != 1 {
+				return nil, nil, &Error{Parameter: key, Message: "expected page[number], page[size], page[offset] or page[limit]"}
+			}
+
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, nil, &Error{Parameter: key, Message: fmt.Sprintf("invalid value %q", value)}
+			}
+
+			switch path[0] {
+			case "number":
+				pagination.Page = n
+			case "size":
+				pagination.PageSize = n
+			case "offset":
+				pagination.Offset = &n
+			case "limit":
+				pagination.Limit = &n
+			default:
+				return nil, nil, &Error{Parameter: key, Message: "expected page[number], page[size], page[offset] or page[limit]"}
+			}
+		case "include":
+			if len(path) > 0 {
+				return nil, nil, &Error{Parameter: key, Message: "unexpected brackets"}
+			}
+			conditions.Includes = splitList(value)
+		case "fields":
+			// Only the primary resource's fieldset maps onto the select
+			if len(path) != 1 {
+				return nil, nil, &Error{Parameter: key, Message: "expected fields[type]"}
+			}
+
+			fieldsets++
+			if fieldsets > 1 {
+				return nil, nil, &Error{Parameter: key, Message: "fieldsets for included types are not supported"}
+			}
+
+			conditions.Fields = splitList(value)
+		}
+	}
+
+	if len(filterErrors) > 0 {
+		return nil, nil, &queryhelper.ValidationError{Errors: filterErrors}
+	}
+
+	return conditions, pagination, nil
+}
+
+// splitKey splits "filter[age][gte]" into "filter" and ["age", "gte"].
+func splitKey(key string) (string, []string, error) {
+
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		return key, nil, nil
+	}
+
+	name := key[:i]
+	rest := key[i:]
+
+	path := make([]string, 0)
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return "", nil, &Error{Parameter: key, Message: "malformed brackets"}
+		}
+
+		path = append(path, rest[1:end])
+		rest = rest[end+1:]
+	}
+
+	return name, path, nil
+}
+
+func parseFilter(path []string, value string, settings *queryhelper.QuerySettings) (queryhelper.FilterCondition, *queryhelper.FilterError) {
+
+	filter := queryhelper.FilterCondition{}
+
+	if len(path) == 0 || len(path) > 2 || path[0] == "" {
+		return filter, &queryhelper.FilterError{Err: fmt.Errorf("expected filter[field] or filter[field][operator]")}
+	}
+
+	filter.Field = path[0]
+
+	values := splitList(value)
+
+	switch {
+	case len(path) == 2:
+		token := path[1]
+		operator, ok := queryhelper.OperatorTokens[token]
+		if !ok {
+			return filter, &queryhelper.FilterError{Field: filter.Field, Operator: token, Err: fmt.Errorf("unknown operator")}
+		}
+		filter.Operator = operator
+	case len(values) > 1:
+		filter.Operator = "IN"
+	default:
+		filter.Operator = "="
+	}
+
+	switch filter.Operator {
+	case "IN", "NOT IN", "REL_ANY", "REL_ALL":
+		filter.Value = toInterfaces(values)
+	case "BETWEEN":
+		if len(values) != 2 {
+			return filter, &queryhelper.FilterError{Field: filter.Field, Operator: filter.Operator, Err: fmt.Errorf("expected two comma separated values")}
+		}
+		filter.Value = toInterfaces(values)
+	case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+	default:
+		filter.Value = value
+	}
+
+	allowedOps, ok := settings.AllowedFilters[filter.Field]
+	if !ok {
+		return filter, &queryhelper.FilterError{Field: filter.Field, Operator: filter.Operator, Err: fmt.Errorf("field is not filterable")}
+	}
+
+	for _, op := range allowedOps {
+		if op == filter.Operator {
+			return filter, nil
+		}
+	}
+
+	return filter, &queryhelper.FilterError{Field: filter.Field, Operator: filter.Operator, Err: fmt.Errorf("operator is not allowed")}
+}
+
+func parseSort(value string) []string {
+
+	fields := make([]string, 0)
+	for _, field := range splitList(value) {
+		if strings.HasPrefix(field, "-") {
+			fields = append(fields, field)
+			continue
+		}
+
+		fields = append(fields, "+"+field)
+	}
+
+	return fields
+}
+
+func splitList(value string) []string {
+
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func toInterfaces(values []string) []interface{} {
+
+	vals := make([]interface{}, len(values))
+	for i, v := range values {
+		vals[i] = v
+	}
+
+	return vals
+}
@@ -0,0 +1,149 @@
+package jsonapi
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type user struct {
+	ID        uint
+	Name      string
+	Status    string
+	Age       int
+	CreatedAt string
+}
+
+var settings = &queryhelper.QuerySettings{
+	AllowedOrderBy: []string{"name", "created_at"},
+	AllowedFields:  []string{"id", "name", "status"},
+	AllowedFilters: map[string][]string{
+		"status": {"=", "IN", "NOT IN"},
+		"age":    {">=", "<", "BETWEEN"},
+		"name":   {"LIKE", "IS NULL"},
+	},
+	FieldTypes: map[string]string{"age": queryhelper.FieldTypeInt},
+}
+
+// TestParseSQL round-trips realistic URLs into the SQL they query with.
+func TestParseSQL(t *testing.T) {
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{
+			url:  `/users?filter[status]=active&filter[age][gte]=18&sort=-created_at,name&page[number]=2&page[size]=25&fields[users]=id,name`,
+			want: `SELECT "id","name" FROM "users" WHERE "age" >= 18 AND "status" = 'active' ORDER BY "created_at" DESC,"name" LIMIT 25 OFFSET 25`,
+		},
+		{
+			url:  `/users?filter[status]=active,pending&sort=name`,
+			want: `SELECT * FROM "users" WHERE "status" IN ('active','pending') ORDER BY "name" LIMIT 10`,
+		},
+		{
+			url:  `/users?filter[status][nin]=banned,deleted&filter[age][between]=18,65&page[offset]=40&page[limit]=20`,
+			want: `SELECT * FROM "users" WHERE ("age" BETWEEN 18 AND 65) AND "status" NOT IN ('banned','deleted') ORDER BY "name","created_at" LIMIT 20 OFFSET 40`,
+		},
+		{
+			url:  `/users?filter[name][like]=Ann%25&filter[age][lt]=30`,
+			want: `SELECT * FROM "users" WHERE "age" < 30 AND "name" LIKE 'Ann%' ESCAPE '\' ORDER BY "name","created_at" LIMIT 10`,
+		},
+		{
+			url:  `/users?filter[name][null]=`,
+			want: `SELECT * FROM "users" WHERE "name" IS NULL ORDER BY "name","created_at" LIMIT 10`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conditions, pagination, err := Parse(u.Query(), settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts := []queryhelper.Option{
+				queryhelper.WithFilters(conditions.Filters),
+				queryhelper.WithOrderBy(conditions.OrderBy),
+				queryhelper.WithFields(conditions.Fields),
+				queryhelper.WithPage(pagination.Page),
+				queryhelper.WithPageSize(pagination.PageSize),
+			}
+			if pagination.Offset != nil {
+				opts = append(opts, queryhelper.WithOffset(*pagination.Offset))
+			}
+			if pagination.Limit != nil {
+				opts = append(opts, queryhelper.WithLimit(*pagination.Limit))
+			}
+
+			query, vars, err := queryhelpertest.RenderSQL(t, "sqlite", queryhelper.NewQueryHelper(opts...), settings, &user{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := queryhelpertest.Dialector{Dialect: "sqlite"}.Explain(query, vars...)
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		query string
+		field string // of the filter error, empty for a parameter error
+		op    string
+	}{
+		{query: `filter[age][gt]=18`, field: "age", op: ">"},
+		{query: `filter[age][around]=18`, field: "age", op: "around"},
+		{query: `filter[password]=x`, field: "password", op: "="},
+		{query: `filter[age][between]=18`, field: "age", op: "BETWEEN"},
+		{query: `filter[age`},
+		{query: `page[cursor]=abc`},
+		{query: `page[number]=two`},
+		{query: `sort[name]=asc`},
+		{query: `fields=id`},
+		{query: `fields[users]=id&fields[companies]=name`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err = Parse(values, settings)
+			if !errors.Is(err, queryhelper.ErrInvalidConditions) {
+				t.Fatalf("err = %v, want ErrInvalidConditions", err)
+			}
+
+			var verr *queryhelper.ValidationError
+			if tt.field == "" {
+				var perr *Error
+				if !errors.As(err, &perr) {
+					t.Errorf("err = %v, want a parameter error", err)
+				}
+				return
+			}
+
+			if !errors.As(err, &verr) || len(verr.Errors) != 1 {
+				t.Fatalf("err = %v, want one filter error", err)
+			}
+			if fe := verr.Errors[0]; fe.Field != tt.field || fe.Operator != tt.op {
+				t.Errorf("filter error on %s %s, want %s %s", fe.Field, fe.Operator, tt.field, tt.op)
+			}
+		})
+	}
+}
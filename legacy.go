@@ -0,0 +1,140 @@
+package queryhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Keys of the legacy request shape:
+//
+//	{"sort": "-created_at", "q": "foo", "filters": {"status": "active"}}
+//
+// sort lists columns separated by commas, each with an optional "-" for
+// descending order, q is the search text and filters maps fields to the
+// values they must equal.
+const (
+	legacySortKey   = "sort"
+	legacySearchKey = "q"
+	filtersKey      = "filters" // an object in the legacy shape, an array otherwise
+)
+
+var (
+	conditionKeysOnce sync.Once
+	conditionKeys     []string // JSON keys of QueryConditions other than filters
+)
+
+// UnmarshalJSON reads conditions in the current shape and in the legacy one,
+// converting the legacy keys. A document mixing keys of both shapes fails
+// with ErrMixedRequestShape, as it is unclear which was meant.
+func (c *QueryConditions) UnmarshalJSON(data []byte) error {
+
+	type plain QueryConditions
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil || !isLegacyRequest(keys) {
+		return json.Unmarshal(data, (*plain)(c))
+	}
+
+	legacy := make([]string, 0, 3)
+	for _, key := range []string{legacySortKey, legacySearchKey} {
+		if _, ok := keys[key]; ok {
+			legacy = append(legacy, key)
+		}
+	}
+
+	current := currentConditionKeys()
+	if raw, ok := keys[filtersKey]; ok {
+		if isJSONObject(raw) {
+			legacy = append(legacy, filtersKey)
+		} else {
+			current = append([]string{filtersKey}, current...)
+		}
+	}
+
+	for _, key := range current {
+		if _, ok := keys[key]; ok {
+			return fmt.Errorf("%w: %s with %s", ErrMixedRequestShape, strings.Join(legacy, ", "), key)
+		}
+	}
+
+	*c = QueryConditions{}
+
+	if raw, ok := keys[legacySortKey]; ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("sort: %w", err)
+		}
+
+		for _, column := range strings.Split(s, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				c.OrderBy = append(c.OrderBy, column)
+			}
+		}
+	}
+
+	if raw, ok := keys[legacySearchKey]; ok {
+		if err := json.Unmarshal(raw, &c.SearchText); err != nil {
+			return fmt.Errorf("q: %w", err)
+		}
+	}
+
+	if raw, ok := keys[filtersKey]; ok {
+		var values map[string]interface{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return fmt.Errorf("filters: %w", err)
+		}
+
+		fields := make([]string, 0, len(values))
+		for field := range values {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			c.Filters = append(c.Filters, FilterCondition{Field: field, Operator: "=", Value: values[field]})
+		}
+	}
+
+	return nil
+}
+
+// isLegacyRequest reports whether a document uses a legacy key. filters is
+// shared by both shapes, and legacy only as an object.
+func isLegacyRequest(keys map[string]json.RawMessage) bool {
+
+	if _, ok := keys[legacySortKey]; ok {
+		return true
+	}
+
+	if _, ok := keys[legacySearchKey]; ok {
+		return true
+	}
+
+	return isJSONObject(keys[filtersKey])
+}
+
+func isJSONObject(raw json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{"))
+}
+
+// currentConditionKeys returns the JSON keys of QueryConditions, but for
+// the filters key both shapes share.
+func currentConditionKeys() []string {
+
+	conditionKeysOnce.Do(func() {
+		t := reflect.TypeOf(QueryConditions{})
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" && name != filtersKey {
+				conditionKeys = append(conditionKeys, name)
+			}
+		}
+	})
+
+	return conditionKeys
+}
@@ -0,0 +1,161 @@
+package queryhelper_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+// TestLegacyRoundTrip checks both request shapes read into the same
+// conditions, which write back in the current shape and read again
+// unchanged.
+func TestLegacyRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		name string
+		body string
+		want queryhelper.QueryConditions
+	}{
+		{
+			name: "legacy",
+			body: `{"sort":"-created_at, name","q":"foo","filters":{"status":"active","age":30}}`,
+			want: queryhelper.QueryConditions{
+				SearchText: "foo",
+				OrderBy:    []string{"-created_at", "name"},
+				Filters: []queryhelper.FilterCondition{
+					{Field: "age", Operator: "=", Value: float64(30)},
+					{Field: "status", Operator: "=", Value: "active"},
+				},
+			},
+		},
+		{
+			name: "legacy search only",
+			body: `{"q":"foo"}`,
+			want: queryhelper.QueryConditions{SearchText: "foo"},
+		},
+		{
+			name: "current",
+			body: `{"search_text":"foo","order_by":["-created_at","name"],"filters":[{"field":"age","operator":"=","value":30},{"field":"status","operator":"=","value":"active"}]}`,
+			want: queryhelper.QueryConditions{
+				SearchText: "foo",
+				OrderBy:    []string{"-created_at", "name"},
+				Filters: []queryhelper.FilterCondition{
+					{Field: "age", Operator: "=", Value: float64(30)},
+					{Field: "status", Operator: "=", Value: "active"},
+				},
+			},
+		},
+		{
+			name: "current with every key",
+			body: `{"search_text":"foo","search_fields":["name"],"sort_factor":-1,"filter_groups":[{"logic":"OR","filters":[{"field":"age","operator":">","value":1}]}],"fields":["id"],"locale":"de"}`,
+			want: queryhelper.QueryConditions{
+				SearchText:   "foo",
+				SearchFields: []string{"name"},
+				SortFactor:   -1,
+				FilterGroups: []queryhelper.FilterGroup{{Logic: "OR", Filters: []queryhelper.FilterCondition{{Field: "age", Operator: ">", Value: float64(1)}}}},
+				Fields:       []string{"id"},
+				Locale:       "de",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var got queryhelper.QueryConditions
+			if err := json.Unmarshal([]byte(tt.body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v\nwant %+v", got, tt.want)
+			}
+
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), `"q"`) || strings.Contains(string(data), `"sort"`) {
+				t.Errorf("written in the legacy shape: %s", data)
+			}
+
+			var again queryhelper.QueryConditions
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again, got) {
+				t.Errorf("read back %+v\nfrom %s\nwant %+v", again, data, got)
+			}
+		})
+	}
+}
+
+func TestLegacyMixedShapes(t *testing.T) {
+
+	for _, body := range []string{
+		`{"q":"foo","search_text":"bar"}`,
+		`{"sort":"-id","order_by":["name"]}`,
+		`{"q":"foo","filters":[{"field":"status","operator":"=","value":"active"}]}`,
+		`{"filters":{"status":"active"},"fields":["id"]}`,
+		`{"sort":"name","locale":"de"}`,
+	} {
+		t.Run(body, func(t *testing.T) {
+
+			var c queryhelper.QueryConditions
+			if err := json.Unmarshal([]byte(body), &c); !errors.Is(err, queryhelper.ErrMixedRequestShape) {
+				t.Errorf("err = %v, want ErrMixedRequestShape", err)
+			}
+		})
+	}
+}
+
+func TestLegacyThroughMiddleware(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"status": {"="}},
+		AllowedSearch:  []string{"name"},
+		AllowedOrderBy: []string{"name"},
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "legacy with pagination", body: `{"page":2,"page_size":5,"sort":"-name","q":"ann","filters":{"status":"active"}}`, status: http.StatusOK},
+		{name: "mixed", body: `{"q":"ann","order_by":["name"]}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var got *queryhelper.QueryConditions
+			handler := queryhelper.Middleware(settings)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				qh, _ := queryhelper.FromContext(r.Context())
+				got = qh.GetQueryConditions()
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d: %s, want %d", w.Code, w.Body, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			want := []queryhelper.FilterCondition{{Field: "status", Operator: "=", Value: "active"}}
+			if got.SearchText != "ann" || !reflect.DeepEqual(got.OrderBy, []string{"-name"}) || !reflect.DeepEqual(got.Filters, want) {
+				t.Errorf("conditions %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package queryhelper
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithLocale picks the columns LocalizedColumns fields resolve to, e.g. "de"
+// or "de-CH".
+func WithLocale(locale string) Option {
+	return func(dq *QueryHelper) {
+		dq.queryConditions.Locale = locale
+	}
+}
+
+// localizedColumn resolves a LocalizedColumns field to the column of locale.
+// Locales without a column fall back to their base language, then to
+// LocaleFallbacks in order and finally to the field's alphabetically first
+// locale.
+func (s *QuerySettings) localizedColumn(field string, locale string) (string, bool) {
+
+	columns := s.LocalizedColumns[field]
+	if len(columns) == 0 {
+		return field, false
+	}
+
+	for _, candidate := range localeChain(locale, s.LocaleFallbacks) {
+		for l, column := range columns {
+			if strings.EqualFold(l, candidate) {
+				return column, true
+			}
+		}
+	}
+
+	return columns[sortedKeys(columns)[0]], true
+}
+
+// localeChain lists the locales tried for locale, each followed by its base
+// language: "de-CH" is tried as de-CH, then de.
+func localeChain(locale string, fallbacks []string) []string {
+
+	chain := make([]string, 0, 2*(len(fallbacks)+1))
+	for _, l := range append([]string{locale}, fallbacks...) {
+		l = strings.ReplaceAll(strings.TrimSpace(l), "_", "-")
+		if l == "" {
+			continue
+		}
+
+		chain = append(chain, l)
+		if base, _, ok := strings.Cut(l, "-"); ok {
+			chain = append(chain, base)
+		}
+	}
+
+	return chain
+}
+
+// localizedVariants returns every column of a LocalizedColumns field.
+func localizedVariants(s *QuerySettings, field string) []string {
+
+	columns := s.LocalizedColumns[field]
+
+	variants := make([]string, 0, len(columns))
+	for _, l := range sortedKeys(columns) {
+		variants = append(variants, columns[l])
+	}
+
+	return variants
+}
+
+// localizeColumns resolves the LocalizedColumns fields among columns, such
+// as the defaults shared by all requests, for the request's locale.
+func (ch *ConditionsHandle) localizeColumns(columns []string, ordered bool) []string {
+
+	if len(ch.Settings.LocalizedColumns) == 0 {
+		return columns
+	}
+
+	out := make([]string, len(columns))
+	for i, entry := range columns {
+		prefix, field := "", entry
+		if ordered {
+			prefix, field = splitOrderBy(entry)
+		}
+
+		if column, ok := ch.Settings.localizedColumn(field, ch.locale); ok {
+			field = column
+		}
+		out[i] = prefix + field
+	}
+
+	return out
+}
+
+// realColumn maps a field to the column it names: for localized fields the
+// column of the request's locale, otherwise the aliased column.
+func (ch *ConditionsHandle) realColumn(field string) (string, bool) {
+
+	if column, ok := ch.Settings.localizedColumn(field, ch.locale); ok {
+		return column, true
+	}
+
+	real, ok := ch.Settings.lookups().columns[field]
+
+	return real, ok
+}
+
+// preferredLocale returns the language an Accept-Language header prefers
+// most, or "" when it names none.
+func preferredLocale(header string) string {
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+
+	return best
+}
+
+func sortedKeys(m map[string]string) []string {
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
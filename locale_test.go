@@ -0,0 +1,122 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testArticle struct {
+	ID     uint
+	NameEn string
+	NameDe string
+	NameSv string
+}
+
+func (testArticle) TableName() string {
+	return "articles"
+}
+
+func localeSettings(fallbacks ...string) *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"name": {"!="}},
+		AllowedSearch:  []string{"name"},
+		AllowedOrderBy: []string{"name"},
+		LocalizedColumns: map[string]map[string]string{
+			"name": {"en": "name_en", "de": "name_de", "sv": "name_sv"},
+		},
+		LocaleFallbacks: fallbacks,
+	}
+}
+
+func TestLocalizedColumns(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		locale    string
+		fallbacks []string
+		column    string
+	}{
+		{name: "exact locale", locale: "sv", column: "name_sv"},
+		{name: "locale in another case", locale: "DE", column: "name_de"},
+		{name: "region falls back to its language", locale: "de-CH", column: "name_de"},
+		{name: "underscore region", locale: "sv_FI", column: "name_sv"},
+		{name: "missing locale falls back", locale: "fr", fallbacks: []string{"it", "en"}, column: "name_en"},
+		{name: "no locale uses the fallbacks", fallbacks: []string{"en-GB"}, column: "name_en"},
+		{name: "default chain ends at the first locale", locale: "fr", fallbacks: []string{"it"}, column: "name_de"},
+		{name: "no locale and no fallbacks", column: "name_de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(
+				queryhelper.WithLocale(tt.locale),
+				queryhelper.WithSearchText("ap"),
+				queryhelper.WithSearchFields([]string{"name"}),
+				queryhelper.WithFilter("name", "!=", "x"),
+				queryhelper.WithOrderBy([]string{"-name"}),
+			)
+
+			query, err := dq.Apply(localeSettings(tt.fallbacks...), queryhelpertest.DryRunDB(t, "sqlite").Model(&testArticle{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := `"` + tt.column + `"`
+			want := `SELECT * FROM "articles" WHERE ` + c + ` != 'x' AND ` + c + ` LIKE '%ap%' ESCAPE '\' ORDER BY ` + c + ` DESC LIMIT 10`
+			if got := findSQL(t, query); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+
+			// Clients keep seeing the public field
+			info := dq.Info().Conditions
+			if !reflect.DeepEqual(info.SearchFields, []string{"name"}) || !reflect.DeepEqual(info.OrderBy, []string{"-name"}) || info.Filters[0].Field != "name" {
+				t.Errorf("info %+v, want the public field", info)
+			}
+		})
+	}
+}
+
+func TestLocalizedColumnsRows(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testArticle{})
+	queryhelpertest.Seed(t, db, &[]testArticle{
+		{ID: 1, NameEn: "apple", NameDe: "Apfel", NameSv: "äpple"},
+		{ID: 2, NameEn: "pear", NameDe: "Birne", NameSv: "päron"},
+		{ID: 3, NameEn: "plum", NameDe: "Pflaume", NameSv: "plommon"},
+	})
+
+	tests := []struct {
+		locale string
+		text   string
+		want   []uint
+	}{
+		{locale: "en-US", text: "p", want: []uint{1, 2, 3}},
+		{locale: "de", text: "p", want: []uint{1, 3}},
+		// Ordered by the Swedish names, which SQLite compares byte by byte
+		{locale: "sv", text: "p", want: []uint{3, 2, 1}},
+		{locale: "sv", text: "on", want: []uint{3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale+" "+tt.text, func(t *testing.T) {
+
+			var articles []testArticle
+			dq := queryhelper.NewQueryHelper(queryhelper.WithLocale(tt.locale), queryhelper.WithSearchText(tt.text), queryhelper.WithOrderBy([]string{"name"}))
+			if err := dq.Execute(localeSettings(), db.Model(&testArticle{}), &articles); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []uint
+			for _, a := range articles {
+				got = append(got, a.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package queryhelper
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lock strengths
+const (
+	LockUpdate = clause.LockingStrengthUpdate
+	LockShare  = clause.LockingStrengthShare
+)
+
+// Locking locks the rows of the page, e.g. for workers claiming jobs with
+// the same conditions the UI lists them by.
+type Locking struct {
+	Strength   string // UPDATE (default) or SHARE
+	SkipLocked bool   // leave out rows locked by other transactions
+	NoWait     bool   // fail instead of waiting for locked rows
+}
+
+// WithLocking adds FOR UPDATE or FOR SHARE to the data query. The count is
+// never locked. Run the query in a transaction for the locks to be held.
+func WithLocking(locking Locking) Option {
+	return func(dq *QueryHelper) {
+		dq.locking = &locking
+	}
+}
+
+// lockingClause builds the locking clause after checking the query's
+// database supports every requested option.
+func lockingClause(query *gorm.DB, locking *Locking) (clause.Locking, error) {
+
+	strength := locking.Strength
+	if strength == "" {
+		strength = LockUpdate
+	}
+
+	if strength != LockUpdate && strength != LockShare {
+		return clause.Locking{}, fmt.Errorf("unknown lock strength %q", locking.Strength)
+	}
+
+	if locking.SkipLocked && locking.NoWait {
+		return clause.Locking{}, errors.New("SKIP LOCKED cannot be combined with NOWAIT")
+	}
+
+	dialect := dialectName(query)
+	d := lookupDialect(dialect)
+
+	switch {
+	case d.NoRowLocking:
+		return clause.Locking{}, &LockingError{Dialect: dialect, Feature: "FOR " + strength}
+	case strength == LockShare && d.NoShareLock:
+		return clause.Locking{}, &LockingError{Dialect: dialect, Feature: "FOR SHARE"}
+	case (locking.SkipLocked || locking.NoWait) && d.NoSkipLocked:
+		feature := "SKIP LOCKED"
+		if locking.NoWait {
+			feature = "NOWAIT"
+		}
+		return clause.Locking{}, &LockingError{Dialect: dialect, Feature: feature}
+	}
+
+	lock := clause.Locking{Strength: strength}
+	if locking.SkipLocked {
+		lock.Options = clause.LockingOptionsSkipLocked
+	} else if locking.NoWait {
+		lock.Options = clause.LockingOptionsNoWait
+	}
+
+	return lock, nil
+}
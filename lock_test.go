@@ -0,0 +1,145 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func lockSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"status": {"="}},
+		AllowedOrderBy: []string{"id"},
+	}
+}
+
+func TestLocking(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		dialect string
+		locking queryhelper.Locking
+		want    string
+	}{
+		{
+			name:    "for update",
+			dialect: "postgres",
+			want:    `SELECT * FROM "users" WHERE "status" = 'queued' ORDER BY "id" LIMIT 10 FOR UPDATE`,
+		},
+		{
+			name:    "skip locked",
+			dialect: "postgres",
+			locking: queryhelper.Locking{SkipLocked: true},
+			want:    `SELECT * FROM "users" WHERE "status" = 'queued' ORDER BY "id" LIMIT 10 FOR UPDATE SKIP LOCKED`,
+		},
+		{
+			name:    "share nowait",
+			dialect: "postgres",
+			locking: queryhelper.Locking{Strength: queryhelper.LockShare, NoWait: true},
+			want:    `SELECT * FROM "users" WHERE "status" = 'queued' ORDER BY "id" LIMIT 10 FOR SHARE NOWAIT`,
+		},
+		{
+			name:    "mysql",
+			dialect: "mysql",
+			locking: queryhelper.Locking{SkipLocked: true},
+			want:    "SELECT * FROM `users` WHERE `status` = 'queued' ORDER BY `id` LIMIT 10 FOR UPDATE SKIP LOCKED",
+		},
+		{
+			name:    "oracle update",
+			dialect: "oracle",
+			want:    `SELECT * FROM "users" WHERE "status" = 'queued' ORDER BY "id" LIMIT 10 FOR UPDATE`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := queryhelpertest.DryRunDB(t, tt.dialect)
+			statements := recordQueries(t, db)
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "queued"), queryhelper.WithLocking(tt.locking))
+			query, err := dq.Apply(lockSettings(), db.Model(&testUser{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The count runs first and is never locked
+			if len(*statements) != 1 || strings.Contains((*statements)[0], " FOR ") {
+				t.Errorf("count %q, want one unlocked count", *statements)
+			}
+
+			if got := findSQL(t, query); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockingCappedByPageSize(t *testing.T) {
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithPageSize(100000), queryhelper.WithLocking(queryhelper.Locking{}))
+	query, err := dq.Apply(lockSettings(), queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `SELECT * FROM "users" ORDER BY "id" LIMIT 100 FOR UPDATE`
+	if got := findSQL(t, query); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLockingRejected(t *testing.T) {
+
+	queryhelper.RegisterDialect("mysql57", &queryhelper.Dialect{NoSkipLocked: true})
+
+	tests := []struct {
+		name        string
+		dialect     string
+		locking     queryhelper.Locking
+		wantFeature string
+	}{
+		{name: "sqlite", dialect: "sqlite", wantFeature: "FOR UPDATE"},
+		{name: "sqlserver", dialect: "sqlserver", locking: queryhelper.Locking{Strength: queryhelper.LockShare}, wantFeature: "FOR SHARE"},
+		{name: "oracle share", dialect: "oracle", locking: queryhelper.Locking{Strength: queryhelper.LockShare}, wantFeature: "FOR SHARE"},
+		{name: "no skip locked", dialect: "mysql57", locking: queryhelper.Locking{SkipLocked: true}, wantFeature: "SKIP LOCKED"},
+		{name: "no nowait", dialect: "mysql57", locking: queryhelper.Locking{NoWait: true}, wantFeature: "NOWAIT"},
+		{name: "invalid options", dialect: "postgres", locking: queryhelper.Locking{SkipLocked: true, NoWait: true}},
+		{name: "unknown strength", dialect: "postgres", locking: queryhelper.Locking{Strength: "KEY SHARE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := queryhelpertest.DryRunDB(t, tt.dialect)
+			statements := recordQueries(t, db)
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithLocking(tt.locking))
+			_, err := dq.Apply(lockSettings(), db.Model(&testUser{}))
+			if err == nil {
+				t.Fatal("locking was accepted")
+			}
+
+			var lerr *queryhelper.LockingError
+			if tt.wantFeature == "" {
+				if errors.As(err, &lerr) {
+					t.Errorf("err = %v, want an option error", err)
+				}
+			} else {
+				if !errors.Is(err, queryhelper.ErrLockingNotSupported) || !errors.As(err, &lerr) {
+					t.Fatalf("err = %v, want a LockingError", err)
+				}
+				if lerr.Dialect != tt.dialect || lerr.Feature != tt.wantFeature {
+					t.Errorf("got %s on %s, want %s on %s", lerr.Feature, lerr.Dialect, tt.wantFeature, tt.dialect)
+				}
+			}
+
+			if len(*statements) != 0 {
+				t.Errorf("ran %q before failing", *statements)
+			}
+		})
+	}
+}
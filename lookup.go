@@ -0,0 +1,215 @@
+package queryhelper
+
+import "strings"
+
+// settingsLookup holds set views of the allow-lists so validation does not
+// scan slices on every request. It is built once per QuerySettings.
+type settingsLookup struct {
+	search          map[string]struct{}
+	orderBy         map[string]struct{}
+	fields          map[string]struct{}
+	filters         map[string]map[string]struct{}
+	caseInsensitive map[string]struct{}
+	indexed         map[string]struct{}
+
+	// DefaultSearchFields (or AllowedSearch) and AllowedOrderBy mapped to
+	// real columns, shared by every request that does not pick its own
+	defaultSearch  []string
+	defaultOrderBy []string
+
+	// real case-insensitive columns, with and without Postgres citext ones
+	ciColumns         map[string]bool
+	ciColumnsPostgres map[string]bool
+
+	// SearchFieldModes and ColumnCasts keyed by real column
+	searchModes map[string]string
+	casts       map[string]string
+
+	// real column -> dialect name ("" for any) -> FieldWrappers template
+	wrappers map[string]map[string]string
+
+	// SearchIDFields as real columns
+	searchIDs []searchIDColumn
+
+	// field -> real column, ColumnAlias with embedded struct paths resolved
+	columns map[string]string
+
+	// prefixed column -> the embedded struct path naming it
+	embedded map[string]string
+}
+
+// lookups returns the settings' lookup sets, building them on first use.
+// Settings must not be modified afterwards.
+func (s *QuerySettings) lookups() *settingsLookup {
+
+	s.lookupOnce.Do(func() {
+		s.lookup = newSettingsLookup(s)
+	})
+
+	return s.lookup
+}
+
+func newSettingsLookup(s *QuerySettings) *settingsLookup {
+
+	columns, embedded := columnNames(s)
+
+	l := &settingsLookup{
+		search:          toSet(s.AllowedSearch),
+		orderBy:         toSet(s.AllowedOrderBy),
+		fields:          toSet(s.AllowedFields),
+		filters:         make(map[string]map[string]struct{}, len(s.AllowedFilters)),
+		caseInsensitive: toSet(s.CaseInsensitiveFields),
+		indexed:         toSet(s.IndexedFields),
+		defaultSearch:   getRealColumns(columns, s.AllowedSearch),
+		defaultOrderBy:  getRealOrderBy(columns, s.AllowedOrderBy),
+		columns:         columns,
+		embedded:        embedded,
+	}
+
+	if len(s.DefaultSearchFields) > 0 {
+		l.defaultSearch = getRealColumns(columns, s.DefaultSearchFields)
+	}
+
+	for _, field := range s.SearchIDFields {
+		l.searchIDs = append(l.searchIDs, searchIDColumn{
+			column:    getRealColumns(columns, []string{field})[0],
+			fieldType: s.FieldTypes[field],
+		})
+	}
+
+	for field, ops := range s.AllowedFilters {
+		l.filters[field] = toSet(ops)
+	}
+
+	if len(s.CaseInsensitiveFields) > 0 {
+		l.ciColumns = make(map[string]bool, len(s.CaseInsensitiveFields))
+		l.ciColumnsPostgres = make(map[string]bool, len(s.CaseInsensitiveFields))
+
+		citext := toSet(s.CitextFields)
+		for _, field := range s.CaseInsensitiveFields {
+			column := getRealColumns(columns, []string{field})[0]
+
+			// Columns of joined relations are also looked up by alias
+			aliased, _ := relationColumn(s.Relations, column)
+
+			for _, c := range append([]string{column, aliased}, localizedVariants(s, field)...) {
+				l.ciColumns[c] = true
+
+				// Citext columns already compare case-insensitively on Postgres
+				if _, ok := citext[field]; !ok {
+					l.ciColumnsPostgres[c] = true
+				}
+			}
+		}
+	}
+
+	if len(s.SearchFieldModes) > 0 {
+		l.searchModes = make(map[string]string, len(s.SearchFieldModes))
+		for field, mode := range s.SearchFieldModes {
+			column := getRealColumns(columns, []string{field})[0]
+			aliased, _ := relationColumn(s.Relations, column)
+
+			for _, c := range append([]string{column, aliased}, localizedVariants(s, field)...) {
+				l.searchModes[c] = mode
+			}
+		}
+	}
+
+	if len(s.ColumnCasts) > 0 {
+		l.casts = make(map[string]string, len(s.ColumnCasts))
+		for field, typ := range s.ColumnCasts {
+			column := getRealColumns(columns, []string{field})[0]
+			aliased, _ := relationColumn(s.Relations, column)
+
+			for _, c := range append([]string{column, aliased}, localizedVariants(s, field)...) {
+				l.casts[c] = typ
+			}
+		}
+	}
+
+	// Templates without a placeholder would not compare the column at all
+	addWrapper := func(field string, dialect string, template string) {
+		if !strings.Contains(template, wrapperPlaceholder) {
+			return
+		}
+
+		if l.wrappers == nil {
+			l.wrappers = make(map[string]map[string]string)
+		}
+
+		column := getRealColumns(columns, []string{field})[0]
+		aliased, _ := relationColumn(s.Relations, column)
+
+		for _, c := range append([]string{column, aliased}, localizedVariants(s, field)...) {
+			if l.wrappers[c] == nil {
+				l.wrappers[c] = make(map[string]string)
+			}
+			l.wrappers[c][dialect] = template
+		}
+	}
+
+	for field, template := range s.FieldWrappers {
+		addWrapper(field, "", template)
+	}
+
+	for field, templates := range s.FieldWrapperDialects {
+		for dialect, template := range templates {
+			addWrapper(field, dialect, template)
+		}
+	}
+
+	return l
+}
+
+func toSet(values []string) map[string]struct{} {
+
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return set
+}
+
+// allowedColumns keeps the allowed columns and maps them to real column
+// names. Order by entries keep their direction prefix. The input slice is
+// returned as is when nothing is dropped or renamed.
+func (ch *ConditionsHandle) allowedColumns(columns []string, allowed map[string]struct{}, ordered bool, kind string, code string, reason string) []string {
+
+	var out []string
+	for i, column := range columns {
+		prefix, field := "", column
+		if ordered {
+			prefix, field = splitOrderBy(column)
+		}
+
+		_, ok := allowed[field]
+		real, renamed := ch.realColumn(field)
+		if ok && !renamed && out == nil {
+			continue
+		}
+
+		// Copy what was kept so far on the first change
+		if out == nil {
+			out = make([]string, i, len(columns))
+			copy(out, columns[:i])
+		}
+
+		if !ok {
+			ch.drop(DroppedItem{Kind: kind, Code: code, Field: field, Reason: reason})
+			continue
+		}
+
+		if renamed {
+			ch.rename(field, real)
+			column = prefix + real
+		}
+		out = append(out, column)
+	}
+
+	if out == nil {
+		return columns
+	}
+
+	return out
+}
@@ -0,0 +1,169 @@
+package queryhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+)
+
+type contextKey struct{}
+
+type queryRequest struct {
+	PaginationRequest
+	QueryConditions
+}
+
+// UnmarshalJSON reads both embedded structs from the body, rather than only
+// the conditions through their promoted UnmarshalJSON.
+func (r *queryRequest) UnmarshalJSON(data []byte) error {
+
+	if err := json.Unmarshal(data, &r.PaginationRequest); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &r.QueryConditions)
+}
+
+type errorResponse struct {
+	Error  string              `json:"error"`
+	Errors []errorResponseItem `json:"errors,omitempty"`
+	Cost   *QueryCostError     `json:"cost,omitempty"`
+}
+
+type errorResponseItem struct {
+	Field    string `json:"field,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Message  string `json:"message"`
+}
+
+// NewContext returns a copy of ctx carrying the query helper.
+func NewContext(ctx context.Context, qh *QueryHelper) context.Context {
+	return context.WithValue(ctx, contextKey{}, qh)
+}
+
+// FromContext returns the query helper stored by Middleware, if any.
+func FromContext(ctx context.Context) (*QueryHelper, bool) {
+	qh, ok := ctx.Value(contextKey{}).(*QueryHelper)
+	return qh, ok && qh != nil
+}
+
+// Middleware builds a QueryHelper from each request and stores it in the
+// request context. GET requests are read from the query string (see
+// ParseValues) and POST requests from a JSON body. Requests failing
+// validation against the settings get a 400 response with a JSON error body.
+func Middleware(settings *QuerySettings) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			var conditions *QueryConditions
+			var pagination *PaginationRequest
+
+			if r.Method == http.MethodPost {
+				mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				if err != nil || mediaType != "application/json" {
+					writeError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+					return
+				}
+
+				var req queryRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					if !errors.Is(err, ErrMixedRequestShape) {
+						err = errors.New("invalid JSON body")
+					}
+					writeError(w, http.StatusBadRequest, err)
+					return
+				}
+
+				conditions = &req.QueryConditions
+				pagination = &req.PaginationRequest
+			} else {
+				c, p, err := ParseValues(r.URL.Query())
+				if err != nil {
+					writeError(w, http.StatusBadRequest, err)
+					return
+				}
+
+				conditions = c
+				pagination = p
+			}
+
+			// Localized fields follow the client's language unless it asks for one
+			locale := conditions.Locale
+			if locale == "" {
+				locale = preferredLocale(r.Header.Get("Accept-Language"))
+			}
+
+			opts := []Option{
+				WithPage(pagination.Page),
+				WithPageSize(pagination.PageSize),
+				WithSearchText(conditions.SearchText),
+				WithSearchFields(conditions.SearchFields),
+				WithOrderBy(conditions.OrderBy),
+				WithSortFactor(conditions.SortFactor),
+				WithFilters(conditions.Filters),
+				WithFilterGroups(conditions.FilterGroups),
+				WithFields(conditions.Fields),
+				WithIncludes(conditions.Includes),
+				WithIncludeFilters(conditions.IncludeFilters),
+				WithSummaries(conditions.RequestedSummaries),
+				WithHistogram(conditions.Histogram),
+				WithLocale(locale),
+				WithCountMode(pagination.CountMode),
+			}
+
+			if pagination.Offset != nil {
+				opts = append(opts, WithOffset(*pagination.Offset))
+			}
+
+			if pagination.Limit != nil {
+				opts = append(opts, WithLimit(*pagination.Limit))
+			}
+
+			qh := NewQueryHelper(opts...)
+
+			// Mixing page and offset is a client error
+			if pagination.Page > 0 && (pagination.Offset != nil || pagination.Limit != nil) {
+				writeError(w, http.StatusBadRequest, ErrPageWithOffset)
+				return
+			}
+
+			if err := qh.Validate(settings); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), qh)))
+		})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+
+	resp := errorResponse{
+		Error: err.Error(),
+	}
+
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		resp.Error = ErrInvalidConditions.Error()
+		for _, fe := range verr.Errors {
+			resp.Errors = append(resp.Errors, errorResponseItem{
+				Field:    fe.Field,
+				Operator: fe.Operator,
+				Message:  fe.Err.Error(),
+			})
+		}
+	}
+
+	// Let clients see which parts of the query to narrow
+	var cerr *QueryCostError
+	if errors.As(err, &cerr) {
+		resp.Cost = cerr
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,205 @@
+package queryhelper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewarePassesEveryCondition(t *testing.T) {
+
+	settings := &QuerySettings{
+		AllowedIncludes: map[string]*IncludeSettings{
+			"comments": {Settings: &QuerySettings{AllowedFilters: map[string][]string{"status": {"="}}}},
+		},
+		Summaries:       map[string]string{"total": "SUM(amount)"},
+		HistogramFields: map[string][]string{"created_at": {IntervalDay}},
+	}
+
+	want := QueryConditions{
+		Includes:           []string{"comments"},
+		IncludeFilters:     map[string][]FilterCondition{"comments": {{Field: "status", Operator: "=", Value: "visible"}}},
+		RequestedSummaries: []string{"total"},
+		Histogram:          &DateHistogram{Field: "created_at", Interval: IntervalDay},
+	}
+
+	requests := map[string]*http.Request{
+		"GET": httptest.NewRequest(http.MethodGet, "/?include=comments&comments.filter[status]=visible&summaries=total&histogram[field]=created_at&histogram[interval]=day", nil),
+		"POST": httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+			`{"includes":["comments"],"include_filters":{"comments":[{"field":"status","operator":"=","value":"visible"}]},"summaries":["total"],"histogram":{"field":"created_at","interval":"day"}}`,
+		)),
+	}
+	requests["POST"].Header.Set("Content-Type", "application/json")
+
+	for method, r := range requests {
+		t.Run(method, func(t *testing.T) {
+
+			var got *QueryConditions
+			handler := Middleware(settings)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				qh, _ := FromContext(r.Context())
+				got = qh.GetQueryConditions()
+			}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+
+			if !reflect.DeepEqual(got.Includes, want.Includes) {
+				t.Errorf("Includes = %v, want %v", got.Includes, want.Includes)
+			}
+			if !reflect.DeepEqual(got.IncludeFilters, want.IncludeFilters) {
+				t.Errorf("IncludeFilters = %v, want %v", got.IncludeFilters, want.IncludeFilters)
+			}
+			if !reflect.DeepEqual(got.RequestedSummaries, want.RequestedSummaries) {
+				t.Errorf("RequestedSummaries = %v, want %v", got.RequestedSummaries, want.RequestedSummaries)
+			}
+			if !reflect.DeepEqual(got.Histogram, want.Histogram) {
+				t.Errorf("Histogram = %+v, want %+v", got.Histogram, want.Histogram)
+			}
+		})
+	}
+}
+
+func TestMiddlewareValidationFailure(t *testing.T) {
+
+	settings := &QuerySettings{
+		AllowedFilters: map[string][]string{"age": {"="}},
+		FieldTypes:     map[string]string{"age": FieldTypeInt},
+	}
+
+	called := false
+	handler := Middleware(settings)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?filter[age]=old", nil))
+
+	if called {
+		t.Error("handler called for an invalid request")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	want := `{"error":"invalid query conditions","errors":[{"field":"age","operator":"=","message":"cannot convert \"old\" to int"}]}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("body %s, want %s", w.Body, want)
+	}
+}
+
+func TestMiddlewareContentType(t *testing.T) {
+
+	tests := []struct {
+		contentType string
+		status      int
+	}{
+		{contentType: "application/json", status: http.StatusOK},
+		{contentType: "application/json; charset=utf-8", status: http.StatusOK},
+		{contentType: "text/plain", status: http.StatusUnsupportedMediaType},
+		{contentType: "application/x-www-form-urlencoded", status: http.StatusUnsupportedMediaType},
+		{contentType: "", status: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+
+			handler := Middleware(&QuerySettings{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := FromContext(r.Context()); !ok {
+					t.Error("no helper in the context")
+				}
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"page":1}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.status != http.StatusOK {
+				want := `{"error":"content type must be application/json"}` + "\n"
+				if w.Body.String() != want {
+					t.Errorf("body %s, want %s", w.Body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFromContextWithoutHelper(t *testing.T) {
+
+	if qh, ok := FromContext(context.Background()); ok || qh != nil {
+		t.Errorf("FromContext = %v, %v on an empty context", qh, ok)
+	}
+
+	// A nil helper stored explicitly is not a helper either
+	if qh, ok := FromContext(NewContext(context.Background(), nil)); ok || qh != nil {
+		t.Errorf("FromContext = %v, %v for a nil helper", qh, ok)
+	}
+
+	// A handler mounted without the middleware finds none
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := FromContext(r.Context()); !ok {
+			http.Error(w, "no query", http.StatusInternalServerError)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?page=1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", w.Code)
+	}
+}
+
+func TestMiddlewareLocale(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   string
+	}{
+		{name: "most preferred language", url: "/", header: "en;q=0.5, de-CH, sv;q=0.8", want: "de-CH"},
+		{name: "wildcard skipped", url: "/", header: "*, sv;q=0.3", want: "sv"},
+		{name: "parameter over header", url: "/?locale=sv", header: "de", want: "sv"},
+		{name: "neither", url: "/", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var got string
+			handler := Middleware(&QuerySettings{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				qh, _ := FromContext(r.Context())
+				got = qh.GetQueryConditions().Locale
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Language", tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if got != tt.want {
+				t.Errorf("locale %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
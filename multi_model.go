@@ -0,0 +1,275 @@
+package queryhelper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SearchTarget is one table a request runs on in MultiModelExecute and
+// MultiModelMerge.
+type SearchTarget struct {
+	Name     string         // tags the target's results, e.g. "users"
+	Query    *gorm.DB       // e.g. db.Model(&User{})
+	Settings *QuerySettings // nil uses the helper's settings provider
+	Dest     interface{}    // pointer to a slice receiving the target's rows
+}
+
+// TargetResult is what one target returned. Each target validates the
+// request against its own settings, so a filter one target does not allow
+// is dropped there and listed in Warnings.
+type TargetResult struct {
+	Name       string         `json:"name"`
+	Pagination PaginationInfo `json:"pagination"`
+	Warnings   []Warning      `json:"warnings,omitempty"`
+	Err        error          `json:"-"`
+}
+
+// TaggedRow is a row of a merged result and the target it came from.
+type TaggedRow struct {
+	Target string      `json:"target"`
+	Item   interface{} `json:"item"`
+}
+
+// MergedResult is a page merged from several targets.
+type MergedResult struct {
+	Items      []TaggedRow    `json:"items"`
+	Pagination PaginationInfo `json:"pagination"` // Total sums every successful target
+	Targets    []TargetResult `json:"targets"`
+}
+
+// MultiModelExecute runs the request on every target concurrently, finding
+// the requested page of each into its Dest. Failures are reported in the
+// results of their targets.
+func (dq *QueryHelper) MultiModelExecute(targets []SearchTarget) []TargetResult {
+
+	results := make([]TargetResult, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target SearchTarget) {
+			defer wg.Done()
+
+			t := dq.forTarget()
+			err := t.Execute(target.Settings, target.Query, target.Dest)
+
+			results[i] = TargetResult{
+				Name:       target.Name,
+				Pagination: *t.pagination.Info,
+				Warnings:   t.Warnings(),
+				Err:        err,
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// MultiModelMerge runs the request on every target concurrently and merges
+// their rows into one page ordered by column, e.g. "-updated_at", which every
+// target's Dest rows must have. The request's own ordering is replaced by
+// it. Each Dest receives the target's rows on the merged page.
+//
+// Like ExecuteSharded, every target returns its first offset+limit rows.
+// Targets that fail are left out of the page and reported in Targets; an
+// error is returned only when all of them fail.
+func (dq *QueryHelper) MultiModelMerge(targets []SearchTarget, column string) (*MergedResult, error) {
+
+	if len(targets) == 0 {
+		return nil, errors.New("no search targets")
+	}
+
+	if dq.locking != nil {
+		return nil, errors.New("locking is not supported across search targets")
+	}
+
+	if dq.sampling != nil {
+		return nil, errors.New("sampling is not supported across search targets")
+	}
+
+	if dq.err != nil {
+		return nil, dq.err
+	}
+
+	if dq.pagination.err != nil {
+		return nil, dq.pagination.err
+	}
+
+	info := dq.pagination.Info
+	fetch := info.Offset + info.Limit
+
+	results := make([]TargetResult, len(targets))
+	lists := make([]reflect.Value, len(targets))
+	keys := make([][]mergeKey, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target SearchTarget) {
+			defer wg.Done()
+
+			t := dq.forTarget()
+			start := time.Now()
+
+			rows, k, total, err := t.queryTarget(target, column, fetch)
+			if t.auditSink != nil {
+				var dest interface{}
+				if rows.IsValid() {
+					dest = rows.Interface()
+				}
+				t.audit(target.Settings, target.Query, start, err, dest)
+			}
+
+			results[i] = TargetResult{Name: target.Name, Warnings: t.Warnings(), Err: err}
+			if err != nil {
+				return
+			}
+
+			results[i].Pagination = *info
+			results[i].Pagination.Total = total
+			results[i].Pagination.TotalPages = totalPages(total, info.Limit)
+			lists[i], keys[i] = rows, k
+		}(i, target)
+	}
+	wg.Wait()
+
+	var (
+		total int64
+		live  []int // targets that succeeded, in order
+		errs  []error
+	)
+	for i, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("search target %s: %w", result.Name, result.Err))
+			continue
+		}
+
+		total += result.Pagination.Total
+		live = append(live, i)
+	}
+
+	if len(live) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	liveLists := make([]reflect.Value, len(live))
+	liveKeys := make([][]mergeKey, len(live))
+	for j, i := range live {
+		liveLists[j], liveKeys[j] = lists[i], keys[i]
+	}
+
+	merged := &MergedResult{
+		Pagination: *info,
+		Targets:    results,
+	}
+	merged.Pagination.Total = total
+	merged.Pagination.TotalPages = totalPages(total, info.Limit)
+
+	// Each target's rows on the page, to set its Dest to
+	pages := make([]reflect.Value, len(live))
+	for j := range live {
+		pages[j] = reflect.MakeSlice(liveLists[j].Type(), 0, 0)
+	}
+
+	order := mergeRows(liveLists, liveKeys, nullsSortLast(dialectName(targets[live[0]].Query)), fetch)
+	for n, at := range order {
+		if n < info.Offset {
+			continue
+		}
+
+		row := liveLists[at[0]].Index(at[1])
+		pages[at[0]] = reflect.Append(pages[at[0]], row)
+		merged.Items = append(merged.Items, TaggedRow{Target: targets[live[at[0]]].Name, Item: row.Interface()})
+	}
+
+	for j, i := range live {
+		reflect.ValueOf(targets[i].Dest).Elem().Set(pages[j])
+	}
+
+	return merged, nil
+}
+
+// queryTarget counts the target's matching rows and finds the first limit
+// of them ordered by column, returning the rows and the keys to merge them
+// on.
+func (dq *QueryHelper) queryTarget(target SearchTarget, column string, limit int) (reflect.Value, []mergeKey, int64, error) {
+
+	settings := target.Settings
+	if settings == nil && dq.settingsProvider != nil {
+		settings = dq.settingsProvider.Current()
+	}
+
+	dq.requested = *dq.queryConditions
+
+	destValue := reflect.ValueOf(target.Dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, 0, errors.New("dest must be a pointer to a slice")
+	}
+	sliceType := destValue.Elem().Type()
+
+	dqh := NewConditionsHandle(settings)
+	if err := dqh.UpdateConditions(dq.queryConditions); err != nil {
+		return reflect.Value{}, nil, 0, err
+	}
+
+	// The merge column is the server's choice, not checked against the
+	// allow-lists
+	dqh.Conditions.OrderBy = []string{column}
+	dq.conditions = dqh
+
+	query := target.Query
+	if dq.wrapSubquery {
+		if err := dqh.checkSubqueryColumns(); err != nil {
+			return reflect.Value{}, nil, 0, err
+		}
+		query = wrapSubquery(query)
+	}
+
+	keys, err := dqh.mergeKeys(query, sliceType.Elem())
+	if err != nil {
+		return reflect.Value{}, nil, 0, err
+	}
+
+	result := dqh.queryShard(query, limit, sliceType)
+	dq.retries = result.retries
+	if result.err != nil {
+		return reflect.Value{}, nil, 0, result.err
+	}
+
+	return result.rows.Elem(), keys, result.total, nil
+}
+
+// forTarget returns a helper running a copy of the request, so targets can
+// run concurrently.
+func (dq *QueryHelper) forTarget() *QueryHelper {
+
+	conditions := cloneConditions(*dq.queryConditions)
+	req := *dq.paginationRequest
+
+	return &QueryHelper{
+		queryConditions:   &conditions,
+		paginationRequest: &req,
+		pagination:        NewPaginationHandle(&req),
+		settingsProvider:  dq.settingsProvider,
+		auditSink:         dq.auditSink,
+		locking:           dq.locking,
+		wrapSubquery:      dq.wrapSubquery,
+		err:               dq.err,
+		sampling:          dq.sampling,
+	}
+}
+
+func totalPages(total int64, limit int) int {
+
+	if total == 0 {
+		return 1
+	}
+
+	return int((total + int64(limit) - 1) / int64(limit))
+}
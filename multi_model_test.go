@@ -0,0 +1,286 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+type testMember struct {
+	ID        uint
+	Name      string
+	UpdatedAt time.Time
+}
+
+func (testMember) TableName() string {
+	return "members"
+}
+
+type testInvoice struct {
+	ID        uint
+	Number    string
+	Status    string
+	UpdatedAt time.Time
+}
+
+func (testInvoice) TableName() string {
+	return "invoices"
+}
+
+type testNote struct {
+	ID        uint
+	Body      string
+	UpdatedAt time.Time
+}
+
+func (testNote) TableName() string {
+	return "notes"
+}
+
+func at(hour int) time.Time {
+	return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC)
+}
+
+// multiModelDB holds three tables searched together. Rows matching "alpha"
+// were updated at distinct hours, and only invoices have a status.
+func multiModelDB(t *testing.T) *gorm.DB {
+
+	t.Helper()
+
+	db := queryhelpertest.NewTestDB(t, &testMember{}, &testInvoice{}, &testNote{})
+	queryhelpertest.Seed(t, db,
+		&[]testMember{
+			{ID: 1, Name: "alpha one", UpdatedAt: at(1)},
+			{ID: 2, Name: "beta", UpdatedAt: at(2)},
+			{ID: 3, Name: "alpha two", UpdatedAt: at(7)},
+		},
+		&[]testInvoice{
+			{ID: 1, Number: "alpha-100", Status: "open", UpdatedAt: at(3)},
+			{ID: 2, Number: "alpha-200", Status: "paid", UpdatedAt: at(5)},
+			{ID: 3, Number: "alpha-300", Status: "open", UpdatedAt: at(8)},
+			{ID: 4, Number: "gamma", Status: "open", UpdatedAt: at(9)},
+		},
+		&[]testNote{
+			{ID: 1, Body: "alpha note", UpdatedAt: at(4)},
+			{ID: 2, Body: "alpha memo", UpdatedAt: at(6)},
+			{ID: 3, Body: "nothing", UpdatedAt: at(10)},
+		},
+	)
+
+	return db
+}
+
+type multiModelDests struct {
+	members  []testMember
+	invoices []testInvoice
+	notes    []testNote
+}
+
+func (d *multiModelDests) targets(db *gorm.DB) []queryhelper.SearchTarget {
+	return []queryhelper.SearchTarget{
+		{
+			Name:     "members",
+			Query:    db.Model(&testMember{}),
+			Settings: &queryhelper.QuerySettings{AllowedSearch: []string{"name"}, AllowedOrderBy: []string{"id"}},
+			Dest:     &d.members,
+		},
+		{
+			Name:     "invoices",
+			Query:    db.Model(&testInvoice{}),
+			Settings: &queryhelper.QuerySettings{AllowedSearch: []string{"number"}, AllowedFilters: map[string][]string{"status": {"="}}, AllowedOrderBy: []string{"id"}},
+			Dest:     &d.invoices,
+		},
+		{
+			Name:     "notes",
+			Query:    db.Model(&testNote{}),
+			Settings: &queryhelper.QuerySettings{AllowedSearch: []string{"body"}, AllowedOrderBy: []string{"id"}},
+			Dest:     &d.notes,
+		},
+	}
+}
+
+func multiModelRequest(opts ...queryhelper.Option) *queryhelper.QueryHelper {
+	return queryhelper.NewQueryHelper(append([]queryhelper.Option{
+		queryhelper.WithSearchText("alpha"),
+		queryhelper.WithEqual("status", "open"),
+	}, opts...)...)
+}
+
+func TestMultiModelExecute(t *testing.T) {
+
+	db := multiModelDB(t)
+
+	var dests multiModelDests
+	results := multiModelRequest(queryhelper.WithPageSize(1)).MultiModelExecute(dests.targets(db))
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	// The status filter applies to invoices and is skipped elsewhere
+	for _, tt := range []struct {
+		name    string
+		total   int64
+		warning string
+	}{
+		{name: "members", total: 2, warning: queryhelper.WarningFilterNotAllowed},
+		{name: "invoices", total: 2},
+		{name: "notes", total: 2, warning: queryhelper.WarningFilterNotAllowed},
+	} {
+		var result *queryhelper.TargetResult
+		for i := range results {
+			if results[i].Name == tt.name {
+				result = &results[i]
+			}
+		}
+		if result == nil {
+			t.Errorf("no result for %s", tt.name)
+			continue
+		}
+
+		if result.Err != nil {
+			t.Errorf("%s: %v", tt.name, result.Err)
+		}
+		if result.Pagination.Total != tt.total || result.Pagination.TotalPages != 2 {
+			t.Errorf("%s: total %d of %d pages, want %d of 2", tt.name, result.Pagination.Total, result.Pagination.TotalPages, tt.total)
+		}
+
+		var codes []string
+		for _, w := range result.Warnings {
+			codes = append(codes, w.Code)
+		}
+		if want := []string{tt.warning}; tt.warning != "" && !reflect.DeepEqual(codes, want) || tt.warning == "" && codes != nil {
+			t.Errorf("%s: warnings %v, want %q", tt.name, codes, tt.warning)
+		}
+	}
+
+	if len(dests.members) != 1 || dests.members[0].ID != 1 {
+		t.Errorf("members %+v, want 1", dests.members)
+	}
+	if len(dests.invoices) != 1 || dests.invoices[0].ID != 1 {
+		t.Errorf("invoices %+v, want 1", dests.invoices)
+	}
+	if len(dests.notes) != 1 || dests.notes[0].ID != 1 {
+		t.Errorf("notes %+v, want 1", dests.notes)
+	}
+}
+
+func TestMultiModelMerge(t *testing.T) {
+
+	tests := []struct {
+		page  int
+		items []string
+		dests [3][]uint
+	}{
+		{page: 1, items: []string{"invoices 3", "members 3", "notes 2"}, dests: [3][]uint{{3}, {3}, {2}}},
+		{page: 2, items: []string{"notes 1", "invoices 1", "members 1"}, dests: [3][]uint{{1}, {1}, {1}}},
+		{page: 3, items: nil, dests: [3][]uint{nil, nil, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint("page ", tt.page), func(t *testing.T) {
+
+			db := multiModelDB(t)
+
+			var dests multiModelDests
+			merged, err := multiModelRequest(queryhelper.WithPage(tt.page), queryhelper.WithPageSize(3)).MultiModelMerge(dests.targets(db), "-updated_at")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var items []string
+			for _, item := range merged.Items {
+				var id uint
+				switch row := item.Item.(type) {
+				case testMember:
+					id = row.ID
+				case testInvoice:
+					id = row.ID
+				case testNote:
+					id = row.ID
+				}
+				items = append(items, fmt.Sprint(item.Target, " ", id))
+			}
+			if !reflect.DeepEqual(items, tt.items) {
+				t.Errorf("items %q, want %q", items, tt.items)
+			}
+
+			if merged.Pagination.Total != 6 || merged.Pagination.TotalPages != 2 {
+				t.Errorf("total %d of %d pages, want 6 of 2", merged.Pagination.Total, merged.Pagination.TotalPages)
+			}
+			for _, target := range merged.Targets {
+				if target.Err != nil || target.Pagination.Total != 2 {
+					t.Errorf("%s: total %d, err %v, want 2", target.Name, target.Pagination.Total, target.Err)
+				}
+			}
+
+			// Each Dest holds its own rows of the merged page
+			got := [3][]uint{}
+			for _, m := range dests.members {
+				got[0] = append(got[0], m.ID)
+			}
+			for _, i := range dests.invoices {
+				got[1] = append(got[1], i.ID)
+			}
+			for _, n := range dests.notes {
+				got[2] = append(got[2], n.ID)
+			}
+			if !reflect.DeepEqual(got, tt.dests) {
+				t.Errorf("dests %v, want %v", got, tt.dests)
+			}
+		})
+	}
+}
+
+func TestMultiModelFailedTarget(t *testing.T) {
+
+	db := multiModelDB(t)
+
+	var dests multiModelDests
+	var ghosts []map[string]interface{}
+	targets := append(dests.targets(db), queryhelper.SearchTarget{
+		Name:     "ghosts",
+		Query:    db.Table("ghosts"),
+		Settings: &queryhelper.QuerySettings{AllowedSearch: []string{"name"}, AllowedOrderBy: []string{"id"}},
+		Dest:     &ghosts,
+	})
+
+	t.Run("execute", func(t *testing.T) {
+
+		results := multiModelRequest().MultiModelExecute(targets)
+		for _, result := range results {
+			if failed := result.Name == "ghosts"; failed != (result.Err != nil) {
+				t.Errorf("%s: err %v", result.Name, result.Err)
+			}
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+
+		merged, err := multiModelRequest(queryhelper.WithPageSize(10)).MultiModelMerge(targets, "-updated_at")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(merged.Items) != 6 || merged.Pagination.Total != 6 {
+			t.Errorf("merged %d items of %d, want 6 of 6", len(merged.Items), merged.Pagination.Total)
+		}
+		if failed := merged.Targets[3]; failed.Name != "ghosts" || failed.Err == nil || !strings.Contains(failed.Err.Error(), "ghosts") {
+			t.Errorf("ghosts result %+v, want its error", failed)
+		}
+	})
+
+	t.Run("every target failing", func(t *testing.T) {
+
+		_, err := multiModelRequest().MultiModelMerge(targets[3:], "-updated_at")
+		if err == nil || !strings.Contains(err.Error(), "search target ghosts") {
+			t.Errorf("err = %v, want the ghosts failure", err)
+		}
+	})
+}
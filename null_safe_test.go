@@ -0,0 +1,119 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testContact struct {
+	ID     uint
+	Name   string
+	Region *string
+}
+
+func (testContact) TableName() string {
+	return "contacts"
+}
+
+func nullSafeSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"region": {"NULL_SAFE_EQ"}, "name": {"!="}},
+		AllowedOrderBy: []string{"id"},
+	}
+}
+
+func TestNullSafeEqualSQL(t *testing.T) {
+
+	tests := []struct {
+		dialect string
+		value   interface{}
+		want    string
+		vars    []interface{}
+	}{
+		{dialect: "postgres", value: "eu", want: `WHERE "name" != ? AND "region" IS NOT DISTINCT FROM ?`, vars: []interface{}{"x", "eu"}},
+		{dialect: "postgres", value: nil, want: `WHERE "name" != ? AND "region" IS NOT DISTINCT FROM ?`, vars: []interface{}{"x", nil}},
+		{dialect: "mysql", value: "eu", want: "WHERE `name` != ? AND `region` <=> ?", vars: []interface{}{"x", "eu"}},
+		{dialect: "sqlite", value: "eu", want: `WHERE "name" != ? AND ("region" = ? OR ("region" IS NULL AND ? IS NULL))`, vars: []interface{}{"x", "eu", "eu"}},
+		{dialect: "sqlite", value: nil, want: `WHERE "name" != ? AND ("region" = ? OR ("region" IS NULL AND ? IS NULL))`, vars: []interface{}{"x", nil, nil}},
+		{dialect: "sqlserver", value: "eu", want: `WHERE "name" != ? AND ("region" = ? OR ("region" IS NULL AND ? IS NULL))`, vars: []interface{}{"x", "eu", "eu"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(
+				queryhelper.WithFilter("name", "!=", "x"),
+				queryhelper.WithFilters([]queryhelper.FilterCondition{{Field: "region", Operator: "NULL_SAFE_EQ", Value: tt.value}}),
+			)
+
+			sql, vars, err := queryhelpertest.RenderSQL(t, tt.dialect, dq, nullSafeSettings(), &testContact{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(sql, tt.want) {
+				t.Errorf("got\n%s\nwant it to contain\n%s", sql, tt.want)
+			}
+			if len(vars) < len(tt.vars) || !reflect.DeepEqual(vars[:len(tt.vars)], tt.vars) {
+				t.Errorf("vars %v, want %v first", vars, tt.vars)
+			}
+		})
+	}
+}
+
+func TestNullSafeEqualRows(t *testing.T) {
+
+	eu, us := "eu", "us"
+	db := queryhelpertest.NewTestDB(t, &testContact{})
+	queryhelpertest.Seed(t, db, &[]testContact{
+		{ID: 1, Name: "a", Region: &eu},
+		{ID: 2, Name: "b"},
+		{ID: 3, Name: "c", Region: &us},
+		{ID: 4, Name: "d"},
+	})
+
+	tests := []struct {
+		name   string
+		filter queryhelper.FilterCondition
+		groups []queryhelper.FilterGroup
+		want   []uint
+	}{
+		{name: "nil matches NULL", filter: queryhelper.FilterCondition{Field: "region", Operator: "NULL_SAFE_EQ"}, want: []uint{2, 4}},
+		{name: "value matches equal", filter: queryhelper.FilterCondition{Field: "region", Operator: "NULL_SAFE_EQ", Value: "eu"}, want: []uint{1}},
+		{name: "alias", filter: queryhelper.FilterCondition{Field: "region", Operator: "<=>", Value: "us"}, want: []uint{3}},
+		{
+			name:   "with another filter",
+			filter: queryhelper.FilterCondition{Field: "name", Operator: "!=", Value: "d"},
+			groups: []queryhelper.FilterGroup{{Logic: queryhelper.LogicOr, Filters: []queryhelper.FilterCondition{
+				{Field: "region", Operator: "NULL_SAFE_EQ"},
+				{Field: "region", Operator: "NULL_SAFE_EQ", Value: "us"},
+			}}},
+			want: []uint{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var contacts []testContact
+			dq := queryhelper.NewQueryHelper(queryhelper.WithFilters([]queryhelper.FilterCondition{tt.filter}), queryhelper.WithFilterGroups(tt.groups))
+			if err := dq.Execute(nullSafeSettings(), db.Model(&testContact{}), &contacts); err != nil {
+				t.Fatal(err)
+			}
+			if len(dq.Info().Warnings) != 0 {
+				t.Errorf("warnings %+v", dq.Info().Warnings)
+			}
+
+			var got []uint
+			for _, c := range contacts {
+				got = append(got, c.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package odata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weedbox/queryhelper"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenLiteral
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenString:
+		return fmt.Sprintf("string '%s'", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func lex(s string) ([]token, error) {
+
+	tokens := make([]token, 0)
+
+	i := 0
+	for i < len(s) {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case c == '\'':
+			// Quotes inside strings are escaped by doubling them
+			start := i
+			i++
+
+			var sb strings.Builder
+			closed := false
+			for i < len(s) {
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						sb.WriteByte('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+
+			if !closed {
+				return nil, &Error{Option: "$filter", Pos: start, Message: "unterminated string"}
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			// Numbers, dates and times
+			start := i
+			i++
+			for i < len(s) && isLiteralChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: s[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(s) && (isIdentStart(s[i]) || (s[i] >= '0' && s[i] <= '9') || s[i] == '/' || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[start:i], pos: start})
+		default:
+			return nil, &Error{Option: "$filter", Pos: i, Message: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(s)})
+
+	return tokens, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLiteralChar(c byte) bool {
+	return c == '.' || c == ':' || c == '+' || c == '-' || isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+type node struct {
+	logic    string
+	not      bool
+	children []*node
+	filter   *queryhelper.FilterCondition
+}
+
+func (n *node) add(child *node) {
+
+	// Flatten nested lists with the same logic
+	if child.filter == nil && !child.not && child.logic == n.logic {
+		n.children = append(n.children, child.children...)
+		return
+	}
+
+	n.children = append(n.children, child)
+}
+
+func (n *node) group() queryhelper.FilterGroup {
+
+	group := queryhelper.FilterGroup{
+		Logic: n.logic,
+		Not:   n.not,
+	}
+
+	for _, child := range n.children {
+		if child.filter != nil {
+			group.Filters = append(group.Filters, *child.filter)
+			continue
+		}
+		group.Groups = append(group.Groups, child.group())
+	}
+
+	return group
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	settings *queryhelper.QuerySettings
+}
+
+func parseFilter(expr string, settings *queryhelper.QuerySettings, conditions *queryhelper.QueryConditions) error {
+
+	tokens, err := lex(expr)
+	if err != nil {
+		return err
+	}
+
+	p := &parser{
+		tokens:   tokens,
+		settings: settings,
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return p.unexpected(tok, "and", "or")
+	}
+
+	switch {
+	case root.filter != nil:
+		conditions.Filters = append(conditions.Filters, *root.filter)
+	case root.logic == queryhelper.LogicAnd && !root.not:
+		for _, child := range root.children {
+			if child.filter != nil {
+				conditions.Filters = append(conditions.Filters, *child.filter)
+				continue
+			}
+			conditions.FilterGroups = append(conditions.FilterGroups, child.group())
+		}
+	default:
+		conditions.FilterGroups = append(conditions.FilterGroups, root.group())
+	}
+
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokenIdent && tok.text == keyword
+}
+
+func (p *parser) unexpected(tok token, expected ...string) error {
+	return &Error{
+		Option:  "$filter",
+		Pos:     tok.pos,
+		Message: fmt.Sprintf("unexpected %s, expected %s", tok.describe(), strings.Join(expected, " or ")),
+	}
+}
+
+func (p *parser) parseOr() (*node, error) {
+	return p.parseList(queryhelper.LogicOr, "or", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	return p.parseList(queryhelper.LogicAnd, "and", p.parseUnary)
+}
+
+func (p *parser) parseList(logic string, keyword string, next func() (*node, error)) (*node, error) {
+
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &node{logic: logic}
+	n.add(first)
+
+	for isKeyword(p.peek(), keyword) {
+		p.next()
+
+		child, err := next()
+		if err != nil {
+			return nil, err
+		}
+		n.add(child)
+	}
+
+	if len(n.children) == 1 {
+		return n.children[0], nil
+	}
+
+	return n, nil
+}
+
+func (p *parser) parseUnary() (*node, error) {
+
+	if !isKeyword(p.peek(), "not") {
+		return p.parsePrimary()
+	}
+	p.next()
+
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	// Double negation cancels out
+	if child.not {
+		child.not = false
+		if len(child.children) == 1 {
+			return child.children[0], nil
+		}
+		return child, nil
+	}
+
+	if child.filter != nil {
+		return &node{logic: queryhelper.LogicAnd, not: true, children: []*node{child}}, nil
+	}
+
+	child.not = true
+	return child, nil
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+
+	tok := p.peek()
+
+	if tok.kind == tokenLParen {
+		p.next()
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.unexpected(closing, "\")\"", "and", "or")
+		}
+
+		return n, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (*node, error) {
+
+	fieldTok := p.next()
+	if fieldTok.kind != tokenIdent {
+		return nil, p.unexpected(fieldTok, "property name", "\"(\"", "not")
+	}
+
+	// Functions like contains() or startswith() are not supported
+	if p.peek().kind == tokenLParen {
+		return nil, &Error{Option: "$filter", Pos: fieldTok.pos, Message: fmt.Sprintf("unsupported function %s()", fieldTok.text), Unsupported: true}
+	}
+
+	if strings.ContainsAny(fieldTok.text, "/.") {
+		return nil, &Error{Option: "$filter", Pos: fieldTok.pos, Message: fmt.Sprintf("unsupported property path %s", fieldTok.text), Unsupported: true}
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokenIdent {
+		return nil, p.unexpected(opTok, "eq", "ne", "gt", "ge", "lt", "le", "in")
+	}
+
+	var operator string
+	var value interface{}
+
+	if opTok.text == "in" {
+		vals, err := p.parseValueList()
+		if err != nil {
+			return nil, err
+		}
+		operator = "IN"
+		value = vals
+	} else {
+		op, ok := comparisonOperators[opTok.text]
+		if !ok {
+			switch opTok.text {
+			case "has", "add", "sub", "mul", "div", "divby", "mod":
+				return nil, &Error{Option: "$filter", Pos: opTok.pos, Message: fmt.Sprintf("unsupported operator %s", opTok.text), Unsupported: true}
+			}
+			return nil, p.unexpected(opTok, "eq", "ne", "gt", "ge", "lt", "le", "in")
+		}
+
+		valTok := p.peek()
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		operator = op
+		if v == nil {
+			switch op {
+			case "=":
+				operator = "IS NULL"
+			case "!=":
+				operator = "IS NOT NULL"
+			default:
+				return nil, &Error{Option: "$filter", Pos: valTok.pos, Message: fmt.Sprintf("null cannot be used with %s", opTok.text)}
+			}
+		}
+
+		value = v
+	}
+
+	field := fieldTok.text
+	allowedOps, ok := p.settings.AllowedFilters[field]
+	if !ok {
+		return nil, &Error{Option: "$filter", Pos: fieldTok.pos, Message: fmt.Sprintf("property %q is not filterable", field)}
+	}
+
+	if !containsString(allowedOps, operator) {
+		return nil, &Error{Option: "$filter", Pos: opTok.pos, Message: fmt.Sprintf("operator %s is not allowed on property %q", opTok.text, field)}
+	}
+
+	return &node{
+		filter: &queryhelper.FilterCondition{
+			Field:    field,
+			Operator: operator,
+			Value:    value,
+		},
+	}, nil
+}
+
+func (p *parser) parseValueList() ([]interface{}, error) {
+
+	if tok := p.next(); tok.kind != tokenLParen {
+		return nil, p.unexpected(tok, "\"(\"")
+	}
+
+	vals := make([]interface{}, 0)
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+
+		tok := p.next()
+		if tok.kind == tokenRParen {
+			return vals, nil
+		}
+		if tok.kind != tokenComma {
+			return nil, p.unexpected(tok, "\",\"", "\")\"")
+		}
+	}
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenString:
+		return tok.text, nil
+	case tokenLiteral:
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return f, nil
+		}
+
+		// Dates and times are passed on as text for the field type to convert
+		return tok.text, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+
+		if p.peek().kind == tokenLParen {
+			return nil, &Error{Option: "$filter", Pos: tok.pos, Message: fmt.Sprintf("unsupported function %s()", tok.text), Unsupported: true}
+		}
+	}
+
+	return nil, p.unexpected(tok, "literal value")
+}
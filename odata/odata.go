@@ -0,0 +1,189 @@
+// Package odata parses a subset of the OData query conventions ($filter,
+// $orderby, $top, $skip and $search) into query conditions and pagination.
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/weedbox/queryhelper"
+)
+
+var ErrUnsupported = errors.New("odata: unsupported construct")
+
+var comparisonOperators = map[string]string{
+	"eq": "=",
+	"ne": "!=",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+}
+
+// Error reports a problem with one query option. Pos is a byte offset into
+// the option value when known, or -1.
+type Error struct {
+	Option      string
+	Pos         int
+	Message     string
+	Unsupported bool
+}
+
+func (e *Error) Error() string {
+
+	if e.Pos >= 0 {
+		return fmt.Sprintf("odata: %s: %s at position %d", e.Option, e.Message, e.Pos)
+	}
+
+	return fmt.Sprintf("odata: %s: %s", e.Option, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Unsupported && target == ErrUnsupported
+}
+
+// Parse parses a raw query string such as
+// "$filter=Status eq 'Active'&$orderby=Name desc&$top=20&$skip=40".
+func Parse(rawQuery string, settings *queryhelper.QuerySettings) (*queryhelper.QueryConditions, *queryhelper.PaginationRequest, error) {
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, nil, &Error{Option: "query", Pos: -1, Message: err.Error()}
+	}
+
+	return ParseValues(values, settings)
+}
+
+// ParseValues parses already decoded query parameters. Parameters that do
+// not start with "$" are ignored.
+func ParseValues(values url.Values, settings *queryhelper.QuerySettings) (*queryhelper.QueryConditions, *queryhelper.PaginationRequest, error) {
+
+	if settings == nil {
+		settings = queryhelper.DefaultQuerySettings
+	}
+
+	conditions := &queryhelper.QueryConditions{}
+	pagination := &queryhelper.PaginationRequest{}
+
+	for key := range values {
+		if !strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		switch key {
+		case "$filter", "$orderby", "$top", "$skip", "$search":
+		default:
+			return nil, nil, &Error{Option: key, Pos: -1, Message: "unsupported query option", Unsupported: true}
+		}
+	}
+
+	if expr := values.Get("$filter"); expr != "" {
+		if err := parseFilter(expr, settings, conditions); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if orderBy := values.Get("$orderby"); orderBy != "" {
+		fields, err := parseOrderBy(orderBy, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions.OrderBy = fields
+	}
+
+	conditions.SearchText = values.Get("$search")
+
+	top, err := parseCount(values, "$top")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skip, err := parseCount(values, "$skip")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if top > 0 {
+		pagination.PageSize = top
+	}
+
+	// Skips that do not fall on a page boundary use a raw offset
+	if skip > 0 {
+		if top > 0 && skip%top == 0 {
+			pagination.Page = skip/top + 1
+		} else {
+			pagination.Offset = &skip
+		}
+	}
+
+	return conditions, pagination, nil
+}
+
+func parseCount(values url.Values, option string) (int, error) {
+
+	raw := values.Get(option)
+	if raw == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, &Error{Option: option, Pos: -1, Message: fmt.Sprintf("invalid value %q", raw)}
+	}
+
+	return n, nil
+}
+
+func parseOrderBy(expr string, settings *queryhelper.QuerySettings) ([]string, error) {
+
+	fields := make([]string, 0)
+
+	offset := 0
+	for _, item := range strings.Split(expr, ",") {
+		parts := strings.Fields(item)
+		pos := offset + strings.Index(item, strings.TrimSpace(item))
+		offset += len(item) + 1
+
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, &Error{Option: "$orderby", Pos: pos, Message: fmt.Sprintf("invalid item %q", strings.TrimSpace(item))}
+		}
+
+		field := parts[0]
+		if strings.Contains(field, "(") || strings.Contains(field, "/") {
+			return nil, &Error{Option: "$orderby", Pos: pos, Message: fmt.Sprintf("unsupported expression %s", field), Unsupported: true}
+		}
+
+		if !containsString(settings.AllowedOrderBy, field) {
+			return nil, &Error{Option: "$orderby", Pos: pos, Message: fmt.Sprintf("field %q is not sortable", field)}
+		}
+
+		direction := "+"
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				direction = "-"
+			default:
+				return nil, &Error{Option: "$orderby", Pos: pos, Message: fmt.Sprintf("invalid direction %q", parts[1])}
+			}
+		}
+
+		fields = append(fields, direction+field)
+	}
+
+	return fields, nil
+}
+
+func containsString(list []string, s string) bool {
+
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
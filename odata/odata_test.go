@@ -0,0 +1,176 @@
+package odata
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+var settings = &queryhelper.QuerySettings{
+	AllowedOrderBy: []string{"Name", "Price"},
+	AllowedFilters: map[string][]string{
+		"Status":    {"=", "!=", "IN", "IS NULL", "IS NOT NULL"},
+		"Price":     {"<", "<=", ">", ">="},
+		"Stock":     {"="},
+		"Active":    {"="},
+		"CreatedAt": {">="},
+	},
+}
+
+type filter = queryhelper.FilterCondition
+type group = queryhelper.FilterGroup
+
+func TestParseGolden(t *testing.T) {
+
+	offset := func(n int) *int { return &n }
+
+	tests := []struct {
+		query      string
+		conditions queryhelper.QueryConditions
+		pagination queryhelper.PaginationRequest
+	}{
+		{
+			query: "$filter=Status eq 'Active' and Price lt 100&$orderby=Name desc&$top=20&$skip=40&$search=chair",
+			conditions: queryhelper.QueryConditions{
+				SearchText: "chair",
+				OrderBy:    []string{"-Name"},
+				Filters: []filter{
+					{Field: "Status", Operator: "=", Value: "Active"},
+					{Field: "Price", Operator: "<", Value: int64(100)},
+				},
+			},
+			pagination: queryhelper.PaginationRequest{Page: 3, PageSize: 20},
+		},
+		{
+			query: "$filter=Status eq 'Active' or Status eq 'New'",
+			conditions: queryhelper.QueryConditions{
+				FilterGroups: []group{{Logic: queryhelper.LogicOr, Filters: []filter{
+					{Field: "Status", Operator: "=", Value: "Active"},
+					{Field: "Status", Operator: "=", Value: "New"},
+				}}},
+			},
+		},
+		{
+			// and binds tighter than or
+			query: "$filter=Price gt 10 and Price le 20.5 or Stock eq 0",
+			conditions: queryhelper.QueryConditions{
+				FilterGroups: []group{{Logic: queryhelper.LogicOr,
+					Filters: []filter{{Field: "Stock", Operator: "=", Value: int64(0)}},
+					Groups: []group{{Logic: queryhelper.LogicAnd, Filters: []filter{
+						{Field: "Price", Operator: ">", Value: int64(10)},
+						{Field: "Price", Operator: "<=", Value: 20.5},
+					}}},
+				}},
+			},
+		},
+		{
+			query: "$filter=Active eq true and (Status in ('A','B') or not (Price ge 100))",
+			conditions: queryhelper.QueryConditions{
+				Filters: []filter{{Field: "Active", Operator: "=", Value: true}},
+				FilterGroups: []group{{Logic: queryhelper.LogicOr,
+					Filters: []filter{{Field: "Status", Operator: "IN", Value: []interface{}{"A", "B"}}},
+					Groups: []group{{Logic: queryhelper.LogicAnd, Not: true, Filters: []filter{
+						{Field: "Price", Operator: ">=", Value: int64(100)},
+					}}},
+				}},
+			},
+		},
+		{
+			// Double negation cancels out
+			query: "$filter=not not Stock eq 1",
+			conditions: queryhelper.QueryConditions{
+				Filters: []filter{{Field: "Stock", Operator: "=", Value: int64(1)}},
+			},
+		},
+		{
+			query: "$filter=Status eq null and Status ne 'It''s'",
+			conditions: queryhelper.QueryConditions{
+				Filters: []filter{
+					{Field: "Status", Operator: "IS NULL"},
+					{Field: "Status", Operator: "!=", Value: "It's"},
+				},
+			},
+		},
+		{
+			// Dates are left as text for the field to convert
+			query: "$filter=CreatedAt ge 2024-01-15T00:00:00Z&$orderby=Price,Name asc",
+			conditions: queryhelper.QueryConditions{
+				OrderBy: []string{"+Price", "+Name"},
+				Filters: []filter{{Field: "CreatedAt", Operator: ">=", Value: "2024-01-15T00:00:00Z"}},
+			},
+		},
+		{
+			// A skip off the page boundary is an offset
+			query:      "$top=20&$skip=30",
+			pagination: queryhelper.PaginationRequest{PageSize: 20, Offset: offset(30)},
+		},
+		{
+			query: "other=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+
+			conditions, pagination, err := Parse(tt.query, settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(*conditions, tt.conditions) {
+				t.Errorf("conditions\n got %#v\nwant %#v", *conditions, tt.conditions)
+			}
+			if !reflect.DeepEqual(*pagination, tt.pagination) {
+				t.Errorf("pagination\n got %#v\nwant %#v", *pagination, tt.pagination)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		query       string
+		option      string
+		pos         int
+		message     string
+		unsupported bool
+	}{
+		{query: "$filter=contains(Name,'x')", option: "$filter", pos: 0, message: "unsupported function contains()", unsupported: true},
+		{query: "$filter=Price eq round(Price)", option: "$filter", pos: 9, message: "unsupported function round()", unsupported: true},
+		{query: "$filter=Category/Name eq 'x'", option: "$filter", pos: 0, message: "unsupported property path Category/Name", unsupported: true},
+		{query: "$filter=Price add 1 eq 2", option: "$filter", pos: 6, message: "unsupported operator add", unsupported: true},
+		{query: "$expand=Items", option: "$expand", pos: -1, message: "unsupported query option", unsupported: true},
+		{query: "$orderby=length(Name)", option: "$orderby", pos: 0, message: "unsupported expression length(Name)", unsupported: true},
+		{query: "$filter=Password eq 'x'", option: "$filter", pos: 0, message: `property "Password" is not filterable`},
+		{query: "$filter=Stock gt 1", option: "$filter", pos: 6, message: `operator gt is not allowed on property "Stock"`},
+		{query: "$filter=Price lt null", option: "$filter", pos: 9, message: "null cannot be used with lt"},
+		{query: "$filter=Status eq 'x' and", option: "$filter", pos: 17, message: `unexpected end of input, expected property name or "(" or not`},
+		{query: "$filter=(Stock eq 1", option: "$filter", pos: 11, message: `unexpected end of input, expected ")" or and or or`},
+		{query: "$filter=Status eq 'x", option: "$filter", pos: 10, message: "unterminated string"},
+		{query: "$orderby=Name sideways", option: "$orderby", pos: 0, message: `invalid direction "sideways"`},
+		{query: "$orderby=Name,Secret", option: "$orderby", pos: 5, message: `field "Secret" is not sortable`},
+		{query: "$top=-1", option: "$top", pos: -1, message: `invalid value "-1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+
+			_, _, err := Parse(tt.query, settings)
+
+			var oerr *Error
+			if !errors.As(err, &oerr) {
+				t.Fatalf("err = %v, want an *Error", err)
+			}
+
+			if oerr.Option != tt.option || oerr.Pos != tt.pos || oerr.Message != tt.message {
+				t.Errorf("got %s at %d: %s\nwant %s at %d: %s", oerr.Option, oerr.Pos, oerr.Message, tt.option, tt.pos, tt.message)
+			}
+			if errors.Is(err, ErrUnsupported) != tt.unsupported {
+				t.Errorf("errors.Is(err, ErrUnsupported) = %v", !tt.unsupported)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package queryhelper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Convention selects how query parameters are named.
+type Convention string
+
+const (
+	// ConventionBracketed uses page, page_size, search, search_fields,
+	// order_by, sort_factor, fields and filter[field][op] parameters.
+	ConventionBracketed Convention = "bracketed"
+
+	// ConventionJSONAPI uses page[number], page[size], sort and
+	// filter[field] / filter[field][op] parameters.
+	ConventionJSONAPI Convention = "jsonapi"
+)
+
+// ParameterSpec is an OpenAPI 3 parameter object.
+type ParameterSpec struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Style       string      `json:"style,omitempty"`
+	Explode     *bool       `json:"explode,omitempty"`
+	Schema      *SchemaSpec `json:"schema"`
+}
+
+// SchemaSpec is the subset of an OpenAPI 3 schema object used by parameters.
+type SchemaSpec struct {
+	Type     string        `json:"type"`
+	Format   string        `json:"format,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	Items    *SchemaSpec   `json:"items,omitempty"`
+	Minimum  *int          `json:"minimum,omitempty"`
+	Maximum  *int          `json:"maximum,omitempty"`
+	MinItems *int          `json:"minItems,omitempty"`
+	MaxItems *int          `json:"maxItems,omitempty"`
+	Default  interface{}   `json:"default,omitempty"`
+}
+
+// OpenAPIParameters describes the query parameters the settings accept, so
+// API documentation can be generated from the same source as validation.
+func (s *QuerySettings) OpenAPIParameters(conventions Convention) ([]ParameterSpec, error) {
+
+	pageName, pageSizeName, sortName := "page", "page_size", "order_by"
+	if conventions == ConventionJSONAPI {
+		pageName, pageSizeName, sortName = "page[number]", "page[size]", "sort"
+	} else if conventions != ConventionBracketed {
+		return nil, fmt.Errorf("unknown parameter convention %q", conventions)
+	}
+
+	params := []ParameterSpec{
+		{
+			Name:        pageName,
+			In:          "query",
+			Description: "Page number, starting at 1",
+			Schema:      &SchemaSpec{Type: "integer", Minimum: intPtr(1), Default: DefaultPage},
+		},
+		{
+			Name:        pageSizeName,
+			In:          "query",
+			Description: "Number of items per page",
+			Schema:      &SchemaSpec{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(DefaultMaxPageSize), Default: DefaultPageSize},
+		},
+	}
+
+	if conventions == ConventionBracketed && len(s.AllowedSearch) > 0 {
+		searchFields := "Fields to search, defaults to all searchable fields"
+		if len(s.DefaultSearchFields) > 0 {
+			searchFields = "Fields to search, defaults to " + strings.Join(s.DefaultSearchFields, ", ")
+		}
+
+		params = append(params,
+			ParameterSpec{
+				Name:        "search",
+				In:          "query",
+				Description: "Text to search for",
+				Schema:      &SchemaSpec{Type: "string"},
+			},
+			listParameter("search_fields", searchFields, &SchemaSpec{Type: "string", Enum: stringEnum(s.AllowedSearch)}),
+		)
+	}
+
+	if len(s.AllowedOrderBy) > 0 {
+		enum := make([]interface{}, 0, len(s.AllowedOrderBy)*2)
+		for _, field := range s.AllowedOrderBy {
+			enum = append(enum, field, "-"+field)
+		}
+
+		params = append(params, listParameter(sortName, "Fields to sort by, prefixed with - for descending order", &SchemaSpec{Type: "string", Enum: enum}))
+	}
+
+	if conventions == ConventionBracketed {
+		params = append(params, ParameterSpec{
+			Name:        "sort_factor",
+			In:          "query",
+			Description: "Default sort direction: 1 ascending, -1 descending",
+			Schema:      &SchemaSpec{Type: "integer", Enum: []interface{}{1, -1}},
+		})
+
+		if len(s.AllowedFields) > 0 {
+			params = append(params, listParameter("fields", "Columns to return", &SchemaSpec{Type: "string", Enum: stringEnum(s.AllowedFields)}))
+		}
+	}
+
+	// Filters in a stable order
+	fields := make([]string, 0, len(s.AllowedFilters))
+	for field := range s.AllowedFilters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		valueSchema, err := fieldTypeSchema(s.FieldTypes[field])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+
+		for _, operator := range s.AllowedFilters[field] {
+			token, ok := operatorToken(operator)
+			if !ok {
+				return nil, fmt.Errorf("field %s: operator %s has no query parameter name", field, operator)
+			}
+
+			name := "filter[" + field + "][" + token + "]"
+			if conventions == ConventionJSONAPI && operator == "=" {
+				name = "filter[" + field + "]"
+			}
+
+			description := fmt.Sprintf("Filter %s with %s", field, operator)
+
+			switch operator {
+			case "IN", "NOT IN", "REL_ANY", "REL_ALL":
+				params = append(params, listParameter(name, description, valueSchema))
+			case "BETWEEN":
+				p := listParameter(name, description+", two comma separated values", valueSchema)
+				p.Schema.MinItems = intPtr(2)
+				p.Schema.MaxItems = intPtr(2)
+				params = append(params, p)
+			case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+				params = append(params, ParameterSpec{
+					Name:        name,
+					In:          "query",
+					Description: description + ", value is ignored",
+					Schema:      &SchemaSpec{Type: "boolean"},
+				})
+			default:
+				params = append(params, ParameterSpec{
+					Name:        name,
+					In:          "query",
+					Description: description,
+					Schema:      valueSchema,
+				})
+			}
+		}
+	}
+
+	return params, nil
+}
+
+func listParameter(name string, description string, items *SchemaSpec) ParameterSpec {
+
+	explode := false
+
+	return ParameterSpec{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Style:       "form",
+		Explode:     &explode,
+		Schema:      &SchemaSpec{Type: "array", Items: items},
+	}
+}
+
+func fieldTypeSchema(fieldType string) (*SchemaSpec, error) {
+
+	switch fieldType {
+	case "", FieldTypeString:
+		return &SchemaSpec{Type: "string"}, nil
+	case FieldTypeInt:
+		return &SchemaSpec{Type: "integer", Format: "int64"}, nil
+	case FieldTypeFloat:
+		return &SchemaSpec{Type: "number", Format: "double"}, nil
+	case FieldTypeBool:
+		return &SchemaSpec{Type: "boolean"}, nil
+	case FieldTypeTime:
+		return &SchemaSpec{Type: "string", Format: "date-time"}, nil
+	case FieldTypeUUID:
+		return &SchemaSpec{Type: "string", Format: "uuid"}, nil
+	}
+
+	return nil, fmt.Errorf("unknown field type %q", fieldType)
+}
+
+// operatorToken returns the query parameter name of an operator, preferring
+// the shortest and then alphabetically first token.
+func operatorToken(operator string) (string, bool) {
+
+	found := ""
+	for token, op := range OperatorTokens {
+		if op != operator {
+			continue
+		}
+
+		if found == "" || len(token) < len(found) || (len(token) == len(found) && token < found) {
+			found = token
+		}
+	}
+
+	return found, found != ""
+}
+
+func stringEnum(values []string) []interface{} {
+
+	enum := make([]interface{}, len(values))
+	for i, v := range values {
+		enum[i] = v
+	}
+
+	return enum
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,77 @@
+package queryhelper_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+// openAPISettings is representative of a listing endpoint's settings.
+var openAPISettings = &queryhelper.QuerySettings{
+	AllowedSearch:       []string{"name", "email"},
+	DefaultSearchFields: []string{"name"},
+	AllowedOrderBy:      []string{"name", "created_at"},
+	AllowedFields:       []string{"id", "name", "email"},
+	AllowedFilters: map[string][]string{
+		"status":     {"=", "IN"},
+		"age":        {">=", "BETWEEN"},
+		"active":     {"="},
+		"created_at": {"<", "IS NULL"},
+		"score":      {"NOT IN"},
+	},
+	FieldTypes: map[string]string{
+		"age":        queryhelper.FieldTypeInt,
+		"active":     queryhelper.FieldTypeBool,
+		"created_at": queryhelper.FieldTypeTime,
+		"score":      queryhelper.FieldTypeFloat,
+	},
+}
+
+// TestOpenAPIParametersGolden compares the parameters of each convention
+// with testdata/openapi_<convention>.json.
+func TestOpenAPIParametersGolden(t *testing.T) {
+
+	for _, convention := range []queryhelper.Convention{queryhelper.ConventionBracketed, queryhelper.ConventionJSONAPI} {
+		t.Run(string(convention), func(t *testing.T) {
+
+			params, err := openAPISettings.OpenAPIParameters(convention)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.MarshalIndent(params, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "openapi_"+string(convention)+".json")
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+				t.Errorf("%s differs, got\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestOpenAPIParametersErrors(t *testing.T) {
+
+	if _, err := openAPISettings.OpenAPIParameters("odata"); err == nil {
+		t.Error("unknown convention accepted")
+	}
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"age": {"="}},
+		FieldTypes:     map[string]string{"age": "decimal"},
+	}
+	if _, err := settings.OpenAPIParameters(queryhelper.ConventionBracketed); err == nil {
+		t.Error("unknown field type accepted")
+	}
+}
@@ -1,7 +1,10 @@
 package queryhelper
 
 import (
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -11,19 +14,36 @@ const (
 )
 
 type PaginationRequest struct {
-	Page     int `json:"page"`
-	PageSize int `json:"page_size"`
+	Page      int    `json:"page"`
+	PageSize  int    `json:"page_size"`
+	Offset    *int   `json:"offset,omitempty"`     // raw offset, bypasses page arithmetic
+	Limit     *int   `json:"limit,omitempty"`      // raw limit, defaults to PageSize
+	CountMode string `json:"count_mode,omitempty"` // exact (default), estimated or none
 }
 
 type PaginationInfo struct {
-	Page       int   `json:"page"`
-	PageSize   int   `json:"page_size"`
-	Total      int64 `json:"total"`
-	TotalPages int   `json:"total_pages"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	CountMode  string `json:"count_mode,omitempty"` // how Total was found, exact when an estimate was unavailable
 }
 
 type PaginationHandle struct {
-	Info *PaginationInfo `json:"info"`
+	Info       *PaginationInfo `json:"info"`
+	raw        bool
+	err        error
+	dropped    []DroppedItem
+	countTable string
+	timeout    time.Duration
+	retry      *RetryPolicy
+	retries    int
+
+	countMode   string        // as requested
+	modeDropped []DroppedItem // count mode fallbacks of the last Apply
+	sampled     float64       // percent of rows the query samples, 0 for all
 }
 
 func NewPaginationHandle(req *PaginationRequest) *PaginationHandle {
@@ -32,6 +52,10 @@ func NewPaginationHandle(req *PaginationRequest) *PaginationHandle {
 		req = &PaginationRequest{}
 	}
 
+	if req.Offset != nil || req.Limit != nil {
+		return newRawPaginationHandle(req)
+	}
+
 	if req.Page <= 0 {
 		req.Page = DefaultPage
 	}
@@ -40,7 +64,9 @@ func NewPaginationHandle(req *PaginationRequest) *PaginationHandle {
 		req.PageSize = DefaultPageSize
 	}
 
+	var dropped []DroppedItem
 	if req.PageSize > DefaultMaxPageSize {
+		dropped = append(dropped, DroppedItem{Kind: DroppedPageSize, Code: WarningPageSizeClamped, Value: req.PageSize, Reason: "clamped to maximum page size"})
 		req.PageSize = DefaultMaxPageSize
 	}
 
@@ -48,8 +74,54 @@ func NewPaginationHandle(req *PaginationRequest) *PaginationHandle {
 		Info: &PaginationInfo{
 			Page:     req.Page,
 			PageSize: req.PageSize,
+			Offset:   (req.Page - 1) * req.PageSize,
+			Limit:    req.PageSize,
 		},
+		countMode: req.CountMode,
+		dropped:   dropped,
+	}
+}
+
+// newRawPaginationHandle uses the requested offset and limit directly. Page
+// is derived best-effort from them.
+func newRawPaginationHandle(req *PaginationRequest) *PaginationHandle {
+
+	p := &PaginationHandle{
+		Info:      &PaginationInfo{},
+		raw:       true,
+		countMode: req.CountMode,
+	}
+
+	// Page and offset describe the same thing in two incompatible ways
+	if req.Page > 0 {
+		p.err = ErrPageWithOffset
+	}
+
+	offset := 0
+	if req.Offset != nil && *req.Offset > 0 {
+		offset = *req.Offset
+	}
+
+	limit := req.PageSize
+	if req.Limit != nil {
+		limit = *req.Limit
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	if limit > DefaultMaxPageSize {
+		p.dropped = append(p.dropped, DroppedItem{Kind: DroppedPageSize, Code: WarningPageSizeClamped, Value: limit, Reason: "clamped to maximum page size"})
+		limit = DefaultMaxPageSize
 	}
+
+	p.Info.Offset = offset
+	p.Info.Limit = limit
+	p.Info.PageSize = limit
+	p.Info.Page = offset/limit + 1
+
+	return p
 }
 
 func (p *PaginationHandle) Page() int {
@@ -61,7 +133,11 @@ func (p *PaginationHandle) PageSize() int {
 }
 
 func (p *PaginationHandle) Offset() int {
-	return (p.Page() - 1) * p.PageSize()
+	return p.Info.Offset
+}
+
+func (p *PaginationHandle) Limit() int {
+	return p.Info.Limit
 }
 
 func (p *PaginationHandle) TotalPages() int {
@@ -78,23 +154,29 @@ func (p *PaginationHandle) Apply(query *gorm.DB) (*gorm.DB, error) {
 		return nil, nil
 	}
 
+	if p.err != nil {
+		return nil, p.err
+	}
+
 	// Count total records for current query
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
+	mode := p.Info.CountMode
+	if mode == "" {
+		mode = CountModeExact
+	}
+
+	if err := p.count(query, mode); err != nil {
 		return query, err
 	}
 
-	p.Info.Total = total
-	if total == 0 {
-		p.Info.TotalPages = 1
-	} else {
-		p.Info.TotalPages = int((total + int64(p.Info.PageSize) - 1) / int64(p.Info.PageSize))
+	// Some databases only paginate ordered results
+	if lookupDialect(dialectName(query)).OrderedPagination {
+		query = ensureOrder(query)
 	}
 
 	// Apply offset and limit
 	query = query.
 		Offset(p.Offset()).
-		Limit(p.Info.PageSize)
+		Limit(p.Limit())
 
 	return query, nil
 }
@@ -102,3 +184,24 @@ func (p *PaginationHandle) Apply(query *gorm.DB) (*gorm.DB, error) {
 func (p *PaginationHandle) CurrentInfo() *PaginationInfo {
 	return p.Info
 }
+
+// droppedItems returns the page size and count mode adjustments.
+func (p *PaginationHandle) droppedItems() []DroppedItem {
+	return append(p.dropped[:len(p.dropped):len(p.dropped)], p.modeDropped...)
+}
+
+// ensureOrder orders by the primary key when the query has no ORDER BY, or by
+// a constant when the key is unknown.
+func ensureOrder(query *gorm.DB) *gorm.DB {
+
+	if _, ok := query.Statement.Clauses["ORDER BY"]; ok {
+		return query
+	}
+
+	pk, err := primaryKeyColumn(query, "")
+	if err != nil {
+		return query.Order(clause.OrderBy{Expression: clause.Expr{SQL: "(SELECT NULL)"}})
+	}
+
+	return query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: pk}})
+}
@@ -0,0 +1,122 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+func TestRawOffsetAndLimit(t *testing.T) {
+
+	db := customersDB(t)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy:    []string{"id"},
+		AllowedCountModes: []string{queryhelper.CountModeExact, queryhelper.CountModeNone},
+	}
+
+	tests := []struct {
+		name  string
+		opts  []queryhelper.Option
+		ids   []uint
+		info  queryhelper.PaginationInfo
+		codes []string
+	}{
+		{
+			name: "offset off the page grid",
+			opts: []queryhelper.Option{queryhelper.WithOffset(5), queryhelper.WithLimit(4)},
+			ids:  []uint{6, 7, 8, 9},
+			info: queryhelper.PaginationInfo{Page: 2, PageSize: 4, Offset: 5, Limit: 4, Total: 12, TotalPages: 3, CountMode: queryhelper.CountModeExact},
+		},
+		{
+			name: "limit only",
+			opts: []queryhelper.Option{queryhelper.WithLimit(3)},
+			ids:  []uint{1, 2, 3},
+			info: queryhelper.PaginationInfo{Page: 1, PageSize: 3, Offset: 0, Limit: 3, Total: 12, TotalPages: 4, CountMode: queryhelper.CountModeExact},
+		},
+		{
+			name: "offset only",
+			opts: []queryhelper.Option{queryhelper.WithOffset(10)},
+			ids:  []uint{11, 12},
+			info: queryhelper.PaginationInfo{Page: 2, PageSize: 10, Offset: 10, Limit: 10, Total: 12, TotalPages: 2, CountMode: queryhelper.CountModeExact},
+		},
+		{
+			name: "offset past the end",
+			opts: []queryhelper.Option{queryhelper.WithOffset(20), queryhelper.WithLimit(5)},
+			info: queryhelper.PaginationInfo{Page: 5, PageSize: 5, Offset: 20, Limit: 5, Total: 12, TotalPages: 3, CountMode: queryhelper.CountModeExact},
+		},
+		{
+			name:  "limit capped",
+			opts:  []queryhelper.Option{queryhelper.WithOffset(11), queryhelper.WithLimit(500)},
+			ids:   []uint{12},
+			info:  queryhelper.PaginationInfo{Page: 1, PageSize: 100, Offset: 11, Limit: 100, Total: 12, TotalPages: 1, CountMode: queryhelper.CountModeExact},
+			codes: []string{queryhelper.WarningPageSizeClamped},
+		},
+		{
+			name: "not counted",
+			opts: []queryhelper.Option{queryhelper.WithOffset(1), queryhelper.WithLimit(2), queryhelper.WithCountMode(queryhelper.CountModeNone)},
+			ids:  []uint{2, 3},
+			info: queryhelper.PaginationInfo{Page: 1, PageSize: 2, Offset: 1, Limit: 2, CountMode: queryhelper.CountModeNone},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(append(tt.opts, queryhelper.WithOrderBy([]string{"id"}))...)
+
+			var customers []testCustomer
+			if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []uint
+			for _, c := range customers {
+				ids = append(ids, c.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("found %v, want %v", ids, tt.ids)
+			}
+
+			if info := dq.Info().Pagination; *info != tt.info {
+				t.Errorf("pagination %+v, want %+v", *info, tt.info)
+			}
+
+			var codes []string
+			for _, w := range dq.Warnings() {
+				codes = append(codes, w.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.codes) {
+				t.Errorf("warnings %v, want %v", codes, tt.codes)
+			}
+		})
+	}
+}
+
+func TestPageWithOffsetRejected(t *testing.T) {
+
+	db := customersDB(t)
+
+	for name, opts := range map[string][]queryhelper.Option{
+		"offset": {queryhelper.WithPage(2), queryhelper.WithOffset(5)},
+		"limit":  {queryhelper.WithPage(2), queryhelper.WithLimit(5)},
+	} {
+		t.Run(name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(opts...)
+			if _, err := dq.Apply(nil, db.Model(&testCustomer{})); !errors.Is(err, queryhelper.ErrPageWithOffset) {
+				t.Errorf("Apply: err = %v, want ErrPageWithOffset", err)
+			}
+
+			var customers []testCustomer
+			if err := dq.Execute(nil, db.Model(&testCustomer{}), &customers); !errors.Is(err, queryhelper.ErrPageWithOffset) {
+				t.Errorf("Execute: err = %v, want ErrPageWithOffset", err)
+			}
+			if len(customers) != 0 {
+				t.Errorf("found %d customers", len(customers))
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package queryhelper
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// PlanCache remembers the outcome of UpdateConditions for recently seen
+// conditions, so repeated requests such as dashboards and saved searches skip
+// validation and normalization. Entries are keyed by the raw conditions and
+// the settings they were validated against, and the least recently used
+// entry is evicted once the cache is full. Custom value validators are
+// assumed to be deterministic.
+//
+// A PlanCache is safe for concurrent use and may be shared between settings.
+type PlanCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[planKey]*list.Element
+}
+
+type planKey struct {
+	settings *QuerySettings
+	sum      [sha256.Size]byte
+}
+
+type plan struct {
+	key        planKey
+	conditions QueryConditions
+	dropped    []DroppedItem
+	names      map[string]string
+	locale     string
+	err        error
+}
+
+func NewPlanCache(size int) *PlanCache {
+
+	if size <= 0 {
+		size = 1
+	}
+
+	return &PlanCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[planKey]*list.Element),
+	}
+}
+
+// Len returns the number of cached plans.
+func (c *PlanCache) Len() int {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Purge drops every cached plan.
+func (c *PlanCache) Purge() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[planKey]*list.Element)
+}
+
+func (c *PlanCache) get(key planKey) (*plan, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return elem.Value.(*plan), true
+}
+
+func (c *PlanCache) add(key planKey, p *plan) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p.key = key
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = p
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(p)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*plan).key)
+	}
+}
+
+// newPlanKey fingerprints the raw conditions. Conditions holding values that
+// cannot be encoded are not cached.
+func newPlanKey(settings *QuerySettings, conditions *QueryConditions) (planKey, bool) {
+
+	data, err := json.Marshal(conditions)
+	if err != nil {
+		return planKey{}, false
+	}
+
+	return planKey{
+		settings: settings,
+		sum:      sha256.Sum256(data),
+	}, true
+}
+
+func newPlan(conditions *QueryConditions, dropped []DroppedItem, names map[string]string, locale string, err error) *plan {
+	return &plan{
+		conditions: cloneConditions(*conditions),
+		dropped:    dropped,
+		names:      names,
+		locale:     locale,
+		err:        err,
+	}
+}
+
+// usePlan restores a cached outcome into conditions as if UpdateConditions
+// had run on them.
+func (ch *ConditionsHandle) usePlan(p *plan, conditions *QueryConditions) error {
+
+	*conditions = cloneConditions(p.conditions)
+	ch.Dropped = p.dropped
+	ch.names = p.names
+	ch.locale = p.locale
+
+	if p.err != nil {
+		return p.err
+	}
+
+	ch.Conditions = conditions
+
+	return nil
+}
+
+// cloneConditions copies the slices callers may modify. Filter values are
+// shared.
+func cloneConditions(c QueryConditions) QueryConditions {
+
+	c.SearchFields = slices.Clone(c.SearchFields)
+	c.OrderBy = slices.Clone(c.OrderBy)
+	c.Fields = slices.Clone(c.Fields)
+	c.Filters = slices.Clone(c.Filters)
+	c.FilterGroups = slices.Clone(c.FilterGroups)
+	c.Includes = slices.Clone(c.Includes)
+	c.IncludeFilters = maps.Clone(c.IncludeFilters)
+	c.RequestedSummaries = slices.Clone(c.RequestedSummaries)
+
+	return c
+}
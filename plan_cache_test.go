@@ -0,0 +1,95 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+func planCacheSettings(cache *queryhelper.PlanCache) *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedSearch:    []string{"name", "email"},
+		AllowedOrderBy:   []string{"name", "age"},
+		AllowedFilters:   map[string][]string{"name": {"=", "LIKE"}, "age": {">=", "IN"}},
+		LocalizedColumns: map[string]map[string]string{"name": {"en": "name_en", "de": "name_de"}},
+		PlanCache:        cache,
+	}
+}
+
+func planCacheConditions() queryhelper.QueryConditions {
+	return queryhelper.QueryConditions{
+		SearchText: "tisch",
+		OrderBy:    []string{"-name", "age", "password"},
+		Filters: []queryhelper.FilterCondition{
+			{Field: "name", Operator: "=", Value: "Stuhl"},
+			{Field: "age", Operator: "IN", Value: []interface{}{18, 21}},
+			{Field: "secret", Operator: "=", Value: 1},
+		},
+		Locale: "de-CH",
+	}
+}
+
+func TestPlanCacheHitMatchesMiss(t *testing.T) {
+
+	settings := planCacheSettings(queryhelper.NewPlanCache(8))
+
+	render := func() (*queryhelper.ConditionsHandle, string) {
+
+		ch := queryhelper.NewConditionsHandle(settings)
+		conditions := planCacheConditions()
+		if err := ch.UpdateConditions(&conditions); err != nil {
+			t.Fatal(err)
+		}
+
+		query, err := ch.Apply(queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ch, findSQL(t, query)
+	}
+
+	missHandle, miss := render()
+	if settings.PlanCache.Len() != 1 {
+		t.Fatalf("%d cached plans, want 1", settings.PlanCache.Len())
+	}
+	hitHandle, hit := render()
+
+	// A hit resolving the localized column for another locale would differ
+	if hit != miss {
+		t.Errorf("cache hit renders\n%s\nmiss renders\n%s", hit, miss)
+	}
+	if !reflect.DeepEqual(hitHandle.Dropped, missHandle.Dropped) {
+		t.Errorf("dropped %+v on a hit, %+v on a miss", hitHandle.Dropped, missHandle.Dropped)
+	}
+	if !reflect.DeepEqual(hitHandle.CurrentInfo(), missHandle.CurrentInfo()) {
+		t.Errorf("info %+v on a hit, %+v on a miss", hitHandle.CurrentInfo(), missHandle.CurrentInfo())
+	}
+}
+
+func BenchmarkPlanCache(b *testing.B) {
+
+	for _, bench := range []struct {
+		name  string
+		cache *queryhelper.PlanCache
+	}{
+		{name: "uncached"},
+		{name: "cached", cache: queryhelper.NewPlanCache(64)},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+
+			settings := planCacheSettings(bench.cache)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				conditions := planCacheConditions()
+				if err := queryhelper.NewConditionsHandle(settings).UpdateConditions(&conditions); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
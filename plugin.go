@@ -0,0 +1,92 @@
+package queryhelper
+
+import (
+	"gorm.io/gorm"
+)
+
+// Statement setting keys of Plugin
+const (
+	RequestKey  = "queryhelper:request"  // *QueryHelper to apply to the statement
+	SettingsKey = "queryhelper:settings" // *QuerySettings of the statement, overrides Plugin.Settings
+	InfoKey     = "queryhelper:info"     // *PaginationInfo of the applied request
+)
+
+// Plugin is a gorm plugin applying the QueryHelper set under RequestKey to
+// queries, so requests run inside the caller's sessions and transactions:
+//
+//	db.Use(queryhelper.Plugin{Settings: map[string]*queryhelper.QuerySettings{"users": userSettings}})
+//
+//	result := db.Set(queryhelper.RequestKey, qh).Model(&User{}).Find(&users)
+//	info, _ := result.Get(queryhelper.InfoKey)
+//
+// The conditions, ordering and pagination are added to the statement before
+// it is built, exactly as Apply adds them, and the count runs first in the
+// same session. Settings come from SettingsKey on the statement, then from
+// Settings by table, then from the QueryHelper's provider. Count statements
+// are left alone.
+type Plugin struct {
+	Settings map[string]*QuerySettings // table -> settings
+}
+
+func (p Plugin) Name() string {
+	return "queryhelper"
+}
+
+func (p Plugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Query().Before("gorm:query").Register("queryhelper:apply", p.apply)
+}
+
+func (p Plugin) apply(tx *gorm.DB) {
+
+	if tx.Error != nil {
+		return
+	}
+
+	value, ok := tx.Get(RequestKey)
+	if !ok {
+		return
+	}
+
+	dq, ok := value.(*QueryHelper)
+	if !ok || dq == nil {
+		return
+	}
+
+	if _, ok := tx.Statement.Dest.(*int64); ok {
+		return
+	}
+
+	// Apply to a copy without the request, so the statements Apply runs
+	// itself, such as the count, do not apply it again
+	query := tx.Session(&gorm.Session{}).Scopes()
+	query.Statement.Settings.Delete(RequestKey)
+
+	q, err := dq.Apply(p.settings(tx), query)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+
+	stmt := tx.Statement
+	stmt.Table = q.Statement.Table
+	stmt.TableExpr = q.Statement.TableExpr
+	stmt.Clauses = q.Statement.Clauses
+	stmt.Selects = q.Statement.Selects
+	stmt.Omits = q.Statement.Omits
+	stmt.Joins = q.Statement.Joins
+	stmt.Preloads = q.Statement.Preloads
+	stmt.Distinct = q.Statement.Distinct
+	stmt.Settings.Store(InfoKey, dq.pagination.CurrentInfo())
+}
+
+func (p Plugin) settings(tx *gorm.DB) *QuerySettings {
+
+	if value, ok := tx.Get(SettingsKey); ok {
+		if settings, ok := value.(*QuerySettings); ok {
+			return settings
+		}
+	}
+
+	// Statements with a model are parsed before callbacks run
+	return p.Settings[tx.Statement.Table]
+}
@@ -0,0 +1,176 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"gorm.io/gorm"
+)
+
+func pluginSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"company_id": {"=", "IN"}},
+		AllowedSearch:  []string{"name"},
+		AllowedOrderBy: []string{"id", "name"},
+		AllowedFields:  []string{"id", "name", "company_id"},
+	}
+}
+
+func pluginRequest() *queryhelper.QueryHelper {
+	return queryhelper.NewQueryHelper(
+		queryhelper.WithFilter("company_id", "in", []int{1, 2}),
+		queryhelper.WithSearchText("customer"),
+		queryhelper.WithOrderBy([]string{"-name"}),
+		queryhelper.WithFields([]string{"id", "name"}),
+		queryhelper.WithPage(2),
+		queryhelper.WithPageSize(3),
+	)
+}
+
+func pluginInfo(t *testing.T, result *gorm.DB) queryhelper.PaginationInfo {
+
+	t.Helper()
+
+	value, ok := result.Get(queryhelper.InfoKey)
+	if !ok {
+		t.Fatal("no info stored")
+	}
+
+	return *value.(*queryhelper.PaginationInfo)
+}
+
+// TestPluginMatchesApply checks a request set on the statement runs the same
+// SQL and gives the same info as Apply.
+func TestPluginMatchesApply(t *testing.T) {
+
+	tests := []struct {
+		name string
+		use  queryhelper.Plugin
+		set  func(db *gorm.DB) *gorm.DB
+	}{
+		{
+			name: "settings by table",
+			use:  queryhelper.Plugin{Settings: map[string]*queryhelper.QuerySettings{"customers": pluginSettings()}},
+			set:  func(db *gorm.DB) *gorm.DB { return db },
+		},
+		{
+			name: "settings on the statement",
+			use:  queryhelper.Plugin{Settings: map[string]*queryhelper.QuerySettings{"customers": {}}},
+			set:  func(db *gorm.DB) *gorm.DB { return db.Set(queryhelper.SettingsKey, pluginSettings()) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := customersDB(t)
+			statements := recordQueries(t, db)
+
+			dq := pluginRequest()
+			query, err := dq.Apply(pluginSettings(), db.Model(&testCustomer{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []testCustomer
+			if err := query.Find(&want).Error; err != nil {
+				t.Fatal(err)
+			}
+			wantSQL := append([]string(nil), *statements...)
+			*statements = nil
+			if len(wantSQL) != 2 {
+				t.Fatalf("Apply ran %q, want the count and the find", wantSQL)
+			}
+
+			if err := db.Use(tt.use); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []testCustomer
+			result := tt.set(db).Set(queryhelper.RequestKey, pluginRequest()).Model(&testCustomer{}).Find(&got)
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+
+			if !reflect.DeepEqual(*statements, wantSQL) {
+				t.Errorf("plugin ran\n%q\nwant\n%q", *statements, wantSQL)
+			}
+			if !reflect.DeepEqual(got, want) || len(got) != 3 {
+				t.Errorf("plugin found %+v, want %+v", got, want)
+			}
+			if info := pluginInfo(t, result); info != *dq.Info().Pagination || info.Total != 8 {
+				t.Errorf("plugin info %+v, want %+v of the 8 customers of companies 1 and 2", info, *dq.Info().Pagination)
+			}
+		})
+	}
+}
+
+func TestPluginInTransaction(t *testing.T) {
+
+	db := customersDB(t)
+	if err := db.Use(queryhelper.Plugin{Settings: map[string]*queryhelper.QuerySettings{"customers": pluginSettings()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	errRollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&testCustomer{ID: 13, Name: "customer 00", CompanyID: 1}).Error; err != nil {
+			return err
+		}
+
+		// The count and the page see the uncommitted row
+		var customers []testCustomer
+		result := tx.Set(queryhelper.RequestKey, queryhelper.NewQueryHelper(queryhelper.WithOrderBy([]string{"name"}))).Model(&testCustomer{}).Find(&customers)
+		if result.Error != nil {
+			return result.Error
+		}
+		if info := pluginInfo(t, result); info.Total != 13 || customers[0].ID != 13 {
+			t.Errorf("total %d, first %d, want 13 and 13", info.Total, customers[0].ID)
+		}
+
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+
+	var count int64
+	if err := db.Model(&testCustomer{}).Count(&count).Error; err != nil || count != 12 {
+		t.Errorf("count %d, %v after rollback, want 12", count, err)
+	}
+}
+
+func TestPluginLeavesOtherQueries(t *testing.T) {
+
+	db := customersDB(t)
+	if err := db.Use(queryhelper.Plugin{Settings: map[string]*queryhelper.QuerySettings{"customers": pluginSettings()}}); err != nil {
+		t.Fatal(err)
+	}
+	statements := recordQueries(t, db)
+
+	var customers []testCustomer
+	result := db.Model(&testCustomer{}).Find(&customers)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	if _, ok := result.Get(queryhelper.InfoKey); ok || len(customers) != 12 || len(*statements) != 1 {
+		t.Errorf("found %d rows in %d statements, want 12 in 1 without info", len(customers), len(*statements))
+	}
+}
+
+func TestPluginRejectedRequest(t *testing.T) {
+
+	db := customersDB(t)
+	if err := db.Use(queryhelper.Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var customers []testCustomer
+	dq := queryhelper.NewQueryHelper(queryhelper.WithPage(2), queryhelper.WithOffset(5))
+	err := db.Set(queryhelper.RequestKey, dq).Model(&testCustomer{}).Find(&customers).Error
+	if !errors.Is(err, queryhelper.ErrPageWithOffset) {
+		t.Errorf("err = %v, want ErrPageWithOffset", err)
+	}
+}
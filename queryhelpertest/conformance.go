@@ -0,0 +1,157 @@
+package queryhelpertest
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+// Payloads the conformance suite sends in names, operators and values.
+var hostilePayloads = []string{
+	"id = 1 OR 1=1 --",
+	"name'; DROP TABLE users; --",
+	"(SELECT password FROM users)",
+	"\" OR \"\"=\"",
+}
+
+// RunConformance checks settings against hostile requests on model, each in
+// a subtest: injection attempts in field names, operators, orderings,
+// search fields, selected fields and values, oversized pages and negative
+// offsets. A request passes when it fails to apply, or when the SQL it
+// renders for DefaultDialect contains none of its payloads and its page
+// stays within the limits.
+func RunConformance(t *testing.T, settings *queryhelper.QuerySettings, model interface{}) {
+
+	t.Helper()
+
+	for _, payload := range hostilePayloads {
+		payload := payload
+
+		t.Run("filter field "+payload, func(t *testing.T) {
+			assertNotRendered(t, settings, model, payload, queryhelper.WithFilters([]queryhelper.FilterCondition{
+				{Field: payload, Operator: "=", Value: 1},
+			}))
+		})
+
+		t.Run("order by "+payload, func(t *testing.T) {
+			assertNotRendered(t, settings, model, payload, queryhelper.WithOrderBy([]string{payload, "-" + payload}))
+		})
+
+		t.Run("search field "+payload, func(t *testing.T) {
+			assertNotRendered(t, settings, model, payload, queryhelper.WithSearchText("x"), queryhelper.WithSearchFields([]string{payload}))
+		})
+
+		t.Run("search text "+payload, func(t *testing.T) {
+			assertNotRendered(t, settings, model, payload, queryhelper.WithSearchText(payload))
+		})
+
+		t.Run("selected field "+payload, func(t *testing.T) {
+			assertNotRendered(t, settings, model, payload, queryhelper.WithFields([]string{payload}))
+		})
+
+		// Operators and values need a field the settings allow
+		for _, field := range filterFields(settings) {
+			field := field
+
+			t.Run("operator on "+field+" "+payload, func(t *testing.T) {
+				assertNotRendered(t, settings, model, payload, queryhelper.WithFilters([]queryhelper.FilterCondition{
+					{Field: field, Operator: payload, Value: 1},
+				}))
+			})
+
+			t.Run("value of "+field+" "+payload, func(t *testing.T) {
+				for _, op := range settings.AllowedFilters[field] {
+					value := interface{}(payload)
+					switch strings.ToUpper(op) {
+					case "BETWEEN":
+						value = []interface{}{payload, payload}
+					case "IN", "NOT IN", "REL_ANY", "REL_ALL":
+						value = []interface{}{payload}
+					}
+
+					assertNotRendered(t, settings, model, payload, queryhelper.WithFilters([]queryhelper.FilterCondition{
+						{Field: field, Operator: op, Value: value},
+					}))
+				}
+			})
+		}
+	}
+
+	t.Run("unknown operator", func(t *testing.T) {
+		for _, field := range filterFields(settings) {
+			assertNotRendered(t, settings, model, "REGEXP", queryhelper.WithFilters([]queryhelper.FilterCondition{
+				{Field: field, Operator: "REGEXP", Value: ".*"},
+			}))
+		}
+	})
+
+	t.Run("oversized page size", func(t *testing.T) {
+		assertPageLimits(t, settings, model, queryhelper.WithPage(1), queryhelper.WithPageSize(1<<30))
+	})
+
+	t.Run("oversized limit", func(t *testing.T) {
+		assertPageLimits(t, settings, model, queryhelper.WithLimit(1<<30))
+	})
+
+	t.Run("negative page", func(t *testing.T) {
+		assertPageLimits(t, settings, model, queryhelper.WithPage(-5), queryhelper.WithPageSize(-5))
+	})
+
+	t.Run("negative offset", func(t *testing.T) {
+		assertPageLimits(t, settings, model, queryhelper.WithOffset(-10))
+	})
+}
+
+// filterFields returns the filterable fields in order.
+func filterFields(settings *queryhelper.QuerySettings) []string {
+
+	if settings == nil {
+		return nil
+	}
+
+	fields := make([]string, 0, len(settings.AllowedFilters))
+	for field := range settings.AllowedFilters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+// assertNotRendered fails when the request applies and its SQL, values
+// bound as ?, contains payload.
+func assertNotRendered(t *testing.T, settings *queryhelper.QuerySettings, model interface{}, payload string, opts ...queryhelper.Option) {
+
+	t.Helper()
+
+	query, _, err := RenderSQL(t, DefaultDialect, queryhelper.NewQueryHelper(opts...), settings, model)
+	if err != nil {
+		return
+	}
+
+	if strings.Contains(query, payload) {
+		t.Errorf("queryhelpertest: %q reached the SQL:\n%s", payload, query)
+	}
+}
+
+// assertPageLimits fails when the request applies with a page outside
+// DefaultMaxPageSize or before the first row.
+func assertPageLimits(t *testing.T, settings *queryhelper.QuerySettings, model interface{}, opts ...queryhelper.Option) {
+
+	t.Helper()
+
+	helper := queryhelper.NewQueryHelper(opts...)
+	if _, _, err := RenderSQL(t, DefaultDialect, helper, settings, model); err != nil {
+		return
+	}
+
+	info := helper.Info().Pagination
+	if info.Limit < 1 || info.Limit > queryhelper.DefaultMaxPageSize {
+		t.Errorf("queryhelpertest: limit %d is outside 1 to %d", info.Limit, queryhelper.DefaultMaxPageSize)
+	}
+	if info.Offset < 0 {
+		t.Errorf("queryhelpertest: offset %d is negative", info.Offset)
+	}
+}
@@ -0,0 +1,230 @@
+// Package queryhelpertest checks what QuerySettings allow and reject, for the
+// tests of packages using queryhelper. SQL is rendered by a DryRun dialector
+// of its own, so no database is needed; NewTestDB opens an in-memory SQLite
+// database for behavioral tests.
+package queryhelpertest
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultDialect is the dialect AssertSQL renders for.
+const DefaultDialect = "sqlite"
+
+// Dialector renders SQL without a database. Dialect picks the queryhelper
+// dialect, such as "postgres" or "mysql", and the quoting: backticks for
+// mysql, double quotes otherwise. Placeholders are written as ?.
+type Dialector struct {
+	Dialect string
+}
+
+func (d Dialector) Name() string {
+	return d.Dialect
+}
+
+func (d Dialector) Initialize(db *gorm.DB) error {
+
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	db.ConnPool = &sql.DB{}
+
+	return nil
+}
+
+func (d Dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d}}
+}
+
+func (d Dialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (d Dialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (d Dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	writer.WriteByte('?')
+}
+
+func (d Dialector) QuoteTo(writer clause.Writer, str string) {
+
+	quote := byte('"')
+	if d.Dialect == "mysql" {
+		quote = '`'
+	}
+
+	for i, part := range strings.Split(str, ".") {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		writer.WriteByte(quote)
+		writer.WriteString(part)
+		writer.WriteByte(quote)
+	}
+}
+
+func (d Dialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+// DryRunDB returns a database rendering SQL for dialect without running it.
+// Writes skip their transaction, which would need a connection.
+func DryRunDB(t testing.TB, dialect string) *gorm.DB {
+
+	t.Helper()
+
+	db, err := gorm.Open(Dialector{Dialect: dialect}, &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("queryhelpertest: open dry run database: %v", err)
+	}
+
+	return db
+}
+
+// NewTestDB opens an in-memory SQLite database, migrates models on it and
+// closes it when the test ends. Each call gets a database of its own.
+func NewTestDB(t testing.TB, models ...interface{}) *gorm.DB {
+
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("queryhelpertest: open database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("queryhelpertest: open database: %v", err)
+	}
+
+	// Every connection to :memory: opens another empty database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			t.Fatalf("queryhelpertest: migrate: %v", err)
+		}
+	}
+
+	return db
+}
+
+// Seed creates fixtures, each a pointer to a record or to a slice of them,
+// in order.
+func Seed(t testing.TB, db *gorm.DB, fixtures ...interface{}) {
+
+	t.Helper()
+
+	for i, fixture := range fixtures {
+		if err := db.Create(fixture).Error; err != nil {
+			t.Fatalf("queryhelpertest: seed fixture %d: %v", i, err)
+		}
+	}
+}
+
+// RenderSQL applies the helper to model with settings and returns the page
+// query for dialect, its values bound as ?, with the values.
+func RenderSQL(t testing.TB, dialect string, helper *queryhelper.QueryHelper, settings *queryhelper.QuerySettings, model interface{}) (string, []interface{}, error) {
+
+	t.Helper()
+
+	q, err := helper.Apply(settings, DryRunDB(t, dialect).Model(model))
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The dry run count leaves its statement behind
+	q.Statement.SQL.Reset()
+	q.Statement.Vars = nil
+
+	var rows []map[string]interface{}
+	stmt := q.Find(&rows).Statement
+	if stmt.Error != nil {
+		return "", nil, stmt.Error
+	}
+
+	return stmt.SQL.String(), stmt.Vars, nil
+}
+
+// AssertSQL renders the helper's page query on model for DefaultDialect,
+// with the values written in, and fails the test unless it contains every
+// wanted substring. It returns the SQL.
+func AssertSQL(t testing.TB, helper *queryhelper.QueryHelper, settings *queryhelper.QuerySettings, model interface{}, wantSubstrings ...string) string {
+
+	t.Helper()
+
+	query, vars, err := RenderSQL(t, DefaultDialect, helper, settings, model)
+	if err != nil {
+		t.Errorf("queryhelpertest: apply: %v", err)
+		return ""
+	}
+
+	explained := Dialector{Dialect: DefaultDialect}.Explain(query, vars...)
+	for _, want := range wantSubstrings {
+		if !strings.Contains(explained, want) {
+			t.Errorf("queryhelpertest: SQL does not contain %q:\n%s", want, explained)
+		}
+	}
+
+	return explained
+}
+
+// AssertRejected fails the test unless validating conditions with settings
+// rejects field: reports it in a validation error, drops it with a warning,
+// or fails because no searchable or sortable field is left.
+func AssertRejected(t testing.TB, settings *queryhelper.QuerySettings, conditions *queryhelper.QueryConditions, field string) {
+
+	t.Helper()
+
+	ch := queryhelper.NewConditionsHandle(settings)
+	c := *conditions
+	err := ch.UpdateConditions(&c)
+
+	if !rejected(err, ch.Dropped, field) {
+		t.Errorf("queryhelpertest: %s was not rejected", field)
+	}
+}
+
+func rejected(err error, dropped []queryhelper.DroppedItem, field string) bool {
+
+	var verr *queryhelper.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			if fe.Field == field {
+				return true
+			}
+		}
+	}
+
+	if errors.Is(err, queryhelper.ErrSearchNotAvailable) || errors.Is(err, queryhelper.ErrOrderByNotAvailable) {
+		return true
+	}
+
+	for _, item := range dropped {
+		if item.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
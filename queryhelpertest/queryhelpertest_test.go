@@ -0,0 +1,239 @@
+package queryhelpertest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+type user struct {
+	ID           uint
+	Name         string
+	Status       string
+	PasswordHash string
+}
+
+var strictSettings = &queryhelper.QuerySettings{
+	AllowedSearch:  []string{"name"},
+	AllowedOrderBy: []string{"name", "id"},
+	AllowedFilters: map[string][]string{
+		"status": {"=", "IN"},
+		"id":     {"=", ">=", "BETWEEN"},
+	},
+}
+
+// permissiveSettings allow whatever names a client sends, the mistake the
+// checks are there to catch.
+func permissiveSettings() *queryhelper.QuerySettings {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"password_hash": {"="}},
+	}
+	for _, payload := range hostilePayloads {
+		settings.AllowedOrderBy = append(settings.AllowedOrderBy, payload)
+		settings.AllowedFilters[payload] = []string{"="}
+	}
+
+	return settings
+}
+
+// recorder is a testing.TB recording failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	messages []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// record runs check with a recorder, in a goroutine of its own so Fatalf
+// can stop it, and returns the failures.
+func record(t *testing.T, check func(tb testing.TB)) []string {
+
+	r := &recorder{TB: t}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		check(r)
+	}()
+	wg.Wait()
+
+	return r.messages
+}
+
+func TestAssertRejected(t *testing.T) {
+
+	conditions := &queryhelper.QueryConditions{
+		Filters: []queryhelper.FilterCondition{{Field: "password_hash", Operator: "=", Value: "x"}},
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		failures := record(t, func(tb testing.TB) {
+			AssertRejected(tb, strictSettings, conditions, "password_hash")
+		})
+		if len(failures) > 0 {
+			t.Errorf("failed: %v", failures)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		failures := record(t, func(tb testing.TB) {
+			AssertRejected(tb, permissiveSettings(), conditions, "password_hash")
+		})
+		if len(failures) != 1 || !strings.Contains(failures[0], "password_hash was not rejected") {
+			t.Errorf("failures %v, want password_hash reported", failures)
+		}
+	})
+
+	t.Run("conditions untouched", func(t *testing.T) {
+		record(t, func(tb testing.TB) {
+			AssertRejected(tb, strictSettings, conditions, "password_hash")
+		})
+		if len(conditions.Filters) != 1 || conditions.Filters[0].Field != "password_hash" {
+			t.Errorf("conditions changed to %+v", conditions)
+		}
+	})
+}
+
+func TestAssertSQL(t *testing.T) {
+
+	helper := func() *queryhelper.QueryHelper {
+		return queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "active"))
+	}
+
+	t.Run("contained", func(t *testing.T) {
+		var sql string
+		failures := record(t, func(tb testing.TB) {
+			sql = AssertSQL(tb, helper(), strictSettings, &user{}, `"status" = 'active'`, "LIMIT 10")
+		})
+		if len(failures) > 0 {
+			t.Errorf("failed: %v", failures)
+		}
+		if !strings.HasPrefix(sql, `SELECT * FROM "users"`) {
+			t.Errorf("returned %s", sql)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		failures := record(t, func(tb testing.TB) {
+			AssertSQL(tb, helper(), strictSettings, &user{}, `"status" = 'banned'`)
+		})
+		if len(failures) != 1 || !strings.Contains(failures[0], `does not contain "\"status\" = 'banned'"`) {
+			t.Errorf("failures %v, want the missing substring reported", failures)
+		}
+	})
+}
+
+func TestRenderSQL(t *testing.T) {
+
+	helper := queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "active"))
+
+	sql, vars, err := RenderSQL(t, "mysql", helper, strictSettings, &user{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "SELECT * FROM `users` WHERE `status` = ? ORDER BY `name`,`id` LIMIT ?"; sql != want {
+		t.Errorf("got %s, want %s", sql, want)
+	}
+	if len(vars) != 2 || vars[0] != "active" {
+		t.Errorf("vars %v", vars)
+	}
+}
+
+func TestRunConformance(t *testing.T) {
+	RunConformance(t, strictSettings, &user{})
+}
+
+// TestRunConformancePermissive runs the suite against permissive settings
+// in a child process, which has to fail.
+func TestRunConformancePermissive(t *testing.T) {
+
+	if os.Getenv("QUERYHELPERTEST_PERMISSIVE") == "1" {
+		RunConformance(t, permissiveSettings(), &user{})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunConformancePermissive$", "-test.v")
+	cmd.Env = append(os.Environ(), "QUERYHELPERTEST_PERMISSIVE=1")
+	out, err := cmd.CombinedOutput()
+
+	if err == nil {
+		t.Fatalf("conformance passed permissive settings:\n%s", out)
+	}
+	if !strings.Contains(string(out), "reached the SQL") {
+		t.Errorf("no payload reported:\n%s", out)
+	}
+}
+
+func TestNewTestDB(t *testing.T) {
+
+	db := NewTestDB(t, &user{})
+	Seed(t, db, &[]user{{Name: "ann", Status: "active"}, {Name: "bob", Status: "banned"}})
+
+	helper := queryhelper.NewQueryHelper(queryhelper.WithEqual("status", "active"))
+	query, err := helper.Apply(strictSettings, db.Model(&user{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []user
+	if err := query.Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "ann" {
+		t.Errorf("found %+v, want ann", users)
+	}
+	if total := helper.Info().Pagination.Total; total != 1 {
+		t.Errorf("total %d, want 1", total)
+	}
+
+	// Each database is a fresh one
+	var count int64
+	if err := NewTestDB(t, &user{}).Model(&user{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("new database has %d users, err %v", count, err)
+	}
+}
+
+func TestSeed(t *testing.T) {
+
+	db := NewTestDB(t, &user{})
+
+	failures := record(t, func(tb testing.TB) {
+		Seed(tb, db, &[]user{{Name: "ann"}, {Name: "bob"}}, &user{Name: "cy"})
+	})
+	if len(failures) > 0 {
+		t.Errorf("failed: %v", failures)
+	}
+
+	var names []string
+	if err := db.Model(&user{}).Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "ann,bob,cy" {
+		t.Errorf("seeded %v, want ann, bob, cy in order", names)
+	}
+
+	failures = record(t, func(tb testing.TB) {
+		Seed(tb, db, &user{Name: "dee"}, 42)
+	})
+	if len(failures) != 1 || !strings.Contains(failures[0], "seed fixture 1") {
+		t.Errorf("failures %v, want fixture 1 reported", failures)
+	}
+}
@@ -0,0 +1,109 @@
+package queryhelper
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm/clause"
+)
+
+// RelationFilter is a many-to-many relation requests filter on as a virtual
+// field with REL_ANY and REL_ALL, e.g. the tags of posts through post_tags.
+// The field must also be listed in AllowedFilters.
+type RelationFilter struct {
+	Table       string `json:"table"`        // join table, e.g. post_tags
+	ForeignKey  string `json:"foreign_key"`  // column of Table referencing the parent row, e.g. post_id
+	References  string `json:"references"`   // column of the parent table, defaults to id
+	ValueColumn string `json:"value_column"` // column of Table the values are matched on, e.g. tag_id
+	ValueType   string `json:"value_type"`   // field type of ValueColumn, e.g. int, when FieldTypes does not declare the field
+}
+
+func isRelationOperator(op string) bool {
+	return op == "REL_ANY" || op == "REL_ALL"
+}
+
+// checkRelationFilter accepts the relation operators only on RelationFilters
+// fields, and those fields only with them, on a non-empty list.
+func checkRelationFilter(settings *QuerySettings, filter FilterCondition) error {
+
+	_, declared := settings.RelationFilters[filter.Field]
+	if !declared {
+		if isRelationOperator(filter.Operator) {
+			return errors.New("field is not a relation filter")
+		}
+		return nil
+	}
+
+	if !isRelationOperator(filter.Operator) {
+		return errors.New("relation filters take REL_ANY or REL_ALL")
+	}
+
+	if values, ok := toInterfaceSlice(filter.Value); !ok || len(values) == 0 {
+		return fmt.Errorf("operator %s requires a non-empty list value", filter.Operator)
+	}
+
+	return nil
+}
+
+// distinctValues converts the values to the value column's type and drops
+// repeated ones, which REL_ALL would otherwise count as more than one. Values
+// of no declared type are compared as text, so "3" and 3 are one value.
+func distinctValues(value interface{}, valueType string) (interface{}, error) {
+
+	values, _ := toInterfaceSlice(value)
+
+	seen := make(map[string]struct{}, len(values))
+	distinct := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if v == nil {
+			return nil, fmt.Errorf("unexpected value %v", v)
+		}
+
+		if valueType != "" {
+			converted, err := coerceScalar(valueType, v)
+			if err != nil {
+				return nil, err
+			}
+			v = converted
+		}
+
+		key := fmt.Sprint(v)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		distinct = append(distinct, v)
+	}
+
+	return distinct, nil
+}
+
+// buildRelationFilter renders REL_ANY as an EXISTS against the join table
+// and REL_ALL as a correlated count of the distinct values matched, which
+// must equal the number requested.
+func buildRelationFilter(filter FilterCondition, rf *RelationFilter) (string, []interface{}, bool) {
+
+	values, ok := toInterfaceSlice(filter.Value)
+	if !ok || len(values) == 0 {
+		return "", nil, false
+	}
+
+	references := rf.References
+	if references == "" {
+		references = "id"
+	}
+
+	table := clause.Table{Name: rf.Table}
+	foreignKey := clause.Column{Table: rf.Table, Name: rf.ForeignKey}
+	parent := clause.Column{Table: clause.CurrentTable, Name: references}
+	value := clause.Column{Table: rf.Table, Name: rf.ValueColumn}
+
+	switch filter.Operator {
+	case "REL_ANY":
+		return "EXISTS (SELECT 1 FROM ? WHERE ? = ? AND ? IN ?)", []interface{}{table, foreignKey, parent, value, values}, true
+	case "REL_ALL":
+		return "(SELECT COUNT(DISTINCT ?) FROM ? WHERE ? = ? AND ? IN ?) = ?", []interface{}{value, table, foreignKey, parent, value, values, len(values)}, true
+	}
+
+	return "", nil, false
+}
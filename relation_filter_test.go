@@ -0,0 +1,94 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testTaggedPost struct {
+	ID    uint
+	Title string
+}
+
+func (testTaggedPost) TableName() string {
+	return "posts"
+}
+
+type testPostTag struct {
+	PostID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey"`
+}
+
+func (testPostTag) TableName() string {
+	return "post_tags"
+}
+
+func TestRelationFilters(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testTaggedPost{}, &testPostTag{})
+	queryhelpertest.Seed(t, db,
+		&[]testTaggedPost{{ID: 1, Title: "one"}, {ID: 2, Title: "two"}, {ID: 3, Title: "three"}, {ID: 4, Title: "untagged"}},
+		&[]testPostTag{{1, 1}, {1, 2}, {1, 3}, {2, 1}, {2, 2}, {3, 3}},
+	)
+
+	tests := []struct {
+		name   string
+		filter queryhelper.FilterCondition
+		want   []uint
+	}{
+		{name: "any of one", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ANY", Value: []interface{}{1}}, want: []uint{1, 2}},
+		{name: "any with unknown", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ANY", Value: []interface{}{3, 9}}, want: []uint{1, 3}},
+		{name: "any of none used", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ANY", Value: []interface{}{9}}},
+		{name: "all of two", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ALL", Value: []interface{}{1, 2}}, want: []uint{1, 2}},
+		{name: "all of three", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ALL", Value: []interface{}{1, 2, 3}}, want: []uint{1}},
+		{name: "all with unknown", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ALL", Value: []interface{}{1, 9}}},
+		{name: "all with duplicates", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ALL", Value: []interface{}{1, 2, 1, 2}}, want: []uint{1, 2}},
+		{name: "all with mixed duplicates", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ALL", Value: []interface{}{"1", 1, float64(2), "2"}}, want: []uint{1, 2}},
+		{name: "any with mixed duplicates", filter: queryhelper.FilterCondition{Field: "tags", Operator: "REL_ANY", Value: []interface{}{"3", float64(3)}}, want: []uint{1, 3}},
+	}
+
+	// Values typed by FieldTypes, by the relation filter, or not at all
+	configs := map[string]func(*queryhelper.QuerySettings){
+		"field type": func(s *queryhelper.QuerySettings) { s.FieldTypes = map[string]string{"tags": queryhelper.FieldTypeInt} },
+		"value type": func(s *queryhelper.QuerySettings) { s.RelationFilters["tags"].ValueType = queryhelper.FieldTypeInt },
+		"untyped":    func(s *queryhelper.QuerySettings) {},
+	}
+
+	for config, configure := range configs {
+		settings := &queryhelper.QuerySettings{
+			AllowedFilters: map[string][]string{"tags": {"REL_ANY", "REL_ALL"}},
+			AllowedOrderBy: []string{"id"},
+			RelationFilters: map[string]*queryhelper.RelationFilter{
+				"tags": {Table: "post_tags", ForeignKey: "post_id", ValueColumn: "tag_id"},
+			},
+		}
+		configure(settings)
+
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/%s", config, tt.name), func(t *testing.T) {
+
+				dq := queryhelper.NewQueryHelper(
+					queryhelper.WithFilters([]queryhelper.FilterCondition{tt.filter}),
+					queryhelper.WithOrderBy([]string{"id"}),
+				)
+
+				var posts []testTaggedPost
+				if err := dq.Execute(settings, db.Model(&testTaggedPost{}), &posts); err != nil {
+					t.Fatal(err)
+				}
+
+				var got []uint
+				for _, p := range posts {
+					got = append(got, p.ID)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("found posts %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
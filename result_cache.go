@@ -0,0 +1,279 @@
+package queryhelper
+
+import (
+	"container/list"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResultCache stores pages found by Execute, so identical requests are
+// served without touching the database. Keys start with the table name and
+// a colon. Implementations must be safe for concurrent use.
+type ResultCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, payload []byte, ttl time.Duration)
+}
+
+// ResultCacheInvalidator is implemented by result caches that can drop the
+// entries of a table.
+type ResultCacheInvalidator interface {
+	Invalidate(table string)
+}
+
+// resultPayload is a cached page.
+type resultPayload struct {
+	Items      json.RawMessage        `json:"items"`
+	Pagination PaginationInfo         `json:"pagination"`
+	Summaries  map[string]interface{} `json:"summaries,omitempty"`
+	Histogram  []HistogramBucket      `json:"histogram,omitempty"`
+}
+
+// resultCacheKey fingerprints the page Execute would find into dest: the
+// normalized conditions, the statement they build including the caller's own
+// scopes, the page and the type of dest. It returns false when the result
+// must not be cached.
+func (dq *QueryHelper) resultCacheKey(settings *QuerySettings, query *gorm.DB, dest interface{}) (*ConditionsHandle, string, bool) {
+
+	// Locked rows must be read, samples differ each time, and a transaction
+	// may see its own writes
+	if dq.locking != nil || dq.sampling != nil || dq.pagination.err != nil || dq.err != nil {
+		return nil, "", false
+	}
+
+	if _, inTx := query.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return nil, "", false
+	}
+
+	destType := reflect.TypeOf(dest)
+	if destType == nil || destType.Kind() != reflect.Ptr {
+		return nil, "", false
+	}
+
+	dqh := NewConditionsHandle(settings)
+	conditions := *dq.queryConditions
+	if err := dqh.UpdateConditions(&conditions); err != nil {
+		return nil, "", false
+	}
+
+	info := dq.pagination.Info
+	countMode := dq.pagination.resolveCountMode(settings)
+
+	if dq.wrapSubquery {
+		query = wrapSubquery(query)
+	}
+
+	var applyErr error
+	sql := query.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		q, err := dqh.Apply(tx)
+		if err != nil {
+			applyErr = err
+			return tx
+		}
+		return withoutPreloads(q).Offset(info.Offset).Limit(info.Limit).Find(reflect.New(destType.Elem()).Interface())
+	})
+	if applyErr != nil {
+		return nil, "", false
+	}
+
+	key := fingerprint(dialectName(query), destType.String(), sql, dqh.Fingerprint(), strconv.Itoa(info.Offset), strconv.Itoa(info.Limit), countMode)
+
+	return dqh, tableName(query) + ":" + key, true
+}
+
+// cachedResult fills dest and the pagination info from the cache, as if the
+// page had been found.
+func (dq *QueryHelper) cachedResult(cache ResultCache, key string, dqh *ConditionsHandle, dest interface{}) bool {
+
+	data, ok := cache.Get(key)
+	if !ok {
+		return false
+	}
+
+	var payload resultPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false
+	}
+
+	if err := json.Unmarshal(payload.Items, dest); err != nil {
+		return false
+	}
+
+	dq.retries = 0
+	dq.pagination.retries = 0
+	dq.conditions = dqh
+	dq.summaries = payload.Summaries
+	dq.histogram = payload.Histogram
+	*dq.pagination.Info = payload.Pagination
+
+	return true
+}
+
+func (dq *QueryHelper) cacheResult(cache ResultCache, key string, ttl time.Duration, dest interface{}) {
+
+	items, err := json.Marshal(dest)
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(resultPayload{
+		Items:      items,
+		Pagination: *dq.pagination.Info,
+		Summaries:  dq.summaries,
+		Histogram:  dq.histogram,
+	})
+	if err != nil {
+		return
+	}
+
+	cache.Set(key, data, ttl)
+}
+
+// tableName returns the query's table, from its model when not set.
+func tableName(query *gorm.DB) string {
+
+	stmt := query.Statement
+	if stmt.Table == "" && stmt.Model != nil {
+		_ = stmt.Parse(stmt.Model)
+	}
+
+	return stmt.Table
+}
+
+// InvalidateOnWrite registers callbacks on db dropping a table's cached
+// results whenever rows of it are created, updated or deleted through db.
+// Writes in a transaction invalidate before they commit, so a page read in
+// between may be cached until its TTL expires.
+func InvalidateOnWrite(db *gorm.DB, cache ResultCacheInvalidator) error {
+
+	invalidate := func(tx *gorm.DB) {
+		if tx.Statement.Table != "" {
+			cache.Invalidate(tx.Statement.Table)
+		}
+	}
+
+	if err := db.Callback().Create().After("gorm:create").Register("queryhelper:invalidate_results", invalidate); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().After("gorm:update").Register("queryhelper:invalidate_results", invalidate); err != nil {
+		return err
+	}
+
+	return db.Callback().Delete().After("gorm:delete").Register("queryhelper:invalidate_results", invalidate)
+}
+
+// MemoryResultCache is an in-memory ResultCache holding up to a fixed number
+// of entries, evicting the least recently used one when full. A TTL of zero
+// or less never expires.
+type MemoryResultCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type resultEntry struct {
+	key     string
+	payload []byte
+	expires time.Time
+}
+
+func NewMemoryResultCache(size int) *MemoryResultCache {
+
+	if size <= 0 {
+		size = 1
+	}
+
+	return &MemoryResultCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryResultCache) Get(key string) ([]byte, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*resultEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return entry.payload, true
+}
+
+func (c *MemoryResultCache) Set(key string, payload []byte, ttl time.Duration) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &resultEntry{key: key, payload: payload}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*resultEntry).key)
+	}
+}
+
+// Invalidate drops every cached page of table.
+func (c *MemoryResultCache) Invalidate(table string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := table + ":"
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(elem)
+			delete(c.items, key)
+		}
+	}
+}
+
+// Len returns the number of cached pages, expired ones included.
+func (c *MemoryResultCache) Len() int {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Purge drops every cached page.
+func (c *MemoryResultCache) Purge() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
@@ -0,0 +1,218 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"gorm.io/gorm"
+)
+
+func cacheSettings(cache queryhelper.ResultCache, ttl time.Duration) *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"company_id": {"="}},
+		AllowedOrderBy: []string{"id"},
+		ResultCache:    cache,
+		ResultCacheTTL: ttl,
+	}
+}
+
+// executedQueries records the SQL of every query db sends to the database,
+// leaving out dry runs such as the cache key's.
+func executedQueries(t *testing.T, db *gorm.DB) *[]string {
+
+	t.Helper()
+
+	var statements []string
+	err := db.Callback().Query().After("gorm:query").Register("test:executed", func(tx *gorm.DB) {
+		if !tx.DryRun {
+			statements = append(statements, tx.Statement.SQL.String())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &statements
+}
+
+// executeCached finds a page of customers and returns it with the number of
+// statements run.
+func executeCached(t *testing.T, db *gorm.DB, statements *[]string, settings *queryhelper.QuerySettings, opts ...queryhelper.Option) ([]testCustomer, *queryhelper.PaginationInfo, int) {
+
+	t.Helper()
+
+	before := len(*statements)
+
+	dq := queryhelper.NewQueryHelper(opts...)
+	var customers []testCustomer
+	if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+		t.Fatal(err)
+	}
+
+	return customers, dq.Info().Pagination, len(*statements) - before
+}
+
+func TestResultCache(t *testing.T) {
+
+	db := customersDB(t)
+	statements := executedQueries(t, db)
+
+	cache := queryhelper.NewMemoryResultCache(10)
+	settings := cacheSettings(cache, 0)
+
+	first, firstInfo, ran := executeCached(t, db, statements, settings, queryhelper.WithPage(2), queryhelper.WithPageSize(5))
+	if ran != 2 || len(first) != 5 {
+		t.Fatalf("first execute ran %d statements for %d rows, want 2 for 5", ran, len(first))
+	}
+
+	second, secondInfo, ran := executeCached(t, db, statements, settings, queryhelper.WithPage(2), queryhelper.WithPageSize(5))
+	if ran != 0 {
+		t.Errorf("identical execute ran %d statements, want none", ran)
+	}
+	if !reflect.DeepEqual(second, first) {
+		t.Errorf("cached rows %+v, want %+v", second, first)
+	}
+	if *secondInfo != *firstInfo || secondInfo.Total != 12 {
+		t.Errorf("cached pagination %+v, want %+v", *secondInfo, *firstInfo)
+	}
+
+	// Another page and other conditions are other keys
+	if _, _, ran := executeCached(t, db, statements, settings, queryhelper.WithPage(3), queryhelper.WithPageSize(5)); ran != 2 {
+		t.Errorf("another page ran %d statements, want 2", ran)
+	}
+	if _, _, ran := executeCached(t, db, statements, settings, queryhelper.WithPage(2), queryhelper.WithPageSize(5), queryhelper.WithEqual("company_id", 1)); ran != 2 {
+		t.Errorf("another filter ran %d statements, want 2", ran)
+	}
+	if cache.Len() != 3 {
+		t.Errorf("cached %d pages, want 3", cache.Len())
+	}
+}
+
+func TestResultCacheInvalidateOnWrite(t *testing.T) {
+
+	db := customersDB(t)
+	statements := executedQueries(t, db)
+
+	cache := queryhelper.NewMemoryResultCache(10)
+	if err := queryhelper.InvalidateOnWrite(db, cache); err != nil {
+		t.Fatal(err)
+	}
+	settings := cacheSettings(cache, 0)
+
+	executeCached(t, db, statements, settings)
+
+	// A write to another table keeps the page
+	if err := db.Create(&testCompany{ID: 4, Name: "umbrella"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ran := executeCached(t, db, statements, settings); ran != 0 {
+		t.Errorf("ran %d statements after writing companies, want none", ran)
+	}
+
+	if err := db.Create(&testCustomer{ID: 13, Name: "customer 00", CompanyID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, info, ran := executeCached(t, db, statements, settings)
+	if ran != 2 || info.Total != 13 {
+		t.Errorf("ran %d statements for %d rows after writing customers, want 2 for 13", ran, info.Total)
+	}
+}
+
+func TestResultCacheBypassed(t *testing.T) {
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, db *gorm.DB, settings *queryhelper.QuerySettings)
+	}{
+		{
+			name: "sampling",
+			run: func(t *testing.T, db *gorm.DB, settings *queryhelper.QuerySettings) {
+				var customers []testCustomer
+				dq := queryhelper.NewQueryHelper(queryhelper.WithSampling(50, queryhelper.SamplingBernoulli))
+				if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "transaction",
+			run: func(t *testing.T, db *gorm.DB, settings *queryhelper.QuerySettings) {
+				err := db.Transaction(func(tx *gorm.DB) error {
+					var customers []testCustomer
+					return queryhelper.NewQueryHelper().Execute(settings, tx.Model(&testCustomer{}), &customers)
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := customersDB(t)
+			statements := executedQueries(t, db)
+
+			cache := queryhelper.NewMemoryResultCache(10)
+			settings := cacheSettings(cache, 0)
+
+			tt.run(t, db, settings)
+			tt.run(t, db, settings)
+
+			if len(*statements) != 4 || cache.Len() != 0 {
+				t.Errorf("ran %d statements and cached %d pages, want 4 and none", len(*statements), cache.Len())
+			}
+		})
+	}
+}
+
+func TestResultCacheExpiry(t *testing.T) {
+
+	db := customersDB(t)
+	statements := executedQueries(t, db)
+
+	settings := cacheSettings(queryhelper.NewMemoryResultCache(10), 20*time.Millisecond)
+
+	executeCached(t, db, statements, settings)
+	if _, _, ran := executeCached(t, db, statements, settings); ran != 0 {
+		t.Errorf("fresh page ran %d statements, want none", ran)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, _, ran := executeCached(t, db, statements, settings); ran != 2 {
+		t.Errorf("expired page ran %d statements, want 2", ran)
+	}
+}
+
+func TestMemoryResultCache(t *testing.T) {
+
+	cache := queryhelper.NewMemoryResultCache(2)
+	cache.Set("users:a", []byte("a"), 0)
+	cache.Set("users:b", []byte("b"), 0)
+	cache.Set("orders:c", []byte("c"), 0)
+
+	// The least recently used entry is evicted
+	if _, ok := cache.Get("users:a"); ok {
+		t.Error("users:a was not evicted")
+	}
+	if got, ok := cache.Get("users:b"); !ok || string(got) != "b" {
+		t.Errorf("users:b = %q, %v", got, ok)
+	}
+
+	cache.Invalidate("users")
+	if _, ok := cache.Get("users:b"); ok {
+		t.Error("users:b survived invalidating users")
+	}
+	if _, ok := cache.Get("orders:c"); !ok {
+		t.Error("orders:c was invalidated with users")
+	}
+
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d after Purge", cache.Len())
+	}
+}
@@ -0,0 +1,148 @@
+package queryhelper
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RetryPolicy retries statements that failed with a transient error, such as
+// a serialization failure or a reset connection. Statements inside a
+// caller's transaction are never retried, since the failure aborts or
+// poisons the transaction.
+type RetryPolicy struct {
+	MaxAttempts int                           // attempts including the first, 1 or less disables retries
+	Backoff     func(retry int) time.Duration // delay before the nth retry, nil doubles from 50ms
+	Retryable   func(err error) bool          // nil uses IsTransient
+}
+
+// transientSQLStates are SQLSTATE codes worth retrying: serialization
+// failure, deadlock, connection failures and administrator shutdown.
+var transientSQLStates = map[string]bool{
+	"40001": true,
+	"40P01": true,
+	"08000": true,
+	"08003": true,
+	"08006": true,
+	"57P01": true,
+}
+
+// IsTransient reports whether err is likely to succeed when retried. It
+// recognizes bad and reset connections and drivers exposing SQLState(), as
+// the pgx and pq drivers do. Timeouts and cancellations are not transient.
+func IsTransient(err error) bool {
+
+	if err == nil || errors.Is(err, ErrQueryTimeout) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var coded interface{ SQLState() string }
+	if errors.As(err, &coded) {
+		return transientSQLStates[coded.SQLState()]
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) retryable() func(err error) bool {
+
+	if p.Retryable == nil {
+		return IsTransient
+	}
+
+	return p.Retryable
+}
+
+// forDest returns the policy refusing to retry once a failed Find has
+// written rows into dest, so rows of two attempts are never mixed.
+func (p *RetryPolicy) forDest(dest interface{}) *RetryPolicy {
+
+	if p == nil {
+		return nil
+	}
+
+	retryable := p.retryable()
+
+	policy := *p
+	policy.Retryable = func(err error) bool {
+		return isEmptyResult(dest) && retryable(err)
+	}
+
+	return &policy
+}
+
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+
+	if p.Backoff != nil {
+		return p.Backoff(retry)
+	}
+
+	return 50 * time.Millisecond << (retry - 1)
+}
+
+// run calls fc until it succeeds or the policy gives up, adding the number of
+// retries to retries. fc is given a fresh session of query for each attempt.
+func (p *RetryPolicy) run(query *gorm.DB, retries *int, fc func(query *gorm.DB) error) error {
+
+	err := fc(query)
+	if p == nil || err == nil {
+		return err
+	}
+
+	if _, inTx := query.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return err
+	}
+
+	retryable := p.retryable()
+
+	ctx := query.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for attempt := 2; attempt <= p.MaxAttempts && retryable(err); attempt++ {
+		timer := time.NewTimer(p.backoff(attempt - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		*retries++
+
+		// A session would carry the failed attempt's error over
+		retry := query.Session(&gorm.Session{})
+		retry.Error = nil
+
+		if err = fc(retry); err == nil {
+			// Statements chained on query would fail with the recovered error
+			query.Error = nil
+			return nil
+		}
+	}
+
+	return err
+}
+
+// isEmptyResult reports whether a failed Find left dest untouched.
+func isEmptyResult(dest interface{}) bool {
+
+	v := reflect.Indirect(reflect.ValueOf(dest))
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return v.IsValid() && v.IsZero()
+}
@@ -0,0 +1,239 @@
+package queryhelper_test
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"gorm.io/gorm"
+)
+
+// flakyStatements fails the first count and find attempts on a database
+// with err, counting every attempt.
+type flakyStatements struct {
+	countFailures, findFailures int
+	err                         error
+	counts, finds               int
+	partial                     *[]testCustomer // receives a row before a failing find
+}
+
+func (f *flakyStatements) register(t *testing.T, db *gorm.DB) {
+
+	t.Helper()
+
+	err := db.Callback().Query().Before("gorm:query").Register("test:flaky", func(tx *gorm.DB) {
+
+		if _, ok := tx.Statement.Dest.(*int64); ok {
+			f.counts++
+			if f.counts <= f.countFailures {
+				tx.AddError(f.err)
+			}
+			return
+		}
+
+		f.finds++
+		if f.finds <= f.findFailures {
+			if f.partial != nil {
+				*f.partial = append(*f.partial, testCustomer{ID: 99})
+			}
+			tx.AddError(f.err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func retrySettings(policy *queryhelper.RetryPolicy) *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedOrderBy: []string{"id"},
+		RetryPolicy:    policy,
+	}
+}
+
+func noBackoff(int) time.Duration {
+	return 0
+}
+
+func TestRetryPolicy(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		policy      *queryhelper.RetryPolicy
+		flaky       flakyStatements
+		wantErr     error
+		wantCounts  int
+		wantFinds   int
+		wantRetries int
+	}{
+		{
+			name:        "count recovers",
+			policy:      &queryhelper.RetryPolicy{MaxAttempts: 3, Backoff: noBackoff},
+			flaky:       flakyStatements{countFailures: 1, err: driver.ErrBadConn},
+			wantCounts:  2,
+			wantFinds:   1,
+			wantRetries: 1,
+		},
+		{
+			name:        "find recovers",
+			policy:      &queryhelper.RetryPolicy{MaxAttempts: 3, Backoff: noBackoff},
+			flaky:       flakyStatements{findFailures: 2, err: fmt.Errorf("read: %w", syscall.ECONNRESET)},
+			wantCounts:  1,
+			wantFinds:   3,
+			wantRetries: 2,
+		},
+		{
+			name:        "gives up",
+			policy:      &queryhelper.RetryPolicy{MaxAttempts: 3, Backoff: noBackoff},
+			flaky:       flakyStatements{findFailures: 5, err: driver.ErrBadConn},
+			wantErr:     driver.ErrBadConn,
+			wantCounts:  1,
+			wantFinds:   3,
+			wantRetries: 2,
+		},
+		{
+			name:       "not transient",
+			policy:     &queryhelper.RetryPolicy{MaxAttempts: 3, Backoff: noBackoff},
+			flaky:      flakyStatements{countFailures: 1, err: errors.New("syntax error")},
+			wantErr:    errors.New("syntax error"),
+			wantCounts: 1,
+		},
+		{
+			name:       "no policy",
+			flaky:      flakyStatements{countFailures: 1, err: driver.ErrBadConn},
+			wantErr:    driver.ErrBadConn,
+			wantCounts: 1,
+		},
+		{
+			name: "custom predicate",
+			policy: &queryhelper.RetryPolicy{MaxAttempts: 2, Backoff: noBackoff, Retryable: func(err error) bool {
+				return err.Error() == "busy"
+			}},
+			flaky:       flakyStatements{countFailures: 1, err: errors.New("busy")},
+			wantCounts:  2,
+			wantFinds:   1,
+			wantRetries: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := customersDB(t)
+			flaky := tt.flaky
+			flaky.register(t, db)
+
+			dq := queryhelper.NewQueryHelper()
+			var customers []testCustomer
+			err := dq.Execute(retrySettings(tt.policy), db.Model(&testCustomer{}), &customers)
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatal(err)
+			case tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error() && !errors.Is(err, tt.wantErr)):
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && len(customers) != 10:
+				t.Errorf("got %d rows, want 10", len(customers))
+			}
+
+			if flaky.counts != tt.wantCounts || flaky.finds != tt.wantFinds {
+				t.Errorf("ran %d counts and %d finds, want %d and %d", flaky.counts, flaky.finds, tt.wantCounts, tt.wantFinds)
+			}
+			if dq.Retries() != tt.wantRetries {
+				t.Errorf("Retries() = %d, want %d", dq.Retries(), tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAfterPartialRows(t *testing.T) {
+
+	db := customersDB(t)
+
+	var customers []testCustomer
+	flaky := flakyStatements{findFailures: 1, err: driver.ErrBadConn, partial: &customers}
+	flaky.register(t, db)
+
+	err := queryhelper.NewQueryHelper().Execute(retrySettings(&queryhelper.RetryPolicy{MaxAttempts: 3, Backoff: noBackoff}), db.Model(&testCustomer{}), &customers)
+	if !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("err = %v, want the find's error", err)
+	}
+	if flaky.finds != 1 {
+		t.Errorf("find ran %d times after writing rows, want 1", flaky.finds)
+	}
+}
+
+func TestRetryPolicyInTransaction(t *testing.T) {
+
+	db := customersDB(t)
+	flaky := flakyStatements{countFailures: 1, err: driver.ErrBadConn}
+	flaky.register(t, db)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var customers []testCustomer
+		return queryhelper.NewQueryHelper().Execute(retrySettings(&queryhelper.RetryPolicy{MaxAttempts: 3, Backoff: noBackoff}), tx.Model(&testCustomer{}), &customers)
+	})
+	if !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("err = %v, want the count's error", err)
+	}
+	if flaky.counts != 1 {
+		t.Errorf("count ran %d times in a transaction, want 1", flaky.counts)
+	}
+}
+
+func TestRetryPolicyAudit(t *testing.T) {
+
+	db := customersDB(t)
+	flaky := flakyStatements{countFailures: 1, findFailures: 1, err: driver.ErrBadConn}
+	flaky.register(t, db)
+
+	sink := &auditRecorder{}
+	var customers []testCustomer
+	err := queryhelper.NewQueryHelper(queryhelper.WithAuditSink(sink)).Execute(retrySettings(&queryhelper.RetryPolicy{MaxAttempts: 2, Backoff: noBackoff}), db.Model(&testCustomer{}), &customers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.entries) != 1 || sink.entries[0].Retries != 2 {
+		t.Errorf("audit %+v, want one entry with 2 retries", sink.entries)
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "pq: " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestIsTransient(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: driver.ErrBadConn, want: true},
+		{err: fmt.Errorf("read tcp: %w", syscall.ECONNRESET), want: true},
+		{err: io.ErrUnexpectedEOF, want: true},
+		{err: sqlStateError("40001"), want: true},
+		{err: fmt.Errorf("find: %w", sqlStateError("40P01")), want: true},
+		{err: sqlStateError("23505"), want: false},
+		{err: &queryhelper.QueryTimeoutError{Timeout: time.Second, Err: driver.ErrBadConn}, want: false},
+		{err: errors.New("syntax error"), want: false},
+		{err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := queryhelper.IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+// Package rsql parses RSQL/FIQL filter expressions such as
+// `status==active;(age>=18,country==SE)` into query conditions.
+package rsql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weedbox/queryhelper"
+)
+
+var comparators = map[string]string{
+	"==":    "=",
+	"!=":    "!=",
+	"=gt=":  ">",
+	">":     ">",
+	"=ge=":  ">=",
+	">=":    ">=",
+	"=lt=":  "<",
+	"<":     "<",
+	"=le=":  "<=",
+	"<=":    "<=",
+	"=in=":  "IN",
+	"=out=": "NOT IN",
+}
+
+var comparatorNames = []string{"==", "!=", "=gt=", "=ge=", "=lt=", "=le=", "=in=", "=out="}
+
+// ParseError reports where an expression could not be parsed.
+type ParseError struct {
+	Offset   int
+	Expected []string
+	Found    string
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+
+	if e.Message != "" {
+		return fmt.Sprintf("rsql: %s at offset %d", e.Message, e.Offset)
+	}
+
+	found := e.Found
+	if found == "" {
+		found = "end of input"
+	} else {
+		found = fmt.Sprintf("%q", found)
+	}
+
+	return fmt.Sprintf("rsql: unexpected %s at offset %d, expected %s", found, e.Offset, strings.Join(e.Expected, " or "))
+}
+
+type node struct {
+	logic      string
+	children   []*node
+	comparison *queryhelper.FilterCondition
+}
+
+type parser struct {
+	input    string
+	pos      int
+	settings *queryhelper.QuerySettings
+}
+
+// ParseRSQL parses an RSQL expression into query conditions. Top-level AND
+// comparisons become Filters and every OR becomes a filter group. Fields and
+// operators are checked against the settings while parsing.
+func ParseRSQL(expr string, settings *queryhelper.QuerySettings) (*queryhelper.QueryConditions, error) {
+
+	if settings == nil {
+		settings = queryhelper.DefaultQuerySettings
+	}
+
+	conditions := &queryhelper.QueryConditions{}
+
+	if strings.TrimSpace(expr) == "" {
+		return conditions, nil
+	}
+
+	p := &parser{
+		input:    expr,
+		settings: settings,
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf(";", ",")
+	}
+
+	switch {
+	case root.comparison != nil:
+		conditions.Filters = []queryhelper.FilterCondition{*root.comparison}
+	case root.logic == queryhelper.LogicAnd:
+		for _, child := range root.children {
+			if child.comparison != nil {
+				conditions.Filters = append(conditions.Filters, *child.comparison)
+				continue
+			}
+			conditions.FilterGroups = append(conditions.FilterGroups, toGroup(child))
+		}
+	default:
+		conditions.FilterGroups = []queryhelper.FilterGroup{toGroup(root)}
+	}
+
+	return conditions, nil
+}
+
+func toGroup(n *node) queryhelper.FilterGroup {
+
+	group := queryhelper.FilterGroup{
+		Logic: n.logic,
+	}
+
+	for _, child := range n.children {
+		if child.comparison != nil {
+			group.Filters = append(group.Filters, *child.comparison)
+			continue
+		}
+		group.Groups = append(group.Groups, toGroup(child))
+	}
+
+	return group
+}
+
+func (p *parser) parseOr() (*node, error) {
+	return p.parseList(queryhelper.LogicOr, ',', p.parseAnd)
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	return p.parseList(queryhelper.LogicAnd, ';', p.parseConstraint)
+}
+
+func (p *parser) parseList(logic string, sep byte, next func() (*node, error)) (*node, error) {
+
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &node{logic: logic}
+	n.add(first)
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) || p.input[p.pos] != sep {
+			break
+		}
+		p.pos++
+
+		child, err := next()
+		if err != nil {
+			return nil, err
+		}
+		n.add(child)
+	}
+
+	if len(n.children) == 1 {
+		return n.children[0], nil
+	}
+
+	return n, nil
+}
+
+// add appends a child, flattening nested lists with the same logic.
+func (n *node) add(child *node) {
+
+	if child.comparison == nil && child.logic == n.logic {
+		n.children = append(n.children, child.children...)
+		return
+	}
+
+	n.children = append(n.children, child)
+}
+
+func (p *parser) parseConstraint() (*node, error) {
+
+	p.skipSpace()
+
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, p.errorf(")", ";", ",")
+		}
+		p.pos++
+
+		return n, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (*node, error) {
+
+	// Selector
+	start := p.pos
+	field := p.readUnreserved()
+	if field == "" {
+		return nil, p.errorf("selector", "(")
+	}
+
+	// Comparator
+	opStart := p.pos
+	operator, ok := p.readComparator()
+	if !ok {
+		p.pos = opStart
+		return nil, p.errorf(comparatorNames...)
+	}
+	token := p.input[opStart:p.pos]
+
+	// Validate against settings
+	allowedOps, fieldAllowed := p.settings.AllowedFilters[field]
+	if !fieldAllowed {
+		return nil, &ParseError{Offset: start, Message: fmt.Sprintf("field %q is not filterable", field)}
+	}
+
+	operatorAllowed := false
+	for _, op := range allowedOps {
+		if op == operator {
+			operatorAllowed = true
+			break
+		}
+	}
+	if !operatorAllowed {
+		return nil, &ParseError{Offset: opStart, Message: fmt.Sprintf("operator %s is not allowed on field %q", token, field)}
+	}
+
+	// Arguments
+	var value interface{}
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+
+		vals := make([]interface{}, 0)
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+
+			p.skipSpace()
+			if p.pos < len(p.input) && p.input[p.pos] == ',' {
+				p.pos++
+				continue
+			}
+			if p.pos < len(p.input) && p.input[p.pos] == ')' {
+				p.pos++
+				break
+			}
+
+			return nil, p.errorf(",", ")")
+		}
+
+		value = vals
+	} else {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		value = v
+		if operator == "IN" || operator == "NOT IN" {
+			value = []interface{}{v}
+		}
+	}
+
+	if list, ok := value.([]interface{}); ok && operator != "IN" && operator != "NOT IN" {
+		if len(list) != 1 {
+			return nil, &ParseError{Offset: opStart, Message: fmt.Sprintf("operator %s takes a single argument", token)}
+		}
+		value = list[0]
+	}
+
+	return &node{
+		comparison: &queryhelper.FilterCondition{
+			Field:    field,
+			Operator: operator,
+			Value:    value,
+		},
+	}, nil
+}
+
+func (p *parser) readComparator() (string, bool) {
+
+	rest := p.input[p.pos:]
+
+	// FIQL style =xx=
+	if strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "==") {
+		end := strings.IndexByte(rest[1:], '=')
+		if end < 0 {
+			return "", false
+		}
+
+		token := rest[:end+2]
+		op, ok := comparators[token]
+		if !ok {
+			return "", false
+		}
+
+		p.pos += len(token)
+		return op, true
+	}
+
+	for _, token := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if strings.HasPrefix(rest, token) {
+			p.pos += len(token)
+			return comparators[token], true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) parseValue() (string, error) {
+
+	p.skipSpace()
+
+	if p.pos >= len(p.input) {
+		return "", p.errorf("value")
+	}
+
+	quote := p.input[p.pos]
+	if quote != '\'' && quote != '"' {
+		v := p.readUnreserved()
+		if v == "" {
+			return "", p.errorf("value")
+		}
+		return v, nil
+	}
+
+	// Quoted string with backslash escapes
+	start := p.pos
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			sb.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", &ParseError{Offset: start, Message: "unterminated quoted string"}
+}
+
+func (p *parser) readUnreserved() string {
+
+	start := p.pos
+	for p.pos < len(p.input) && !isReserved(p.input[p.pos]) {
+		p.pos++
+	}
+
+	return p.input[start:p.pos]
+}
+
+func isReserved(c byte) bool {
+	switch c {
+	case '"', '\'', '(', ')', ';', ',', '=', '!', '~', '<', '>', ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) errorf(expected ...string) *ParseError {
+
+	found := ""
+	if p.pos < len(p.input) {
+		found = p.input[p.pos : p.pos+1]
+	}
+
+	return &ParseError{
+		Offset:   p.pos,
+		Expected: expected,
+		Found:    found,
+	}
+}
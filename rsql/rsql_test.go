@@ -0,0 +1,134 @@
+package rsql
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+var settings = &queryhelper.QuerySettings{
+	AllowedFilters: map[string][]string{
+		"status":  {"=", "!=", "IN", "NOT IN"},
+		"age":     {">", ">=", "<", "<="},
+		"country": {"=", "IN"},
+		"name":    {"="},
+	},
+}
+
+// golden maps expressions to the conditions they parse to, written as JSON.
+var golden = []struct {
+	expr string
+	want string
+}{
+	{
+		expr: ``,
+		want: `{}`,
+	},
+	{
+		expr: `status==active`,
+		want: `{"filters":[{"field":"status","operator":"=","value":"active"}]}`,
+	},
+	{
+		expr: `status==active;age=ge=18`,
+		want: `{"filters":[{"field":"status","operator":"=","value":"active"},{"field":"age","operator":">=","value":"18"}]}`,
+	},
+	{
+		expr: `status!=banned,age<18`,
+		want: `{"filter_groups":[{"logic":"OR","filters":[{"field":"status","operator":"!=","value":"banned"},{"field":"age","operator":"<","value":"18"}]}]}`,
+	},
+	{
+		expr: `status==active;(age>=18,country==SE)`,
+		want: `{"filters":[{"field":"status","operator":"=","value":"active"}],` +
+			`"filter_groups":[{"logic":"OR","filters":[{"field":"age","operator":">=","value":"18"},{"field":"country","operator":"=","value":"SE"}]}]}`,
+	},
+	{
+		// Nested parentheses, with an AND inside an OR inside an AND
+		expr: `status==active;((age=gt=18;country==SE),(age=lt=65;country=in=(NO,DK)))`,
+		want: `{"filters":[{"field":"status","operator":"=","value":"active"}],` +
+			`"filter_groups":[{"logic":"OR","groups":[` +
+			`{"logic":"AND","filters":[{"field":"age","operator":">","value":"18"},{"field":"country","operator":"=","value":"SE"}]},` +
+			`{"logic":"AND","filters":[{"field":"age","operator":"<","value":"65"},{"field":"country","operator":"IN","value":["NO","DK"]}]}]}]}`,
+	},
+	{
+		// Redundant parentheses and lists of the same logic are flattened
+		expr: `((status==a));(age>1;(age<9))`,
+		want: `{"filters":[{"field":"status","operator":"=","value":"a"},{"field":"age","operator":">","value":"1"},{"field":"age","operator":"<","value":"9"}]}`,
+	},
+	{
+		// Commas and semicolons in quoted strings are values
+		expr: `name=="Smith, John";status=out=('a,b',"c;d")`,
+		want: `{"filters":[{"field":"name","operator":"=","value":"Smith, John"},{"field":"status","operator":"NOT IN","value":["a,b","c;d"]}]}`,
+	},
+	{
+		expr: `name=='it\'s' , name=="say \"hi\""`,
+		want: `{"filter_groups":[{"logic":"OR","filters":[{"field":"name","operator":"=","value":"it's"},{"field":"name","operator":"=","value":"say \"hi\""}]}]}`,
+	},
+	{
+		// A single argument of =in= is still a list
+		expr: ` status=in=active `,
+		want: `{"filters":[{"field":"status","operator":"IN","value":["active"]}]}`,
+	},
+}
+
+func TestParseRSQLGolden(t *testing.T) {
+
+	for _, tt := range golden {
+		t.Run(tt.expr, func(t *testing.T) {
+
+			got, err := ParseRSQL(tt.expr, settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want queryhelper.QueryConditions
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(*got, want) {
+				gotJSON, _ := json.Marshal(got)
+				t.Errorf("got  %s\nwant %s", gotJSON, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRSQLErrors(t *testing.T) {
+
+	tests := []struct {
+		expr     string
+		offset   int
+		expected []string
+		message  string
+	}{
+		{expr: `status==active;`, offset: 15, expected: []string{"selector", "("}},
+		{expr: `status=~active`, offset: 6, expected: comparatorNames},
+		{expr: `(status==a`, offset: 10, expected: []string{")", ";", ","}},
+		{expr: `status==a)`, offset: 9, expected: []string{";", ","}},
+		{expr: `status==`, offset: 8, expected: []string{"value"}},
+		{expr: `status=in=(a,b`, offset: 14, expected: []string{",", ")"}},
+		{expr: `name=="Smith, John`, offset: 6, message: "unterminated quoted string"},
+		{expr: `status==a;password==x`, offset: 10, message: `field "password" is not filterable`},
+		{expr: `age==18`, offset: 3, message: `operator == is not allowed on field "age"`},
+		{expr: `status==(a,b)`, offset: 6, message: "operator == takes a single argument"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+
+			_, err := ParseRSQL(tt.expr, settings)
+
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("err = %v, want a ParseError", err)
+			}
+
+			if perr.Offset != tt.offset || !reflect.DeepEqual(perr.Expected, tt.expected) || perr.Message != tt.message {
+				t.Errorf("got offset %d expecting %q (%s), want offset %d expecting %q (%s)", perr.Offset, perr.Expected, perr.Message, tt.offset, tt.expected, tt.message)
+			}
+		})
+	}
+}
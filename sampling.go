@@ -0,0 +1,81 @@
+package queryhelper
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sampling methods, how TABLESAMPLE picks rows on PostgreSQL
+const (
+	SamplingSystem    = "SYSTEM"    // whole blocks of the table, fastest but clustered
+	SamplingBernoulli = "BERNOULLI" // each row independently, reads the whole table
+)
+
+// Sampling previews a random share of the table's rows instead of all of
+// them, for a fast approximate look at huge tables.
+type Sampling struct {
+	Percent float64 `json:"percent"` // share of rows sampled, above 0 and up to 100
+	Method  string  `json:"method"`  // SYSTEM (default) or BERNOULLI
+}
+
+// WithSampling runs the request on a sample of percent of the table's rows.
+// Counts are scaled to the whole table and reported as estimated. A percent
+// outside (0, 100] or an unknown method is not applied; Err, Apply and
+// Execute return the mistake instead.
+func WithSampling(percent float64, method string) Option {
+	return func(dq *QueryHelper) {
+
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" {
+			method = SamplingSystem
+		}
+
+		var err error
+		switch {
+		case math.IsNaN(percent) || percent <= 0 || percent > 100:
+			err = fmt.Errorf("%w: percent %v is not above 0 and up to 100", ErrInvalidSampling, percent)
+		case method != SamplingSystem && method != SamplingBernoulli:
+			err = fmt.Errorf("%w: unknown method %s", ErrInvalidSampling, method)
+		}
+
+		if err != nil {
+			if dq.err == nil {
+				dq.err = err
+			}
+			return
+		}
+
+		dq.sampling = &Sampling{Percent: percent, Method: method}
+	}
+}
+
+// applySampling samples the query's table with TABLESAMPLE where the
+// database has it. Elsewhere, and for derived tables, rows are kept when the
+// dialect's random value falls below the share, or when their primary key
+// modulo 10000 does, for dialects without one.
+func applySampling(query *gorm.DB, sampling *Sampling, primaryKey string) (*gorm.DB, error) {
+
+	d := lookupDialect(dialectName(query))
+	table := tableName(query)
+
+	if d.TableSample && query.Statement.TableExpr == nil && table != "" {
+		query = query.Table("? TABLESAMPLE "+sampling.Method+" (?)", clause.Table{Name: table}, sampling.Percent)
+		query.Statement.Table = table
+		return query, nil
+	}
+
+	if d.Random != "" {
+		return query.Where(d.Random+" < ?", sampling.Percent/100), nil
+	}
+
+	pk, err := primaryKeyColumn(query, primaryKey)
+	if err != nil {
+		return nil, fmt.Errorf("sampling: %w", err)
+	}
+
+	return query.Where("MOD(?, 10000) < ?", clause.Column{Table: clause.CurrentTable, Name: pk}, sampling.Percent*100), nil
+}
@@ -0,0 +1,127 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+func TestSamplingPostgresSQL(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"status": {"="}},
+		AllowedOrderBy: []string{"name"},
+	}
+
+	tests := []struct {
+		method string
+		wrap   bool
+		want   string
+	}{
+		{
+			method: queryhelper.SamplingSystem,
+			want:   `SELECT * FROM "users" TABLESAMPLE SYSTEM (2.5) WHERE "status" = 'open' ORDER BY "name" LIMIT 10`,
+		},
+		{
+			method: queryhelper.SamplingBernoulli,
+			want:   `SELECT * FROM "users" TABLESAMPLE BERNOULLI (2.5) WHERE "status" = 'open' ORDER BY "name" LIMIT 10`,
+		},
+		{
+			// A derived table cannot be sampled, its rows are drawn at random
+			method: queryhelper.SamplingSystem,
+			wrap:   true,
+			want:   `SELECT * FROM (SELECT * FROM "users") AS q WHERE "status" = 'open' AND random() < 0.025 ORDER BY "name" LIMIT 10`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s wrapped=%v", tt.method, tt.wrap), func(t *testing.T) {
+
+			opts := []queryhelper.Option{
+				queryhelper.WithFilter("status", "=", "open"),
+				queryhelper.WithOrderBy([]string{"name"}),
+				queryhelper.WithSampling(2.5, tt.method),
+			}
+			if tt.wrap {
+				opts = append(opts, queryhelper.WithSubqueryWrapping())
+			}
+
+			dq := queryhelper.NewQueryHelper(opts...)
+			query, err := dq.Apply(settings, queryhelpertest.DryRunDB(t, "postgres").Model(&testUser{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := findSQL(t, query); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+			if mode := dq.Info().Pagination.CountMode; mode != queryhelper.CountModeEstimated {
+				t.Errorf("count mode %q, want estimated", mode)
+			}
+		})
+	}
+}
+
+// TestSamplingSQLiteFallback samples on SQLite, which has no TABLESAMPLE, by
+// its random() fallback.
+func TestSamplingSQLiteFallback(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testUser{})
+
+	const n = 2000
+	users := make([]testUser, n)
+	for i := range users {
+		status := "open"
+		if i%2 == 1 {
+			status = "closed"
+		}
+		users[i] = testUser{ID: uint(i + 1), Name: fmt.Sprintf("user %04d", i), Status: status}
+	}
+	if err := db.CreateInBatches(users, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"status": {"="}},
+	}
+
+	sample := func(percent float64) ([]testUser, *queryhelper.PaginationInfo) {
+
+		dq := queryhelper.NewQueryHelper(
+			queryhelper.WithFilter("status", "=", "open"),
+			queryhelper.WithSampling(percent, queryhelper.SamplingBernoulli),
+			queryhelper.WithPageSize(100),
+		)
+
+		var found []testUser
+		if err := dq.Execute(settings, db.Model(&testUser{}).Session(&gorm.Session{}), &found); err != nil {
+			t.Fatal(err)
+		}
+
+		return found, dq.Info().Pagination
+	}
+
+	// The whole table sampled is every matching row
+	found, info := sample(100)
+	if info.Total != n/2 || len(found) != 100 || info.CountMode != queryhelper.CountModeEstimated {
+		t.Errorf("full sample: total %d, %d rows, count mode %q", info.Total, len(found), info.CountMode)
+	}
+
+	// A tenth of the 1000 open users, scaled back up: far more than five
+	// standard deviations (about 9.5 sampled rows) would be needed to miss
+	found, info = sample(10)
+	if info.Total < 500 || info.Total > 1500 {
+		t.Errorf("estimated total %d, want about %d", info.Total, n/2)
+	}
+	if len(found) == 0 || len(found) > 100 {
+		t.Errorf("%d rows on the page", len(found))
+	}
+	for _, u := range found {
+		if u.Status != "open" {
+			t.Errorf("sampled %+v, which does not match the filter", u)
+		}
+	}
+}
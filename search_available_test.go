@@ -0,0 +1,132 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+func TestRequireSearchableFields(t *testing.T) {
+
+	db := customersDB(t)
+
+	tests := []struct {
+		name     string
+		settings func() *queryhelper.QuerySettings
+		opts     []queryhelper.Option
+		err      error    // when searchable fields are required
+		warnings []string // when they are not
+		first    uint     // first customer found when they are not
+		found    int
+	}{
+		{
+			name:     "nothing searchable",
+			opts:     []queryhelper.Option{queryhelper.WithSearchText("customer 01")},
+			err:      queryhelper.ErrSearchNotAvailable,
+			warnings: []string{queryhelper.WarningSearchNotAvailable},
+			first:    1,
+			found:    12,
+		},
+		{
+			name:     "every search field rejected",
+			settings: func() *queryhelper.QuerySettings { return &queryhelper.QuerySettings{AllowedSearch: []string{"name"}} },
+			opts:     []queryhelper.Option{queryhelper.WithSearchText("customer 01"), queryhelper.WithSearchFields([]string{"company_id", "secret"})},
+			err:      queryhelper.ErrSearchNotAvailable,
+			warnings: []string{queryhelper.WarningSearchFieldNotAllowed, queryhelper.WarningSearchFieldNotAllowed, queryhelper.WarningSearchNotAvailable},
+			first:    1,
+			found:    12,
+		},
+		{
+			name:     "one search field left",
+			settings: func() *queryhelper.QuerySettings { return &queryhelper.QuerySettings{AllowedSearch: []string{"name"}} },
+			opts:     []queryhelper.Option{queryhelper.WithSearchText("customer 01"), queryhelper.WithSearchFields([]string{"secret", "name"})},
+			warnings: []string{queryhelper.WarningSearchFieldNotAllowed},
+			first:    12,
+			found:    1,
+		},
+		{
+			name:     "identifier fields only",
+			settings: func() *queryhelper.QuerySettings { return &queryhelper.QuerySettings{SearchIDFields: []string{"id"}} },
+			opts:     []queryhelper.Option{queryhelper.WithSearchText("7")},
+			first:    7,
+			found:    1,
+		},
+		{
+			name:  "blank search text",
+			opts:  []queryhelper.Option{queryhelper.WithSearchText("  ")},
+			first: 1,
+			found: 12,
+		},
+		{
+			name:     "every order rejected",
+			settings: func() *queryhelper.QuerySettings { return &queryhelper.QuerySettings{AllowedOrderBy: []string{"name"}} },
+			opts:     []queryhelper.Option{queryhelper.WithOrderBy([]string{"-company_id", "secret"})},
+			err:      queryhelper.ErrOrderByNotAvailable,
+			warnings: []string{queryhelper.WarningOrderByNotAllowed, queryhelper.WarningOrderByNotAllowed, queryhelper.WarningOrderByNotAvailable},
+			first:    12, // by the default order, name
+			found:    12,
+		},
+		{
+			name: "one order left",
+			settings: func() *queryhelper.QuerySettings {
+				return &queryhelper.QuerySettings{AllowedOrderBy: []string{"name", "id"}}
+			},
+			opts:     []queryhelper.Option{queryhelper.WithOrderBy([]string{"secret", "-id"})},
+			warnings: []string{queryhelper.WarningOrderByNotAllowed},
+			first:    12,
+			found:    12,
+		},
+		{
+			name:     "no order requested",
+			settings: func() *queryhelper.QuerySettings { return &queryhelper.QuerySettings{AllowedOrderBy: []string{"-id"}} },
+			first:    12,
+			found:    12,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, required := range []bool{true, false} {
+			name := tt.name + " leniently"
+			if required {
+				name = tt.name + " strictly"
+			}
+
+			t.Run(name, func(t *testing.T) {
+
+				settings := &queryhelper.QuerySettings{}
+				if tt.settings != nil {
+					settings = tt.settings()
+				}
+				settings.RequireSearchableFields = required
+
+				dq := queryhelper.NewQueryHelper(append(tt.opts, queryhelper.WithPageSize(20))...)
+				var customers []testCustomer
+				err := dq.Execute(settings, db.Model(&testCustomer{}), &customers)
+
+				if required && tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("err = %v, want %v", err, tt.err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(customers) != tt.found || customers[0].ID != tt.first {
+					t.Errorf("found %d customers from %d, want %d from %d", len(customers), customers[0].ID, tt.found, tt.first)
+				}
+
+				var codes []string
+				for _, w := range dq.Info().Warnings {
+					codes = append(codes, w.Code)
+				}
+				if !reflect.DeepEqual(codes, tt.warnings) {
+					t.Errorf("warnings %v, want %v", codes, tt.warnings)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package queryhelper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// searchIDColumn is a SearchIDFields column and the field type its value is
+// bound as.
+type searchIDColumn struct {
+	column    string
+	fieldType string
+}
+
+// searchIDMatches returns the SearchIDFields columns a search text shaped
+// like an integer or a UUID matches exactly, and the value each compares
+// with. Integer columns, and columns without a type, take integers, UUID
+// columns take UUIDs and string columns take either as typed.
+func (ch *ConditionsHandle) searchIDMatches(text string) ([]string, []interface{}) {
+
+	idColumns := ch.Settings.lookups().searchIDs
+	if len(idColumns) == 0 {
+		return nil, nil
+	}
+
+	var integer interface{}
+	if isDigits(text) {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			integer = n
+		}
+	}
+
+	var uuid interface{}
+	if id, ok := parseUUID(text); ok {
+		uuid = id
+	}
+
+	if integer == nil && uuid == nil {
+		return nil, nil
+	}
+
+	var (
+		columns []string
+		values  []interface{}
+	)
+	for _, c := range idColumns {
+		var value interface{}
+		switch c.fieldType {
+		case "", FieldTypeInt:
+			value = integer
+		case FieldTypeUUID:
+			value = uuid
+		case FieldTypeString:
+			value = text
+		}
+
+		if value != nil {
+			columns = append(columns, c.column)
+			values = append(values, value)
+		}
+	}
+
+	return columns, values
+}
+
+func isDigits(s string) bool {
+
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseUUID accepts a UUID in its hyphenated form in either case and returns
+// it lower-cased.
+func parseUUID(s string) (string, bool) {
+
+	if len(s) != 36 {
+		return "", false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return "", false
+			}
+		}
+	}
+
+	return strings.ToLower(s), true
+}
@@ -0,0 +1,126 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testTicket struct {
+	ID      uint
+	Ref     string
+	Code    string
+	Subject string
+}
+
+func (testTicket) TableName() string {
+	return "tickets"
+}
+
+func searchIDSettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedSearch:  []string{"subject"},
+		AllowedOrderBy: []string{"id"},
+		SearchIDFields: []string{"id", "ref", "code"},
+		FieldTypes:     map[string]string{"ref": queryhelper.FieldTypeUUID, "code": queryhelper.FieldTypeString},
+	}
+}
+
+func TestSearchIDFieldsSQL(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "integer",
+			text: " 12345 ",
+			want: `WHERE "subject" LIKE '%12345%' ESCAPE '\' OR "id" = 12345 OR "code" = '12345' ORDER`,
+		},
+		{
+			name: "uuid",
+			text: "6F9619FF-8B86-D011-B42D-00C04FC964FF",
+			want: `WHERE "subject" LIKE '%6F9619FF-8B86-D011-B42D-00C04FC964FF%' ESCAPE '\' OR "ref" = '6f9619ff-8b86-d011-b42d-00c04fc964ff' OR "code" = '6F9619FF-8B86-D011-B42D-00C04FC964FF' ORDER`,
+		},
+		{
+			name: "mixed text",
+			text: "order 12345",
+			want: `WHERE "subject" LIKE '%order 12345%' ESCAPE '\'`,
+		},
+		{
+			name: "signed number",
+			text: "-12",
+			want: `WHERE "subject" LIKE '%-12%' ESCAPE '\'`,
+		},
+		{
+			name: "uuid without hyphens",
+			text: "6f9619ff8b86d011b42d00c04fc964ff",
+			want: `WHERE "subject" LIKE '%6f9619ff8b86d011b42d00c04fc964ff%' ESCAPE '\'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithSearchText(tt.text))
+			queryhelpertest.AssertSQL(t, dq, searchIDSettings(), &testTicket{}, tt.want)
+		})
+	}
+}
+
+func TestSearchIDFieldsRows(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testTicket{})
+	queryhelpertest.Seed(t, db, &[]testTicket{
+		{ID: 7, Ref: "6f9619ff-8b86-d011-b42d-00c04fc964ff", Code: "A-1", Subject: "refund"},
+		{ID: 8, Ref: "0e4d5c1a-9f3b-4c2e-8d7a-1b2c3d4e5f60", Code: "17", Subject: "order 7 late"},
+		{ID: 17, Ref: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Code: "B-2", Subject: "invoice"},
+		{ID: 9, Ref: "a3bb189e-8bf9-3888-9912-ace4e6543002", Code: "C-3", Subject: "order 17 missing"},
+	})
+
+	tests := []struct {
+		name string
+		text string
+		want []uint
+	}{
+		{name: "integer matches the id, the code and the subject", text: "17", want: []uint{8, 9, 17}},
+		{name: "integer matches only the exact id", text: "7", want: []uint{7, 8, 9}},
+		{name: "uuid in upper case", text: "6F9619FF-8B86-D011-B42D-00C04FC964FF", want: []uint{7}},
+		{name: "mixed text searches the subject only", text: "order 7", want: []uint{8}},
+		{name: "id-like text found nowhere", text: "4242", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var tickets []testTicket
+			dq := queryhelper.NewQueryHelper(queryhelper.WithSearchText(tt.text), queryhelper.WithOrderBy([]string{"id"}))
+			if err := dq.Execute(searchIDSettings(), db.Model(&testTicket{}), &tickets); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []uint
+			for _, ticket := range tickets {
+				got = append(got, ticket.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIDFieldsWithFilter(t *testing.T) {
+
+	settings := searchIDSettings()
+	settings.AllowedFilters = map[string][]string{"code": {"!="}}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithSearchText("17"), queryhelper.WithFilter("code", "!=", "17"))
+
+	// The exact matches stay inside the search, under the filter
+	queryhelpertest.AssertSQL(t, dq, settings, &testTicket{},
+		`WHERE "code" != '17' AND ("subject" LIKE '%17%' ESCAPE '\' OR "id" = 17 OR "code" = '17')`)
+}
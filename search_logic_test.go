@@ -0,0 +1,95 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testProduct struct {
+	ID          uint
+	Name        string
+	Description string
+	Category    string
+}
+
+func (testProduct) TableName() string {
+	return "products"
+}
+
+func TestSearchFieldLogic(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testProduct{})
+	queryhelpertest.Seed(t, db, &[]testProduct{
+		{ID: 1, Name: "red chair", Description: "a red wooden chair", Category: "home"},
+		{ID: 2, Name: "red pen", Description: "writes in blue", Category: "office"},
+		{ID: 3, Name: "blue lamp", Description: "with a red switch", Category: "home"},
+		{ID: 4, Name: "green desk", Description: "oak", Category: "office"},
+		{ID: 5, Name: "red desk", Description: "red oak", Category: "office"},
+	})
+
+	tests := []struct {
+		name     string
+		logic    string
+		category string
+		want     []uint
+	}{
+		{name: "any field by default", want: []uint{1, 2, 3, 5}},
+		{name: "any field", logic: queryhelper.LogicOr, want: []uint{1, 2, 3, 5}},
+		{name: "every field", logic: queryhelper.LogicAnd, want: []uint{1, 5}},
+		{name: "every field, lower case", logic: "and", want: []uint{1, 5}},
+		// The search is parenthesized, so OR does not escape the filter
+		{name: "any field with a filter", category: "home", want: []uint{1, 3}},
+		{name: "every field with a filter", logic: queryhelper.LogicAnd, category: "office", want: []uint{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := &queryhelper.QuerySettings{
+				AllowedSearch:    []string{"name", "description"},
+				AllowedFilters:   map[string][]string{"category": {"="}},
+				AllowedOrderBy:   []string{"id"},
+				SearchFieldLogic: tt.logic,
+			}
+
+			opts := []queryhelper.Option{queryhelper.WithSearchText("red")}
+			if tt.category != "" {
+				opts = append(opts, queryhelper.WithEqual("category", tt.category))
+			}
+
+			var products []testProduct
+			if err := queryhelper.NewQueryHelper(opts...).Execute(settings, db.Model(&testProduct{}), &products); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]uint, len(products))
+			for i, p := range products {
+				got[i] = p.ID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFieldLogicSQL(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedSearch:    []string{"name", "description"},
+		AllowedFilters:   map[string][]string{"category": {"="}},
+		SearchFieldLogic: queryhelper.LogicAnd,
+	}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithSearchText("red"), queryhelper.WithEqual("category", "home"))
+	got := queryhelpertest.AssertSQL(t, dq, settings, &testProduct{},
+		`WHERE "category" = 'home' AND ("name" LIKE '%red%' ESCAPE '\' AND "description" LIKE '%red%' ESCAPE '\')`)
+
+	if strings.Contains(got, " OR ") {
+		t.Errorf("every field is combined with OR:\n%s", got)
+	}
+}
@@ -0,0 +1,133 @@
+package queryhelper_test
+
+import (
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+type testItem struct {
+	ID          uint
+	OrderNumber string
+	Name        string
+	SkuCode     string
+}
+
+func (testItem) TableName() string {
+	return "items"
+}
+
+func TestSearchFieldModes(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		mode  string
+		modes map[string]string
+		want  string
+	}{
+		{
+			name:  "overrides on contains",
+			modes: map[string]string{"order_number": queryhelper.SearchModeExact, "sku": queryhelper.SearchModePrefix},
+			want:  `WHERE "order_number" = 'A_1' OR "name" LIKE '%A\_1%' ESCAPE '\' OR "sku_code" LIKE 'A\_1%' ESCAPE '\' LIMIT 10`,
+		},
+		{
+			name:  "overrides on prefix",
+			mode:  queryhelper.SearchModePrefix,
+			modes: map[string]string{"order_number": queryhelper.SearchModeExact, "name": queryhelper.SearchModeContains},
+			want:  `WHERE "order_number" = 'A_1' OR "name" LIKE '%A\_1%' ESCAPE '\' OR "sku_code" LIKE 'A\_1%' ESCAPE '\' LIMIT 10`,
+		},
+		{
+			name: "global exact",
+			mode: queryhelper.SearchModeExact,
+			want: `WHERE "order_number" = 'A_1' OR "name" = 'A_1' OR "sku_code" = 'A_1' LIMIT 10`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := &queryhelper.QuerySettings{
+				AllowedSearch:    []string{"order_number", "name", "sku"},
+				ColumnAlias:      map[string]string{"sku": "sku_code"},
+				SearchMode:       tt.mode,
+				SearchFieldModes: tt.modes,
+			}
+
+			queryhelpertest.AssertSQL(t, queryhelper.NewQueryHelper(queryhelper.WithSearchText("A_1")), settings, &testItem{}, tt.want)
+		})
+	}
+}
+
+func TestSearchFieldModesRows(t *testing.T) {
+
+	db := queryhelpertest.NewTestDB(t, &testItem{})
+	queryhelpertest.Seed(t, db, &[]testItem{
+		{ID: 1, OrderNumber: "SO-7", Name: "bolt", SkuCode: "X-1"},
+		{ID: 2, OrderNumber: "SO-70", Name: "nut", SkuCode: "X-2"},
+		{ID: 3, OrderNumber: "SO-8", Name: "bolt SO-7 kit", SkuCode: "X-3"},
+		{ID: 4, OrderNumber: "SO-9", Name: "washer", SkuCode: "SO-7B"},
+		{ID: 5, OrderNumber: "SO-10", Name: "screw", SkuCode: "B-SO-7"},
+	})
+
+	settings := &queryhelper.QuerySettings{
+		AllowedSearch:    []string{"order_number", "name", "sku"},
+		AllowedOrderBy:   []string{"id"},
+		ColumnAlias:      map[string]string{"sku": "sku_code"},
+		SearchFieldModes: map[string]string{"order_number": queryhelper.SearchModeExact, "sku": queryhelper.SearchModePrefix},
+	}
+
+	var items []testItem
+	if err := queryhelper.NewQueryHelper(queryhelper.WithSearchText("SO-7")).Execute(settings, db.Model(&testItem{}), &items); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not SO-70 by order number, nor B-SO-7 by SKU
+	var got []uint
+	for _, item := range items {
+		got = append(got, item.ID)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 3 || got[2] != 4 {
+		t.Errorf("got %v, want [1 3 4]", got)
+	}
+}
+
+func TestDefaultSearchFields(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		defaults []string
+		fields   []string
+		want     string
+	}{
+		{
+			name:     "explicit fields",
+			defaults: []string{"sku"},
+			fields:   []string{"order_number", "sku"},
+			want:     `WHERE "order_number" LIKE '%x%' ESCAPE '\' OR "sku_code" LIKE '%x%' ESCAPE '\' LIMIT 10`,
+		},
+		{
+			name:     "defaults through aliases",
+			defaults: []string{"sku"},
+			want:     `WHERE "sku_code" LIKE '%x%' ESCAPE '\' LIMIT 10`,
+		},
+		{
+			name: "every allowed field without defaults",
+			want: `WHERE "order_number" LIKE '%x%' ESCAPE '\' OR "name" LIKE '%x%' ESCAPE '\' OR "sku_code" LIKE '%x%' ESCAPE '\' LIMIT 10`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := &queryhelper.QuerySettings{
+				AllowedSearch:       []string{"order_number", "name", "sku"},
+				DefaultSearchFields: tt.defaults,
+				ColumnAlias:         map[string]string{"sku": "sku_code"},
+			}
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithSearchText("x"), queryhelper.WithSearchFields(tt.fields))
+			queryhelpertest.AssertSQL(t, dq, settings, &testItem{}, tt.want)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package queryhelper
+
+import (
+	"sync/atomic"
+)
+
+// SettingsProvider supplies the QuerySettings to use for a query. Callers
+// capture Current() once per Apply, so a provider may hand out different
+// settings over time without affecting queries already in flight.
+type SettingsProvider interface {
+	Current() *QuerySettings
+}
+
+// AtomicSettingsProvider is a SettingsProvider whose settings can be replaced
+// at runtime. Settings passed to it must not be modified after publishing;
+// build a new QuerySettings and Swap it in instead.
+type AtomicSettingsProvider struct {
+	settings atomic.Pointer[QuerySettings]
+}
+
+func NewAtomicSettingsProvider(settings *QuerySettings) *AtomicSettingsProvider {
+
+	p := &AtomicSettingsProvider{}
+	p.settings.Store(settings)
+
+	return p
+}
+
+func (p *AtomicSettingsProvider) Current() *QuerySettings {
+
+	settings := p.settings.Load()
+	if settings == nil {
+		return DefaultQuerySettings
+	}
+
+	return settings
+}
+
+// Swap publishes new settings and returns the previous ones. Plans cached
+// for the previous settings are purged.
+func (p *AtomicSettingsProvider) Swap(settings *QuerySettings) *QuerySettings {
+
+	old := p.settings.Swap(settings)
+	if old != nil && old.PlanCache != nil {
+		old.PlanCache.Purge()
+	}
+
+	return old
+}
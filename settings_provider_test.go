@@ -0,0 +1,124 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+)
+
+// TestAtomicSettingsProviderSwap applies requests while the settings are
+// swapped, run with -race. Each Apply sees one snapshot: a filter on status
+// or on age, never both or neither.
+func TestAtomicSettingsProviderSwap(t *testing.T) {
+
+	statusSettings := &queryhelper.QuerySettings{AllowedFilters: map[string][]string{"status": {"="}}}
+	ageSettings := &queryhelper.QuerySettings{AllowedFilters: map[string][]string{"age": {"="}}}
+
+	provider := queryhelper.NewAtomicSettingsProvider(statusSettings)
+	db := queryhelpertest.DryRunDB(t, "postgres")
+
+	stop := make(chan struct{})
+	swapped := make(chan struct{})
+	go func() {
+		defer close(swapped)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			if i%2 == 0 {
+				provider.Swap(ageSettings)
+			} else {
+				provider.Swap(statusSettings)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				dq := queryhelper.NewQueryHelper(
+					queryhelper.WithSettingsProvider(provider),
+					queryhelper.WithEqual("status", "active"),
+					queryhelper.WithEqual("age", 30),
+				)
+
+				if _, err := dq.Apply(nil, db.Model(&testUser{})); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if filters := dq.Info().Conditions.Filters; len(filters) != 1 {
+					t.Errorf("filters %+v, want the ones of one snapshot", filters)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	close(stop)
+	<-swapped
+}
+
+// TestSharedSettingsConcurrentValidation validates concurrently against
+// settings whose lookups are built on first use, run with -race.
+func TestSharedSettingsConcurrentValidation(t *testing.T) {
+
+	newSettings := func() *queryhelper.QuerySettings {
+		return &queryhelper.QuerySettings{
+			AllowedSearch:  []string{"name", "email"},
+			AllowedOrderBy: []string{"name", "age"},
+			AllowedFilters: map[string][]string{"status": {"=", "IN"}, "age": {">=", "<"}},
+			ColumnAlias:    map[string]string{"years": "age"},
+		}
+	}
+
+	conditions := func() *queryhelper.QueryConditions {
+		return &queryhelper.QueryConditions{
+			SearchText: "ann",
+			OrderBy:    []string{"-years", "password"},
+			Filters: []queryhelper.FilterCondition{
+				{Field: "status", Operator: "IN", Value: []interface{}{"a", "b"}},
+				{Field: "years", Operator: ">=", Value: 18},
+				{Field: "secret", Operator: "=", Value: 1},
+			},
+		}
+	}
+
+	// What one goroutine alone gets
+	want := queryhelper.NewConditionsHandle(newSettings())
+	if err := want.UpdateConditions(conditions()); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := newSettings()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ch := queryhelper.NewConditionsHandle(settings)
+			if err := ch.UpdateConditions(conditions()); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(ch.Conditions, want.Conditions) || !reflect.DeepEqual(ch.Dropped, want.Dropped) {
+				t.Errorf("got %+v dropping %+v, want %+v dropping %+v", ch.Conditions, ch.Dropped, want.Conditions, want.Dropped)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,544 @@
+package queryhelper
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// WithPartialShardResults lets ExecuteSharded return the page merged from the
+// shards that succeeded when others fail. ShardErrors reports the failures.
+func WithPartialShardResults() Option {
+	return func(dq *QueryHelper) {
+		dq.partialShards = true
+	}
+}
+
+// ShardErrors returns the shards the last ExecuteSharded failed on.
+func (dq *QueryHelper) ShardErrors() []*ShardError {
+	return dq.shardErrors
+}
+
+// ExecuteSharded applies the conditions to every shard concurrently and
+// finds the merged page into dest, a pointer to a slice. Total is the sum of
+// the shards' totals.
+//
+// Every shard returns its first offset+limit rows, which are merged on the
+// ORDER BY columns, so deep pages cost every shard a deep read. The ORDER BY
+// columns must be fields of dest, and text is merged bytewise, so order text
+// columns with a binary collation. Columns sorted by FieldWrappers, locking
+// and two-phase pagination are not supported across shards.
+func (dq *QueryHelper) ExecuteSharded(settings *QuerySettings, shards []*gorm.DB, dest interface{}) error {
+
+	if settings == nil && dq.settingsProvider != nil {
+		settings = dq.settingsProvider.Current()
+	}
+
+	start := time.Now()
+	err := dq.executeSharded(settings, shards, dest)
+
+	if dq.auditSink != nil {
+		var query *gorm.DB
+		if len(shards) > 0 {
+			query = shards[0]
+		}
+		dq.audit(settings, query, start, err, dest)
+	}
+
+	return err
+}
+
+// shardResult is what one shard returned.
+type shardResult struct {
+	total   int64
+	rows    reflect.Value // pointer to a slice of dest's type
+	retries int
+	err     error
+}
+
+func (dq *QueryHelper) executeSharded(settings *QuerySettings, shards []*gorm.DB, dest interface{}) error {
+
+	dq.retries = 0
+	dq.pagination.retries = 0
+	dq.shardErrors = nil
+	dq.requested = *dq.queryConditions
+
+	if len(shards) == 0 {
+		return errors.New("no shards")
+	}
+
+	if dq.locking != nil {
+		return errors.New("locking is not supported across shards")
+	}
+
+	if dq.sampling != nil {
+		return errors.New("sampling is not supported across shards")
+	}
+
+	if dq.err != nil {
+		return dq.err
+	}
+
+	if dq.pagination.err != nil {
+		return dq.pagination.err
+	}
+
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return errors.New("dest must be a pointer to a slice")
+	}
+	sliceType := destValue.Elem().Type()
+
+	dqh := NewConditionsHandle(settings)
+	if err := dqh.UpdateConditions(dq.queryConditions); err != nil {
+		return err
+	}
+
+	dq.conditions = dqh
+
+	if dq.wrapSubquery {
+		if err := dqh.checkSubqueryColumns(); err != nil {
+			return err
+		}
+	}
+
+	keys, err := dqh.mergeKeys(shards[0], sliceType.Elem())
+	if err != nil {
+		return err
+	}
+
+	info := dq.pagination.Info
+	fetch := info.Offset + info.Limit
+
+	results := make([]shardResult, len(shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		if dq.wrapSubquery {
+			shard = wrapSubquery(shard)
+		}
+
+		go func(i int, shard *gorm.DB) {
+			defer wg.Done()
+			results[i] = dqh.queryShard(shard, fetch, sliceType)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var (
+		total int64
+		lists []reflect.Value
+		errs  []error
+	)
+	for i, result := range results {
+		dq.retries += result.retries
+
+		if result.err != nil {
+			shardErr := &ShardError{Shard: i, Err: result.err}
+			dq.shardErrors = append(dq.shardErrors, shardErr)
+			errs = append(errs, shardErr)
+			continue
+		}
+
+		total += result.total
+		lists = append(lists, result.rows.Elem())
+	}
+
+	// A page with no shard behind it is not a partial result
+	if len(errs) > 0 && (!dq.partialShards || len(lists) == 0) {
+		return errors.Join(errs...)
+	}
+
+	info.Total = total
+	if total == 0 {
+		info.TotalPages = 1
+	} else {
+		info.TotalPages = int((total + int64(info.Limit) - 1) / int64(info.Limit))
+	}
+
+	merged := mergeShards(lists, keys, nullsSortLast(dialectName(shards[0])), fetch, sliceType)
+
+	offset := min(info.Offset, merged.Len())
+	destValue.Elem().Set(merged.Slice(offset, merged.Len()))
+
+	return nil
+}
+
+// queryShard counts the shard's matching rows and finds the first limit of
+// them, under one deadline when QueryTimeout is set.
+func (ch *ConditionsHandle) queryShard(shard *gorm.DB, limit int, sliceType reflect.Type) shardResult {
+
+	settings := ch.Settings
+	result := shardResult{rows: reflect.New(sliceType)}
+
+	result.err = withTimeout(shard, settings.QueryTimeout, func(shard *gorm.DB) error {
+
+		query, err := ch.Apply(shard)
+		if err != nil {
+			return err
+		}
+
+		if settings.DeduplicateOnPrimaryKey {
+			if query, err = deduplicate(query, settings, ch.Conditions.Fields); err != nil {
+				return err
+			}
+		}
+
+		if settings.QueryTimeout > 0 {
+			if query, err = applyStatementTimeout(query, settings.QueryTimeout); err != nil {
+				return err
+			}
+		}
+
+		countQuery := withoutPreloads(query)
+		if settings.CountTable != "" {
+			countQuery = countQuery.Session(&gorm.Session{}).Table(settings.CountTable)
+		}
+
+		err = settings.RetryPolicy.run(countQuery, &result.retries, func(q *gorm.DB) error {
+			return q.Count(&result.total).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		// Some databases only paginate ordered results
+		if lookupDialect(dialectName(query)).OrderedPagination {
+			query = ensureOrder(query)
+		}
+
+		rows := result.rows.Interface()
+
+		return settings.RetryPolicy.forDest(rows).run(query.Limit(limit), &result.retries, func(q *gorm.DB) error {
+			return q.Find(rows).Error
+		})
+	})
+
+	return result
+}
+
+// mergeKey is one ORDER BY column the shards' rows are merged on.
+type mergeKey struct {
+	column  string
+	field   *schema.Field // nil for map rows
+	desc    bool
+	numeric bool // cast to a number, so text is compared as one
+	text    bool // cast to text, so numbers are compared as their digits
+}
+
+// mergeKeys resolves the ORDER BY columns to the fields of rows of type elem.
+// Columns sorted by a FieldWrappers expression or cast to a type other than
+// the portable ColumnCasts ones cannot be compared like the shards sorted
+// them, and are rejected.
+func (ch *ConditionsHandle) mergeKeys(query *gorm.DB, elem reflect.Type) ([]mergeKey, error) {
+
+	var s *schema.Schema
+
+	base := elem
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+
+	if base.Kind() == reflect.Struct {
+		stmt := query.Session(&gorm.Session{NewDB: true}).Statement
+		if err := stmt.Parse(reflect.New(base).Interface()); err != nil {
+			return nil, err
+		}
+		s = stmt.Schema
+	} else if base.Kind() != reflect.Map || base.Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("cannot merge shard rows of type %s", elem)
+	}
+
+	dialect := dialectName(query)
+	wrappers := ch.wrapperColumns(dialect)
+	casts := ch.castColumns(dialect, lookupDialect(dialect))
+	portable := ch.Settings.lookups().casts
+
+	keys := make([]mergeKey, 0, len(ch.Conditions.OrderBy))
+	for _, entry := range ch.Conditions.OrderBy {
+		prefix, column := splitOrderBy(entry)
+
+		if _, path := relationColumn(ch.Settings.Relations, column); path != "" {
+			return nil, fmt.Errorf("cannot merge shards ordered by joined column %s", column)
+		}
+
+		desc := ch.Conditions.SortFactor < 0
+		if prefix != "" {
+			desc = prefix == "-"
+		}
+
+		key := mergeKey{
+			column: column[strings.LastIndexByte(column, '.')+1:],
+			desc:   desc,
+		}
+
+		if _, ok := wrappers[column]; ok {
+			return nil, fmt.Errorf("cannot merge shards ordered by %s: it is sorted by its FieldWrappers expression", column)
+		}
+
+		if cast, ok := casts[column]; ok {
+			switch portable[column] {
+			case CastInteger, CastDecimal, CastFloat:
+				key.numeric = true
+			case CastText:
+				key.text = true
+			default:
+				return nil, fmt.Errorf("cannot merge shards ordered by %s: it is sorted as %s", column, cast)
+			}
+		}
+
+		if s != nil {
+			if key.field = s.LookUpField(key.column); key.field == nil {
+				return nil, fmt.Errorf("cannot merge shards ordered by %s: not a field of %s", column, base)
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (k *mergeKey) value(row reflect.Value) interface{} {
+
+	for row.Kind() == reflect.Ptr {
+		if row.IsNil() {
+			return nil
+		}
+		row = row.Elem()
+	}
+
+	var v reflect.Value
+	if k.field != nil {
+		v = k.field.ReflectValueOf(context.Background(), row)
+	} else {
+		v = row.MapIndex(reflect.ValueOf(k.column))
+	}
+
+	value := sortValue(v)
+
+	if s, ok := value.(string); ok && k.numeric {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+
+	if k.text && value != nil {
+		return sortText(value)
+	}
+
+	return value
+}
+
+// sortText writes a value cast to text as the database would for numbers.
+func sortText(value interface{}) string {
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case isIntKind(rv.Kind()):
+		return strconv.FormatInt(rv.Int(), 10)
+	case isUintKind(rv.Kind()):
+		return strconv.FormatUint(rv.Uint(), 10)
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// sortValue unwraps pointers, interfaces and driver.Valuer types such as
+// sql.NullString to the value the database sorted by.
+func sortValue(v reflect.Value) interface{} {
+
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil
+	}
+
+	value := v.Interface()
+	if v.CanAddr() {
+		value = v.Addr().Interface()
+	}
+
+	valuer, ok := value.(driver.Valuer)
+	if !ok {
+		return v.Interface()
+	}
+
+	value, err := valuer.Value()
+	if err != nil {
+		return nil
+	}
+
+	return value
+}
+
+// nullsSortLast reports whether the database sorts NULL after every value
+// in ascending order, as Postgres and Oracle do. MySQL, SQLite and SQL Server
+// sort it first.
+func nullsSortLast(dialect string) bool {
+	return dialect == "postgres" || dialect == "oracle"
+}
+
+// compareSortValues orders two values of a column the way the database does
+// as far as Go can tell. Text is compared bytewise.
+func compareSortValues(a, b interface{}, nullsLast bool) int {
+
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil || b == nil:
+		if (a == nil) == nullsLast {
+			return 1
+		}
+		return -1
+	}
+
+	switch x := a.(type) {
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case y:
+				return -1
+			}
+			return 1
+		}
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isIntKind(va.Kind()) && isIntKind(vb.Kind()):
+		return cmp.Compare(va.Int(), vb.Int())
+	case isUintKind(va.Kind()) && isUintKind(vb.Kind()):
+		return cmp.Compare(va.Uint(), vb.Uint())
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		return strings.Compare(va.String(), vb.String())
+	}
+
+	if fa, ok := floatOf(va); ok {
+		if fb, ok := floatOf(vb); ok {
+			return cmp.Compare(fa, fb)
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uintptr
+}
+
+func floatOf(v reflect.Value) (float64, bool) {
+
+	switch {
+	case isIntKind(v.Kind()):
+		return float64(v.Int()), true
+	case isUintKind(v.Kind()):
+		return float64(v.Uint()), true
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+// mergeShards merges the shards' ordered rows into the first limit rows of
+// their union. Rows that tie keep the order of their shards.
+func mergeShards(lists []reflect.Value, keys []mergeKey, nullsLast bool, limit int, sliceType reflect.Type) reflect.Value {
+
+	listKeys := make([][]mergeKey, len(lists))
+	for i := range lists {
+		listKeys[i] = keys
+	}
+
+	merged := reflect.MakeSlice(sliceType, 0, limit)
+	for _, at := range mergeRows(lists, listKeys, nullsLast, limit) {
+		merged = reflect.Append(merged, lists[at[0]].Index(at[1]))
+	}
+
+	return merged
+}
+
+// mergeRows returns the first limit rows of the union of ordered lists as
+// list and index pairs. Each list's rows are read with its own keys, so
+// lists of different types merge on a column they share. Rows that tie keep
+// the order of their lists.
+func mergeRows(lists []reflect.Value, keys [][]mergeKey, nullsLast bool, limit int) [][2]int {
+
+	rows := make([][2]int, 0, limit)
+	next := make([]int, len(lists))
+
+	compare := func(a int, b int) int {
+		ra, rb := lists[a].Index(next[a]), lists[b].Index(next[b])
+		for i := range keys[a] {
+			c := compareSortValues(keys[a][i].value(ra), keys[b][i].value(rb), nullsLast)
+			if keys[a][i].desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+
+	for len(rows) < limit {
+		best := -1
+		for i, list := range lists {
+			if next[i] >= list.Len() {
+				continue
+			}
+			if best < 0 || compare(i, best) < 0 {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		rows = append(rows, [2]int{best, next[best]})
+		next[best]++
+	}
+
+	return rows
+}
@@ -0,0 +1,70 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+func TestExecuteShardedRejectsUnmergeableOrder(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy: []string{"name", "status"},
+		FieldWrappers:  map[string]string{"name": "LOWER(%s)"},
+		ColumnCasts:    map[string]string{"status": "DATE"},
+	}
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{order: "name", want: "FieldWrappers"},
+		{order: "status", want: "sorted as DATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+
+			dq := queryhelper.NewQueryHelper(queryhelper.WithOrderBy([]string{tt.order}))
+			shards := []*gorm.DB{queryhelpertest.DryRunDB(t, "sqlite"), queryhelpertest.DryRunDB(t, "sqlite")}
+
+			var users []testUser
+			err := dq.ExecuteSharded(settings, shards, &users)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeShardsTextCast(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy: []string{"age"},
+		ColumnCasts:    map[string]string{"age": queryhelper.CastText},
+	}
+
+	first := queryhelpertest.NewTestDB(t, &testUser{})
+	queryhelpertest.Seed(t, first, &[]testUser{{ID: 1, Age: 100}, {ID: 2, Age: 9}})
+	second := queryhelpertest.NewTestDB(t, &testUser{})
+	queryhelpertest.Seed(t, second, &testUser{ID: 3, Age: 20})
+
+	// Each shard sorts its ages as text, and so does the merge
+	dq := queryhelper.NewQueryHelper(queryhelper.WithOrderBy([]string{"age"}))
+	var users []testUser
+	if err := dq.ExecuteSharded(settings, []*gorm.DB{first.Model(&testUser{}), second.Model(&testUser{})}, &users); err != nil {
+		t.Fatal(err)
+	}
+
+	var ages []int
+	for _, u := range users {
+		ages = append(ages, u.Age)
+	}
+	if want := []int{100, 20, 9}; !reflect.DeepEqual(ages, want) {
+		t.Errorf("ages %v, want %v", ages, want)
+	}
+}
@@ -0,0 +1,213 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+// Instants around the boundaries text comparison gets wrong: across a
+// second, a day, a year and one- and two-digit months.
+var eventTimes = []time.Time{
+	time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+	time.Date(2024, 1, 1, 7, 59, 59, 0, time.UTC),
+	time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+	time.Date(2024, 1, 1, 8, 0, 1, 0, time.UTC),
+	time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC),
+}
+
+type timeEvent struct {
+	ID uint
+	At time.Time
+}
+
+type textEvent struct {
+	ID uint
+	At string
+}
+
+type unixEvent struct {
+	ID uint
+	At int64
+}
+
+// eventsDB stores eventTimes as the format asks, with ids from 1.
+func eventsDB(t *testing.T, format string) *gorm.DB {
+
+	t.Helper()
+
+	var model, rows interface{}
+	switch format {
+	case "":
+		var events []timeEvent
+		for i, at := range eventTimes {
+			events = append(events, timeEvent{ID: uint(i + 1), At: at})
+		}
+		model, rows = &timeEvent{}, &events
+	case queryhelper.TimeFormatRFC3339:
+		var events []textEvent
+		for i, at := range eventTimes {
+			events = append(events, textEvent{ID: uint(i + 1), At: at.Format("2006-01-02T15:04:05Z")})
+		}
+		model, rows = &textEvent{}, &events
+	case queryhelper.TimeFormatUnix:
+		var events []unixEvent
+		for i, at := range eventTimes {
+			events = append(events, unixEvent{ID: uint(i + 1), At: at.Unix()})
+		}
+		model, rows = &unixEvent{}, &events
+	}
+
+	db := queryhelpertest.NewTestDB(t, model)
+	queryhelpertest.Seed(t, db, rows)
+
+	return db.Model(model)
+}
+
+// TestSQLiteTimesMatchInstants runs time filters on SQLite for each storage
+// format and expects the rows a database comparing instants returns, as
+// Postgres does for timestamptz columns.
+func TestSQLiteTimesMatchInstants(t *testing.T) {
+
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name      string
+		filter    queryhelper.FilterCondition
+		match     func(time.Time) bool
+		canonical bool // needs a canonical format, values not in UTC
+	}{
+		{
+			name:   "after",
+			filter: queryhelper.FilterCondition{Field: "at", Operator: ">", Value: "2024-01-01T08:00:00Z"},
+			match:  func(v time.Time) bool { return v.After(at("2024-01-01T08:00:00Z")) },
+		},
+		{
+			name:   "at or after",
+			filter: queryhelper.FilterCondition{Field: "at", Operator: ">=", Value: "2024-01-01T08:00:00Z"},
+			match:  func(v time.Time) bool { return !v.Before(at("2024-01-01T08:00:00Z")) },
+		},
+		{
+			name:   "before",
+			filter: queryhelper.FilterCondition{Field: "at", Operator: "<", Value: "2024-01-01T08:00:00Z"},
+			match:  func(v time.Time) bool { return v.Before(at("2024-01-01T08:00:00Z")) },
+		},
+		{
+			name:   "between",
+			filter: queryhelper.FilterCondition{Field: "at", Operator: "BETWEEN", Value: []interface{}{"2024-01-01T00:00:00Z", "2024-01-01T23:59:59Z"}},
+			match: func(v time.Time) bool {
+				return !v.Before(at("2024-01-01T00:00:00Z")) && !v.After(at("2024-01-01T23:59:59Z"))
+			},
+		},
+		{
+			name:   "in",
+			filter: queryhelper.FilterCondition{Field: "at", Operator: "IN", Value: []interface{}{"2024-01-01T08:00:00Z", "2024-10-01T12:00:00Z"}},
+			match: func(v time.Time) bool {
+				return v.Equal(at("2024-01-01T08:00:00Z")) || v.Equal(at("2024-10-01T12:00:00Z"))
+			},
+		},
+		{
+			name:   "between with offsets",
+			filter: queryhelper.FilterCondition{Field: "at", Operator: "BETWEEN", Value: []interface{}{"2024-01-01T09:00:00+01:00", "2024-01-02T01:00:00+01:00"}},
+			match: func(v time.Time) bool {
+				return !v.Before(at("2024-01-01T08:00:00Z")) && !v.After(at("2024-01-02T00:00:00Z"))
+			},
+			canonical: true,
+		},
+	}
+
+	for _, format := range []string{"", queryhelper.TimeFormatRFC3339, queryhelper.TimeFormatUnix} {
+		db := eventsDB(t, format)
+
+		settings := &queryhelper.QuerySettings{
+			AllowedFilters:   map[string][]string{"at": {">", ">=", "<", "BETWEEN", "IN"}},
+			AllowedOrderBy:   []string{"id"},
+			FieldTypes:       map[string]string{"at": queryhelper.FieldTypeTime},
+			SQLiteTimeFormat: format,
+		}
+
+		for _, tt := range tests {
+			if tt.canonical && format == "" {
+				continue
+			}
+
+			t.Run(fmt.Sprintf("%s/%s", format, tt.name), func(t *testing.T) {
+
+				dq := queryhelper.NewQueryHelper(
+					queryhelper.WithFilters([]queryhelper.FilterCondition{tt.filter}),
+					queryhelper.WithOrderBy([]string{"id"}),
+					queryhelper.WithPageSize(100),
+				)
+
+				var rows []map[string]interface{}
+				if err := dq.Execute(settings, db.Session(&gorm.Session{}), &rows); err != nil {
+					t.Fatal(err)
+				}
+
+				var got, want []string
+				for _, row := range rows {
+					got = append(got, fmt.Sprint(row["id"]))
+				}
+				for i, v := range eventTimes {
+					if tt.match(v) {
+						want = append(want, fmt.Sprint(i+1))
+					}
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("found ids %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestTimeValuesBoundAsIs checks that only an opted-in SQLite format
+// converts time values; Postgres and SQLite by default bind time.Time.
+func TestTimeValuesBoundAsIs(t *testing.T) {
+
+	tests := []struct {
+		dialect string
+		format  string
+		want    interface{}
+	}{
+		{dialect: "postgres", format: queryhelper.TimeFormatUnix, want: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{dialect: "sqlite", format: "", want: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{dialect: "sqlite", format: queryhelper.TimeFormatRFC3339, want: "2024-01-01T08:00:00Z"},
+		{dialect: "sqlite", format: queryhelper.TimeFormatUnix, want: int64(1704096000)},
+	}
+
+	for _, tt := range tests {
+		settings := &queryhelper.QuerySettings{
+			AllowedFilters:   map[string][]string{"at": {">="}},
+			FieldTypes:       map[string]string{"at": queryhelper.FieldTypeTime},
+			SQLiteTimeFormat: tt.format,
+		}
+
+		helper := queryhelper.NewQueryHelper(queryhelper.WithFilter("at", ">=", "2024-01-01T09:00:00+01:00"))
+		_, vars, err := queryhelpertest.RenderSQL(t, tt.dialect, helper, settings, &timeEvent{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := vars[0]
+		if v, ok := got.(time.Time); ok {
+			got = v.UTC()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q binds %#v, want %#v", tt.dialect, tt.format, vars[0], tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package queryhelper
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// WithSubqueryWrapping treats the query passed to Apply as an opaque derived
+// table, selecting from (query) AS q. Conditions, ordering, counting and
+// pagination then apply to its output columns once, however the query is
+// built, e.g. from raw SQL, a UNION or a CTE. Declare the output columns in
+// QuerySettings.SubqueryColumns to have requests checked against them.
+func WithSubqueryWrapping() Option {
+	return func(dq *QueryHelper) {
+		dq.wrapSubquery = true
+	}
+}
+
+// wrapSubquery returns a query selecting from query as the derived table q,
+// keeping its model for the primary key and table lookups.
+func wrapSubquery(query *gorm.DB) *gorm.DB {
+
+	wrapped := query.Session(&gorm.Session{NewDB: true})
+	if query.Statement.Model != nil {
+		wrapped = wrapped.Model(query.Statement.Model)
+	}
+
+	return wrapped.Table("(?) AS q", query)
+}
+
+// checkSubqueryColumns rejects conditions referencing a column that is not
+// one of SubqueryColumns. Nothing is checked when none are declared.
+func (ch *ConditionsHandle) checkSubqueryColumns() error {
+
+	declared := ch.Settings.SubqueryColumns
+	if len(declared) == 0 {
+		return nil
+	}
+
+	c := ch.Conditions
+
+	columns := make([]string, 0, len(c.SearchFields)+len(c.OrderBy)+len(c.Fields)+len(c.Filters))
+	columns = append(columns, c.SearchFields...)
+	for _, entry := range c.OrderBy {
+		_, field := splitOrderBy(entry)
+		columns = append(columns, field)
+	}
+	columns = append(columns, c.Fields...)
+	columns = appendFilterColumns(columns, c.Filters, c.FilterGroups)
+	ids, _ := ch.searchIDMatches(strings.TrimSpace(c.SearchText))
+	columns = append(columns, ids...)
+	if c.Histogram != nil {
+		columns = append(columns, c.Histogram.Field)
+	}
+
+	public := ch.publicNames()
+	for _, column := range columns {
+		// Relation filters correlate on the parent column
+		if rf, ok := ch.Settings.RelationFilters[column]; ok {
+			column = rf.References
+			if column == "" {
+				column = "id"
+			}
+		}
+
+		if !containsString(declared, column) {
+			return fmt.Errorf("unknown field %s: %s is not a column of the wrapped query", publicColumns(public, []string{column})[0], column)
+		}
+	}
+
+	return nil
+}
+
+func appendFilterColumns(columns []string, filters []FilterCondition, groups []FilterGroup) []string {
+
+	for _, filter := range filters {
+		columns = append(columns, filter.Field)
+	}
+
+	for _, group := range groups {
+		columns = appendFilterColumns(columns, group.Filters, group.Groups)
+	}
+
+	return columns
+}
@@ -0,0 +1,150 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+type testListRow struct {
+	ID   uint
+	Name string
+	Kind string
+}
+
+const unionSQL = "SELECT id, name, 'customer' AS kind FROM customers UNION ALL SELECT id, name, 'company' AS kind FROM companies"
+
+func subquerySettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters:  map[string][]string{"kind": {"="}, "company_id": {"="}},
+		AllowedSearch:   []string{"name"},
+		AllowedOrderBy:  []string{"name", "id"},
+		SubqueryColumns: []string{"id", "name", "kind"},
+	}
+}
+
+func TestSubqueryWrapping(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		opts  []queryhelper.Option
+		names []string
+		total int64
+	}{
+		{
+			name:  "filter the union",
+			opts:  []queryhelper.Option{queryhelper.WithEqual("kind", "company"), queryhelper.WithOrderBy([]string{"-name"})},
+			names: []string{"initech", "globex", "acme"},
+			total: 3,
+		},
+		{
+			name:  "search and page",
+			opts:  []queryhelper.Option{queryhelper.WithSearchText("customer 0"), queryhelper.WithOrderBy([]string{"name"}), queryhelper.WithPage(2), queryhelper.WithPageSize(4)},
+			names: []string{"customer 05", "customer 06", "customer 07", "customer 08"},
+			total: 9,
+		},
+		{
+			name:  "both tables",
+			opts:  []queryhelper.Option{queryhelper.WithOrderBy([]string{"name"}), queryhelper.WithPageSize(4)},
+			names: []string{"acme", "customer 01", "customer 02", "customer 03"},
+			total: 15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := customersDB(t)
+			statements := recordQueries(t, db)
+
+			dq := queryhelper.NewQueryHelper(append(tt.opts, queryhelper.WithSubqueryWrapping())...)
+			var rows []testListRow
+			if err := dq.Execute(subquerySettings(), db.Raw(unionSQL), &rows); err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, row := range rows {
+				names = append(names, row.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("got %q, want %q", names, tt.names)
+			}
+			if total := dq.Info().Pagination.Total; total != tt.total {
+				t.Errorf("total %d, want %d", total, tt.total)
+			}
+
+			// The count and the page both select from the union once
+			for _, statement := range *statements {
+				if strings.Count(statement, "UNION ALL") != 1 || !strings.Contains(statement, "FROM ("+unionSQL+") AS q") {
+					t.Errorf("statement does not wrap the union:\n%s", statement)
+				}
+			}
+			if len(*statements) != 2 || !strings.HasPrefix((*statements)[0], "SELECT count(*) FROM (") {
+				t.Errorf("ran %q, want the count and the find", *statements)
+			}
+		})
+	}
+}
+
+func TestSubqueryWrappingUndeclaredColumn(t *testing.T) {
+
+	tests := []struct {
+		name string
+		opt  queryhelper.Option
+	}{
+		{name: "filter", opt: queryhelper.WithEqual("company_id", 1)},
+		{name: "fields", opt: queryhelper.WithFields([]string{"company_id"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			settings := subquerySettings()
+			settings.AllowedFields = []string{"id", "company_id"}
+
+			db := customersDB(t)
+			dq := queryhelper.NewQueryHelper(tt.opt, queryhelper.WithSubqueryWrapping())
+			var rows []testListRow
+			err := dq.Execute(settings, db.Raw(unionSQL), &rows)
+			if err == nil || !strings.Contains(err.Error(), "unknown field company_id") {
+				t.Errorf("err = %v, want unknown field company_id", err)
+			}
+		})
+	}
+}
+
+func TestSubqueryWrappingBuiltQuery(t *testing.T) {
+
+	db := customersDB(t)
+
+	// Clauses of the base query are applied inside the derived table only
+	base := db.Model(&testOrder{}).Select("customer_id, SUM(amount) AS total").Group("customer_id").Having("COUNT(*) = ?", 2)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters:  map[string][]string{"total": {">="}},
+		AllowedOrderBy:  []string{"customer_id"},
+		SubqueryColumns: []string{"customer_id", "total"},
+	}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithFilter("total", ">=", 150), queryhelper.WithOrderBy([]string{"customer_id"}), queryhelper.WithSubqueryWrapping())
+	var rows []struct {
+		CustomerID uint
+		Total      int
+	}
+	if err := dq.Execute(settings, base, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	// Customers with two orders are odd, totalling 20 * i + 1
+	want := []uint{9, 11}
+	var got []uint
+	for _, row := range rows {
+		got = append(got, row.CustomerID)
+	}
+	if !reflect.DeepEqual(got, want) || dq.Info().Pagination.Total != 2 {
+		t.Errorf("got %v of %d, want %v of 2", got, dq.Info().Pagination.Total, want)
+	}
+}
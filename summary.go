@@ -0,0 +1,97 @@
+package queryhelper
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WithSummaries requests the named QuerySettings.Summaries, computed by
+// Execute over every matching row.
+func WithSummaries(names []string) Option {
+	return func(dq *QueryHelper) {
+		dq.queryConditions.RequestedSummaries = names
+	}
+}
+
+// normalizeSummaries drops requested summaries the settings do not define,
+// and repeated ones.
+func (ch *ConditionsHandle) normalizeSummaries(names []string) []string {
+
+	summaries := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := ch.Settings.Summaries[name]; !ok || containsString(summaries, name) {
+			if !ok {
+				ch.drop(DroppedItem{Kind: DroppedSummary, Code: WarningSummaryNotDefined, Field: name, Reason: "summary is not defined"})
+			}
+			continue
+		}
+
+		summaries = append(summaries, name)
+	}
+
+	return summaries
+}
+
+// summarize computes the named aggregates over every row the conditioned
+// query matches. Grouped and distinct queries are aggregated over their
+// result rows.
+func summarize(query *gorm.DB, aggregates map[string]string, names []string) (map[string]interface{}, error) {
+
+	// Aliases the database cannot misread, mapped back to names after
+	exprs := make([]string, len(names))
+	for i, name := range names {
+		exprs[i] = aggregates[name] + " AS qh_summary_" + strconv.Itoa(i)
+	}
+	sel := clause.Select{Expression: clause.Expr{SQL: strings.Join(exprs, ", ")}}
+
+	row := make(map[string]interface{}, len(names))
+	if err := aggregateBase(query).Clauses(sel).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		value := row["qh_summary_"+strconv.Itoa(i)]
+
+		// Rows scanned for a model hold pointers to the values
+		for v := reflect.ValueOf(value); v.Kind() == reflect.Ptr; v = reflect.ValueOf(value) {
+			value = nil
+			if !v.IsNil() {
+				value = v.Elem().Interface()
+			}
+		}
+
+		// Drivers return decimals as text
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+
+		summaries[name] = value
+	}
+
+	return summaries, nil
+}
+
+// aggregateBase returns the conditioned query ready for a new select list,
+// without ordering. Grouped and distinct queries are wrapped as a subquery
+// aliased qh_rows.
+func aggregateBase(query *gorm.DB) *gorm.DB {
+
+	// Scopes forces a copy of the statement before it is modified
+	base := withoutPreloads(query).Session(&gorm.Session{}).Scopes()
+	delete(base.Statement.Clauses, "ORDER BY")
+
+	_, grouped := base.Statement.Clauses["GROUP BY"]
+	if grouped || base.Statement.Distinct {
+		return query.Session(&gorm.Session{NewDB: true}).Table("(?) AS qh_rows", base)
+	}
+
+	base.Statement.Selects = nil
+	base.Statement.Omits = nil
+
+	return base
+}
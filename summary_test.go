@@ -0,0 +1,137 @@
+package queryhelper_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+func summarySettings() *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"amount": {">="}, "customer_id": {"<="}},
+		AllowedOrderBy: []string{"id"},
+		Summaries: map[string]string{
+			"total":   "SUM(amount)",
+			"average": "AVG(amount)",
+			"largest": "MAX(amount)",
+			"rows":    "COUNT(*)",
+		},
+	}
+}
+
+// seededOrders returns the amounts customersDB gives orders, by customer.
+func seededOrders() map[int][]int {
+
+	orders := make(map[int][]int)
+	for i := 1; i <= 12; i++ {
+		for j := 0; j <= i%2; j++ {
+			orders[i] = append(orders[i], i*10+j)
+		}
+	}
+
+	return orders
+}
+
+func TestSummaries(t *testing.T) {
+
+	db := customersDB(t)
+
+	// Orders of customers up to 8 of at least 50, summed by hand
+	var total, largest, rows int
+	for customer, amounts := range seededOrders() {
+		for _, amount := range amounts {
+			if customer <= 8 && amount >= 50 {
+				total += amount
+				rows++
+				if amount > largest {
+					largest = amount
+				}
+			}
+		}
+	}
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithFilter("amount", ">=", 50),
+		queryhelper.WithFilter("customer_id", "<=", 8),
+		queryhelper.WithSummaries([]string{"total", "average", "largest", "rows", "total", "median"}),
+		queryhelper.WithPageSize(2),
+	)
+
+	var orders []testOrder
+	if err := dq.Execute(summarySettings(), db.Model(&testOrder{}), &orders); err != nil {
+		t.Fatal(err)
+	}
+
+	// The page is two rows, the summaries cover every matching one
+	if len(orders) != 2 {
+		t.Errorf("got %d rows, want 2", len(orders))
+	}
+
+	info := dq.Info()
+	want := map[string]string{
+		"total":   fmt.Sprint(total),
+		"average": fmt.Sprint(float64(total) / float64(rows)),
+		"largest": fmt.Sprint(largest),
+		"rows":    fmt.Sprint(rows),
+	}
+	if len(info.Summaries) != len(want) {
+		t.Errorf("summaries %v, want %v", info.Summaries, want)
+	}
+	for name, value := range want {
+		if got := fmt.Sprint(info.Summaries[name]); got != value {
+			t.Errorf("%s = %s, want %s", name, got, value)
+		}
+	}
+
+	if len(info.Warnings) != 1 || info.Warnings[0].Code != queryhelper.WarningSummaryNotDefined || info.Warnings[0].Field != "median" {
+		t.Errorf("warnings %+v, want median not defined", info.Warnings)
+	}
+}
+
+func TestSummariesOverGroupedRows(t *testing.T) {
+
+	db := customersDB(t)
+
+	// Customers with two orders, summed per customer and then over them
+	var total, customers int
+	for _, amounts := range seededOrders() {
+		if len(amounts) == 2 {
+			total += amounts[0] + amounts[1]
+			customers++
+		}
+	}
+
+	base := db.Model(&testOrder{}).
+		Select("customer_id, SUM(amount) AS amount").
+		Group("customer_id").
+		Having("COUNT(*) = ?", 2)
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithSummaries([]string{"total", "rows"}), queryhelper.WithPageSize(2))
+
+	var rows []map[string]interface{}
+	if err := dq.Execute(&queryhelper.QuerySettings{Summaries: summarySettings().Summaries}, base, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries := dq.Info().Summaries
+	if fmt.Sprint(summaries["total"]) != fmt.Sprint(total) || fmt.Sprint(summaries["rows"]) != fmt.Sprint(customers) {
+		t.Errorf("summaries %v, want total %d over %d rows", summaries, total, customers)
+	}
+}
+
+func TestSummariesNotRequested(t *testing.T) {
+
+	db := customersDB(t)
+	statements := executedQueries(t, db)
+
+	dq := queryhelper.NewQueryHelper()
+	var orders []testOrder
+	if err := dq.Execute(summarySettings(), db.Model(&testOrder{}), &orders); err != nil {
+		t.Fatal(err)
+	}
+
+	if dq.Info().Summaries != nil || len(*statements) != 2 {
+		t.Errorf("summaries %v after %d statements, want none after count and find", dq.Info().Summaries, len(*statements))
+	}
+}
@@ -0,0 +1,183 @@
+package queryhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// withTimeout runs fc with the query's context limited to timeout, and
+// reports a statement cut short by it as a QueryTimeoutError.
+func withTimeout(query *gorm.DB, timeout time.Duration, fc func(query *gorm.DB) error) error {
+
+	if timeout <= 0 {
+		return fc(query)
+	}
+
+	parent := query.Statement.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	err := fc(query.WithContext(ctx))
+
+	var timeoutErr *QueryTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return err
+	}
+
+	// Drivers report cancellation in their own words, so check the context
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded) {
+		return &QueryTimeoutError{Timeout: timeout, Err: err}
+	}
+
+	return err
+}
+
+// applyStatementTimeout asks the database to enforce the timeout too: a
+// MAX_EXECUTION_TIME hint on MySQL, and SET LOCAL statement_timeout on
+// Postgres when the query runs in a transaction, where it lasts until the
+// transaction ends.
+func applyStatementTimeout(query *gorm.DB, timeout time.Duration) (*gorm.DB, error) {
+
+	ms := timeout.Milliseconds()
+	if ms <= 0 {
+		return query, nil
+	}
+
+	switch dialectName(query) {
+	case "mysql":
+		return query.Clauses(maxExecutionTimeHint{ms: ms}), nil
+	case "postgres":
+		if _, ok := query.Statement.ConnPool.(gorm.TxCommitter); !ok {
+			return query, nil
+		}
+
+		err := query.Session(&gorm.Session{NewDB: true}).Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", ms)).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
+
+// maxExecutionTimeHint adds /*+ MAX_EXECUTION_TIME(ms) */ after SELECT, for
+// the count and the data query alike.
+type maxExecutionTimeHint struct {
+	ms int64
+}
+
+func (h maxExecutionTimeHint) ModifyStatement(stmt *gorm.Statement) {
+
+	sel := stmt.Clauses["SELECT"]
+	sel.AfterNameExpression = h
+	stmt.Clauses["SELECT"] = sel
+}
+
+func (h maxExecutionTimeHint) Build(builder clause.Builder) {
+	builder.WriteString(fmt.Sprintf("/*+ MAX_EXECUTION_TIME(%d) */", h.ms))
+}
+
+// Execute applies the conditions and pagination and finds the page into
+// dest. With QuerySettings.QueryTimeout set, the count and the find share
+// one deadline; with a RetryPolicy, transient failures of either are
+// retried. With a ResultCache, identical pages are served from the cache.
+// Requested summaries and the histogram are computed over every matching row
+// after the page is found.
+func (dq *QueryHelper) Execute(settings *QuerySettings, query *gorm.DB, dest interface{}) error {
+
+	if query == nil {
+		return errors.New("query is nil")
+	}
+
+	if settings == nil && dq.settingsProvider != nil {
+		settings = dq.settingsProvider.Current()
+	}
+
+	var (
+		timeout time.Duration
+		policy  *RetryPolicy
+	)
+	if settings != nil {
+		timeout = settings.QueryTimeout
+		policy = settings.RetryPolicy.forDest(dest)
+	}
+
+	start := time.Now()
+
+	// Serve identical pages from the result cache
+	var (
+		cache ResultCache
+		key   string
+	)
+	if settings != nil && settings.ResultCache != nil {
+		if dqh, k, ok := dq.resultCacheKey(settings, query, dest); ok {
+			if dq.cachedResult(settings.ResultCache, k, dqh, dest) {
+				if dq.auditSink != nil {
+					dq.audit(settings, query, start, nil, dest)
+				}
+				return nil
+			}
+			cache, key = settings.ResultCache, k
+		}
+	}
+
+	err := withTimeout(query, timeout, func(query *gorm.DB) error {
+
+		q, err := dq.apply(settings, query)
+		if err != nil {
+			return err
+		}
+
+		err = policy.run(q, &dq.retries, func(q *gorm.DB) error {
+			return q.Find(dest).Error
+		})
+		if err == nil {
+			err = dq.SortPage(dest)
+		}
+		if err != nil || dq.aggregateQuery == nil {
+			return err
+		}
+
+		conditions := dq.conditions.Conditions
+
+		if len(conditions.RequestedSummaries) > 0 {
+			err = settings.RetryPolicy.run(dq.aggregateQuery, &dq.retries, func(q *gorm.DB) error {
+				summaries, err := summarize(q, settings.Summaries, conditions.RequestedSummaries)
+				dq.summaries = summaries
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if conditions.Histogram != nil {
+			return settings.RetryPolicy.run(dq.aggregateQuery, &dq.retries, func(q *gorm.DB) error {
+				buckets, err := histogram(q, conditions.Histogram, settings.SQLiteTimeFormat)
+				dq.histogram = buckets
+				return err
+			})
+		}
+
+		return nil
+	})
+
+	if err == nil && cache != nil {
+		dq.cacheResult(cache, key, settings.ResultCacheTTL, dest)
+	}
+
+	if dq.auditSink != nil {
+		dq.audit(settings, query, start, err, dest)
+	}
+
+	return err
+}
@@ -0,0 +1,191 @@
+package queryhelper_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+	"github.com/weedbox/queryhelper/queryhelpertest"
+	"gorm.io/gorm"
+)
+
+// slowQueries delays the count by countDelay and the find by findDelay on
+// db, returning early with the context's error when it ends first, as
+// drivers do.
+func slowQueries(t *testing.T, db *gorm.DB, countDelay, findDelay time.Duration) {
+
+	t.Helper()
+
+	err := db.Callback().Query().Before("gorm:query").Register("test:slow", func(tx *gorm.DB) {
+
+		delay := findDelay
+		if _, ok := tx.Statement.Dest.(*int64); ok {
+			delay = countDelay
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-tx.Statement.Context.Done():
+			tx.AddError(tx.Statement.Context.Err())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func timeoutSettings(timeout time.Duration) *queryhelper.QuerySettings {
+	return &queryhelper.QuerySettings{
+		AllowedOrderBy: []string{"id"},
+		QueryTimeout:   timeout,
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		countDelay time.Duration
+		findDelay  time.Duration
+		wantErr    bool
+	}{
+		{name: "within the deadline"},
+		{name: "slow count", countDelay: time.Second, wantErr: true},
+		{name: "slow find", findDelay: time.Second, wantErr: true},
+		// Neither statement alone exceeds the timeout, both together do
+		{name: "shared deadline", countDelay: 60 * time.Millisecond, findDelay: 60 * time.Millisecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := customersDB(t)
+			slowQueries(t, db, tt.countDelay, tt.findDelay)
+
+			start := time.Now()
+
+			var customers []testCustomer
+			err := queryhelper.NewQueryHelper().Execute(timeoutSettings(100*time.Millisecond), db.Model(&testCustomer{}), &customers)
+
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("returned after %s", elapsed)
+			}
+
+			if !tt.wantErr {
+				if err != nil || len(customers) != 10 {
+					t.Errorf("got %d rows, err = %v", len(customers), err)
+				}
+				return
+			}
+
+			if !errors.Is(err, queryhelper.ErrQueryTimeout) {
+				t.Fatalf("err = %v, want ErrQueryTimeout", err)
+			}
+
+			var terr *queryhelper.QueryTimeoutError
+			if !errors.As(err, &terr) || terr.Timeout != 100*time.Millisecond {
+				t.Errorf("err = %#v, want a QueryTimeoutError of 100ms", err)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want it to wrap the driver's error", err)
+			}
+		})
+	}
+}
+
+func TestQueryTimeoutKeepsCallerDeadline(t *testing.T) {
+
+	db := customersDB(t)
+	slowQueries(t, db, 0, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var customers []testCustomer
+	err := queryhelper.NewQueryHelper().Execute(timeoutSettings(time.Minute), db.WithContext(ctx).Model(&testCustomer{}), &customers)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the caller's deadline", err)
+	}
+}
+
+func TestQueryTimeoutMySQLHint(t *testing.T) {
+
+	db := queryhelpertest.DryRunDB(t, "mysql")
+	statements := recordQueries(t, db)
+
+	query, err := queryhelper.NewQueryHelper().Apply(timeoutSettings(1500*time.Millisecond), db.Model(&testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCount := "SELECT /*+ MAX_EXECUTION_TIME(1500) */ count(*) FROM `users`"
+	if len(*statements) != 1 || (*statements)[0] != wantCount {
+		t.Errorf("count %q, want %q", *statements, wantCount)
+	}
+
+	want := "SELECT /*+ MAX_EXECUTION_TIME(1500) */ * FROM `users` ORDER BY `id` LIMIT 10"
+	if got := findSQL(t, query); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// dryRunTx stands in for a transaction on a dry run database.
+type dryRunTx struct {
+	gorm.ConnPool
+}
+
+func (dryRunTx) Commit() error {
+	return nil
+}
+
+func (dryRunTx) Rollback() error {
+	return nil
+}
+
+func TestQueryTimeoutPostgresSetLocal(t *testing.T) {
+
+	tests := []struct {
+		name string
+		tx   bool
+		want []string
+	}{
+		{name: "outside a transaction"},
+		{name: "in a transaction", tx: true, want: []string{"SET LOCAL statement_timeout = 2000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := queryhelpertest.DryRunDB(t, "postgres")
+
+			var statements []string
+			err := db.Callback().Raw().After("gorm:raw").Register("test:record", func(tx *gorm.DB) {
+				statements = append(statements, tx.Statement.SQL.String())
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			query := db.Model(&testUser{})
+			if tt.tx {
+				query.Statement.ConnPool = dryRunTx{ConnPool: &sql.DB{}}
+			}
+
+			q, err := queryhelper.NewQueryHelper().Apply(timeoutSettings(2*time.Second), query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(statements, ";") != strings.Join(tt.want, ";") {
+				t.Errorf("ran %q, want %q", statements, tt.want)
+			}
+			if got := findSQL(t, q); strings.Contains(got, "MAX_EXECUTION_TIME") {
+				t.Errorf("postgres got a MySQL hint:\n%s", got)
+			}
+		})
+	}
+}
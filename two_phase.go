@@ -0,0 +1,216 @@
+package queryhelper
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PaginationOffset   = "offset"
+	PaginationTwoPhase = "two_phase"
+)
+
+// twoPhaseKeys is the key order of a two-phase page, for sorting its rows.
+type twoPhaseKeys struct {
+	db     *gorm.DB
+	column string
+	index  map[string]int // key as text -> position
+}
+
+// applyTwoPhase runs the paginated query selecting only the primary key and
+// returns a fresh query for the full rows of those keys, with the keys in
+// page order. The rows query is not ordered; the rows it finds are sorted in
+// Go. Only the model, table, sparse select and preloads carry over to the
+// returned query, so joins for display are added by the caller.
+func applyTwoPhase(query *gorm.DB, settings *QuerySettings, fields []string, retries *int) (*gorm.DB, *twoPhaseKeys, error) {
+
+	pk, err := primaryKeyColumn(query, settings.PrimaryKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Joined tables may have a column of the same name
+	column := clause.Column{Table: clause.CurrentTable, Name: pk}
+
+	// Phase one: keys of the page, with all conditions, ordering and limits
+	var ids []interface{}
+	err = withTimeout(withoutPreloads(query), settings.QueryTimeout, func(q *gorm.DB) error {
+		return settings.RetryPolicy.run(q, retries, func(q *gorm.DB) error {
+			ids = nil
+			tx := q.Session(&gorm.Session{}).Clauses(clause.Select{Columns: []clause.Column{column}})
+			tx.Statement.Selects = nil
+			return tx.Pluck(pk, &ids).Error
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Phase two: full rows by key
+	rows := query.Session(&gorm.Session{NewDB: true})
+	if query.Statement.Model != nil {
+		rows = rows.Model(query.Statement.Model)
+	}
+	if query.Statement.Table != "" {
+		rows = rows.Table(query.Statement.Table)
+	}
+	if len(fields) > 0 {
+		rows = rows.Select(fields)
+	}
+	rows = copyPreloads(rows, query)
+	rows = rows.Where(clause.IN{Column: column, Values: ids})
+
+	keys := &twoPhaseKeys{db: query, column: pk, index: make(map[string]int, len(ids))}
+	for i, id := range ids {
+		keys.index[keyText(id)] = i
+	}
+
+	return rows, keys, nil
+}
+
+// SortPage puts the rows found with the query Apply returned for a
+// two-phase page, dest being a pointer to a slice of models or maps, in the
+// order of the page's keys. Execute sorts them itself. Without a two-phase
+// page dest is left as is.
+func (dq *QueryHelper) SortPage(dest interface{}) error {
+
+	if dq.twoPhase == nil {
+		return nil
+	}
+
+	return dq.twoPhase.sort(dest)
+}
+
+func (k *twoPhaseKeys) sort(dest interface{}) error {
+
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return errors.New("dest must be a pointer to a slice")
+	}
+	slice := destValue.Elem()
+
+	if slice.Len() < 2 {
+		return nil
+	}
+
+	key, err := k.rowKey(slice.Type().Elem(), dest)
+	if err != nil {
+		return err
+	}
+
+	positions := make([]int, slice.Len())
+	for i := range positions {
+		p, ok := k.index[keyText(key(slice.Index(i)))]
+		if !ok {
+			p = len(k.index)
+		}
+		positions[i] = p
+	}
+
+	sorted := reflect.MakeSlice(slice.Type(), slice.Len(), slice.Len())
+	order := make([]int, slice.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return positions[order[a]] < positions[order[b]]
+	})
+	for i, j := range order {
+		sorted.Index(i).Set(slice.Index(j))
+	}
+	slice.Set(sorted)
+
+	return nil
+}
+
+// rowKey returns a function reading the primary key of rows of type elem,
+// maps or structs and pointers to them.
+func (k *twoPhaseKeys) rowKey(elem reflect.Type, dest interface{}) (func(row reflect.Value) interface{}, error) {
+
+	if elem.Kind() == reflect.Map && elem.Key().Kind() == reflect.String {
+		return func(row reflect.Value) interface{} {
+			v := row.MapIndex(reflect.ValueOf(k.column).Convert(elem.Key()))
+			if !v.IsValid() {
+				return nil
+			}
+			return v.Interface()
+		}, nil
+	}
+
+	stmt := k.db.Session(&gorm.Session{NewDB: true}).Statement
+	if err := stmt.Parse(dest); err != nil {
+		return nil, err
+	}
+
+	field := stmt.Schema.LookUpField(k.column)
+	if field == nil {
+		return nil, fmt.Errorf("primary key %s is not a field of %s", k.column, stmt.Schema.Name)
+	}
+
+	ctx := k.db.Statement.Context
+	return func(row reflect.Value) interface{} {
+		row = reflect.Indirect(row)
+		if !row.IsValid() {
+			return nil
+		}
+		v, _ := field.ValueOf(ctx, row)
+		return v
+	}, nil
+}
+
+// keyText renders a key the same for the driver's value and the field's.
+func keyText(v interface{}) string {
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			v = value
+		}
+	}
+
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+
+	return fmt.Sprint(rv.Interface())
+}
+
+// primaryKeyColumn returns the configured primary key or, when it is empty,
+// the query model's.
+func primaryKeyColumn(query *gorm.DB, configured string) (string, error) {
+
+	if configured != "" {
+		return configured, nil
+	}
+
+	stmt := query.Statement
+	if stmt.Model == nil {
+		return "", errors.New("primary key unknown: set QuerySettings.PrimaryKey or use a model")
+	}
+
+	if err := stmt.Parse(stmt.Model); err != nil {
+		return "", err
+	}
+
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return "", errors.New("primary key unknown: model has no single primary key")
+	}
+
+	return stmt.Schema.PrioritizedPrimaryField.DBName, nil
+}
@@ -0,0 +1,147 @@
+package queryhelper_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+	"gorm.io/gorm"
+)
+
+func TestTwoPhaseMatchesSinglePhase(t *testing.T) {
+
+	db := customersDB(t)
+
+	settings := func(strategy string) *queryhelper.QuerySettings {
+		return &queryhelper.QuerySettings{
+			AllowedOrderBy:     []string{"company.name", "name"},
+			AllowedFilters:     map[string][]string{"company.name": {"!="}},
+			Relations:          map[string]*queryhelper.Relation{"company": {Table: "companies", ForeignKey: "company_id"}},
+			PaginationStrategy: strategy,
+		}
+	}
+
+	find := func(strategy string, page int) ([]testCustomer, *queryhelper.PaginationInfo) {
+
+		dq := queryhelper.NewQueryHelper(
+			queryhelper.WithFilter("company.name", "!=", "initech"),
+			queryhelper.WithOrderBy([]string{"-company.name", "name"}),
+			queryhelper.WithPage(page),
+			queryhelper.WithPageSize(3),
+		)
+
+		var customers []testCustomer
+		query := db.Model(&testCustomer{}).Preload("Company").Preload("Orders")
+		if err := dq.Execute(settings(strategy), query, &customers); err != nil {
+			t.Fatalf("%s page %d: %v", strategy, page, err)
+		}
+
+		return customers, dq.Info().Pagination
+	}
+
+	for page := 1; page <= 3; page++ {
+		single, singleInfo := find(queryhelper.PaginationOffset, page)
+		twoPhase, twoPhaseInfo := find(queryhelper.PaginationTwoPhase, page)
+
+		if !reflect.DeepEqual(twoPhase, single) {
+			t.Errorf("page %d: two-phase found\n%+v\nsingle-phase found\n%+v", page, twoPhase, single)
+		}
+		if *twoPhaseInfo != *singleInfo {
+			t.Errorf("page %d: two-phase pagination %+v, single-phase %+v", page, twoPhaseInfo, singleInfo)
+		}
+	}
+
+	// The first page holds globex's customers by name, preloaded
+	first, _ := find(queryhelper.PaginationTwoPhase, 1)
+	var names []string
+	for _, c := range first {
+		names = append(names, c.Company.Name+"/"+c.Name)
+		if len(c.Orders) == 0 {
+			t.Errorf("%s has no orders preloaded", c.Name)
+		}
+	}
+	if got, want := strings.Join(names, ","), "globex/customer 03,globex/customer 06,globex/customer 09"; got != want {
+		t.Errorf("first page %s, want %s", got, want)
+	}
+}
+
+func TestTwoPhaseSQL(t *testing.T) {
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy:     []string{"company.name"},
+		Relations:          map[string]*queryhelper.Relation{"company": {Table: "companies", ForeignKey: "company_id"}},
+		PaginationStrategy: queryhelper.PaginationTwoPhase,
+	}
+
+	var statements []string
+	db := customersDB(t)
+	db.Callback().Query().After("gorm:query").Register("record", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	})
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithOrderBy([]string{"company.name"}))
+	query, err := dq.Apply(settings, db.Model(&testCustomer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is qualified, as the joined table has an id as well
+	want := "SELECT `customers`.`id` FROM `customers` LEFT JOIN `companies` `company` ON `company`.`id` = `customers`.`company_id` ORDER BY `company`.`name` LIMIT 10"
+	if len(statements) != 2 || statements[1] != want {
+		t.Errorf("statements %q, want the count and\n%s", statements, want)
+	}
+
+	// The rows are sorted in Go, not by the database
+	statements = nil
+	var customers []testCustomer
+	if err := query.Find(&customers).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 1 || strings.Contains(statements[0], "ORDER BY") || strings.Contains(statements[0], "CASE") {
+		t.Errorf("rows query is ordered: %q", statements)
+	}
+}
+
+func TestSortPage(t *testing.T) {
+
+	db := customersDB(t)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedOrderBy:     []string{"name"},
+		PaginationStrategy: queryhelper.PaginationTwoPhase,
+	}
+
+	dq := queryhelper.NewQueryHelper(queryhelper.WithOrderBy([]string{"name"}), queryhelper.WithPageSize(4))
+	query, err := dq.Apply(settings, db.Model(&testCustomer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Found by id, the reverse of the page's order
+	var customers []testCustomer
+	if err := query.Order("id").Find(&customers).Error; err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	if err := query.Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dq.SortPage(&customers); err != nil {
+		t.Fatal(err)
+	}
+	if err := dq.SortPage(&rows); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"customer 01", "customer 02", "customer 03", "customer 04"}
+	for i, name := range want {
+		if customers[i].Name != name {
+			t.Errorf("model %d is %s, want %s", i, customers[i].Name, name)
+		}
+		if rows[i]["name"] != name {
+			t.Errorf("map %d is %v, want %s", i, rows[i]["name"], name)
+		}
+	}
+}
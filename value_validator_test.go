@@ -0,0 +1,157 @@
+package queryhelper_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weedbox/queryhelper"
+)
+
+func TestValueValidators(t *testing.T) {
+
+	// received records what the validators were given, to check it was coerced
+	var received []interface{}
+
+	maxRange := func(v interface{}) error {
+
+		received = append(received, v)
+
+		bounds, ok := v.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return fmt.Errorf("want two bounds, got %#v", v)
+		}
+		from, ok := bounds[0].(time.Time)
+		if !ok {
+			return fmt.Errorf("lower bound is %T", bounds[0])
+		}
+		to, ok := bounds[1].(time.Time)
+		if !ok {
+			return fmt.Errorf("upper bound is %T", bounds[1])
+		}
+		if to.Sub(from) > 31*24*time.Hour {
+			return errors.New("range exceeds 31 days")
+		}
+
+		return nil
+	}
+
+	noLeadingWildcard := func(v interface{}) error {
+
+		received = append(received, v)
+
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("pattern is %T", v)
+		}
+		if strings.HasPrefix(s, "%") || strings.HasPrefix(s, "_") {
+			return errors.New("pattern must not start with a wildcard")
+		}
+
+		return nil
+	}
+
+	anyTime := func(v interface{}) error {
+
+		received = append(received, v)
+
+		if _, ok := v.(time.Time); !ok {
+			if _, ok := v.([]interface{}); !ok {
+				return fmt.Errorf("value is %T", v)
+			}
+		}
+
+		return nil
+	}
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{
+			"created_at": {">=", "BETWEEN"},
+			"name":       {"LIKE"},
+		},
+		FieldTypes: map[string]string{"created_at": queryhelper.FieldTypeTime},
+		ValueValidators: map[string]map[string]func(v interface{}) error{
+			"created_at": {"BETWEEN": maxRange, "*": anyTime},
+			"name":       {"LIKE": noLeadingWildcard},
+		},
+	}
+
+	jan1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		filter   queryhelper.FilterCondition
+		want     string // the failure, empty when the filter passes
+		received []interface{}
+	}{
+		{
+			name:     "range within 31 days",
+			filter:   queryhelper.FilterCondition{Field: "created_at", Operator: "BETWEEN", Value: []interface{}{"2024-01-01T00:00:00Z", "2024-01-31T00:00:00Z"}},
+			received: []interface{}{[]interface{}{jan1, jan1.AddDate(0, 0, 30)}, []interface{}{jan1, jan1.AddDate(0, 0, 30)}},
+		},
+		{
+			name:     "range over 31 days",
+			filter:   queryhelper.FilterCondition{Field: "created_at", Operator: "BETWEEN", Value: []interface{}{"2024-01-01T00:00:00Z", "2024-03-01T00:00:00Z"}},
+			want:     "range exceeds 31 days",
+			received: []interface{}{[]interface{}{jan1, jan1.AddDate(0, 2, 0)}},
+		},
+		{
+			name:     "any operator",
+			filter:   queryhelper.FilterCondition{Field: "created_at", Operator: ">=", Value: "2024-01-01T00:00:00Z"},
+			received: []interface{}{jan1},
+		},
+		{
+			name:     "prefix pattern",
+			filter:   queryhelper.FilterCondition{Field: "name", Operator: "LIKE", Value: "ann%"},
+			received: []interface{}{"ann%"},
+		},
+		{
+			name:     "leading percent",
+			filter:   queryhelper.FilterCondition{Field: "name", Operator: "LIKE", Value: "%ann"},
+			want:     "pattern must not start with a wildcard",
+			received: []interface{}{"%ann"},
+		},
+		{
+			name:     "leading underscore",
+			filter:   queryhelper.FilterCondition{Field: "name", Operator: "LIKE", Value: "_nn"},
+			want:     "pattern must not start with a wildcard",
+			received: []interface{}{"_nn"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			received = nil
+
+			err := queryhelper.NewConditionsHandle(settings).UpdateConditions(&queryhelper.QueryConditions{
+				Filters: []queryhelper.FilterCondition{tt.filter},
+			})
+
+			if fmt.Sprintf("%#v", received) != fmt.Sprintf("%#v", tt.received) {
+				t.Errorf("validators received %#v, want %#v", received, tt.received)
+			}
+
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var verr *queryhelper.ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, queryhelper.ErrInvalidConditions) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+			if len(verr.Errors) != 1 {
+				t.Fatalf("got %d filter errors, want 1", len(verr.Errors))
+			}
+			fe := verr.Errors[0]
+			if fe.Field != tt.filter.Field || fe.Operator != tt.filter.Operator || fe.Err.Error() != tt.want {
+				t.Errorf("got %s %s: %v, want %s %s: %s", fe.Field, fe.Operator, fe.Err, tt.filter.Field, tt.filter.Operator, tt.want)
+			}
+		})
+	}
+}
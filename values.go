@@ -0,0 +1,406 @@
+package queryhelper
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseValues reads query parameters in the bracketed convention:
+//
+//	page=2&page_size=20&search=chair&search_fields=name,sku
+//	&order_by=-created_at,name&sort_factor=-1&fields=id,name
+//	&filter[status]=active&filter[price][gte]=100&filter[id][in]=1,2,3
+//	&include=comments&comments.filter[status]=visible&summaries=total_value
+//	&histogram[field]=created_at&histogram[interval]=day&histogram[time_zone]=Europe/Paris
+//	&locale=de-CH
+//	&group[0][logic]=or&group[0][filter][0][status]=new&group[0][filter][1][status]=open
+//
+// offset and limit may be given instead of page and page_size, and
+// count_mode picks how the total is found.
+// Filter operators are named by OperatorTokens or OperatorAliases, in any
+// letter case; a filter without an operator uses "=". List operators accept
+// comma separated or repeated values; a backslash escapes a comma or a
+// backslash in an item. Filter groups are numbered, with their filters, and
+// nest as group[0][group][1].
+// Allow-lists are not checked here but when the conditions are applied.
+func ParseValues(values url.Values) (*QueryConditions, *PaginationRequest, error) {
+
+	conditions := &QueryConditions{}
+	pagination := &PaginationRequest{}
+
+	var err error
+
+	if pagination.Page, err = intValue(values, "page"); err != nil {
+		return nil, nil, err
+	}
+
+	if pagination.PageSize, err = intValue(values, "page_size"); err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range []string{"offset", "limit"} {
+		if values.Get(key) == "" {
+			continue
+		}
+
+		n, err := intValue(values, key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if key == "offset" {
+			pagination.Offset = &n
+		} else {
+			pagination.Limit = &n
+		}
+	}
+
+	if conditions.SortFactor, err = intValue(values, "sort_factor"); err != nil {
+		return nil, nil, err
+	}
+
+	conditions.SearchText = values.Get("search")
+	conditions.Locale = values.Get("locale")
+	pagination.CountMode = values.Get("count_mode")
+	conditions.SearchFields = listValue(values["search_fields"])
+	conditions.OrderBy = listValue(values["order_by"])
+	conditions.Fields = listValue(values["fields"])
+	conditions.Includes = listValue(values["include"])
+	conditions.RequestedSummaries = listValue(values["summaries"])
+
+	if field := values.Get("histogram[field]"); field != "" {
+		conditions.Histogram = &DateHistogram{
+			Field:    field,
+			Interval: values.Get("histogram[interval]"),
+			TimeZone: values.Get("histogram[time_zone]"),
+		}
+
+		if fill := values.Get("histogram[fill_empty]"); fill != "" {
+			if conditions.Histogram.FillEmpty, err = strconv.ParseBool(fill); err != nil {
+				return nil, nil, fmt.Errorf("%w: histogram[fill_empty] must be a boolean", ErrInvalidConditions)
+			}
+		}
+	}
+
+	// Filters in a stable order
+	keys := make([]string, 0)
+	for key := range values {
+		if strings.HasPrefix(key, "filter[") || strings.Contains(key, ".filter[") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var filterErrors []*FilterError
+	for _, key := range keys {
+		// Filters on an included relation are prefixed with its name
+		include, param, _ := strings.Cut(key, ".filter[")
+		if param == "" {
+			include, param = "", key
+		} else {
+			param = "filter[" + param
+		}
+
+		filter, ferr := parseFilterParam(param, values[key])
+		if ferr != nil {
+			if include != "" {
+				ferr.Field = include + "." + ferr.Field
+			}
+			filterErrors = append(filterErrors, ferr)
+			continue
+		}
+
+		if include == "" {
+			conditions.Filters = append(conditions.Filters, filter)
+			continue
+		}
+
+		if conditions.IncludeFilters == nil {
+			conditions.IncludeFilters = make(map[string][]FilterCondition)
+		}
+		conditions.IncludeFilters[include] = append(conditions.IncludeFilters[include], filter)
+	}
+
+	groups, err := parseGroups(values, &filterErrors)
+	if err != nil {
+		return nil, nil, err
+	}
+	conditions.FilterGroups = groups
+
+	if len(filterErrors) > 0 {
+		return nil, nil, &ValidationError{Errors: filterErrors}
+	}
+
+	return conditions, pagination, nil
+}
+
+// groupNode collects the parameters of one filter group by index.
+type groupNode struct {
+	group   FilterGroup
+	filters map[int]FilterCondition
+	groups  map[int]*groupNode
+}
+
+func newGroupNode() *groupNode {
+	return &groupNode{filters: make(map[int]FilterCondition), groups: make(map[int]*groupNode)}
+}
+
+// parseGroups reads the group[...] parameters, ordered by their indexes.
+func parseGroups(values url.Values, filterErrors *[]*FilterError) ([]FilterGroup, error) {
+
+	root := newGroupNode()
+
+	// Parameters in a stable order, so are filter errors
+	keys := make([]string, 0)
+	for key := range values {
+		if strings.HasPrefix(key, "group[") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		raw := values[key]
+
+		segments, ok := bracketSegments(strings.TrimPrefix(key, "group"))
+		if !ok || len(segments) < 2 {
+			return nil, fmt.Errorf("%w: malformed parameter %s", ErrInvalidConditions, key)
+		}
+
+		// Walk group[i][group][j]... down to the group the parameter sets
+		node := root
+		for {
+			i, err := strconv.Atoi(segments[0])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("%w: malformed parameter %s", ErrInvalidConditions, key)
+			}
+
+			child, ok := node.groups[i]
+			if !ok {
+				child = newGroupNode()
+				node.groups[i] = child
+			}
+			node, segments = child, segments[1:]
+
+			if len(segments) < 2 || segments[0] != "group" {
+				break
+			}
+			segments = segments[1:]
+		}
+
+		switch {
+		case len(segments) == 1 && segments[0] == "logic":
+			node.group.Logic = strings.TrimSpace(firstValue(raw))
+		case len(segments) == 1 && segments[0] == "not":
+			not, err := strconv.ParseBool(firstValue(raw))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s must be a boolean", ErrInvalidConditions, key)
+			}
+			node.group.Not = not
+		case (len(segments) == 3 || len(segments) == 4) && segments[0] == "filter":
+			i, err := strconv.Atoi(segments[1])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("%w: malformed parameter %s", ErrInvalidConditions, key)
+			}
+
+			param := "filter[" + strings.Join(segments[2:], "][") + "]"
+			filter, ferr := parseFilterParam(param, raw)
+			if ferr != nil {
+				*filterErrors = append(*filterErrors, ferr)
+				continue
+			}
+			node.filters[i] = filter
+		default:
+			return nil, fmt.Errorf("%w: malformed parameter %s", ErrInvalidConditions, key)
+		}
+	}
+
+	return root.children(), nil
+}
+
+// children returns the node's groups with their filters, by index.
+func (n *groupNode) children() []FilterGroup {
+
+	if len(n.groups) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, 0, len(n.groups))
+	for i := range n.groups {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	groups := make([]FilterGroup, 0, len(indexes))
+	for _, i := range indexes {
+		child := n.groups[i]
+		group := child.group
+
+		filterIndexes := make([]int, 0, len(child.filters))
+		for j := range child.filters {
+			filterIndexes = append(filterIndexes, j)
+		}
+		sort.Ints(filterIndexes)
+
+		for _, j := range filterIndexes {
+			group.Filters = append(group.Filters, child.filters[j])
+		}
+		group.Groups = child.children()
+
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// bracketSegments splits "[a][b]" into a and b.
+func bracketSegments(s string) ([]string, bool) {
+
+	var segments []string
+	for s != "" {
+		if s[0] != '[' {
+			return nil, false
+		}
+
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil, false
+		}
+
+		segments = append(segments, s[1:end])
+		s = s[end+1:]
+	}
+
+	return segments, true
+}
+
+func firstValue(raw []string) string {
+
+	if len(raw) == 0 {
+		return ""
+	}
+
+	return raw[0]
+}
+
+func intValue(values url.Values, key string) (int, error) {
+
+	raw := values.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", ErrInvalidConditions, key)
+	}
+
+	return n, nil
+}
+
+// listValue splits comma separated values, also accepting repeated keys.
+func listValue(raw []string) []string {
+
+	if len(raw) == 0 {
+		return nil
+	}
+
+	items := make([]string, 0, len(raw))
+	for _, v := range raw {
+		for _, item := range splitList(v) {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// splitList splits on commas not escaped by a backslash. \, and \\ stand
+// for a comma and a backslash; other backslashes are kept as written.
+func splitList(v string) []string {
+
+	if !strings.Contains(v, `\`) {
+		return strings.Split(v, ",")
+	}
+
+	var items []string
+	var item strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v) && (v[i+1] == ',' || v[i+1] == '\\'):
+			i++
+			item.WriteByte(v[i])
+		case v[i] == ',':
+			items = append(items, item.String())
+			item.Reset()
+		default:
+			item.WriteByte(v[i])
+		}
+	}
+
+	return append(items, item.String())
+}
+
+func parseFilterParam(key string, raw []string) (FilterCondition, *FilterError) {
+
+	filter := FilterCondition{}
+
+	// filter[field] or filter[field][op]
+	rest := strings.TrimPrefix(key, "filter[")
+	end := strings.IndexByte(rest, ']')
+	if end <= 0 {
+		return filter, &FilterError{Field: key, Err: fmt.Errorf("malformed filter parameter")}
+	}
+
+	filter.Field = rest[:end]
+	rest = rest[end+1:]
+
+	token := "eq"
+	if rest != "" {
+		if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+			return filter, &FilterError{Field: filter.Field, Err: fmt.Errorf("malformed filter parameter")}
+		}
+		token = rest[1 : len(rest)-1]
+	}
+
+	operator, ok := CanonicalOperator(token)
+	if !ok {
+		return filter, &FilterError{Field: filter.Field, Operator: token, Err: fmt.Errorf("unknown operator")}
+	}
+	filter.Operator = operator
+
+	// UpdateConditions wraps the value of contains
+	if isContainsOperator(token) {
+		filter.Operator = token
+	}
+
+	switch operator {
+	case "IN", "NOT IN", "BETWEEN", "REL_ANY", "REL_ALL":
+		items := listValue(raw)
+		vals := make([]interface{}, len(items))
+		for i, item := range items {
+			vals[i] = item
+		}
+
+		if operator == "BETWEEN" && len(vals) != 2 {
+			return filter, &FilterError{Field: filter.Field, Operator: operator, Err: fmt.Errorf("expected two values")}
+		}
+
+		filter.Value = vals
+	case "IS NULL", "IS NOT NULL", "EMPTY", "NOT EMPTY":
+	default:
+		if len(raw) != 1 {
+			return filter, &FilterError{Field: filter.Field, Operator: operator, Err: fmt.Errorf("expected a single value")}
+		}
+
+		filter.Value = raw[0]
+	}
+
+	return filter, nil
+}
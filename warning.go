@@ -0,0 +1,72 @@
+package queryhelper
+
+// Warning codes. They are stable, so clients may switch on them.
+const (
+	WarningPageSizeClamped       = "page_size_clamped"
+	WarningSortFactorClamped     = "sort_factor_clamped"
+	WarningCountModeNotAllowed   = "count_mode_not_allowed"
+	WarningCountModeUnavailable  = "count_mode_unavailable" // the database cannot estimate, counted exactly
+	WarningSearchFieldNotAllowed = "search_field_not_allowed"
+	WarningOrderByNotAllowed     = "order_by_not_allowed"
+	WarningSearchNotAvailable    = "search_not_available"   // search text ignored, no field left to search
+	WarningOrderByNotAvailable   = "order_by_not_available" // no requested column left to sort by, default order used
+	WarningFieldNotAllowed       = "field_not_allowed"
+	WarningFilterNotAllowed      = "filter_not_allowed"
+	WarningOperatorNotAllowed    = "operator_not_allowed"
+	WarningDuplicateFilter       = "duplicate_filter"
+	WarningConflictingFilter     = "conflicting_filter" // resolved per QuerySettings.ConflictPolicy
+	WarningIncludeNotAllowed     = "include_not_allowed"
+	WarningIncludeNotRequested   = "include_not_requested" // filter on a relation the request does not include
+	WarningSummaryNotDefined     = "summary_not_defined"
+	WarningHistogramNotAllowed   = "histogram_not_allowed"
+	WarningIntervalNotAllowed    = "interval_not_allowed"
+	WarningUnknownTimeZone       = "unknown_time_zone"
+)
+
+// Warning tells a client that part of its request was adjusted or ignored
+// rather than rejected. Message is for people, Params carries the operator
+// and value involved for clients building their own messages.
+type Warning struct {
+	Code    string                 `json:"code"`
+	Field   string                 `json:"field,omitempty"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// Warnings returns a warning for every adjustment made to the request by
+// pagination and UpdateConditions, in the order they were made.
+func (dq *QueryHelper) Warnings() []Warning {
+
+	var dropped []DroppedItem
+	if dq.conditions != nil {
+		dropped = append(dropped, dq.conditions.Dropped...)
+	}
+	dropped = append(dropped, dq.pagination.droppedItems()...)
+
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	warnings := make([]Warning, len(dropped))
+	for i, item := range dropped {
+		w := Warning{
+			Code:    item.Code,
+			Field:   item.Field,
+			Message: item.Reason,
+		}
+
+		if item.Operator != "" || item.Value != nil {
+			w.Params = make(map[string]interface{}, 2)
+			if item.Operator != "" {
+				w.Params["operator"] = item.Operator
+			}
+			if item.Value != nil {
+				w.Params["value"] = item.Value
+			}
+		}
+
+		warnings[i] = w
+	}
+
+	return warnings
+}
@@ -0,0 +1,98 @@
+package queryhelper_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+// TestWarnings checks adjustments from pagination and UpdateConditions in one
+// request are all reported, with codes clients can switch on.
+func TestWarnings(t *testing.T) {
+
+	db := customersDB(t)
+
+	settings := &queryhelper.QuerySettings{
+		AllowedFilters: map[string][]string{"company_id": {"="}},
+		AllowedSearch:  []string{"name"},
+		AllowedOrderBy: []string{"id"},
+	}
+
+	dq := queryhelper.NewQueryHelper(
+		queryhelper.WithPageSize(5000),
+		queryhelper.WithSortFactor(3),
+		queryhelper.WithSearchText("customer"),
+		queryhelper.WithSearchFields([]string{"name", "secret"}),
+		queryhelper.WithFilter("company_id", ">", 1),
+		queryhelper.WithEqual("password", "x"),
+	)
+
+	var customers []testCustomer
+	if err := dq.Execute(settings, db.Model(&testCustomer{}), &customers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(customers) != 12 {
+		t.Errorf("got %d rows, want 12", len(customers))
+	}
+
+	info := dq.Info()
+	got := make(map[string]queryhelper.Warning)
+	for _, w := range info.Warnings {
+		got[w.Code] = w
+	}
+
+	want := map[string]queryhelper.Warning{
+		"page_size_clamped":        {Code: "page_size_clamped"},
+		"sort_factor_clamped":      {Code: "sort_factor_clamped"},
+		"search_field_not_allowed": {Code: "search_field_not_allowed", Field: "secret"},
+		"operator_not_allowed":     {Code: "operator_not_allowed", Field: "company_id"},
+		"filter_not_allowed":       {Code: "filter_not_allowed", Field: "password"},
+	}
+	if len(info.Warnings) != len(want) {
+		t.Errorf("warnings %+v, want %d", info.Warnings, len(want))
+	}
+	for code, w := range want {
+		g, ok := got[code]
+		if !ok {
+			t.Errorf("no %s warning", code)
+			continue
+		}
+		if g.Field != w.Field || g.Message == "" {
+			t.Errorf("%s: field %q, message %q, want field %q and a message", code, g.Field, g.Message, w.Field)
+		}
+	}
+
+	if params := got["operator_not_allowed"].Params; !reflect.DeepEqual(params, map[string]interface{}{"operator": ">", "value": 1}) {
+		t.Errorf("operator_not_allowed params %v", params)
+	}
+	if value := got["page_size_clamped"].Params["value"]; value != 5000 {
+		t.Errorf("page_size_clamped value %v, want 5000", value)
+	}
+
+	// The warnings reach the JSON of the info
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Warnings []struct {
+			Code  string `json:"code"`
+			Field string `json:"field"`
+		}
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Warnings) != len(want) {
+		t.Errorf("JSON %s, want %d warnings", data, len(want))
+	}
+	for _, w := range decoded.Warnings {
+		if _, ok := want[w.Code]; !ok {
+			t.Errorf("JSON warning %+v not expected", w)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package queryhelper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+var whereInputOperators = map[string]string{
+	"eq":   "=",
+	"neq":  "!=",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"in":   "IN",
+	"nin":  "NOT IN",
+	"like": "LIKE",
+}
+
+// WhereInputError reports a problem at a path inside a where input, such as
+// "and[1].or[0].country.eqq".
+type WhereInputError struct {
+	Path    string
+	Message string
+}
+
+func (e *WhereInputError) Error() string {
+	return fmt.Sprintf("where input %s: %s", e.Path, e.Message)
+}
+
+// FromWhereInput converts a GraphQL-style where input into query conditions:
+//
+//	{status: {eq: "active"}, and: [{age: {gte: 18}}, {or: [{country: {eq: "SE"}}]}]}
+//
+// Keys of one object are ANDed. "and" and "or" take lists of objects and
+// "not" takes a single object. Fields and operators are checked against the
+// settings.
+func FromWhereInput(where map[string]interface{}, settings *QuerySettings) (*QueryConditions, error) {
+
+	if settings == nil {
+		settings = DefaultQuerySettings
+	}
+
+	conditions := &QueryConditions{}
+
+	if len(where) == 0 {
+		return conditions, nil
+	}
+
+	root, err := whereObject(where, "where", settings)
+	if err != nil {
+		return nil, err
+	}
+
+	root.apply(conditions)
+
+	return conditions, nil
+}
+
+func whereObject(obj map[string]interface{}, path string, settings *QuerySettings) (*filterNode, error) {
+
+	if len(obj) == 0 {
+		return nil, &WhereInputError{Path: path, Message: "empty object"}
+	}
+
+	// Walk keys in a stable order so errors and output are deterministic
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	n := &filterNode{logic: LogicAnd}
+	for _, key := range keys {
+		value := obj[key]
+		keyPath := path + "." + key
+
+		switch key {
+		case "and", "or":
+			items, ok := toInterfaceSlice(value)
+			if !ok || len(items) == 0 {
+				return nil, &WhereInputError{Path: keyPath, Message: "expected a non-empty list of objects"}
+			}
+
+			logic := LogicAnd
+			if key == "or" {
+				logic = LogicOr
+			}
+
+			list := &filterNode{logic: logic}
+			for i, item := range items {
+				itemPath := keyPath + "[" + strconv.Itoa(i) + "]"
+
+				itemObj, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, &WhereInputError{Path: itemPath, Message: "expected an object"}
+				}
+
+				child, err := whereObject(itemObj, itemPath, settings)
+				if err != nil {
+					return nil, err
+				}
+				list.add(child)
+			}
+
+			if len(list.children) == 1 {
+				n.add(list.children[0])
+			} else {
+				n.add(list)
+			}
+		case "not":
+			notObj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, &WhereInputError{Path: keyPath, Message: "expected an object"}
+			}
+
+			child, err := whereObject(notObj, keyPath, settings)
+			if err != nil {
+				return nil, err
+			}
+
+			if child.filter != nil {
+				child = &filterNode{logic: LogicAnd, children: []*filterNode{child}}
+			}
+			child.not = !child.not
+			n.add(child)
+		default:
+			ops, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, &WhereInputError{Path: keyPath, Message: "expected an operator object"}
+			}
+
+			filters, err := whereField(key, ops, keyPath, settings)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, f := range filters {
+				n.add(f)
+			}
+		}
+	}
+
+	if len(n.children) == 1 {
+		return n.children[0], nil
+	}
+
+	return n, nil
+}
+
+func whereField(field string, ops map[string]interface{}, path string, settings *QuerySettings) ([]*filterNode, error) {
+
+	allowedOps, fieldAllowed := settings.AllowedFilters[field]
+	if !fieldAllowed {
+		return nil, &WhereInputError{Path: path, Message: "field is not filterable"}
+	}
+
+	if len(ops) == 0 {
+		return nil, &WhereInputError{Path: path, Message: "empty operator object"}
+	}
+
+	keys := make([]string, 0, len(ops))
+	for key := range ops {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nodes := make([]*filterNode, 0, len(keys))
+	for _, key := range keys {
+		value := ops[key]
+		opPath := path + "." + key
+
+		var operator string
+		switch key {
+		case "isNull":
+			isNull, ok := value.(bool)
+			if !ok {
+				return nil, &WhereInputError{Path: opPath, Message: "expected a boolean"}
+			}
+
+			operator = "IS NOT NULL"
+			if isNull {
+				operator = "IS NULL"
+			}
+			value = nil
+		default:
+			op, ok := whereInputOperators[key]
+			if !ok {
+				return nil, &WhereInputError{Path: opPath, Message: "unknown operator"}
+			}
+			operator = op
+
+			switch {
+			case operator == "IN" || operator == "NOT IN":
+				items, ok := toInterfaceSlice(value)
+				if !ok {
+					return nil, &WhereInputError{Path: opPath, Message: "expected a list"}
+				}
+				value = items
+			case value == nil && operator == "=":
+				operator = "IS NULL"
+			case value == nil && operator == "!=":
+				operator = "IS NOT NULL"
+			case value == nil:
+				return nil, &WhereInputError{Path: opPath, Message: "null is not allowed"}
+			}
+		}
+
+		if !containsString(allowedOps, operator) {
+			return nil, &WhereInputError{Path: opPath, Message: fmt.Sprintf("operator %s is not allowed", operator)}
+		}
+
+		nodes = append(nodes, &filterNode{
+			filter: &FilterCondition{
+				Field:    field,
+				Operator: operator,
+				Value:    value,
+			},
+		})
+	}
+
+	return nodes, nil
+}
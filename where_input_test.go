@@ -0,0 +1,133 @@
+package queryhelper_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/weedbox/queryhelper"
+)
+
+var whereSettings = &queryhelper.QuerySettings{
+	AllowedFilters: map[string][]string{
+		"status":  {"=", "!=", "IN", "NOT IN", "IS NULL", "IS NOT NULL"},
+		"age":     {">", ">=", "<", "<="},
+		"country": {"=", "IN"},
+		"name":    {"LIKE"},
+	},
+}
+
+// whereInput decodes a where input written as JSON, as a GraphQL layer
+// would pass it.
+func whereInput(t *testing.T, input string) map[string]interface{} {
+
+	t.Helper()
+
+	var where map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &where); err != nil {
+		t.Fatal(err)
+	}
+
+	return where
+}
+
+func TestFromWhereInput(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: `{}`, want: `{}`},
+		{name: "one field", input: `{"status": {"eq": "active"}}`, want: `{"filters":[{"field":"status","operator":"=","value":"active"}]}`},
+		{name: "operators of one field", input: `{"age": {"gte": 18, "lt": 65}}`, want: `{"filters":[{"field":"age","operator":">=","value":18},{"field":"age","operator":"<","value":65}]}`},
+		{name: "fields anded", input: `{"status": {"neq": "banned"}, "name": {"like": "%ann%"}}`, want: `{"filters":[{"field":"name","operator":"LIKE","value":"%ann%"},{"field":"status","operator":"!=","value":"banned"}]}`},
+		{name: "lists", input: `{"status": {"in": ["a", "b"], "nin": []}}`, want: `{"filters":[{"field":"status","operator":"IN","value":["a","b"]},{"field":"status","operator":"NOT IN","value":[]}]}`},
+		{name: "null equality", input: `{"status": {"eq": null}}`, want: `{"filters":[{"field":"status","operator":"IS NULL"}]}`},
+		{name: "null inequality", input: `{"status": {"neq": null}}`, want: `{"filters":[{"field":"status","operator":"IS NOT NULL"}]}`},
+		{name: "is null", input: `{"status": {"isNull": true}}`, want: `{"filters":[{"field":"status","operator":"IS NULL"}]}`},
+		{name: "is not null", input: `{"status": {"isNull": false}}`, want: `{"filters":[{"field":"status","operator":"IS NOT NULL"}]}`},
+		{
+			name:  "nested",
+			input: `{"status": {"eq": "active"}, "and": [{"age": {"gte": 18}}, {"or": [{"country": {"eq": "SE"}}]}]}`,
+			want:  `{"filters":[{"field":"age","operator":">=","value":18},{"field":"country","operator":"=","value":"SE"},{"field":"status","operator":"=","value":"active"}]}`,
+		},
+		{
+			name:  "or of ands",
+			input: `{"or": [{"status": {"eq": "a"}, "age": {"gt": 1}}, {"country": {"in": ["SE", "NO"]}}]}`,
+			want: `{"filter_groups":[{"logic":"OR","filters":[{"field":"country","operator":"IN","value":["SE","NO"]}],` +
+				`"groups":[{"logic":"AND","filters":[{"field":"age","operator":">","value":1},{"field":"status","operator":"=","value":"a"}]}]}]}`,
+		},
+		{name: "not", input: `{"not": {"status": {"eq": "banned"}}}`, want: `{"filter_groups":[{"logic":"AND","not":true,"filters":[{"field":"status","operator":"=","value":"banned"}]}]}`},
+		{name: "not of or", input: `{"not": {"or": [{"status": {"eq": "a"}}, {"status": {"eq": "b"}}]}}`, want: `{"filter_groups":[{"logic":"OR","not":true,"filters":[{"field":"status","operator":"=","value":"a"},{"field":"status","operator":"=","value":"b"}]}]}`},
+		{name: "double not", input: `{"not": {"not": {"status": {"eq": "a"}}}}`, want: `{"filters":[{"field":"status","operator":"=","value":"a"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := queryhelper.FromWhereInput(whereInput(t, tt.input), whereSettings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want queryhelper.QueryConditions
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("got  %s\nwant %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestFromWhereInputErrors(t *testing.T) {
+
+	tests := []struct {
+		input   string
+		path    string
+		message string
+	}{
+		{input: `{"password": {"eq": "x"}}`, path: "where.password", message: "field is not filterable"},
+		{input: `{"status": {"eqq": "x"}}`, path: "where.status.eqq", message: "unknown operator"},
+		{input: `{"age": {"eq": 18}}`, path: "where.age.eq", message: "operator = is not allowed"},
+		{input: `{"age": {"gt": null}}`, path: "where.age.gt", message: "null is not allowed"},
+		{input: `{"status": {"in": "a"}}`, path: "where.status.in", message: "expected a list"},
+		{input: `{"status": {"isNull": "yes"}}`, path: "where.status.isNull", message: "expected a boolean"},
+		{input: `{"status": "active"}`, path: "where.status", message: "expected an operator object"},
+		{input: `{"status": {}}`, path: "where.status", message: "empty operator object"},
+		{input: `{"and": []}`, path: "where.and", message: "expected a non-empty list of objects"},
+		{input: `{"or": {"status": {"eq": "a"}}}`, path: "where.or", message: "expected a non-empty list of objects"},
+		{input: `{"and": [{"status": {"eq": "a"}}, "x"]}`, path: "where.and[1]", message: "expected an object"},
+		{input: `{"and": [{}]}`, path: "where.and[0]", message: "empty object"},
+		{input: `{"not": [{"status": {"eq": "a"}}]}`, path: "where.not", message: "expected an object"},
+		{
+			input:   `{"and": [{"age": {"gte": 18}}, {"or": [{"country": {"eqq": "SE"}}]}]}`,
+			path:    "where.and[1].or[0].country.eqq",
+			message: "unknown operator",
+		},
+		{
+			input:   `{"not": {"or": [{"status": {"eq": "a"}}, {"ssn": {"eq": "b"}}]}}`,
+			path:    "where.not.or[1].ssn",
+			message: "field is not filterable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+
+			_, err := queryhelper.FromWhereInput(whereInput(t, tt.input), whereSettings)
+
+			var werr *queryhelper.WhereInputError
+			if !errors.As(err, &werr) {
+				t.Fatalf("err = %v, want a WhereInputError", err)
+			}
+			if werr.Path != tt.path || werr.Message != tt.message {
+				t.Errorf("got %s: %s, want %s: %s", werr.Path, werr.Message, tt.path, tt.message)
+			}
+		})
+	}
+}